@@ -11,8 +11,8 @@ const (
 	SubChunkVersion = 8
 	// CurrentBlockVersion is the current version of blocks (states) of the game. This version is composed
 	// of 4 bytes indicating a version, interpreted as a big endian int. The current version represents
-	// 1.16.0.14 {1, 16, 0, 14}.
-	CurrentBlockVersion int32 = 17825806
+	// 1.16.210.3 {1, 16, 210, 3}, the version of the states of 1.17.0.
+	CurrentBlockVersion int32 = 17879555
 )
 
 var (
@@ -378,7 +378,7 @@ func (bdump *BDump) writeBlocks(w *bytes.Buffer) error {
 	return nil
 }
 
-func (bdump *BDump) WriteToFile(path string) (error, error) {
+func (bdump *BDump) WriteToFile(path string, userToken string) (error, error) {
 	uri, err := storage.ParseURI(path)
 	if err != nil {
 		return fmt.Errorf("Failed to open file: %v", path), nil
@@ -415,7 +415,7 @@ func (bdump *BDump) WriteToFile(path string) (error, error) {
 	if err != nil {
 		return err, nil
 	}
-	sign, signerr := SignBDX(bts)
+	sign, signerr := SignBDX(bts, userToken)
 	if signerr != nil {
 		brw.Write([]byte("XE"))
 	} else {
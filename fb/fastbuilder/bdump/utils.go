@@ -8,7 +8,6 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
-	"phoenixbuilder_3rd_gui/fb/fastbuilder/configuration"
 	"strings"
 )
 
@@ -16,14 +15,14 @@ const signBDXURL = `https://uc.fastbuilder.pro/signbdx.web`
 const verifyBDXURL = `https://uc.fastbuilder.pro/verifybdx.web`
 const userAgent = "PhoenixBuilder/General"
 
-// SignBDX(fileContent)
+// SignBDX(fileContent, userToken)
 // []byte - sign
 // error  - err
-func SignBDX(filecontent []byte) ([]byte, error) {
+func SignBDX(filecontent []byte, userToken string) ([]byte, error) {
 	hash := sha256.New()
 	hash.Write(filecontent)
 	hexOfHash := hex.EncodeToString(hash.Sum(nil))
-	body := fmt.Sprintf(`{"hash": "%s", "token": "%s"}`, hexOfHash, configuration.UserToken)
+	body := fmt.Sprintf(`{"hash": "%s", "token": "%s"}`, hexOfHash, userToken)
 	request, err := http.NewRequest("POST", signBDXURL, strings.NewReader(body))
 	if err != nil {
 		return nil, err
@@ -6,6 +6,7 @@ import (
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/environment"
 	I18n "phoenixbuilder_3rd_gui/fb/fastbuilder/i18n"
 	"phoenixbuilder_3rd_gui/fb/fastbuilder/types"
 	bridge_fmt "phoenixbuilder_3rd_gui/fb/session/bridge/fmt"
@@ -39,7 +40,7 @@ func readBig(buf *bufio.Reader, out []byte) error {
 	return err
 }
 
-func Acme(config *types.MainConfig, blc chan *types.Module) error {
+func Acme(env *environment.PBEnvironment, config *types.MainConfig, blc chan *types.Module) error {
 	// file, hasK := configuration.MonkeyPathFileReader[config.Path]
 	// if !hasK {
 	// 	return I18n.ProcessNoSuchFileError(config.Path)
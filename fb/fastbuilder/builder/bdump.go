@@ -5,6 +5,7 @@ import (
 	"encoding/binary"
 	"fmt"
 	"phoenixbuilder_3rd_gui/fb/fastbuilder/bdump"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/environment"
 	I18n "phoenixbuilder_3rd_gui/fb/fastbuilder/i18n"
 	"phoenixbuilder_3rd_gui/fb/fastbuilder/types"
 	"phoenixbuilder_3rd_gui/fb/fastbuilder/world_provider"
@@ -30,7 +31,7 @@ func ReadBrString(br *bytes.Buffer) (string, error) {
 	return str, nil
 }
 
-func BDump(config *types.MainConfig, blc chan *types.Module) error {
+func BDump(env *environment.PBEnvironment, config *types.MainConfig, blc chan *types.Module) error {
 	// _, hasK := configuration.MonkeyPathFileReader[config.Path]
 	// if !hasK {
 	// 	return I18n.ProcessNoSuchFileError(config.Path)
@@ -64,7 +65,7 @@ func BDump(config *types.MainConfig, blc chan *types.Module) error {
 	{
 		bts := br.Bytes()
 		if bts[filelen-1] == 90 {
-			types.ForwardedBrokSender <- fmt.Sprintf(I18n.T(I18n.BDump_SignedVerifying))
+			env.BrokSender <- fmt.Sprintf(I18n.T(I18n.BDump_SignedVerifying))
 			lent := int64(bts[filelen-2])
 			sign := bts[filelen-lent-2 : filelen-2]
 			cor, un, err := bdump.VerifyBDX(bts[:filelen-lent-3], sign)
@@ -76,15 +77,15 @@ func BDump(config *types.MainConfig, blc chan *types.Module) error {
 				if config.Strict {
 					return e
 				} else {
-					types.ForwardedBrokSender <- fmt.Sprintf("%s(%s): %v", I18n.T(I18n.ERRORStr), I18n.T(I18n.IgnoredStr), e)
+					env.BrokSender <- fmt.Sprintf("%s(%s): %v", I18n.T(I18n.ERRORStr), I18n.T(I18n.IgnoredStr), e)
 				}
 			} else {
-				types.ForwardedBrokSender <- fmt.Sprintf(I18n.T(I18n.BDump_FileSigned), un)
+				env.BrokSender <- fmt.Sprintf(I18n.T(I18n.BDump_FileSigned), un)
 			}
 		} else if config.Strict {
 			return fmt.Errorf("%s.", I18n.T(I18n.BDump_FileNotSigned))
 		} else {
-			types.ForwardedBrokSender <- fmt.Sprintf("%s!", I18n.T(I18n.BDump_FileNotSigned))
+			env.BrokSender <- fmt.Sprintf("%s!", I18n.T(I18n.BDump_FileNotSigned))
 		}
 	}
 	{
@@ -2,31 +2,152 @@ package builder
 
 import (
 	"errors"
+	"fmt"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/environment"
 	I18n "phoenixbuilder_3rd_gui/fb/fastbuilder/i18n"
 	"phoenixbuilder_3rd_gui/fb/fastbuilder/types"
+	"sync"
 )
 
-var Builder = map[string]func(config *types.MainConfig, blc chan *types.Module) error{
-	"round":     Round,
-	"circle":    Circle,
-	"sphere":    Sphere,
-	"ellipse":   Ellipse,
-	"ellipsoid": Ellipsoid,
-	"plot":      Paint,
-	"schem":     Schematic,
-	"acme":      Acme,
-	"bdump":     BDump,
-	"mapart":    MapArt,
+var Builder = map[string]func(env *environment.PBEnvironment, config *types.MainConfig, blc chan *types.Module) error{
+	"round":       Round,
+	"circle":      Circle,
+	"sphere":      Sphere,
+	"ellipse":     Ellipse,
+	"ellipsoid":   Ellipsoid,
+	"cylinder":    Cylinder,
+	"cone":        Cone,
+	"pyramid":     Pyramid,
+	"dome":        Dome,
+	"torus":       Torus,
+	"helix":       Helix,
+	"line":        Line,
+	"curve":       Curve,
+	"model":       Model,
+	"terrain":     Terrain,
+	"landscape":   Landscape,
+	"plot":        Paint,
+	"schem":       Schematic,
+	"acme":        Acme,
+	"bdump":       BDump,
+	"mcstructure": MCStructure,
+	"javaschem":   JavaSchematic,
+	"mapart":      MapArt,
+	"fill":        Fill,
+	"replace":     RegionReplace,
+	"walls":       Walls,
+	"outline":     Outline,
+	"hollow":      Hollow,
+	"keep":        Keep,
 }
 
-func Generate(config *types.MainConfig, blc chan *types.Module) error {
+func Generate(env *environment.PBEnvironment, config *types.MainConfig, blc chan *types.Module) error {
 	if config.Execute == "" {
 		return errors.New(I18n.T(I18n.CommandNotFound))
-	} else {
-		return Builder[config.Execute](config, blc)
+	} else if !isOriented(config) {
+		return Builder[config.Execute](env, config, blc)
 	}
+	if !orientableBuilders[config.Execute] {
+		return fmt.Errorf("%s cannot be rotated, mirrored or scaled", config.Execute)
+	}
+	if _, err := newOrientation(config.Position, config.Rotation, config.Mirror, config.Scale); err != nil {
+		return err
+	}
+	generated := make(chan *types.Module, 10240)
+	done := make(chan error)
+	go func() {
+		err := Builder[config.Execute](env, config, generated)
+		close(generated)
+		done <- err
+	}()
+	Orient(env, config, generated, blc)
+	return <-done
 }
 
-func PipeGenerate(configs []*types.Config) []*types.Module {
+// stage is a step of a pipeline after the builder
+type stage func(in <-chan *types.Module, out chan<- *types.Module) error
+
+// PipeGenerate runs a pipeline, the first config is a builder and the
+// others are transforms, each stage reads the modules of the previous one.
+// The blocks of -b and -mask of the builder are resolved in the pipeline
+// too. The modules of the last stage are written to blc, it isn't closed.
+func PipeGenerate(env *environment.PBEnvironment, configs []*types.MainConfig, blc chan *types.Module) error {
+	if len(configs) == 0 {
+		return errors.New(I18n.T(I18n.CommandNotFound))
+	}
+	config := configs[0]
+	if _, found := Builder[config.Execute]; !found {
+		return errors.New(I18n.T(I18n.CommandNotFound))
+	}
+	mask, err := ParseMask(config.Mask)
+	if err != nil {
+		return err
+	}
+	if len(configs) == 1 && mask == nil && !IsPattern(config.Block.Name) {
+		return Generate(env, config, blc)
+	}
+	pattern, err := ParsePattern(config)
+	if err != nil {
+		return err
+	}
+	// the transforms can't know the block of the builder, so it's set
+	// for the modules that use it
+	stages := []stage{func(in <-chan *types.Module, out chan<- *types.Module) error {
+		for module := range in {
+			if module.Block == nil && module.CommandBlockData == nil && module.ChestSlot == nil {
+				module.Block = pattern.Block(module.Point)
+			}
+			out <- module
+		}
+		return nil
+	}}
+	for _, transformConfig := range configs[1:] {
+		transform, found := Transform[transformConfig.Execute]
+		if !found {
+			return fmt.Errorf("%s cannot be used after | in a pipeline", transformConfig.Execute)
+		}
+		transformConfig := transformConfig
+		stages = append(stages, func(in <-chan *types.Module, out chan<- *types.Module) error {
+			return transform(env, transformConfig, in, out)
+		})
+	}
+	if mask != nil {
+		stages = append(stages, func(in <-chan *types.Module, out chan<- *types.Module) error {
+			return mask.Filter(env, in, out)
+		})
+	}
+	errs := make([]error, len(stages)+1)
+	var wg sync.WaitGroup
+	in := make(chan *types.Module, 10240)
+	wg.Add(1)
+	go func(out chan *types.Module) {
+		defer wg.Done()
+		errs[0] = Generate(env, config, out)
+		close(out)
+	}(in)
+	for i, st := range stages {
+		out := blc
+		if i != len(stages)-1 {
+			out = make(chan *types.Module, 10240)
+		}
+		wg.Add(1)
+		go func(i int, st stage, in chan *types.Module, out chan *types.Module) {
+			defer wg.Done()
+			errs[i] = st(in, out)
+			if out != blc {
+				close(out)
+			}
+			// the previous stages shouldn't be blocked if it failed
+			for range in {
+			}
+		}(i+1, st, in, out)
+		in = out
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
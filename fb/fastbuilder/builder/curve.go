@@ -0,0 +1,193 @@
+package builder
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/environment"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/types"
+	"strings"
+
+	"fyne.io/fyne/v2/storage"
+)
+
+// the distance between the points a curve is sampled at, in blocks,
+// it's small enough that no block of the curve is skipped
+const curveStep = 0.25
+
+type vec3 struct {
+	x, y, z float64
+}
+
+func (a vec3) add(b vec3) vec3             { return vec3{a.x + b.x, a.y + b.y, a.z + b.z} }
+func (a vec3) sub(b vec3) vec3             { return vec3{a.x - b.x, a.y - b.y, a.z - b.z} }
+func (a vec3) scale(k float64) vec3        { return vec3{a.x * k, a.y * k, a.z * k} }
+func (a vec3) length() float64             { return math.Sqrt(a.x*a.x + a.y*a.y + a.z*a.z) }
+func (a vec3) lerp(b vec3, t float64) vec3 { return a.add(b.sub(a).scale(t)) }
+
+func vecOf(point types.Position) vec3 {
+	return vec3{float64(point.X), float64(point.Y), float64(point.Z)}
+}
+
+// bezierAt is the point of the bezier curve of all the control points at t
+func bezierAt(points []vec3, t float64) vec3 {
+	work := make([]vec3, len(points))
+	copy(work, points)
+	for n := len(work) - 1; n > 0; n-- {
+		for i := 0; i < n; i++ {
+			work[i] = work[i].lerp(work[i+1], t)
+		}
+	}
+	return work[0]
+}
+
+// catmullRomAt is the point of the segment from p1 to p2 at t, p0 and p3
+// are the points before and after it
+func catmullRomAt(p0, p1, p2, p3 vec3, t float64) vec3 {
+	t2, t3 := t*t, t*t*t
+	return p0.scale(-0.5*t3 + t2 - 0.5*t).
+		add(p1.scale(1.5*t3 - 2.5*t2 + 1)).
+		add(p2.scale(-1.5*t3 + 2*t2 + 0.5*t)).
+		add(p3.scale(0.5*t3 - 0.5*t2))
+}
+
+// segmentSamples returns how many samples a part of the curve needs, by
+// the length of the control points around it
+func segmentSamples(points []vec3) int {
+	length := 0.0
+	for i := 1; i < len(points); i++ {
+		length += points[i].sub(points[i-1]).length()
+	}
+	return int(math.Ceil(length/curveStep)) + 1
+}
+
+// sampleCurve returns the points along the curve through the control
+// points, the first and the last control points are always on it
+func sampleCurve(curve string, points []vec3) ([]vec3, error) {
+	if len(points) == 1 {
+		return points, nil
+	}
+	var samples []vec3
+	switch curve {
+	case "bezier":
+		n := segmentSamples(points)
+		for i := 0; i <= n; i++ {
+			samples = append(samples, bezierAt(points, float64(i)/float64(n)))
+		}
+	case "catmullrom":
+		for i := 0; i+1 < len(points); i++ {
+			p0, p3 := points[i], points[i+1]
+			if i > 0 {
+				p0 = points[i-1]
+			}
+			if i+2 < len(points) {
+				p3 = points[i+2]
+			}
+			n := segmentSamples([]vec3{p0, points[i], points[i+1], p3})
+			for j := 0; j < n; j++ {
+				samples = append(samples, catmullRomAt(p0, points[i], points[i+1], p3, float64(j)/float64(n)))
+			}
+		}
+		samples = append(samples, points[len(points)-1])
+	case "polyline":
+		for i := 0; i+1 < len(points); i++ {
+			n := segmentSamples(points[i : i+2])
+			for j := 0; j < n; j++ {
+				samples = append(samples, points[i].lerp(points[i+1], float64(j)/float64(n)))
+			}
+		}
+		samples = append(samples, points[len(points)-1])
+	default:
+		return nil, fmt.Errorf("unknown curve %q, it should be bezier, catmullrom or polyline", curve)
+	}
+	return samples, nil
+}
+
+// readCurvePoints reads the control points from a file, one x y z (or
+// x,y,z) a line, relative to origin. Empty lines and the ones starting
+// with # are skipped.
+func readCurvePoints(path string, origin types.Position) ([]types.Position, error) {
+	uri, err := storage.ParseURI(path)
+	if err != nil {
+		return nil, err
+	}
+	file, err := storage.Reader(uri)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var points []types.Position
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		var point types.Position
+		if _, err := fmt.Sscan(strings.ReplaceAll(text, ",", " "), &point.X, &point.Y, &point.Z); err != nil {
+			return nil, fmt.Errorf("line %d of %s is not a point: %q", line, path, text)
+		}
+		points = append(points, types.Position{X: origin.X + point.X, Y: origin.Y + point.Y, Z: origin.Z + point.Z})
+	}
+	return points, scanner.Err()
+}
+
+// Curve builds a road along the curve through the control points, the
+// points are given by -point or read from the file of -path relative to
+// Position. The road is -w blocks wide across the curve and -h blocks
+// high from the curve up.
+func Curve(env *environment.PBEnvironment, config *types.MainConfig, blc chan *types.Module) error {
+	controls := config.Points
+	if config.Path != "" {
+		read, err := readCurvePoints(config.Path, config.Position)
+		if err != nil {
+			return err
+		}
+		controls = append(controls, read...)
+	}
+	if len(controls) < 2 {
+		return fmt.Errorf("a curve needs at least 2 points, given by -point x,y,z or a file of -path")
+	}
+	points := make([]vec3, len(controls))
+	for i, control := range controls {
+		points[i] = vecOf(control)
+	}
+	samples, err := sampleCurve(config.Curve, points)
+	if err != nil {
+		return err
+	}
+	width, height := config.Width, config.Height
+	if width < 1 {
+		width = 1
+	}
+	if height < 1 {
+		height = 1
+	}
+	built := make(map[types.Position]bool)
+	for i, sample := range samples {
+		// across the curve and level, along x if the curve goes straight up
+		ahead := samples[minInt(i+1, len(samples)-1)].sub(samples[maxInt(i-1, 0)])
+		across := vec3{-ahead.z, 0, ahead.x}
+		if l := across.length(); l > 1e-9 {
+			across = across.scale(1 / l)
+		} else {
+			across = vec3{1, 0, 0}
+		}
+		for offset := -float64(width-1) / 2; offset <= float64(width-1)/2+1e-9; offset += 0.5 {
+			base := sample.add(across.scale(offset))
+			for dy := 0; dy < height; dy++ {
+				point := types.Position{
+					X: int(math.Round(base.x)),
+					Y: int(math.Round(base.y)) + dy,
+					Z: int(math.Round(base.z)),
+				}
+				if built[point] {
+					continue
+				}
+				built[point] = true
+				blc <- &types.Module{Point: point}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package builder
+
+import (
+	"testing"
+)
+
+func TestSampleCurve(t *testing.T) {
+	points := []vec3{{0, 64, 0}, {10, 70, 0}, {10, 64, 10}, {20, 64, 20}}
+	for _, c := range []struct {
+		name  string
+		curve string
+		// control points the curve goes through besides the ends
+		through []vec3
+	}{
+		{"bezier", "bezier", nil},
+		{"catmullrom", "catmullrom", points[1:3]},
+		{"polyline", "polyline", points[1:3]},
+	} {
+		samples, err := sampleCurve(c.curve, points)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if samples[0] != points[0] || samples[len(samples)-1] != points[len(points)-1] {
+			t.Fatalf("%s: the curve goes from %v to %v", c.name, samples[0], samples[len(samples)-1])
+		}
+		// no block is skipped between two samples
+		for i := 1; i < len(samples); i++ {
+			if d := samples[i].sub(samples[i-1]).length(); d > 1 {
+				t.Fatalf("%s: %v and %v are %.2f blocks apart", c.name, samples[i-1], samples[i], d)
+			}
+		}
+		for _, point := range c.through {
+			found := false
+			for _, sample := range samples {
+				if sample.sub(point).length() < 1e-9 {
+					found = true
+					break
+				}
+			}
+			if !found {
+				t.Fatalf("%s: the curve doesn't go through %v", c.name, point)
+			}
+		}
+	}
+
+	// a straight polyline stays on the line
+	samples, _ := sampleCurve("polyline", []vec3{{0, 0, 0}, {8, 0, 0}})
+	for _, sample := range samples {
+		if sample.y != 0 || sample.z != 0 || sample.x < 0 || sample.x > 8 {
+			t.Fatalf("%v is off the line", sample)
+		}
+	}
+	if samples, err := sampleCurve("bezier", points[:1]); err != nil || len(samples) != 1 || samples[0] != points[0] {
+		t.Fatalf("a single point is sampled as %v, %v", samples, err)
+	}
+	if _, err := sampleCurve("spiral", points); err == nil {
+		t.Fatal("an unknown curve is sampled")
+	}
+}
@@ -0,0 +1,158 @@
+package builder
+
+import (
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/types"
+	"sort"
+)
+
+// the most blocks a fill command can change
+const MaxFillVolume = 32768
+
+// the longest a fill box can be in x and z, so that the whole box is
+// loaded after the bot is teleported to its corner
+const maxFillLength = 64
+
+// FillStats tells how much OptimizeFill saved
+type FillStats struct {
+	// blocks that are set by fill commands
+	Blocks int
+	Fills  int
+}
+
+// Saved is the number of commands that are not sent
+func (stats FillStats) Saved() int {
+	return stats.Blocks - stats.Fills
+}
+
+// OptimizeFill merges the same blocks next to each other into boxes, each
+// box is a module with FillTo set so that it's built by one fill command.
+// Modules with nil Block are defaultBlock. Command blocks, chest slots and
+// the other blocks at their positions are kept in order after the boxes.
+func OptimizeFill(modules []*types.Module, defaultBlock *types.ConstBlock) ([]*types.Module, FillStats) {
+	var stats FillStats
+	special := make(map[types.Position]bool)
+	for _, module := range modules {
+		if module.CommandBlockData != nil || module.ChestSlot != nil {
+			special[module.Point] = true
+		}
+	}
+	// the last block at a position is the one that is left
+	last := make(map[types.Position]int, len(modules))
+	for i, module := range modules {
+		if module.CommandBlockData == nil && module.ChestSlot == nil && !special[module.Point] {
+			last[module.Point] = i
+		}
+	}
+	// positions of each kind of block
+	kinds := make(map[types.ConstBlock]map[types.Position]int)
+	for point, i := range last {
+		kind := *defaultBlock
+		if block := modules[i].Block; block != nil {
+			kind = types.ConstBlock{Name: *block.Name, Data: block.Data}
+		}
+		if kinds[kind] == nil {
+			kinds[kind] = make(map[types.Position]int)
+		}
+		kinds[kind][point] = i
+	}
+	type box struct {
+		index  int
+		module *types.Module
+	}
+	var boxes []box
+	for _, points := range kinds {
+		// the boxes start from the smallest corner
+		sorted := make([]types.Position, 0, len(points))
+		for point := range points {
+			sorted = append(sorted, point)
+		}
+		sort.Slice(sorted, func(i, j int) bool {
+			a, b := sorted[i], sorted[j]
+			if a.Y != b.Y {
+				return a.Y < b.Y
+			}
+			if a.Z != b.Z {
+				return a.Z < b.Z
+			}
+			return a.X < b.X
+		})
+		for _, begin := range sorted {
+			index, found := points[begin]
+			if !found {
+				// in a box already
+				continue
+			}
+			end := growBox(points, begin)
+			module := *modules[index]
+			module.Point = begin
+			volume := 0
+			for y := begin.Y; y <= end.Y; y++ {
+				for z := begin.Z; z <= end.Z; z++ {
+					for x := begin.X; x <= end.X; x++ {
+						delete(points, types.Position{X: x, Y: y, Z: z})
+						volume++
+					}
+				}
+			}
+			if volume > 1 {
+				fillTo := end
+				module.FillTo = &fillTo
+				stats.Blocks += volume
+				stats.Fills++
+			}
+			boxes = append(boxes, box{index: index, module: &module})
+		}
+	}
+	// keep the order of the structure roughly, so the bot doesn't
+	// move back and forth
+	sort.Slice(boxes, func(i, j int) bool {
+		return boxes[i].index < boxes[j].index
+	})
+	optimized := make([]*types.Module, 0, len(boxes))
+	for _, b := range boxes {
+		optimized = append(optimized, b.module)
+	}
+	for _, module := range modules {
+		if module.CommandBlockData != nil || module.ChestSlot != nil || special[module.Point] {
+			optimized = append(optimized, module)
+		}
+	}
+	return optimized, stats
+}
+
+// growBox returns the end of the largest box from begin found greedily,
+// x first, then z and then y
+func growBox(points map[types.Position]int, begin types.Position) types.Position {
+	has := func(x, y, z int) bool {
+		_, found := points[types.Position{X: x, Y: y, Z: z}]
+		return found
+	}
+	end := begin
+	for end.X-begin.X+1 < maxFillLength && has(end.X+1, begin.Y, begin.Z) {
+		end.X++
+	}
+	width := end.X - begin.X + 1
+	rowFull := func(y, z int) bool {
+		for x := begin.X; x <= end.X; x++ {
+			if !has(x, y, z) {
+				return false
+			}
+		}
+		return true
+	}
+	for end.Z-begin.Z+1 < maxFillLength && width*(end.Z-begin.Z+2) <= MaxFillVolume && rowFull(begin.Y, end.Z+1) {
+		end.Z++
+	}
+	area := width * (end.Z - begin.Z + 1)
+	for area*(end.Y-begin.Y+2) <= MaxFillVolume {
+		full := true
+		for z := begin.Z; z <= end.Z && full; z++ {
+			full = rowFull(end.Y+1, z)
+		}
+		if !full {
+			break
+		}
+		end.Y++
+	}
+	return end
+}
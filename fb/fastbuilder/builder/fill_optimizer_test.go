@@ -0,0 +1,64 @@
+package builder
+
+import (
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/types"
+	"testing"
+)
+
+// box returns the modules of the box from begin to end, nil block is
+// the default block
+func box(begin, end types.Position, block *types.Block) []*types.Module {
+	var modules []*types.Module
+	for y := begin.Y; y <= end.Y; y++ {
+		for z := begin.Z; z <= end.Z; z++ {
+			for x := begin.X; x <= end.X; x++ {
+				modules = append(modules, &types.Module{Block: block, Point: types.Position{X: x, Y: y, Z: z}})
+			}
+		}
+	}
+	return modules
+}
+
+func TestOptimizeFill(t *testing.T) {
+	stone := &types.ConstBlock{Name: "stone"}
+	glass := types.CreateBlock("glass", 0)
+	origin := types.Position{}
+	commandBlock := &types.Module{
+		Block:            types.CreateBlock("command_block", 0),
+		CommandBlockData: &types.CommandBlockData{Command: "say hi"},
+		Point:            types.Position{X: 1},
+	}
+	for _, c := range []struct {
+		name    string
+		modules []*types.Module
+		// the modules after optimizing and the fill commands of them
+		count int
+		stats FillStats
+		check func(optimized []*types.Module) bool
+	}{
+		{"cube", box(origin, types.Position{X: 1, Y: 1, Z: 1}, nil), 1, FillStats{Blocks: 8, Fills: 1}, func(optimized []*types.Module) bool {
+			return optimized[0].FillTo != nil && *optimized[0].FillTo == types.Position{X: 1, Y: 1, Z: 1}
+		}},
+		{"two blocks", append(box(origin, types.Position{X: 1}, nil), box(types.Position{Z: 1}, types.Position{X: 1, Z: 1}, glass)...), 2, FillStats{Blocks: 4, Fills: 2}, nil},
+		{"single blocks", []*types.Module{{Point: origin}, {Block: glass, Point: types.Position{X: 1}}}, 2, FillStats{}, func(optimized []*types.Module) bool {
+			return optimized[0].FillTo == nil && optimized[1].FillTo == nil
+		}},
+		{"the last block of a position", append(box(origin, types.Position{X: 2}, nil), &types.Module{Block: glass, Point: types.Position{X: 1}}), 3, FillStats{}, func(optimized []*types.Module) bool {
+			// boxes are in the order of their last blocks
+			return optimized[2].Block == glass && optimized[2].Point == types.Position{X: 1}
+		}},
+		{"longer than a fill", box(origin, types.Position{X: maxFillLength}, nil), 2, FillStats{Blocks: maxFillLength, Fills: 1}, nil},
+		{"command block", append(box(origin, types.Position{X: 3}, nil), commandBlock), 4, FillStats{Blocks: 2, Fills: 1}, func(optimized []*types.Module) bool {
+			// the block under the command block is kept in order after the boxes
+			return optimized[2].Point == commandBlock.Point && optimized[3] == commandBlock && optimized[0].FillTo == nil
+		}},
+	} {
+		optimized, stats := OptimizeFill(c.modules, stone)
+		if len(optimized) != c.count || stats != c.stats {
+			t.Fatalf("%s: %d modules and %+v, it should be %d and %+v", c.name, len(optimized), stats, c.count, c.stats)
+		}
+		if c.check != nil && !c.check(optimized) {
+			t.Fatalf("%s: wrong modules %v", c.name, optimized)
+		}
+	}
+}
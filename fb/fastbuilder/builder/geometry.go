@@ -1,8 +1,11 @@
 package builder
 
-import "phoenixbuilder_3rd_gui/fb/fastbuilder/types"
+import (
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/environment"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/types"
+)
 
-func Circle(config *types.MainConfig, blc chan *types.Module) error {
+func Circle(env *environment.PBEnvironment, config *types.MainConfig, blc chan *types.Module) error {
 	Radius := config.Radius
 	Facing := config.Facing
 	point := config.Position
@@ -41,7 +44,7 @@ func Circle(config *types.MainConfig, blc chan *types.Module) error {
 	return nil
 }
 
-func Ellipse(config *types.MainConfig, blc chan *types.Module) error {
+func Ellipse(env *environment.PBEnvironment, config *types.MainConfig, blc chan *types.Module) error {
 	Length := config.Length
 	Width := config.Width
 	Facing := config.Facing
@@ -81,7 +84,7 @@ func Ellipse(config *types.MainConfig, blc chan *types.Module) error {
 	return nil
 }
 
-func Ellipsoid(config *types.MainConfig, blc chan *types.Module) error {
+func Ellipsoid(env *environment.PBEnvironment, config *types.MainConfig, blc chan *types.Module) error {
 	Length := config.Length
 	Width := config.Width
 	Height := config.Height
@@ -100,7 +103,7 @@ func Ellipsoid(config *types.MainConfig, blc chan *types.Module) error {
 	return nil
 }
 
-func Round(config *types.MainConfig, blc chan *types.Module) error {
+func Round(env *environment.PBEnvironment, config *types.MainConfig, blc chan *types.Module) error {
 	Radius := config.Radius
 	Facing := config.Facing
 	point := config.Position
@@ -139,7 +142,7 @@ func Round(config *types.MainConfig, blc chan *types.Module) error {
 	return nil
 }
 
-func Sphere(config *types.MainConfig, blc chan *types.Module) error {
+func Sphere(env *environment.PBEnvironment, config *types.MainConfig, blc chan *types.Module) error {
 	Radius := config.Radius
 	Shape := config.Shape
 	point := config.Position
@@ -4,6 +4,7 @@ import (
 	_ "embed"
 	"fmt"
 	"image"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/environment"
 	"phoenixbuilder_3rd_gui/fb/fastbuilder/types"
 
 	"fyne.io/fyne/v2/storage"
@@ -206,7 +207,7 @@ func GetYMap(blocks [][]*colorBlock, MapY int) [][]int {
 	return YMap
 }
 
-func MapArt(config *types.MainConfig, blc chan *types.Module) error {
+func MapArt(env *environment.PBEnvironment, config *types.MainConfig, blc chan *types.Module) error {
 	MapX := config.MapX
 	MapZ := config.MapZ
 	MapY := config.MapY
@@ -0,0 +1,69 @@
+package builder
+
+import (
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/environment"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/types"
+	"strings"
+)
+
+// Mask restricts the building to where the block in the world matches, it's
+// given by -mask as a list of blocks like "stone,dirt@1", a leading ! builds
+// only where the world doesn't match, e.g. "!air".
+type Mask struct {
+	blocks []*types.Block
+	// blocks given without data match any data
+	anyData []bool
+	invert  bool
+}
+
+// ParseMask returns nil for an empty mask
+func ParseMask(s string) (*Mask, error) {
+	s = strings.TrimSpace(strings.ReplaceAll(s, "minecraft:", ""))
+	if s == "" {
+		return nil, nil
+	}
+	mask := &Mask{}
+	if strings.HasPrefix(s, "!") {
+		mask.invert = true
+		s = s[1:]
+	}
+	for _, item := range strings.Split(s, ",") {
+		block, err := parseBlock(item, 0)
+		if err != nil {
+			return nil, err
+		}
+		mask.blocks = append(mask.blocks, block)
+		mask.anyData = append(mask.anyData, !strings.Contains(item, "@"))
+	}
+	return mask, nil
+}
+
+func (mask *Mask) Matches(name string, data uint16) bool {
+	for i, block := range mask.blocks {
+		if *block.Name == name && (mask.anyData[i] || block.Data == data) {
+			return !mask.invert
+		}
+	}
+	return mask.invert
+}
+
+// Filter passes the modules at the positions that match, the world is
+// read after all modules are received
+func (mask *Mask) Filter(env *environment.PBEnvironment, in <-chan *types.Module, out chan<- *types.Module) error {
+	var modules []*types.Module
+	var points []types.Position
+	for module := range in {
+		modules = append(modules, module)
+		points = append(points, module.Point)
+	}
+	blocks, err := readWorld(env, points)
+	if err != nil {
+		return err
+	}
+	for i, block := range blocks {
+		if block != nil && mask.Matches(block.Name, block.Data) {
+			out <- modules[i]
+		}
+	}
+	return nil
+}
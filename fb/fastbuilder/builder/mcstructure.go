@@ -0,0 +1,90 @@
+package builder
+
+import (
+	"fmt"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/command"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/environment"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/structure"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/types"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/world_provider"
+	"sort"
+	"strings"
+
+	"fyne.io/fyne/v2/storage"
+)
+
+func MCStructure(env *environment.PBEnvironment, config *types.MainConfig, blc chan *types.Module) error {
+	uri, err := storage.ParseURI(config.Path)
+	if err != nil {
+		return err
+	}
+	file, err := storage.Reader(uri)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	s, err := structure.ReadMCStructure(file)
+	if err != nil {
+		return err
+	}
+	emitStructure(env, config, s, blc)
+	return nil
+}
+
+// JavaSchematic imports Sponge (.schem) and litematica (.litematic) schematics
+func JavaSchematic(env *environment.PBEnvironment, config *types.MainConfig, blc chan *types.Module) error {
+	uri, err := storage.ParseURI(config.Path)
+	if err != nil {
+		return err
+	}
+	file, err := storage.Reader(uri)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	s, err := structure.ReadJavaStructure(file)
+	if err != nil {
+		return err
+	}
+	emitStructure(env, config, s, blc)
+	return nil
+}
+
+// emitStructure sends the blocks of a structure read from file, placing
+// the origin of the structure at the position of the config.
+func emitStructure(env *environment.PBEnvironment, config *types.MainConfig, s *structure.Structure, blc chan *types.Module) {
+	if len(s.Inexact) > 0 {
+		command.Tellraw(env.Connection, fmt.Sprintf("Structure: Following blocks couldn't be converted exactly: %s", strings.Join(s.Inexact, ", ")))
+	}
+	if len(s.Unmapped) > 0 {
+		unmapped := make([]string, 0, len(s.Unmapped))
+		for name, count := range s.Unmapped {
+			unmapped = append(unmapped, fmt.Sprintf("%s x%d", name, count))
+		}
+		sort.Strings(unmapped)
+		command.Tellraw(env.Connection, fmt.Sprintf("Structure: Following blocks are unknown and skipped: %s", strings.Join(unmapped, ", ")))
+	}
+	for _, blk := range s.Blocks {
+		pos := types.Position{
+			X: blk.Point.X + config.Position.X,
+			Y: blk.Point.Y + config.Position.Y,
+			Z: blk.Point.Z + config.Position.Z,
+		}
+		module := &types.Module{
+			Block:            world_provider.RuntimeIdArray_117[blk.BlockRuntimeId].Take(),
+			CommandBlockData: blk.CommandBlockData,
+			Point:            pos,
+		}
+		blc <- module
+		if blk.ChestData == nil {
+			continue
+		}
+		for _, slot := range *blk.ChestData {
+			slotcopy := types.ChestSlot(slot)
+			blc <- &types.Module{
+				ChestSlot: &slotcopy,
+				Point:     pos,
+			}
+		}
+	}
+}
@@ -0,0 +1,513 @@
+package builder
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"io/ioutil"
+	"math"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/environment"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/types"
+	"strconv"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/storage"
+	"github.com/disintegration/imaging"
+)
+
+// the most blocks a model may be along each axis and in total, the
+// blocks inside are found with a grid of the whole model
+const (
+	maxModelSize   = 1024
+	maxModelVolume = 256 * 256 * 256
+)
+
+// meshMaterial is a material of an mtl file, colors are from 0 to 1
+type meshMaterial struct {
+	color    vec3
+	hasColor bool
+	texture  image.Image
+}
+
+type meshTriangle struct {
+	v        [3]vec3
+	uv       [3][2]float64
+	hasUV    bool
+	colors   [3]vec3
+	hasColor bool
+	material *meshMaterial
+}
+
+// colorAt returns the color of the point of the triangle at the
+// barycentric coordinates, from 0 to 1, false if it has no color
+func (tri *meshTriangle) colorAt(a, b, c float64) (vec3, bool) {
+	if m := tri.material; m != nil && m.texture != nil && tri.hasUV {
+		u := tri.uv[0][0]*a + tri.uv[1][0]*b + tri.uv[2][0]*c
+		v := tri.uv[0][1]*a + tri.uv[1][1]*b + tri.uv[2][1]*c
+		// textures repeat, v goes up from the bottom of the image
+		u, v = u-math.Floor(u), v-math.Floor(v)
+		bounds := m.texture.Bounds()
+		x := bounds.Min.X + minInt(int(u*float64(bounds.Dx())), bounds.Dx()-1)
+		y := bounds.Min.Y + minInt(int((1-v)*float64(bounds.Dy())), bounds.Dy()-1)
+		r, g, b, _ := m.texture.At(x, y).RGBA()
+		return vec3{float64(r) / 0xffff, float64(g) / 0xffff, float64(b) / 0xffff}, true
+	}
+	if tri.hasColor {
+		return tri.colors[0].scale(a).add(tri.colors[1].scale(b)).add(tri.colors[2].scale(c)), true
+	}
+	if m := tri.material; m != nil && m.hasColor {
+		return m.color, true
+	}
+	return vec3{}, false
+}
+
+func readURI(uri fyne.URI) ([]byte, error) {
+	reader, err := storage.Reader(uri)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return ioutil.ReadAll(reader)
+}
+
+// siblingURI returns the file of name in the folder of uri
+func siblingURI(uri fyne.URI, name string) (fyne.URI, error) {
+	parent, err := storage.Parent(uri)
+	if err != nil {
+		return nil, err
+	}
+	return storage.Child(parent, strings.ReplaceAll(name, "\\", "/"))
+}
+
+func parseFloats(fields []string) ([]float64, error) {
+	values := make([]float64, len(fields))
+	for i, field := range fields {
+		value, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+// readMTL reads the materials of an mtl file, the textures of map_Kd are
+// loaded from the folder of it
+func readMTL(uri fyne.URI, materials map[string]*meshMaterial) error {
+	data, err := readURI(uri)
+	if err != nil {
+		return err
+	}
+	var current *meshMaterial
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "newmtl":
+			current = &meshMaterial{}
+			materials[fields[1]] = current
+		case "Kd":
+			if current == nil || len(fields) < 4 {
+				continue
+			}
+			if values, err := parseFloats(fields[1:4]); err == nil {
+				current.color = vec3{values[0], values[1], values[2]}
+				current.hasColor = true
+			}
+		case "map_Kd":
+			if current == nil {
+				continue
+			}
+			// options of the map come before the name of the file
+			textureURI, err := siblingURI(uri, fields[len(fields)-1])
+			if err != nil {
+				return err
+			}
+			textureData, err := readURI(textureURI)
+			if err != nil {
+				return fmt.Errorf("cannot read texture %s: %v", fields[len(fields)-1], err)
+			}
+			texture, err := imaging.Decode(bytes.NewReader(textureData))
+			if err != nil {
+				return fmt.Errorf("cannot decode texture %s: %v", fields[len(fields)-1], err)
+			}
+			current.texture = texture
+		}
+	}
+	return scanner.Err()
+}
+
+// readOBJ reads the triangles of an obj file, faces of more vertices are
+// split into triangles. The colors of vertices given after their
+// positions are used when the material has no texture.
+func readOBJ(uri fyne.URI) ([]*meshTriangle, error) {
+	data, err := readURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	var vertices, colors []vec3
+	var hasColors []bool
+	var uvs [][2]float64
+	materials := make(map[string]*meshMaterial)
+	var material *meshMaterial
+	var triangles []*meshTriangle
+	// index resolves the 1 based or negative index of a face
+	index := func(field string, count int) (int, error) {
+		i, err := strconv.Atoi(field)
+		if err != nil {
+			return 0, err
+		}
+		if i < 0 {
+			i += count
+		} else {
+			i--
+		}
+		if i < 0 || i >= count {
+			return 0, fmt.Errorf("index %s out of range", field)
+		}
+		return i, nil
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "v":
+			values, err := parseFloats(fields[1:])
+			if err != nil || len(values) < 3 {
+				return nil, fmt.Errorf("line %d: invalid vertex", line)
+			}
+			vertices = append(vertices, vec3{values[0], values[1], values[2]})
+			if len(values) >= 6 {
+				colors = append(colors, vec3{values[3], values[4], values[5]})
+				hasColors = append(hasColors, true)
+			} else {
+				colors = append(colors, vec3{})
+				hasColors = append(hasColors, false)
+			}
+		case "vt":
+			values, err := parseFloats(fields[1:])
+			if err != nil || len(values) < 1 {
+				return nil, fmt.Errorf("line %d: invalid texture coordinate", line)
+			}
+			// v is optional
+			uv := [2]float64{values[0], 0}
+			if len(values) > 1 {
+				uv[1] = values[1]
+			}
+			uvs = append(uvs, uv)
+		case "mtllib":
+			for _, name := range fields[1:] {
+				mtlURI, err := siblingURI(uri, name)
+				if err != nil {
+					return nil, err
+				}
+				if err := readMTL(mtlURI, materials); err != nil {
+					return nil, fmt.Errorf("cannot read materials %s: %v", name, err)
+				}
+			}
+		case "usemtl":
+			if len(fields) > 1 {
+				material = materials[fields[1]]
+			}
+		case "f":
+			if len(fields) < 4 {
+				return nil, fmt.Errorf("line %d: a face needs at least 3 vertices", line)
+			}
+			type corner struct {
+				v, vt int
+			}
+			corners := make([]corner, len(fields)-1)
+			for i, field := range fields[1:] {
+				parts := strings.Split(field, "/")
+				v, err := index(parts[0], len(vertices))
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %v", line, err)
+				}
+				corners[i] = corner{v: v, vt: -1}
+				if len(parts) > 1 && parts[1] != "" {
+					if corners[i].vt, err = index(parts[1], len(uvs)); err != nil {
+						return nil, fmt.Errorf("line %d: %v", line, err)
+					}
+				}
+			}
+			for i := 1; i+1 < len(corners); i++ {
+				tri := &meshTriangle{material: material, hasUV: true, hasColor: true}
+				for j, c := range []corner{corners[0], corners[i], corners[i+1]} {
+					tri.v[j] = vertices[c.v]
+					tri.colors[j] = colors[c.v]
+					tri.hasColor = tri.hasColor && hasColors[c.v]
+					if c.vt < 0 {
+						tri.hasUV = false
+					} else {
+						tri.uv[j] = uvs[c.vt]
+					}
+				}
+				triangles = append(triangles, tri)
+			}
+		}
+	}
+	return triangles, scanner.Err()
+}
+
+// readSTL reads the triangles of a binary or ascii stl file
+func readSTL(uri fyne.URI) ([]*meshTriangle, error) {
+	data, err := readURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	// ascii files start with solid, but so do some binary ones
+	if len(data) >= 84 {
+		count := int(binary.LittleEndian.Uint32(data[80:84]))
+		if 84+count*50 == len(data) {
+			triangles := make([]*meshTriangle, count)
+			for i := range triangles {
+				tri := &meshTriangle{}
+				// the normal comes before the vertices
+				offset := 84 + i*50 + 12
+				for j := 0; j < 3; j++ {
+					var xyz [3]float64
+					for k := range xyz {
+						bits := binary.LittleEndian.Uint32(data[offset+j*12+k*4:])
+						xyz[k] = float64(math.Float32frombits(bits))
+					}
+					tri.v[j] = vec3{xyz[0], xyz[1], xyz[2]}
+				}
+				triangles[i] = tri
+			}
+			return triangles, nil
+		}
+	}
+	var triangles []*meshTriangle
+	var corners []vec3
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || fields[0] != "vertex" {
+			continue
+		}
+		values, err := parseFloats(fields[1:4])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid vertex", line)
+		}
+		corners = append(corners, vec3{values[0], values[1], values[2]})
+		if len(corners) == 3 {
+			triangles = append(triangles, &meshTriangle{v: [3]vec3{corners[0], corners[1], corners[2]}})
+			corners = corners[:0]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(triangles) == 0 {
+		return nil, fmt.Errorf("no triangles are found in the stl file")
+	}
+	return triangles, nil
+}
+
+// modelUp turns a point so that the axis of facing points up
+func modelUp(p vec3, facing string) vec3 {
+	switch facing {
+	case "x":
+		return vec3{-p.y, p.x, p.z}
+	case "z":
+		return vec3{p.x, p.z, -p.y}
+	default:
+		return p
+	}
+}
+
+// modelVoxel is a block of the surface of a model, the colors of the
+// points in it are summed up
+type modelVoxel struct {
+	color   vec3
+	samples int
+}
+
+// blockOfColor returns the block of the closest color of the map art
+// colors, the color is from 0 to 1
+func blockOfColor(color vec3, cache map[int]*types.Block) *types.Block {
+	i := Closest([3]float64{color.x * 255, color.y * 255, color.z * 255}, &colorArray2D)
+	block, found := cache[i]
+	if !found {
+		block = types.CreateBlock(blockArray2D[i].Name, uint16(blockArray2D[i].Meta))
+		cache[i] = block
+	}
+	return block
+}
+
+// Model builds the obj or stl model of -path with its lowest corner at
+// Position. The model is scaled to -h blocks high if -h is more than 1,
+// otherwise a unit of it is a block, and -facing is the axis of it that
+// points up. Only the surface is built with -s hollow. Blocks are chosen
+// by the colors of the materials, textures or vertices, the parts
+// without colors are -b.
+func Model(env *environment.PBEnvironment, config *types.MainConfig, blc chan *types.Module) error {
+	uri, err := storage.ParseURI(config.Path)
+	if err != nil {
+		return err
+	}
+	if err := checkFacing(config.Facing); err != nil {
+		return err
+	}
+	var triangles []*meshTriangle
+	switch strings.ToLower(uri.Extension()) {
+	case ".obj":
+		triangles, err = readOBJ(uri)
+	case ".stl":
+		triangles, err = readSTL(uri)
+	default:
+		return fmt.Errorf("unknown model format %s, it should be .obj or .stl", uri.Extension())
+	}
+	if err != nil {
+		return err
+	}
+	if len(triangles) == 0 {
+		return fmt.Errorf("the model has no faces")
+	}
+	min := vec3{math.Inf(1), math.Inf(1), math.Inf(1)}
+	max := vec3{math.Inf(-1), math.Inf(-1), math.Inf(-1)}
+	for _, tri := range triangles {
+		for i := range tri.v {
+			p := modelUp(tri.v[i], config.Facing)
+			tri.v[i] = p
+			min = vec3{math.Min(min.x, p.x), math.Min(min.y, p.y), math.Min(min.z, p.z)}
+			max = vec3{math.Max(max.x, p.x), math.Max(max.y, p.y), math.Max(max.z, p.z)}
+		}
+	}
+	scale := 1.0
+	if config.Height > 1 && max.y > min.y {
+		// the top of the model is in the highest block
+		scale = float64(config.Height-1) / (max.y - min.y)
+	}
+	size := [3]int{
+		int(math.Floor((max.x-min.x)*scale)) + 1,
+		int(math.Floor((max.y-min.y)*scale)) + 1,
+		int(math.Floor((max.z-min.z)*scale)) + 1,
+	}
+	for _, s := range size {
+		if s > maxModelSize {
+			return fmt.Errorf("the model is %dx%dx%d blocks, it should be at most %d blocks along each axis", size[0], size[1], size[2], maxModelSize)
+		}
+	}
+	if size[0]*size[1]*size[2] > maxModelVolume {
+		return fmt.Errorf("the model is %dx%dx%d blocks, it should be at most %d blocks in total", size[0], size[1], size[2], maxModelVolume)
+	}
+	voxels := make(map[types.Position]*modelVoxel)
+	for _, tri := range triangles {
+		var v [3]vec3
+		for i := range v {
+			v[i] = tri.v[i].sub(min).scale(scale)
+		}
+		longest := math.Max(v[0].sub(v[1]).length(), math.Max(v[1].sub(v[2]).length(), v[2].sub(v[0]).length()))
+		// two points a block so that the surface has no holes
+		n := int(math.Ceil(longest*2)) + 1
+		for i := 0; i <= n; i++ {
+			for j := 0; i+j <= n; j++ {
+				a, b := float64(i)/float64(n), float64(j)/float64(n)
+				c := 1 - a - b
+				p := v[0].scale(a).add(v[1].scale(b)).add(v[2].scale(c))
+				point := types.Position{X: modelCell(p.x, size[0]), Y: modelCell(p.y, size[1]), Z: modelCell(p.z, size[2])}
+				voxel := voxels[point]
+				if voxel == nil {
+					voxel = &modelVoxel{}
+					voxels[point] = voxel
+				}
+				if color, ok := tri.colorAt(a, b, c); ok {
+					voxel.color = voxel.color.add(color)
+					voxel.samples++
+				}
+			}
+		}
+	}
+	var inside []bool
+	if config.Shape != "hollow" {
+		inside = modelInside(voxels, size)
+	}
+	cache := make(map[int]*types.Block)
+	blockAt := func(point types.Position) *types.Block {
+		voxel := voxels[point]
+		if voxel == nil || voxel.samples == 0 {
+			return nil
+		}
+		return blockOfColor(voxel.color.scale(1/float64(voxel.samples)), cache)
+	}
+	for y := 0; y < size[1]; y++ {
+		for x := 0; x < size[0]; x++ {
+			for z := 0; z < size[2]; z++ {
+				point := types.Position{X: x, Y: y, Z: z}
+				var block *types.Block
+				if _, found := voxels[point]; found {
+					block = blockAt(point)
+				} else if inside != nil && inside[(x*size[1]+y)*size[2]+z] {
+					// the blocks inside are the same as the surface above them
+					for top := y + 1; top < size[1]; top++ {
+						if _, found := voxels[types.Position{X: x, Y: top, Z: z}]; found {
+							block = blockAt(types.Position{X: x, Y: top, Z: z})
+							break
+						}
+					}
+				} else {
+					continue
+				}
+				blc <- &types.Module{
+					Block: block,
+					Point: types.Position{X: config.Position.X + x, Y: config.Position.Y + y, Z: config.Position.Z + z},
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// modelCell returns the block a coordinate of the scaled model is in,
+// the rounding errors at the faces of the blocks are ignored
+func modelCell(c float64, size int) int {
+	return maxInt(minInt(int(math.Floor(c+1e-6)), size-1), 0)
+}
+
+// modelInside finds the blocks enclosed by the surface, by filling from
+// the outside of the model. The index of a block is (x*size[1]+y)*size[2]+z.
+func modelInside(surface map[types.Position]*modelVoxel, size [3]int) []bool {
+	// the grid is padded by a block on each side so the outside is connected
+	sx, sy, sz := size[0]+2, size[1]+2, size[2]+2
+	outside := make([]bool, sx*sy*sz)
+	at := func(x, y, z int) int {
+		return (x*sy+y)*sz + z
+	}
+	queue := [][3]int{{0, 0, 0}}
+	outside[0] = true
+	for len(queue) > 0 {
+		p := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+		for _, d := range [6][3]int{{1, 0, 0}, {-1, 0, 0}, {0, 1, 0}, {0, -1, 0}, {0, 0, 1}, {0, 0, -1}} {
+			x, y, z := p[0]+d[0], p[1]+d[1], p[2]+d[2]
+			if x < 0 || y < 0 || z < 0 || x >= sx || y >= sy || z >= sz || outside[at(x, y, z)] {
+				continue
+			}
+			if _, found := surface[types.Position{X: x - 1, Y: y - 1, Z: z - 1}]; found {
+				continue
+			}
+			outside[at(x, y, z)] = true
+			queue = append(queue, [3]int{x, y, z})
+		}
+	}
+	inside := make([]bool, size[0]*size[1]*size[2])
+	for x := 0; x < size[0]; x++ {
+		for y := 0; y < size[1]; y++ {
+			for z := 0; z < size[2]; z++ {
+				inside[(x*size[1]+y)*size[2]+z] = !outside[at(x+1, y+1, z+1)]
+			}
+		}
+	}
+	return inside
+}
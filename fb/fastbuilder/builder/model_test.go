@@ -0,0 +1,98 @@
+package builder
+
+import (
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/types"
+	"strings"
+	"testing"
+
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/test"
+)
+
+func TestReadOBJ(t *testing.T) {
+	test.NewApp()
+	for _, c := range []struct {
+		name      string
+		obj       string
+		triangles int
+		hasUV     bool
+		uv        [2]float64
+		err       bool
+	}{
+		{"triangle", "v 0 0 0\nv 1 0 0\nv 0 1 0\nf 1 2 3\n", 1, false, [2]float64{}, false},
+		{"quad", "v 0 0 0\nv 1 0 0\nv 1 1 0\nv 0 1 0\nf 1 2 3 4\n", 2, false, [2]float64{}, false},
+		{"negative indices", "v 0 0 0\nv 1 0 0\nv 0 1 0\nf -3 -2 -1\n", 1, false, [2]float64{}, false},
+		{"texture coordinates", "v 0 0 0\nv 1 0 0\nv 0 1 0\nvt 0.5 0.25\nf 1/1 2/1 3/1\n", 1, true, [2]float64{0.5, 0.25}, false},
+		{"u only", "v 0 0 0\nv 1 0 0\nv 0 1 0\nvt 0.5\nf 1/1 2/1 3/1\n", 1, true, [2]float64{0.5, 0}, false},
+		{"no coordinates", "vt\n", 0, false, [2]float64{}, true},
+		{"index out of range", "v 0 0 0\nv 1 0 0\nf 1 2 3\n", 0, false, [2]float64{}, true},
+		{"two vertices", "v 0 0 0\nv 1 0 0\nf 1 2\n", 0, false, [2]float64{}, true},
+	} {
+		path := filepath.Join(t.TempDir(), "model.obj")
+		if err := os.WriteFile(path, []byte(c.obj), 0644); err != nil {
+			t.Fatal(err)
+		}
+		triangles, err := readOBJ(storage.NewFileURI(path))
+		if (err != nil) != c.err {
+			t.Fatalf("%s: error %v", c.name, err)
+		}
+		if len(triangles) != c.triangles {
+			t.Fatalf("%s: %d triangles, it should be %d", c.name, len(triangles), c.triangles)
+		}
+		if len(triangles) > 0 && (triangles[0].hasUV != c.hasUV || triangles[0].uv[0] != c.uv) {
+			t.Fatalf("%s: texture coordinates %v %v", c.name, triangles[0].hasUV, triangles[0].uv[0])
+		}
+	}
+}
+
+func TestReadSTL(t *testing.T) {
+	test.NewApp()
+	ascii := "solid t\nfacet normal 0 0 1\nouter loop\nvertex 0 0 0\nvertex 1 0 0\nvertex 0 1 0\nendloop\nendfacet\nendsolid t\n"
+	// a binary file starting with solid like some exporters write
+	binaryData := make([]byte, 84+50)
+	copy(binaryData, "solid binary")
+	binary.LittleEndian.PutUint32(binaryData[80:], 1)
+	for i, value := range []float32{0, 0, 1, 0, 0, 0, 2, 0, 0, 0, 3, 0} {
+		binary.LittleEndian.PutUint32(binaryData[84+i*4:], math.Float32bits(value))
+	}
+	for _, c := range []struct {
+		name   string
+		data   []byte
+		second vec3
+		err    bool
+	}{
+		{"ascii", []byte(ascii), vec3{1, 0, 0}, false},
+		{"binary", binaryData, vec3{2, 0, 0}, false},
+		{"empty", []byte("solid t\nendsolid t\n"), vec3{}, true},
+	} {
+		path := filepath.Join(t.TempDir(), "model.stl")
+		if err := os.WriteFile(path, c.data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		triangles, err := readSTL(storage.NewFileURI(path))
+		if (err != nil) != c.err {
+			t.Fatalf("%s: error %v", c.name, err)
+		}
+		if err == nil && (len(triangles) != 1 || triangles[0].v[1] != c.second) {
+			t.Fatalf("%s: triangles %v", c.name, triangles)
+		}
+	}
+}
+
+func TestModelVolume(t *testing.T) {
+	test.NewApp()
+	path := filepath.Join(t.TempDir(), "model.stl")
+	stl := "solid t\nfacet normal 0 0 0\nouter loop\nvertex 0 0 0\nvertex 300 0 300\nvertex 0 300 0\nendloop\nendfacet\nendsolid t\n"
+	if err := os.WriteFile(path, []byte(stl), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config := &types.MainConfig{Path: "file://" + path, Facing: "y", Shape: "solid"}
+	err := Model(nil, config, make(chan *types.Module))
+	if err == nil || !strings.Contains(err.Error(), "in total") {
+		t.Fatalf("a model of 301x301x301 blocks is built: %v", err)
+	}
+}
@@ -0,0 +1,204 @@
+package builder
+
+import (
+	"fmt"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/environment"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/types"
+	"strings"
+)
+
+// the builders that import structures, which can be rotated, mirrored
+// and scaled by -rotate, -mirror and -scale
+var orientableBuilders = map[string]bool{
+	"schem":       true,
+	"acme":        true,
+	"bdump":       true,
+	"mcstructure": true,
+	"javaschem":   true,
+	"mapart":      true,
+}
+
+func isOriented(config *types.MainConfig) bool {
+	return config.Rotation != 0 || config.Mirror != "" || config.Scale > 1
+}
+
+// the horizontal directions, clockwise seen from above
+const (
+	directionNorth = iota
+	directionEast
+	directionSouth
+	directionWest
+)
+
+// directionData is how a kind of block keeps its horizontal direction
+// in the bits of mask of its data
+type directionData struct {
+	mask uint16
+	// the data of north, east, south and west
+	values [4]uint16
+}
+
+var (
+	stairsDirection   = &directionData{mask: 3, values: [4]uint16{3, 0, 2, 1}}
+	doorDirection     = &directionData{mask: 3, values: [4]uint16{3, 0, 1, 2}}
+	repeaterDirection = &directionData{mask: 3, values: [4]uint16{2, 3, 0, 1}}
+	// facing_direction, up and down (0 and 1) are kept
+	facingDirection = &directionData{mask: 7, values: [4]uint16{2, 5, 3, 4}}
+)
+
+func directionDataOf(name string) *directionData {
+	name = strings.TrimPrefix(name, "minecraft:")
+	if strings.HasSuffix(name, "_stairs") {
+		return stairsDirection
+	}
+	if strings.HasSuffix(name, "_door") {
+		return doorDirection
+	}
+	switch name {
+	case "unpowered_repeater", "powered_repeater", "unpowered_comparator", "powered_comparator":
+		return repeaterDirection
+	case "command_block", "repeating_command_block", "chain_command_block",
+		"dispenser", "dropper", "observer", "piston", "sticky_piston",
+		"furnace", "lit_furnace", "chest", "trapped_chest", "ender_chest", "ladder":
+		return facingDirection
+	}
+	return nil
+}
+
+// transform returns the data after the block is mirrored and then
+// rotated clockwise by turns
+func (d *directionData) transform(data uint16, turns int, mirror string) uint16 {
+	direction := -1
+	for i, v := range d.values {
+		if data&d.mask == v {
+			direction = i
+			break
+		}
+	}
+	if direction < 0 {
+		return data
+	}
+	if (mirror == "x" && (direction == directionEast || direction == directionWest)) ||
+		(mirror == "z" && (direction == directionNorth || direction == directionSouth)) {
+		direction = (direction + 2) % 4
+	}
+	direction = (direction + turns) % 4
+	return data&^d.mask | d.values[direction]
+}
+
+// orientation mirrors, rotates and then scales modules around center
+type orientation struct {
+	center types.Position
+	// clockwise quarter turns seen from above
+	turns  int
+	mirror string
+	scale  int
+	// blocks of modules may be shared, so changed blocks are new ones
+	blocks map[types.ConstBlock]*types.Block
+}
+
+func newOrientation(center types.Position, degrees int, mirror string, scale int) (*orientation, error) {
+	if degrees%90 != 0 {
+		return nil, fmt.Errorf("%d is not a multiple of 90 degrees", degrees)
+	}
+	if mirror != "" && mirror != "x" && mirror != "z" {
+		return nil, fmt.Errorf("invalid mirror axis %s, it should be x or z", mirror)
+	}
+	if scale < 0 {
+		return nil, fmt.Errorf("invalid scale %d", scale)
+	}
+	if scale == 0 {
+		scale = 1
+	}
+	return &orientation{
+		center: center,
+		turns:  (degrees/90%4 + 4) % 4,
+		mirror: mirror,
+		scale:  scale,
+		blocks: make(map[types.ConstBlock]*types.Block),
+	}, nil
+}
+
+func (o *orientation) block(block *types.Block) *types.Block {
+	if block == nil || (o.turns == 0 && o.mirror == "") {
+		return block
+	}
+	direction := directionDataOf(*block.Name)
+	if direction == nil {
+		return block
+	}
+	var data uint16
+	if direction == doorDirection && block.Data&8 != 0 {
+		// the upper half of a door keeps the hinge instead of the direction
+		data = block.Data
+		if o.mirror != "" {
+			data ^= 1
+		}
+	} else {
+		data = direction.transform(block.Data, o.turns, o.mirror)
+	}
+	if data == block.Data {
+		return block
+	}
+	key := types.ConstBlock{Name: *block.Name, Data: data}
+	if b, found := o.blocks[key]; found {
+		return b
+	}
+	b := types.CreateBlock(key.Name, key.Data)
+	o.blocks[key] = b
+	return b
+}
+
+// offset mirrors and rotates the offset of a module from center
+func (o *orientation) offset(dx, dz int) (int, int) {
+	if o.mirror == "x" {
+		dx = -dx
+	} else if o.mirror == "z" {
+		dz = -dz
+	}
+	for i := 0; i < o.turns; i++ {
+		dx, dz = -dz, dx
+	}
+	return dx, dz
+}
+
+// apply writes the modules that module becomes to out, there are
+// scale^3 of them
+func (o *orientation) apply(module *types.Module, out chan<- *types.Module) {
+	block := o.block(module.Block)
+	dx, dz := o.offset(module.Point.X-o.center.X, module.Point.Z-o.center.Z)
+	dy := module.Point.Y - o.center.Y
+	if o.scale == 1 {
+		module.Block = block
+		module.Point = types.Position{X: o.center.X + dx, Y: o.center.Y + dy, Z: o.center.Z + dz}
+		out <- module
+		return
+	}
+	for i := 0; i < o.scale; i++ {
+		for j := 0; j < o.scale; j++ {
+			for k := 0; k < o.scale; k++ {
+				scaled := *module
+				scaled.Block = block
+				scaled.Point = types.Position{
+					X: o.center.X + dx*o.scale + i,
+					Y: o.center.Y + dy*o.scale + j,
+					Z: o.center.Z + dz*o.scale + k,
+				}
+				out <- &scaled
+			}
+		}
+	}
+}
+
+// Orient applies -mirror, -rotate and -scale to the modules of a
+// structure placed at config.Position
+func Orient(env *environment.PBEnvironment, config *types.MainConfig, in <-chan *types.Module, out chan<- *types.Module) error {
+	o, err := newOrientation(config.Position, config.Rotation, config.Mirror, config.Scale)
+	if err != nil {
+		return err
+	}
+	for module := range in {
+		o.apply(module, out)
+	}
+	return nil
+}
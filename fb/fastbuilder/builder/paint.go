@@ -3,6 +3,8 @@ package builder
 import (
 	"errors"
 	"fmt"
+	"image"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/environment"
 	"phoenixbuilder_3rd_gui/fb/fastbuilder/types"
 
 	"fyne.io/fyne/v2/storage"
@@ -15,7 +17,7 @@ type ColorBlock struct {
 	Block *types.ConstBlock
 }
 
-func Paint(config *types.MainConfig, blc chan *types.Module) error {
+func Paint(env *environment.PBEnvironment, config *types.MainConfig, blc chan *types.Module) error {
 	width := config.Width
 	height := config.Height
 	facing := config.Facing
@@ -24,19 +26,10 @@ func Paint(config *types.MainConfig, blc chan *types.Module) error {
 	// if !hasK {
 	// 	return I18n.ProcessNoSuchFileError(config.Path)
 	// }
-	uri, err := storage.ParseURI(config.Path)
+	img, err := readImage(config.Path)
 	if err != nil {
 		return err
 	}
-	file, err := storage.Reader(uri)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-	img, err := imaging.Decode(file)
-	if err != nil {
-		return fmt.Errorf("Image Decode Error: %v", err)
-	}
 	if width != 0 && height != 0 {
 		img = imaging.Resize(img, width, height, imaging.Lanczos)
 	}
@@ -90,6 +83,24 @@ func Paint(config *types.MainConfig, blc chan *types.Module) error {
 	return nil
 }
 
+// readImage decodes the image of path
+func readImage(path string) (image.Image, error) {
+	uri, err := storage.ParseURI(path)
+	if err != nil {
+		return nil, err
+	}
+	file, err := storage.Reader(uri)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	img, err := imaging.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("Image Decode Error: %v", err)
+	}
+	return img, nil
+}
+
 func getBlock(c colorful.Color) *types.Block {
 	if _, _, _, a := c.RGBA(); a == 0 {
 		return AirBlock.Take()
@@ -0,0 +1,194 @@
+package builder
+
+import (
+	"fmt"
+	"math"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/noise"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/types"
+	"strconv"
+	"strings"
+)
+
+// Pattern decides the block of each position, it's given by -b:
+//
+//	stone                                  a single block, -d is its data
+//	stone:60,cobblestone:30,andesite@1:10  blocks chosen randomly by weight
+//	gradient:y:16:stone,cobblestone,dirt   blocks changing along y for 16 blocks from the position
+//	noise:8:stone,cobblestone,andesite     blocks chosen by noise of the size 8
+//
+// name@data gives the data of a block in a list, it's -d if omitted.
+// The random choices depend on the position and -seed only, so the
+// blocks are the same when a task is built again.
+type Pattern interface {
+	Block(point types.Position) *types.Block
+}
+
+// IsPattern tells if -b is more than a single block
+func IsPattern(block string) bool {
+	return strings.ContainsAny(strings.ReplaceAll(block, "minecraft:", ""), ":,")
+}
+
+type singlePattern struct {
+	block *types.Block
+}
+
+func (p *singlePattern) Block(point types.Position) *types.Block {
+	return p.block
+}
+
+type weightedPattern struct {
+	blocks  []*types.Block
+	weights []int
+	total   int
+	seed    int64
+}
+
+func (p *weightedPattern) Block(point types.Position) *types.Block {
+	r := int(noise.Hash(point.X, point.Y, point.Z, p.seed) * float64(p.total))
+	for i, weight := range p.weights {
+		if r < weight {
+			return p.blocks[i]
+		}
+		r -= weight
+	}
+	return p.blocks[len(p.blocks)-1]
+}
+
+type gradientPattern struct {
+	blocks []*types.Block
+	axis   string
+	origin types.Position
+	length int
+	seed   int64
+}
+
+func (p *gradientPattern) Block(point types.Position) *types.Block {
+	var offset int
+	switch p.axis {
+	case "x":
+		offset = point.X - p.origin.X
+	case "y":
+		offset = point.Y - p.origin.Y
+	default:
+		offset = point.Z - p.origin.Z
+	}
+	t := math.Max(0, math.Min(1, float64(offset)/float64(p.length)))
+	f := t * float64(len(p.blocks)-1)
+	i := int(f)
+	// blocks between two of the list are mixed so that the change is smooth
+	if i+1 < len(p.blocks) && noise.Hash(point.X, point.Y, point.Z, p.seed) < f-float64(i) {
+		i++
+	}
+	return p.blocks[i]
+}
+
+type noisePattern struct {
+	blocks []*types.Block
+	size   float64
+	perlin *noise.Perlin
+}
+
+func (p *noisePattern) Block(point types.Position) *types.Block {
+	v := p.perlin.Noise3(float64(point.X)/p.size, float64(point.Y)/p.size, float64(point.Z)/p.size)
+	i := int((v + 1) / 2 * float64(len(p.blocks)))
+	if i < 0 {
+		i = 0
+	} else if i >= len(p.blocks) {
+		i = len(p.blocks) - 1
+	}
+	return p.blocks[i]
+}
+
+// parseBlock parses name@data, data is defaultData if omitted
+func parseBlock(s string, defaultData uint16) (*types.Block, error) {
+	name, dataStr := s, ""
+	if i := strings.Index(s, "@"); i >= 0 {
+		name, dataStr = s[:i], s[i+1:]
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, fmt.Errorf("empty block name in %q", s)
+	}
+	data := defaultData
+	if dataStr != "" {
+		d, err := strconv.ParseUint(dataStr, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid block data in %q", s)
+		}
+		data = uint16(d)
+	}
+	return types.CreateBlock(name, data), nil
+}
+
+func parseBlockList(s string, defaultData uint16) ([]*types.Block, error) {
+	var blocks []*types.Block
+	for _, item := range strings.Split(s, ",") {
+		block, err := parseBlock(item, defaultData)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks, nil
+}
+
+// ParsePattern parses the -b of config
+func ParsePattern(config *types.MainConfig) (Pattern, error) {
+	s := strings.ReplaceAll(config.Block.Name, "minecraft:", "")
+	data := config.Block.Data
+	if !IsPattern(s) {
+		return &singlePattern{block: types.CreateBlock(s, data)}, nil
+	}
+	if strings.HasPrefix(s, "gradient:") {
+		parts := strings.SplitN(s, ":", 4)
+		if len(parts) != 4 || (parts[1] != "x" && parts[1] != "y" && parts[1] != "z") {
+			return nil, fmt.Errorf("invalid gradient pattern %q, it should be gradient:<x|y|z>:<length>:<blocks>", s)
+		}
+		length, err := strconv.Atoi(parts[2])
+		if err != nil || length <= 0 {
+			return nil, fmt.Errorf("invalid length of gradient pattern: %s", parts[2])
+		}
+		blocks, err := parseBlockList(parts[3], data)
+		if err != nil {
+			return nil, err
+		}
+		return &gradientPattern{blocks: blocks, axis: parts[1], origin: config.Position, length: length, seed: config.Seed}, nil
+	}
+	if strings.HasPrefix(s, "noise:") {
+		parts := strings.SplitN(s, ":", 3)
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("invalid noise pattern %q, it should be noise:<size>:<blocks>", s)
+		}
+		size, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil || size <= 0 {
+			return nil, fmt.Errorf("invalid size of noise pattern: %s", parts[1])
+		}
+		blocks, err := parseBlockList(parts[2], data)
+		if err != nil {
+			return nil, err
+		}
+		return &noisePattern{blocks: blocks, size: size, perlin: noise.NewPerlin(config.Seed)}, nil
+	}
+	p := &weightedPattern{seed: config.Seed}
+	for _, item := range strings.Split(s, ",") {
+		weight := 1
+		if i := strings.LastIndex(item, ":"); i >= 0 {
+			w, err := strconv.Atoi(item[i+1:])
+			if err != nil || w < 0 {
+				return nil, fmt.Errorf("invalid weight in %q", item)
+			}
+			item, weight = item[:i], w
+		}
+		block, err := parseBlock(item, data)
+		if err != nil {
+			return nil, err
+		}
+		p.blocks = append(p.blocks, block)
+		p.weights = append(p.weights, weight)
+		p.total += weight
+	}
+	if p.total == 0 {
+		return nil, fmt.Errorf("the weights of pattern %q are all 0", s)
+	}
+	return p, nil
+}
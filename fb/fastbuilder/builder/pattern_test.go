@@ -0,0 +1,104 @@
+package builder
+
+import (
+	"fmt"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/types"
+	"testing"
+)
+
+func TestParsePattern(t *testing.T) {
+	origin := types.Position{X: 10, Y: 64, Z: 10}
+	for _, c := range []struct {
+		name    string
+		block   string
+		data    uint16
+		pattern bool
+		// the blocks expected at the offsets from origin, name@data
+		blocks map[types.Position]string
+		err    bool
+	}{
+		{"single block", "minecraft:stone", 2, false, map[types.Position]string{{}: "stone@2", {X: 5}: "stone@2"}, false},
+		{"weighted", "stone:1,glass@3:0", 1, true, map[types.Position]string{{}: "stone@1", {Y: 7}: "stone@1"}, false},
+		{"gradient", "gradient:y:4:stone,glass@5", 0, true, map[types.Position]string{{}: "stone@0", {Y: -3}: "stone@0", {Y: 4}: "glass@5", {Y: 9}: "glass@5"}, false},
+		{"noise of one block", "noise:8:dirt@1", 0, true, map[types.Position]string{{}: "dirt@1", {X: 100, Z: -7}: "dirt@1"}, false},
+		{"no weights", "stone,glass", 0, true, nil, false},
+		{"zero weights", "stone:0,glass:0", 0, true, nil, true},
+		{"negative weight", "stone:-1,glass", 0, true, nil, true},
+		{"gradient axis", "gradient:w:4:stone", 0, true, nil, true},
+		{"gradient length", "gradient:x:0:stone", 0, true, nil, true},
+		{"noise size", "noise:-1:stone", 0, true, nil, true},
+		{"block data", "stone@x,glass", 0, true, nil, true},
+		{"empty block", "stone,,glass", 0, true, nil, true},
+	} {
+		if IsPattern(c.block) != c.pattern {
+			t.Fatalf("%s: IsPattern(%q) is %v", c.name, c.block, !c.pattern)
+		}
+		config := &types.MainConfig{Block: &types.ConstBlock{Name: c.block, Data: c.data}, Position: origin, Seed: 1}
+		pattern, err := ParsePattern(config)
+		if (err != nil) != c.err {
+			t.Fatalf("%s: error %v", c.name, err)
+		}
+		for offset, want := range c.blocks {
+			block := pattern.Block(types.Position{X: origin.X + offset.X, Y: origin.Y + offset.Y, Z: origin.Z + offset.Z})
+			if got := fmt.Sprintf("%s@%d", *block.Name, block.Data); got != want {
+				t.Fatalf("%s: %s at %v, it should be %s", c.name, got, offset, want)
+			}
+		}
+	}
+}
+
+func TestPatternIsStable(t *testing.T) {
+	for _, block := range []string{"stone:1,glass:1,dirt:1", "gradient:x:8:stone,glass,dirt", "noise:4:stone,glass,dirt"} {
+		config := &types.MainConfig{Block: &types.ConstBlock{Name: block}, Seed: 7}
+		a, err := ParsePattern(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := ParsePattern(config)
+		seen := map[string]bool{}
+		for x := 0; x < 16; x++ {
+			for z := 0; z < 16; z++ {
+				point := types.Position{X: x, Y: 64, Z: z}
+				if *a.Block(point).Name != *b.Block(point).Name {
+					t.Fatalf("%s: the blocks at %v differ with the same seed", block, point)
+				}
+				seen[*a.Block(point).Name] = true
+			}
+		}
+		if len(seen) < 2 {
+			t.Fatalf("%s: only %v are chosen", block, seen)
+		}
+	}
+}
+
+func TestMask(t *testing.T) {
+	for _, c := range []struct {
+		name    string
+		mask    string
+		block   string
+		data    uint16
+		matches bool
+	}{
+		{"listed", "stone,dirt", "dirt", 3, true},
+		{"not listed", "stone,dirt", "glass", 0, false},
+		{"with prefix", "minecraft:stone", "stone", 0, true},
+		{"same data", "stone@1", "stone", 1, true},
+		{"other data", "stone@1", "stone", 2, false},
+		{"inverted", "!air", "stone", 0, true},
+		{"inverted listed", "!air,water", "water", 0, false},
+	} {
+		mask, err := ParseMask(c.mask)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if mask.Matches(c.block, c.data) != c.matches {
+			t.Fatalf("%s: %q matches %s@%d is %v", c.name, c.mask, c.block, c.data, !c.matches)
+		}
+	}
+	if mask, err := ParseMask(" "); mask != nil || err != nil {
+		t.Fatalf("an empty mask is %v, %v", mask, err)
+	}
+	if _, err := ParseMask("stone@x"); err == nil {
+		t.Fatal("a mask with invalid data is parsed")
+	}
+}
@@ -0,0 +1,201 @@
+package builder
+
+import (
+	"phoenixbuilder_3rd_gui/fb/dragonfly/server/block/cube"
+	"phoenixbuilder_3rd_gui/fb/dragonfly/server/world"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/environment"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/types"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/world_provider"
+	"sort"
+	"strings"
+)
+
+// the builders that decide what to build by the blocks currently in the
+// world, the blocks built before are not generated again, so a task of
+// them can't skip the blocks before a checkpoint
+var worldBuilders = map[string]bool{
+	"replace": true,
+	"keep":    true,
+}
+
+// ReadsWorld tells if what the config builds is decided by the world
+func ReadsWorld(config *types.MainConfig) bool {
+	return worldBuilders[config.Execute] || config.Mask != ""
+}
+
+// regionOf returns the corners of the box between config.Position and
+// config.End, the first one is the smallest
+func regionOf(config *types.MainConfig) (begin, end types.Position) {
+	begin, end = config.Position, config.End
+	if end.X < begin.X {
+		begin.X, end.X = end.X, begin.X
+	}
+	if end.Y < begin.Y {
+		begin.Y, end.Y = end.Y, begin.Y
+	}
+	if end.Z < begin.Z {
+		begin.Z, end.Z = end.Z, begin.Z
+	}
+	return
+}
+
+// regionBuilder generates the positions of the box that shape accepts,
+// onX, onY and onZ tell if the position is on the faces of the box in
+// that axis, the block returned by shape is nil for the block of config
+func regionBuilder(config *types.MainConfig, blc chan *types.Module, shape func(onX, onY, onZ bool) (block *types.Block, build bool)) {
+	begin, end := regionOf(config)
+	for x := begin.X; x <= end.X; x++ {
+		onX := x == begin.X || x == end.X
+		for z := begin.Z; z <= end.Z; z++ {
+			onZ := z == begin.Z || z == end.Z
+			for y := begin.Y; y <= end.Y; y++ {
+				onY := y == begin.Y || y == end.Y
+				block, build := shape(onX, onY, onZ)
+				if build {
+					blc <- &types.Module{Block: block, Point: types.Position{X: x, Y: y, Z: z}}
+				}
+			}
+		}
+	}
+}
+
+var airBlock = types.CreateBlock("air", 0)
+
+// Fill fills the box between the position and the end with the block
+func Fill(env *environment.PBEnvironment, config *types.MainConfig, blc chan *types.Module) error {
+	regionBuilder(config, blc, func(onX, onY, onZ bool) (*types.Block, bool) {
+		return nil, true
+	})
+	return nil
+}
+
+// Walls builds the four vertical sides of the box
+func Walls(env *environment.PBEnvironment, config *types.MainConfig, blc chan *types.Module) error {
+	regionBuilder(config, blc, func(onX, onY, onZ bool) (*types.Block, bool) {
+		return nil, onX || onZ
+	})
+	return nil
+}
+
+// Outline builds the six faces of the box, the inside is not changed
+func Outline(env *environment.PBEnvironment, config *types.MainConfig, blc chan *types.Module) error {
+	regionBuilder(config, blc, func(onX, onY, onZ bool) (*types.Block, bool) {
+		return nil, onX || onY || onZ
+	})
+	return nil
+}
+
+// Hollow builds the six faces of the box and fills the inside with air
+func Hollow(env *environment.PBEnvironment, config *types.MainConfig, blc chan *types.Module) error {
+	regionBuilder(config, blc, func(onX, onY, onZ bool) (*types.Block, bool) {
+		if onX || onY || onZ {
+			return nil, true
+		}
+		return airBlock, true
+	})
+	return nil
+}
+
+// matchesOldBlock tells if a block is the one given by -ob, -od is
+// compared too unless -old_method is any
+func matchesOldBlock(config *types.MainConfig, name string, data uint16) bool {
+	if strings.TrimPrefix(name, "minecraft:") != strings.TrimPrefix(config.OldBlock.Name, "minecraft:") {
+		return false
+	}
+	return config.OldMethod == "any" || data == config.OldBlock.Data
+}
+
+// VisitWorld calls visit with the block in the world at each of the
+// points, i is the index of the point. All of them are read before
+// anything is built since reading moves the bot, other tasks reading the
+// world wait until it's done.
+func VisitWorld(env *environment.PBEnvironment, points []types.Position, visit func(i int, block world.Block)) error {
+	// points in the same chunk are read together
+	order := make([]int, len(points))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := points[order[i]], points[order[j]]
+		if a.X>>4 != b.X>>4 {
+			return a.X>>4 < b.X>>4
+		}
+		return a.Z>>4 < b.Z>>4
+	})
+	env.WorldHolder.Visit(func(w *world.World) {
+		for _, i := range order {
+			point := points[i]
+			visit(i, w.Block(cube.Pos{point.X, point.Y, point.Z}))
+		}
+	})
+	return nil
+}
+
+// readWorld returns the blocks in the world at the points, nil for the
+// unknown ones
+func readWorld(env *environment.PBEnvironment, points []types.Position) ([]*types.ConstBlock, error) {
+	blocks := make([]*types.ConstBlock, len(points))
+	err := VisitWorld(env, points, func(i int, block world.Block) {
+		runtimeId := world.LoadRuntimeID(block)
+		if int(runtimeId) < len(world_provider.RuntimeIdArray_117) {
+			blocks[i] = world_provider.RuntimeIdArray_117[runtimeId]
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return blocks, nil
+}
+
+// readRegion returns the positions of the box where the block in the world
+// is accepted by match
+func readRegion(env *environment.PBEnvironment, config *types.MainConfig, match func(name string, data uint16) bool) ([]types.Position, error) {
+	begin, end := regionOf(config)
+	var points []types.Position
+	for x := begin.X; x <= end.X; x++ {
+		for z := begin.Z; z <= end.Z; z++ {
+			for y := begin.Y; y <= end.Y; y++ {
+				points = append(points, types.Position{X: x, Y: y, Z: z})
+			}
+		}
+	}
+	blocks, err := readWorld(env, points)
+	if err != nil {
+		return nil, err
+	}
+	var positions []types.Position
+	for i, block := range blocks {
+		if block != nil && match(block.Name, block.Data) {
+			positions = append(positions, points[i])
+		}
+	}
+	return positions, nil
+}
+
+// RegionReplace sets the block where the world has the block given by -ob
+func RegionReplace(env *environment.PBEnvironment, config *types.MainConfig, blc chan *types.Module) error {
+	positions, err := readRegion(env, config, func(name string, data uint16) bool {
+		return matchesOldBlock(config, name, data)
+	})
+	if err != nil {
+		return err
+	}
+	for _, position := range positions {
+		blc <- &types.Module{Point: position}
+	}
+	return nil
+}
+
+// Keep sets the block only where the world has air
+func Keep(env *environment.PBEnvironment, config *types.MainConfig, blc chan *types.Module) error {
+	positions, err := readRegion(env, config, func(name string, data uint16) bool {
+		return name == "air"
+	})
+	if err != nil {
+		return err
+	}
+	for _, position := range positions {
+		blc <- &types.Module{Point: position}
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package builder
+
+import (
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/types"
+	"sort"
+	"strings"
+)
+
+// the passes of ScheduleByChunk, blocks of a later pass need the ones of
+// the passes before to stay where they are
+const (
+	passSolid = iota
+	passGravity
+	passAttached
+)
+
+// blocks that fall if there is nothing below them
+var gravityBlocks = map[string]bool{
+	"sand":            true,
+	"gravel":          true,
+	"concrete_powder": true,
+	"anvil":           true,
+	"dragon_egg":      true,
+	"scaffolding":     true,
+}
+
+// blocks that break if the block they are on is not there
+var attachedBlocks = map[string]bool{
+	"torch":                         true,
+	"soul_torch":                    true,
+	"redstone_torch":                true,
+	"unlit_redstone_torch":          true,
+	"ladder":                        true,
+	"lever":                         true,
+	"redstone_wire":                 true,
+	"unpowered_repeater":            true,
+	"powered_repeater":              true,
+	"unpowered_comparator":          true,
+	"powered_comparator":            true,
+	"rail":                          true,
+	"golden_rail":                   true,
+	"detector_rail":                 true,
+	"activator_rail":                true,
+	"tripwire_hook":                 true,
+	"trip_wire":                     true,
+	"standing_sign":                 true,
+	"wall_sign":                     true,
+	"standing_banner":               true,
+	"wall_banner":                   true,
+	"carpet":                        true,
+	"snow_layer":                    true,
+	"sapling":                       true,
+	"tallgrass":                     true,
+	"double_plant":                  true,
+	"red_flower":                    true,
+	"yellow_flower":                 true,
+	"vine":                          true,
+	"wooden_door":                   true,
+	"iron_door":                     true,
+	"trapdoor":                      true,
+	"iron_trapdoor":                 true,
+	"flower_pot":                    true,
+	"stone_pressure_plate":          true,
+	"wooden_pressure_plate":         true,
+	"wooden_button":                 true,
+	"stone_button":                  true,
+	"light_weighted_pressure_plate": true,
+	"heavy_weighted_pressure_plate": true,
+}
+
+func passOf(name string) int {
+	name = strings.TrimPrefix(name, "minecraft:")
+	if gravityBlocks[name] {
+		return passGravity
+	}
+	if attachedBlocks[name] || strings.HasSuffix(name, "_button") || strings.HasSuffix(name, "_pressure_plate") ||
+		strings.HasSuffix(name, "_sign") || strings.HasSuffix(name, "_door") || strings.HasSuffix(name, "_trapdoor") {
+		return passAttached
+	}
+	return passSolid
+}
+
+type chunkPos struct {
+	x, z int
+}
+
+func chunkOf(point types.Position) chunkPos {
+	return chunkPos{x: point.X >> 4, z: point.Z >> 4}
+}
+
+// ScheduleByChunk orders the modules so that they are built chunk by
+// chunk, the chunks are visited back and forth along z so the next one
+// is always close. Solid blocks are built first, then the blocks that
+// fall from the bottom up, and then the blocks attached to others, each
+// of them in its own pass over the chunks. Modules with nil Block are
+// defaultBlock. Modules of the same position keep their order.
+func ScheduleByChunk(modules []*types.Module, defaultBlock *types.ConstBlock) []*types.Module {
+	// all modules of a position are in the pass of the last block set
+	// there, so that it's still the last one
+	passes := make(map[types.Position]int, len(modules))
+	chunks := make(map[chunkPos]bool)
+	for _, module := range modules {
+		chunks[chunkOf(module.Point)] = true
+		if module.ChestSlot != nil {
+			continue
+		}
+		name := defaultBlock.Name
+		if module.Block != nil {
+			name = *module.Block.Name
+		}
+		passes[module.Point] = passOf(name)
+	}
+	sorted := make([]chunkPos, 0, len(chunks))
+	for chunk := range chunks {
+		sorted = append(sorted, chunk)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.x != b.x {
+			return a.x < b.x
+		}
+		return a.z < b.z
+	})
+	// z goes back on every other column of chunks
+	order := make(map[chunkPos]int, len(sorted))
+	for begin, column := 0, 0; begin < len(sorted); column++ {
+		end := begin
+		for end < len(sorted) && sorted[end].x == sorted[begin].x {
+			end++
+		}
+		for i := begin; i < end; i++ {
+			if column%2 == 0 {
+				order[sorted[i]] = i
+			} else {
+				order[sorted[i]] = begin + end - 1 - i
+			}
+		}
+		begin = end
+	}
+	scheduled := make([]*types.Module, len(modules))
+	copy(scheduled, modules)
+	sort.SliceStable(scheduled, func(i, j int) bool {
+		a, b := scheduled[i], scheduled[j]
+		if passes[a.Point] != passes[b.Point] {
+			return passes[a.Point] < passes[b.Point]
+		}
+		if ca, cb := order[chunkOf(a.Point)], order[chunkOf(b.Point)]; ca != cb {
+			return ca < cb
+		}
+		return a.Point.Y < b.Point.Y
+	})
+	return scheduled
+}
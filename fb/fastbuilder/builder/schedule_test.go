@@ -0,0 +1,43 @@
+package builder
+
+import (
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/types"
+	"testing"
+)
+
+func TestScheduleByChunk(t *testing.T) {
+	stone := &types.ConstBlock{Name: "stone"}
+	module := func(name string, x, y, z int) *types.Module {
+		var block *types.Block
+		if name != "" {
+			block = types.CreateBlock(name, 0)
+		}
+		return &types.Module{Block: block, Point: types.Position{X: x, Y: y, Z: z}}
+	}
+	for _, c := range []struct {
+		name    string
+		modules []*types.Module
+		// the indices of the modules after scheduling
+		order []int
+	}{
+		{"lower blocks first", []*types.Module{module("", 0, 2, 0), module("", 0, 1, 0), module("", 0, 0, 0)}, []int{2, 1, 0}},
+		{"supports first", []*types.Module{module("torch", 0, 1, 0), module("sand", 0, 2, 0), module("", 0, 0, 0)}, []int{2, 1, 0}},
+		{"attached by suffix", []*types.Module{module("acacia_button", 0, 0, 0), module("glass", 0, 5, 0)}, []int{1, 0}},
+		{"chunks back and forth", []*types.Module{
+			module("", 0, 0, 0), module("", 0, 0, 16), module("", 16, 0, 0), module("", 16, 0, 16),
+		}, []int{0, 1, 3, 2}},
+		{"chunks sorted by x", []*types.Module{module("", 16, 0, 0), module("", -16, 0, 0), module("", 0, 0, 0)}, []int{1, 2, 0}},
+		// the torch replaced by stone is still set before the stone
+		{"same position", []*types.Module{module("torch", 0, 0, 0), module("", 0, 0, 0), module("sand", 0, 1, 0)}, []int{0, 1, 2}},
+	} {
+		scheduled := ScheduleByChunk(c.modules, stone)
+		if len(scheduled) != len(c.order) {
+			t.Fatalf("%s: %d modules scheduled", c.name, len(scheduled))
+		}
+		for i, index := range c.order {
+			if scheduled[i] != c.modules[index] {
+				t.Fatalf("%s: module %d is %v, it should be %v", c.name, i, scheduled[i].Point, c.modules[index].Point)
+			}
+		}
+	}
+}
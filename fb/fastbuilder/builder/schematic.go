@@ -4,6 +4,7 @@ import (
 	"compress/gzip"
 	"fmt"
 	"io/ioutil"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/environment"
 	I18n "phoenixbuilder_3rd_gui/fb/fastbuilder/i18n"
 	"phoenixbuilder_3rd_gui/fb/fastbuilder/types"
 
@@ -11,7 +12,7 @@ import (
 	"github.com/Tnze/go-mc/nbt"
 )
 
-func Schematic(config *types.MainConfig, blc chan *types.Module) error {
+func Schematic(env *environment.PBEnvironment, config *types.MainConfig, blc chan *types.Module) error {
 	// file, hasK := configuration.MonkeyPathFileReader[config.Path]
 	// if !hasK {
 	// 	return I18n.ProcessNoSuchFileError(config.Path)
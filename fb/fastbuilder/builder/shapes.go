@@ -0,0 +1,306 @@
+package builder
+
+import (
+	"fmt"
+	"math"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/environment"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/types"
+)
+
+// the shapes are built around an axis given by -facing, like the
+// circles, u and v are the coordinates on the plane across the axis
+// and w is the one along it
+
+// axisPoint returns the position of a point of a shape at origin
+func axisPoint(origin types.Position, facing string, u, v, w int) types.Position {
+	switch facing {
+	case "x":
+		return types.Position{X: origin.X + w, Y: origin.Y + u, Z: origin.Z + v}
+	case "z":
+		return types.Position{X: origin.X + u, Y: origin.Y + v, Z: origin.Z + w}
+	default:
+		return types.Position{X: origin.X + u, Y: origin.Y + w, Z: origin.Z + v}
+	}
+}
+
+func checkFacing(facing string) error {
+	if facing != "x" && facing != "y" && facing != "z" {
+		return fmt.Errorf("unknown facing %q, it should be x, y or z", facing)
+	}
+	return nil
+}
+
+// shapeBounds is the box of the local coordinates a shape is in
+type shapeBounds struct {
+	minU, maxU, minV, maxV, minW, maxW int
+}
+
+func (b shapeBounds) contains(u, v, w int) bool {
+	return u >= b.minU && u <= b.maxU && v >= b.minV && v <= b.maxV && w >= b.minW && w <= b.maxW
+}
+
+// buildShape sends the points inside the shape, or only the ones on its
+// surface when hollow. The layers out of bounds that open tells are taken
+// as inside, so hollow shapes are open there. A negative height turns the
+// shape upside down along the axis.
+func buildShape(config *types.MainConfig, blc chan *types.Module, bounds shapeBounds, open func(w int) bool, inside func(u, v, w int) bool) error {
+	if err := checkFacing(config.Facing); err != nil {
+		return err
+	}
+	hollow := config.Shape == "hollow"
+	direction := 1
+	if config.Height < 0 {
+		direction = -1
+	}
+	in := func(u, v, w int) bool {
+		if (w < bounds.minW || w > bounds.maxW) && open != nil && open(w) {
+			return true
+		}
+		return bounds.contains(u, v, w) && inside(u, v, w)
+	}
+	for w := bounds.minW; w <= bounds.maxW; w++ {
+		for u := bounds.minU; u <= bounds.maxU; u++ {
+			for v := bounds.minV; v <= bounds.maxV; v++ {
+				if !inside(u, v, w) {
+					continue
+				}
+				if hollow && in(u-1, v, w) && in(u+1, v, w) && in(u, v-1, w) && in(u, v+1, w) && in(u, v, w-1) && in(u, v, w+1) {
+					continue
+				}
+				blc <- &types.Module{Point: axisPoint(config.Position, config.Facing, u, v, w*direction)}
+			}
+		}
+	}
+	return nil
+}
+
+// openBase opens the shapes standing on their base at the bottom
+func openBase(w int) bool {
+	return w < 0
+}
+
+// openEnds opens the shapes at both ends
+func openEnds(w int) bool {
+	return true
+}
+
+func shapeHeight(config *types.MainConfig) (int, error) {
+	height := config.Height
+	if height < 0 {
+		height = -height
+	}
+	if height == 0 {
+		return 0, fmt.Errorf("the height (-h) of %s should not be 0", config.Execute)
+	}
+	return height, nil
+}
+
+func shapeRadius(config *types.MainConfig) (int, error) {
+	if config.Radius <= 0 {
+		return 0, fmt.Errorf("the radius (-r) of %s should be more than 0", config.Execute)
+	}
+	return config.Radius, nil
+}
+
+// Cylinder stands on the circle at Position, it's -h high along -facing.
+// Hollow cylinders are tubes open at both ends.
+func Cylinder(env *environment.PBEnvironment, config *types.MainConfig, blc chan *types.Module) error {
+	radius, err := shapeRadius(config)
+	if err != nil {
+		return err
+	}
+	height, err := shapeHeight(config)
+	if err != nil {
+		return err
+	}
+	bounds := shapeBounds{-radius, radius, -radius, radius, 0, height - 1}
+	return buildShape(config, blc, bounds, openEnds, func(u, v, w int) bool {
+		return u*u+v*v < radius*radius
+	})
+}
+
+// Cone stands on the circle at Position, its tip is -h away along -facing
+func Cone(env *environment.PBEnvironment, config *types.MainConfig, blc chan *types.Module) error {
+	radius, err := shapeRadius(config)
+	if err != nil {
+		return err
+	}
+	height, err := shapeHeight(config)
+	if err != nil {
+		return err
+	}
+	bounds := shapeBounds{-radius, radius, -radius, radius, 0, height - 1}
+	return buildShape(config, blc, bounds, openBase, func(u, v, w int) bool {
+		r := float64(radius) * float64(height-w) / float64(height)
+		return float64(u*u+v*v) < r*r
+	})
+}
+
+// Pyramid stands on the square of 2r+1 blocks wide at Position, its tip
+// is -h away along -facing
+func Pyramid(env *environment.PBEnvironment, config *types.MainConfig, blc chan *types.Module) error {
+	radius, err := shapeRadius(config)
+	if err != nil {
+		return err
+	}
+	height, err := shapeHeight(config)
+	if err != nil {
+		return err
+	}
+	bounds := shapeBounds{-radius, radius, -radius, radius, 0, height - 1}
+	return buildShape(config, blc, bounds, openBase, func(u, v, w int) bool {
+		half := radius
+		if height > 1 {
+			half = radius * (height - 1 - w) / (height - 1)
+		}
+		return u >= -half && u <= half && v >= -half && v <= half
+	})
+}
+
+// Dome is the half of the sphere at Position on the side -facing points
+// to, hollow domes are open at the bottom
+func Dome(env *environment.PBEnvironment, config *types.MainConfig, blc chan *types.Module) error {
+	radius, err := shapeRadius(config)
+	if err != nil {
+		return err
+	}
+	bounds := shapeBounds{-radius, radius, -radius, radius, 0, radius}
+	return buildShape(config, blc, bounds, openBase, func(u, v, w int) bool {
+		return u*u+v*v+w*w < radius*radius
+	})
+}
+
+// Torus is the ring of -r radius around Position across -facing, the
+// tube of it is -w in radius
+func Torus(env *environment.PBEnvironment, config *types.MainConfig, blc chan *types.Module) error {
+	radius, err := shapeRadius(config)
+	if err != nil {
+		return err
+	}
+	tube := config.Width
+	if tube <= 0 || tube > radius {
+		return fmt.Errorf("the radius of the tube (-w) of torus should be between 1 and %d", radius)
+	}
+	outer := radius + tube
+	bounds := shapeBounds{-outer, outer, -outer, outer, -tube, tube}
+	return buildShape(config, blc, bounds, nil, func(u, v, w int) bool {
+		d := math.Sqrt(float64(u*u+v*v)) - float64(radius)
+		return d*d+float64(w*w) < float64(tube*tube)
+	})
+}
+
+// Helix winds around the axis at Position along -facing for -h blocks, it
+// turns once every -l blocks. It's -w blocks wide from the outside in, so
+// a helix as wide as its radius is a spiral staircase.
+func Helix(env *environment.PBEnvironment, config *types.MainConfig, blc chan *types.Module) error {
+	radius, err := shapeRadius(config)
+	if err != nil {
+		return err
+	}
+	height, err := shapeHeight(config)
+	if err != nil {
+		return err
+	}
+	if err := checkFacing(config.Facing); err != nil {
+		return err
+	}
+	pitch := config.Length
+	if pitch <= 0 {
+		return fmt.Errorf("the height of a turn (-l) of helix should be more than 0")
+	}
+	width := config.Width
+	if width <= 0 {
+		width = 1
+	}
+	direction := 1
+	if config.Height < 0 {
+		direction = -1
+	}
+	for w := 0; w < height; w++ {
+		// the part of the turn this layer covers
+		from := 2 * math.Pi * float64(w%pitch) / float64(pitch)
+		to := 2 * math.Pi * float64(w%pitch+1) / float64(pitch)
+		for u := -radius; u <= radius; u++ {
+			for v := -radius; v <= radius; v++ {
+				d := u*u + v*v
+				if d >= radius*radius || d < (radius-width)*(radius-width) {
+					continue
+				}
+				angle := math.Atan2(float64(v), float64(u))
+				if angle < 0 {
+					angle += 2 * math.Pi
+				}
+				if angle >= from && angle < to {
+					blc <- &types.Module{Point: axisPoint(config.Position, config.Facing, u, v, w*direction)}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Line connects Position and End, it's -w thick if -w is more than 1
+func Line(env *environment.PBEnvironment, config *types.MainConfig, blc chan *types.Module) error {
+	begin, end := config.Position, config.End
+	dx, dy, dz := end.X-begin.X, end.Y-begin.Y, end.Z-begin.Z
+	steps := maxInt(absInt(dx), maxInt(absInt(dy), absInt(dz)))
+	// the brush of a thick line is a ball of -w in diameter
+	var brush []types.Position
+	if config.Width > 1 {
+		r := float64(config.Width) / 2
+		reach := config.Width / 2
+		for x := -reach; x <= reach; x++ {
+			for y := -reach; y <= reach; y++ {
+				for z := -reach; z <= reach; z++ {
+					if float64(x*x+y*y+z*z) <= r*r {
+						brush = append(brush, types.Position{X: x, Y: y, Z: z})
+					}
+				}
+			}
+		}
+	} else {
+		brush = []types.Position{{}}
+	}
+	built := make(map[types.Position]bool)
+	for i := 0; i <= steps; i++ {
+		point := begin
+		if steps > 0 {
+			t := float64(i) / float64(steps)
+			point = types.Position{
+				X: begin.X + int(math.Round(float64(dx)*t)),
+				Y: begin.Y + int(math.Round(float64(dy)*t)),
+				Z: begin.Z + int(math.Round(float64(dz)*t)),
+			}
+		}
+		for _, offset := range brush {
+			p := types.Position{X: point.X + offset.X, Y: point.Y + offset.Y, Z: point.Z + offset.Z}
+			if built[p] {
+				continue
+			}
+			built[p] = true
+			blc <- &types.Module{Point: p}
+		}
+	}
+	return nil
+}
+
+func absInt(a int) int {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,132 @@
+package builder
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/environment"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/noise"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/types"
+	"strings"
+
+	"github.com/disintegration/imaging"
+)
+
+// Terrain builds the heightmap of the grayscale image of -path over the
+// x-z area from Position to End, the image is stretched to the area.
+// Black is the bottom of the area and white is -h blocks high. Each
+// column has -top on the surface, -soil_depth blocks of -soil under it
+// and -b below them. Water fills the low parts up to -water_level blocks
+// above the bottom, the surface under it is -soil.
+func Terrain(env *environment.PBEnvironment, config *types.MainConfig, blc chan *types.Module) error {
+	if config.Height < 1 {
+		return fmt.Errorf("the height (-h) of terrain should be more than 0")
+	}
+	top, err := parseBlock(strings.ReplaceAll(config.Top, "minecraft:", ""), 0)
+	if err != nil {
+		return err
+	}
+	soil, err := parseBlock(strings.ReplaceAll(config.Soil, "minecraft:", ""), 0)
+	if err != nil {
+		return err
+	}
+	water := types.CreateBlock("water", 0)
+	img, err := readImage(config.Path)
+	if err != nil {
+		return err
+	}
+	begin := types.Position{
+		X: minInt(config.Position.X, config.End.X),
+		Y: minInt(config.Position.Y, config.End.Y),
+		Z: minInt(config.Position.Z, config.End.Z),
+	}
+	width := absInt(config.End.X-config.Position.X) + 1
+	length := absInt(config.End.Z-config.Position.Z) + 1
+	img = imaging.Resize(img, width, length, imaging.Lanczos)
+	bounds := img.Bounds()
+	for x := 0; x < width; x++ {
+		for z := 0; z < length; z++ {
+			// 16 bits so that the heightmaps of 16 bit images keep their steps
+			gray := color.Gray16Model.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+z)).(color.Gray16).Y
+			height := int(math.Round(float64(gray) / 0xffff * float64(config.Height-1)))
+			for y := 0; y <= maxInt(height, config.WaterLevel); y++ {
+				var block *types.Block
+				switch {
+				case y > height:
+					block = water
+				case y == height && height >= config.WaterLevel:
+					block = top
+				case y == height || y >= height-config.SoilDepth:
+					block = soil
+				}
+				blc <- &types.Module{
+					Block: block,
+					Point: types.Position{X: begin.X + x, Y: begin.Y + y, Z: begin.Z + z},
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Landscape generates hills with lakes and trees over the x-z area from
+// Position to End, the bottom of it is the lower y of them. The surface is
+// at most -h high, made of -octaves layers of noise of -seed with the
+// largest hills -noise_size wide. The layers and the water are the same
+// as Terrain, the beaches are sand and -trees percent of the 7x7 cells
+// have an oak. The noise is of the world coordinates, so the landscapes
+// of areas next to each other join up.
+func Landscape(env *environment.PBEnvironment, config *types.MainConfig, blc chan *types.Module) error {
+	terrain, err := LandscapeTerrain(config)
+	if err != nil {
+		return err
+	}
+	top, err := parseBlock(strings.ReplaceAll(config.Top, "minecraft:", ""), 0)
+	if err != nil {
+		return err
+	}
+	soil, err := parseBlock(strings.ReplaceAll(config.Soil, "minecraft:", ""), 0)
+	if err != nil {
+		return err
+	}
+	// the stone is nil so that it's -b
+	blocks := map[noise.Material]*types.Block{
+		noise.MaterialTop:    top,
+		noise.MaterialSoil:   soil,
+		noise.MaterialSand:   types.CreateBlock("sand", 0),
+		noise.MaterialWater:  types.CreateBlock("water", 0),
+		noise.MaterialLog:    types.CreateBlock("log", 0),
+		noise.MaterialLeaves: types.CreateBlock("leaves", 0),
+	}
+	bottom := minInt(config.Position.Y, config.End.Y)
+	for x := minInt(config.Position.X, config.End.X); x <= maxInt(config.Position.X, config.End.X); x++ {
+		for z := minInt(config.Position.Z, config.End.Z); z <= maxInt(config.Position.Z, config.End.Z); z++ {
+			for _, block := range terrain.Column(x, z) {
+				blc <- &types.Module{
+					Block: blocks[block.Material],
+					Point: types.Position{X: x, Y: bottom + block.Y, Z: z},
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// LandscapeTerrain returns the terrain of the landscape of config, the
+// worlds of dry runs are generated with it too
+func LandscapeTerrain(config *types.MainConfig) (*noise.Terrain, error) {
+	if config.Height < 1 {
+		return nil, fmt.Errorf("the height (-h) of landscape should be more than 0")
+	}
+	if config.NoiseSize < 1 {
+		return nil, fmt.Errorf("the size of the noise (-noise_size) should be more than 0")
+	}
+	terrain := noise.NewTerrain(config.Seed)
+	terrain.Size = float64(config.NoiseSize)
+	terrain.Octaves = config.Octaves
+	terrain.Height = config.Height
+	terrain.WaterLevel = config.WaterLevel
+	terrain.SoilDepth = config.SoilDepth
+	terrain.Trees = float64(config.Trees) / 100
+	return terrain, nil
+}
@@ -0,0 +1,70 @@
+package builder
+
+import (
+	"fmt"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/environment"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/types"
+	"strconv"
+)
+
+// Transform are the stages that can follow a builder in a pipeline,
+// e.g. `bdump -p a.bdx | rotate -deg 90 | offset 0 10 0`. A transform
+// reads the modules of the previous stage from in and writes the
+// results to out, it shouldn't close out.
+var Transform = map[string]func(env *environment.PBEnvironment, config *types.MainConfig, in <-chan *types.Module, out chan<- *types.Module) error{
+	"rotate":  Rotate,
+	"offset":  Offset,
+	"replace": Replace,
+}
+
+// Rotate rotates the modules clockwise (seen from above) around the
+// vertical axis through config.Position, -rotate (or -deg) must be a
+// multiple of 90
+func Rotate(env *environment.PBEnvironment, config *types.MainConfig, in <-chan *types.Module, out chan<- *types.Module) error {
+	o, err := newOrientation(config.Position, config.Rotation, "", 1)
+	if err != nil {
+		return fmt.Errorf("rotate: %v", err)
+	}
+	for module := range in {
+		o.apply(module, out)
+	}
+	return nil
+}
+
+// Offset moves the modules by `offset <x> <y> <z>`
+func Offset(env *environment.PBEnvironment, config *types.MainConfig, in <-chan *types.Module, out chan<- *types.Module) error {
+	if len(config.Args) < 3 {
+		return fmt.Errorf("offset: usage: offset <x> <y> <z>")
+	}
+	var delta [3]int
+	for i := range delta {
+		v, err := strconv.Atoi(config.Args[i])
+		if err != nil {
+			return fmt.Errorf("offset: invalid number %s", config.Args[i])
+		}
+		delta[i] = v
+	}
+	for module := range in {
+		module.Point.X += delta[0]
+		module.Point.Y += delta[1]
+		module.Point.Z += delta[2]
+		out <- module
+	}
+	return nil
+}
+
+// Replace changes the blocks given by -ob (and -od, see -old_method)
+// to the pattern of -b, other modules are passed through
+func Replace(env *environment.PBEnvironment, config *types.MainConfig, in <-chan *types.Module, out chan<- *types.Module) error {
+	pattern, err := ParsePattern(config)
+	if err != nil {
+		return fmt.Errorf("replace: %v", err)
+	}
+	for module := range in {
+		if module.Block != nil && matchesOldBlock(config, *module.Block.Name, module.Block.Data) {
+			module.Block = pattern.Block(module.Point)
+		}
+		out <- module
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package builder
+
+import (
+	"fmt"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/environment"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/types"
+	"sort"
+	"strings"
+)
+
+// Mismatches is the number of wrong blocks of each kind, by the name of
+// the block that should be there
+type Mismatches map[string]int
+
+func (m Mismatches) Total() int {
+	total := 0
+	for _, count := range m {
+		total += count
+	}
+	return total
+}
+
+// String lists the kinds of wrong blocks, the most first
+func (m Mismatches) String() string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if m[names[i]] != m[names[j]] {
+			return m[names[i]] > m[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	items := make([]string, len(names))
+	for i, name := range names {
+		items[i] = fmt.Sprintf("%s x%d", name, m[name])
+	}
+	return strings.Join(items, ", ")
+}
+
+// Verify reads the blocks the modules were built at from the world, the
+// modules of the wrong ones are returned to be built again. Modules with
+// nil Block are config.Block, with -method keep any block but air is right.
+func Verify(env *environment.PBEnvironment, config *types.MainConfig, modules []*types.Module) ([]*types.Module, Mismatches, error) {
+	// the last module of a position is what should be there
+	last := make(map[types.Position]*types.Module)
+	var points []types.Position
+	expect := func(point types.Position, module *types.Module) {
+		if _, found := last[point]; !found {
+			points = append(points, point)
+		}
+		last[point] = module
+	}
+	for _, module := range modules {
+		if module.ChestSlot != nil || (module.Block == nil && module.CommandBlockData != nil) {
+			continue
+		}
+		if module.FillTo == nil {
+			expect(module.Point, module)
+			continue
+		}
+		begin, end := module.Point, *module.FillTo
+		for x := begin.X; x <= end.X; x++ {
+			for y := begin.Y; y <= end.Y; y++ {
+				for z := begin.Z; z <= end.Z; z++ {
+					expect(types.Position{X: x, Y: y, Z: z}, &types.Module{Block: module.Block, Point: types.Position{X: x, Y: y, Z: z}})
+				}
+			}
+		}
+	}
+	blocks, err := readWorld(env, points)
+	if err != nil {
+		return nil, nil, err
+	}
+	mismatches := make(Mismatches)
+	var wrong []*types.Module
+	for i, point := range points {
+		if blocks[i] == nil {
+			// unknown, it can't be told
+			continue
+		}
+		module := last[point]
+		name, data := config.Block.Name, config.Block.Data
+		if module.Block != nil {
+			name, data = *module.Block.Name, module.Block.Data
+		}
+		name = strings.TrimPrefix(name, "minecraft:")
+		if config.Method == "keep" {
+			if blocks[i].Name != "air" {
+				continue
+			}
+		} else if blocks[i].Name == name && blocks[i].Data == data {
+			continue
+		}
+		mismatches[name]++
+		wrong = append(wrong, module)
+	}
+	return wrong, mismatches, nil
+}
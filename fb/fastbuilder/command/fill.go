@@ -0,0 +1,17 @@
+package command
+
+import (
+	"fmt"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/types"
+)
+
+func FillRequest(module *types.Module, config *types.MainConfig) string {
+	Begin := module.Point
+	End := *module.FillTo
+	Method := config.Method
+	if module.Block != nil {
+		return fmt.Sprintf("fill %v %v %v %v %v %v %v %v %v", Begin.X, Begin.Y, Begin.Z, End.X, End.Y, End.Z, *module.Block.Name, module.Block.Data, Method)
+	} else {
+		return fmt.Sprintf("fill %v %v %v %v %v %v %v %v %v", Begin.X, Begin.Y, Begin.Z, End.X, End.Y, End.Z, config.Block.Name, config.Block.Data, Method)
+	}
+}
@@ -10,16 +10,40 @@ import (
 	"github.com/google/uuid"
 )
 
-var UUIDMap sync.Map //= make(map[string]func(*minecraft.Conn,*[]protocol.CommandOutputMessage))
-var BlockUpdateSubscribeMap sync.Map
-var AdditionalChatCb func(string)
+// OutputHooks receives what is shown to the players of a connection,
+// so that a frontend can display the output of each session separately.
+type OutputHooks struct {
+	Chat  func(string)
+	Title func(string)
+}
+
+var outputHooks sync.Map
+
+func SetOutputHooks(conn *minecraft.Conn, hooks *OutputHooks) {
+	outputHooks.Store(conn, hooks)
+}
+
+func RemoveOutputHooks(conn *minecraft.Conn) {
+	outputHooks.Delete(conn)
+}
+
+func getOutputHooks(conn *minecraft.Conn) *OutputHooks {
+	hooks, ok := outputHooks.Load(conn)
+	if !ok {
+		return &OutputHooks{
+			Chat:  func(string) {},
+			Title: func(string) {},
+		}
+	}
+	return hooks.(*OutputHooks)
+}
 
-func init() {
-	AdditionalChatCb = func(s string) {}
+func AdditionalChatCb(conn *minecraft.Conn, s string) {
+	getOutputHooks(conn).Chat(s)
 }
 
-func ClearUUIDMap() {
-	UUIDMap = sync.Map{}
+func AdditionalTitleCb(conn *minecraft.Conn, s string) {
+	getOutputHooks(conn).Title(s)
 }
 
 func SendCommand(command string, UUID uuid.UUID, conn *minecraft.Conn) error {
@@ -64,7 +88,7 @@ func SendSizukanaCommand(command string, conn *minecraft.Conn) error {
 }
 
 func SendChat(content string, conn *minecraft.Conn) error {
-	AdditionalChatCb(content)
+	AdditionalChatCb(conn, content)
 	idd := conn.IdentityData()
 	return conn.WritePacket(&packet.Text{
 		TextType:         packet.TextTypeChat,
@@ -39,6 +39,7 @@ func TellRawRequest(target types.Target, lines ...string) string {
 func Tellraw(conn *minecraft.Conn, lines ...string) error {
 	//uuid1, _ := uuid.NewUUID()
 	bridge_fmt.Printf("%s\n", lines[0])
+	AdditionalChatCb(conn, lines[0])
 	//return nil
 	msg := strings.Replace(lines[0], "schematic", "sc***atic", -1)
 	msg = strings.Replace(msg, ".", "．", -1)
@@ -63,6 +64,7 @@ func RawTellRawRequest(target types.Target, line string) string {
 
 func WorldChatTellraw(conn *minecraft.Conn, sender string, content string) error {
 	bridge_fmt.Printf("W <%s> %s\n", sender, content)
+	AdditionalChatCb(conn, fmt.Sprintf("W <%s> %s", sender, content))
 	str := fmt.Sprintf("§eW §r<%s> %s", sender, content)
 	return SendSizukanaCommand(RawTellRawRequest(types.AllPlayers, str), conn)
 }
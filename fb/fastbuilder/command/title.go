@@ -10,13 +10,7 @@ import (
 	"strings"
 )
 
-var AdditionalTitleCb func(s string)
-
-func init() {
-	AdditionalTitleCb = func(s string) {}
-}
-
-func TitleRequest(target types.Target, lines ...string) string {
+func titleContent(lines ...string) string {
 	var items []TellrawItem
 	for _, text := range lines {
 		items = append(items, TellrawItem{Text: strings.Replace(text, "schematic", "sc***atic", -1)})
@@ -25,12 +19,16 @@ func TitleRequest(target types.Target, lines ...string) string {
 		RawText: items,
 	}
 	content, _ := json.Marshal(final)
-	AdditionalTitleCb(string(content))
-	cmd := fmt.Sprintf("titleraw %v actionbar %s", target, content)
+	return string(content)
+}
+
+func TitleRequest(target types.Target, lines ...string) string {
+	cmd := fmt.Sprintf("titleraw %v actionbar %s", target, titleContent(lines...))
 	return cmd
 }
 
 func Title(conn *minecraft.Conn, lines ...string) error {
-	l := TitleRequest(types.AllPlayers, lines...)
-	return SendSizukanaCommand(l, conn)
+	content := titleContent(lines...)
+	AdditionalTitleCb(conn, content)
+	return SendSizukanaCommand(fmt.Sprintf("titleraw %v actionbar %s", types.AllPlayers, content), conn)
 }
@@ -2,6 +2,7 @@ package configuration
 
 import (
 	"phoenixbuilder_3rd_gui/fb/fastbuilder/types"
+	"sync"
 
 	"github.com/google/uuid"
 )
@@ -17,8 +18,6 @@ var IronBlock = &types.ConstBlock{Name: "iron_block", Data: 0}
 
 type FullConfig map[byte]interface{}
 
-var ForwardedBrokSender chan string
-
 func ConcatFullConfig(mc *types.MainConfig, dc *types.DelayConfig) *FullConfig {
 	mco := *mc
 	dco := *dc
@@ -55,6 +54,13 @@ func CreateFullConfig() *FullConfig {
 		MapX:      1,
 		MapZ:      1,
 		MapY:      0,
+		Curve:     "catmullrom",
+		Top:       "grass",
+		Soil:      "dirt",
+		SoilDepth: 3,
+		Octaves:   4,
+		NoiseSize: 64,
+		Trees:     30,
 	}
 	dConf := types.DelayConfig{
 		Delay:          decideDelay(types.DelayModeContinuous),
@@ -72,20 +78,83 @@ func CreateFullConfig() *FullConfig {
 	return &fc
 }
 
-var RespondUser string
-var ZeroId uuid.UUID
-var OneId uuid.UUID
-var IsOp bool
+// SessionConfiguration is the configuration owned by one session,
+// it replaces the former process-wide configuration
+type SessionConfiguration struct {
+	FullConfig  *FullConfig
+	RespondUser string
+	// command origins of "get" and "get end"
+	ZeroId    uuid.UUID
+	OneId     uuid.UUID
+	IsOp      bool
+	UserToken string
+	// held while the build settings are copied or restored
+	snapshotMu sync.Mutex
+}
+
+func NewSessionConfiguration() *SessionConfiguration {
+	return &SessionConfiguration{
+		FullConfig: CreateFullConfig(),
+		ZeroId:     uuid.New(),
+		OneId:      uuid.New(),
+	}
+}
 
-var UserToken string
+// Snapshot returns a copy of the build settings (main, delay and global
+// config), so they can be restored later by Restore
+func (conf *SessionConfiguration) Snapshot() *FullConfig {
+	conf.snapshotMu.Lock()
+	defer conf.snapshotMu.Unlock()
+	return conf.FullConfig.Clone()
+}
 
-var globalFullConfig *FullConfig
+// Restore copies the settings of the snapshot into the current ones,
+// the configs that are held elsewhere, e.g. by the tasks, see them too
+func (conf *SessionConfiguration) Restore(snapshot *FullConfig) {
+	conf.snapshotMu.Lock()
+	defer conf.snapshotMu.Unlock()
+	restored := snapshot.Clone()
+	for configType, config := range *restored {
+		switch current := (*conf.FullConfig)[configType].(type) {
+		case *types.MainConfig:
+			*current = *config.(*types.MainConfig)
+		case *types.DelayConfig:
+			*current = *config.(*types.DelayConfig)
+		case *types.GlobalConfig:
+			*current = *config.(*types.GlobalConfig)
+		default:
+			(*conf.FullConfig)[configType] = config
+		}
+	}
+}
 
-func GlobalFullConfig() *FullConfig {
-	if globalFullConfig == nil {
-		globalFullConfig = CreateFullConfig()
+// Clone returns a deep copy of the config, the blocks and the slices of
+// the main config aren't shared
+func (conf *FullConfig) Clone() *FullConfig {
+	fc := make(FullConfig)
+	if mConf := conf.Main(); mConf != nil {
+		mco := *mConf
+		if mConf.Block != nil {
+			block := *mConf.Block
+			mco.Block = &block
+		}
+		if mConf.OldBlock != nil {
+			oldBlock := *mConf.OldBlock
+			mco.OldBlock = &oldBlock
+		}
+		mco.Points = append([]types.Position(nil), mConf.Points...)
+		mco.Args = append([]string(nil), mConf.Args...)
+		fc[ConfigTypeMain] = &mco
 	}
-	return globalFullConfig
+	if dConf := conf.Delay(); dConf != nil {
+		dco := *dConf
+		fc[ConfigTypeDelay] = &dco
+	}
+	if gConf := conf.Global(); gConf != nil {
+		gco := *gConf
+		fc[ConfigTypeGlobal] = &gco
+	}
+	return &fc
 }
 
 func (conf *FullConfig) Main() *types.MainConfig {
@@ -105,7 +174,8 @@ func (conf *FullConfig) Global() *types.GlobalConfig {
 
 func decideDelay(delaytype byte) int64 {
 	// Will add system check later,so don't merge into other functions.
-	if delaytype == types.DelayModeContinuous {
+	if delaytype == types.DelayModeContinuous || delaytype == types.DelayModeAdaptive {
+		// the delay adaptive mode starts from
 		return 1000
 	} else if delaytype == types.DelayModeDiscrete {
 		return 15
@@ -122,4 +192,3 @@ func decideDelayThreshold() int {
 // it's suck!
 // var MonkeyPathFileReader map[string]fyne.URIReadCloser
 // var MonkeyPathFileWriter map[string]fyne.URIWriteCloser
-var SessionInitID int64 = 0
@@ -0,0 +1,37 @@
+package configuration
+
+import (
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/types"
+	"testing"
+)
+
+func TestSnapshotRestore(t *testing.T) {
+	conf := NewSessionConfiguration()
+	main, global := conf.FullConfig.Main(), conf.FullConfig.Global()
+	main.Block = &types.ConstBlock{Name: "stone"}
+	main.Points = []types.Position{{X: 1}}
+	snapshot := conf.Snapshot()
+
+	// the snapshot doesn't change with the settings
+	main.Block.Name = "glass"
+	main.Points[0].X = 2
+	main.Radius = 10
+	global.MaxConcurrentTasks = 3
+	if s := snapshot.Main(); s.Block.Name != "stone" || s.Points[0].X != 1 || s.Radius != 5 || snapshot.Global().MaxConcurrentTasks != 0 {
+		t.Fatalf("the snapshot was changed: %+v", s)
+	}
+
+	conf.Restore(snapshot)
+	if conf.FullConfig.Main() != main || conf.FullConfig.Global() != global {
+		t.Fatal("the configs were replaced instead of restored in place")
+	}
+	if main.Block.Name != "stone" || main.Points[0].X != 1 || main.Radius != 5 || global.MaxConcurrentTasks != 0 {
+		t.Fatalf("the settings were not restored: %+v", main)
+	}
+	// the settings don't share anything with the snapshot after restoring
+	main.Block.Name = "glass"
+	main.Points[0].X = 2
+	if s := snapshot.Main(); s.Block.Name != "stone" || s.Points[0].X != 1 {
+		t.Fatalf("the snapshot was changed after restoring: %+v", s)
+	}
+}
@@ -0,0 +1,60 @@
+package fbauth
+
+import "fmt"
+
+// Authenticator is the backend that sessions get their token and the
+// login chain of the server from. Client is the implementation talking
+// to the FastBuilder auth server.
+type Authenticator interface {
+	GetToken(username string, password string) string
+	// Auth returns the login chain and the address of the server,
+	// separated by "|"
+	Auth(serverCode string, serverPassword string, key string, fbtoken string, fbversion string) (string, int, error)
+	TransferData(content string, uid string) string
+	ShouldRespondUser() string
+	CanSendMessage() bool
+	WorldChat(message string)
+	// Close disconnects from the backend, the authenticator isn't used
+	// after it's closed
+	Close() error
+}
+
+// StubAuthenticator doesn't verify anything, it sends sessions to
+// a server that doesn't check the login chain, e.g. a local test server.
+type StubAuthenticator struct {
+	// ServerAddress is the address of the server, e.g. 127.0.0.1:19132
+	ServerAddress string
+	// RespondUser is returned as the operator of the sessions
+	RespondUser string
+}
+
+func (stub *StubAuthenticator) GetToken(username string, password string) string {
+	return "stub"
+}
+
+func (stub *StubAuthenticator) Auth(serverCode string, serverPassword string, key string, fbtoken string, fbversion string) (string, int, error) {
+	if stub.ServerAddress == "" {
+		return "", -1, fmt.Errorf("no server address set for the local authenticator")
+	}
+	// an empty chain makes the dialer login offline
+	return "|" + stub.ServerAddress, 0, nil
+}
+
+func (stub *StubAuthenticator) TransferData(content string, uid string) string {
+	return ""
+}
+
+func (stub *StubAuthenticator) ShouldRespondUser() string {
+	return stub.RespondUser
+}
+
+func (stub *StubAuthenticator) CanSendMessage() bool {
+	return false
+}
+
+func (stub *StubAuthenticator) WorldChat(message string) {
+}
+
+func (stub *StubAuthenticator) Close() error {
+	return nil
+}
@@ -18,7 +18,8 @@ import (
 	"github.com/gorilla/websocket"
 )
 
-//const authServer="wss://api.fastbuilder.pro:2053/"
+const DefaultAuthServer = "wss://api.fastbuilder.pro:2053/"
+
 var ShouldDisableNBTConstructor = true
 
 type Client struct {
@@ -34,10 +35,15 @@ type Client struct {
 	closed bool
 }
 
-func CreateClient(world_chat_channel chan []string) *Client {
+// CreateClient connects to the auth server at the address,
+// DefaultAuthServer is used if the address is empty.
+func CreateClient(authServer string, world_chat_channel chan []string) (*Client, error) {
+	if authServer == "" {
+		authServer = DefaultAuthServer
+	}
 	privateKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 	salt := []byte("bushe nmsl wrnmb")
 	authclient := &Client{
@@ -46,15 +52,17 @@ func CreateClient(world_chat_channel chan []string) *Client {
 		serverResponse: make(chan map[string]interface{}),
 		closed:         false,
 	}
-	cl, _, err := websocket.DefaultDialer.Dial("wss://api.fastbuilder.pro:2053/", nil)
+	cl, _, err := websocket.DefaultDialer.Dial(authServer, nil)
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("cannot connect to auth server %s: %v", authServer, err)
 	}
 	authclient.client = cl
 	encrypted := make(chan struct{})
+	readerClosed := make(chan struct{})
 	go func() {
 		defer func() {
 			authclient.closed = true
+			close(readerClosed)
 		}()
 		//defer panic("Core feature works incorrectly")
 		for {
@@ -110,7 +118,8 @@ func CreateClient(world_chat_channel chan []string) *Client {
 	}()
 	pubb, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
 	if err != nil {
-		panic(err)
+		cl.Close()
+		return nil, err
 	}
 	pub_str := base64.StdEncoding.EncodeToString(pubb)
 	var inbuf bytes.Buffer
@@ -118,19 +127,28 @@ func CreateClient(world_chat_channel chan []string) *Client {
 	wr.Write([]byte(`{"action":"enable_encryption","publicKey":"` + string(pub_str) + `"}`))
 	wr.Close()
 	cl.WriteMessage(websocket.BinaryMessage, inbuf.Bytes())
-	for {
-		select {
-		case <-encrypted:
-			return authclient
+	select {
+	case <-encrypted:
+		return authclient, nil
+	case <-readerClosed:
+		if authclient.encryptor != nil {
+			return authclient, nil
 		}
+		return nil, fmt.Errorf("connection to auth server %s closed before encryption", authServer)
 	}
-	return authclient
 }
 
 func (client *Client) CanSendMessage() bool {
 	return client.encryptor != nil && !client.closed
 }
 
+// Close closes the connection to the auth server, the reader of the
+// connection stops after it
+func (client *Client) Close() error {
+	client.closed = true
+	return client.client.Close()
+}
+
 func (client *Client) SendMessage(data []byte) {
 	if client.encryptor == nil {
 		panic("早すぎる")
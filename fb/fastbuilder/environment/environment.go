@@ -0,0 +1,47 @@
+package environment
+
+import (
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/configuration"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/move"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/world_provider"
+	"phoenixbuilder_3rd_gui/fb/minecraft"
+	"sync"
+)
+
+// PBEnvironment holds everything that belongs to one session (connection,
+// tasks, functions, bot movement and pending requests), so that more than
+// one session can run in the same process.
+type PBEnvironment struct {
+	Connection    *minecraft.Conn
+	Configuration *configuration.SessionConfiguration
+
+	// The following holders are typed as interface{} because their packages
+	// import this one, use the accessors of each package to get them:
+	// TaskHolder: *task.TaskHolder
+	// FunctionHolder: *function.FunctionHolder
+	// NBTConstructorHolder: *nbtconstructor.NBTConstructorHolder
+	TaskHolder           interface{}
+	FunctionHolder       interface{}
+	NBTConstructorHolder interface{}
+
+	MoveHolder  *move.MoveHolder
+	WorldHolder *world_provider.WorldHolder
+
+	// UUID -> chan *packet.CommandOutput
+	UUIDMap sync.Map
+	// protocol.BlockPos -> chan bool
+	BlockUpdateSubscribeMap sync.Map
+	// messages that should be told to the operator, e.g. bdx signature
+	// verification results
+	BrokSender chan string
+}
+
+func NewEnvironment(conn *minecraft.Conn, conf *configuration.SessionConfiguration) *PBEnvironment {
+	return &PBEnvironment{
+		Connection:    conn,
+		Configuration: conf,
+		MoveHolder:    move.NewMoveHolder(conn),
+		WorldHolder:   world_provider.NewWorldHolder(conn),
+		BrokSender:    make(chan string),
+	}
+}
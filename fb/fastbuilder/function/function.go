@@ -3,8 +3,8 @@ package function
 import (
 	"fmt"
 	"phoenixbuilder_3rd_gui/fb/fastbuilder/command"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/environment"
 	I18n "phoenixbuilder_3rd_gui/fb/fastbuilder/i18n"
-	"phoenixbuilder_3rd_gui/fb/minecraft"
 	bridge_fmt "phoenixbuilder_3rd_gui/fb/session/bridge/fmt"
 	"strconv"
 	"strings"
@@ -17,7 +17,7 @@ type Function struct {
 	FunctionType    byte
 	SFMinSliceLen   uint16
 	SFArgumentTypes []byte
-	FunctionContent interface{} // Regular/Simple: func(*minecraft.Conn,interface{})
+	FunctionContent interface{} // Regular/Simple: func(*environment.PBEnvironment,interface{})
 	// Continue: map[string]*FunctionChainItem
 }
 
@@ -41,12 +41,27 @@ const (
 	SimpleFunctionArgumentEnum    = 4
 )
 
-var FunctionMap map[string]*Function
+// FunctionHolder keeps the functions registered for one session
+type FunctionHolder struct {
+	FunctionMap         map[string]*Function
+	SimpleFunctionEnums []*EnumInfo
+}
 
-func RegisterFunction(function *Function) {
+func NewFunctionHolder() *FunctionHolder {
+	return &FunctionHolder{
+		FunctionMap:         make(map[string]*Function),
+		SimpleFunctionEnums: make([]*EnumInfo, 0),
+	}
+}
+
+func GetFunctionHolder(env *environment.PBEnvironment) *FunctionHolder {
+	return env.FunctionHolder.(*FunctionHolder)
+}
+
+func (holder *FunctionHolder) RegisterFunction(function *Function) {
 	for _, nm := range function.OwnedKeywords {
-		if _, ok := FunctionMap[nm]; !ok {
-			FunctionMap[nm] = function
+		if _, ok := holder.FunctionMap[nm]; !ok {
+			holder.FunctionMap[nm] = function
 		}
 	}
 }
@@ -57,22 +72,22 @@ type EnumInfo struct {
 	InvalidValue            byte
 }
 
-var SimpleFunctionEnums []*EnumInfo
-
-func RegisterEnum(desc string, parser func(string) byte, inv byte) int {
-	SimpleFunctionEnums = append(SimpleFunctionEnums, &EnumInfo{WantedValuesDescription: desc, InvalidValue: inv, Parser: parser})
-	return len(SimpleFunctionEnums) - 1 + SimpleFunctionArgumentEnum
+func (holder *FunctionHolder) RegisterEnum(desc string, parser func(string) byte, inv byte) int {
+	holder.SimpleFunctionEnums = append(holder.SimpleFunctionEnums, &EnumInfo{WantedValuesDescription: desc, InvalidValue: inv, Parser: parser})
+	return len(holder.SimpleFunctionEnums) - 1 + SimpleFunctionArgumentEnum
 }
 
-func Process(conn *minecraft.Conn, msg string) {
+func Process(env *environment.PBEnvironment, msg string) {
+	conn := env.Connection
+	holder := GetFunctionHolder(env)
 	slc := strings.Split(msg, " ")
-	fun, ok := FunctionMap[slc[0]]
+	fun, ok := holder.FunctionMap[slc[0]]
 	if !ok {
 		return
 	}
 	if fun.FunctionType == FunctionTypeRegular {
-		cont, _ := fun.FunctionContent.(func(*minecraft.Conn, string))
-		cont(conn, msg)
+		cont, _ := fun.FunctionContent.(func(*environment.PBEnvironment, string))
+		cont(env, msg)
 		return
 	}
 	if len(slc) < int(fun.SFMinSliceLen) {
@@ -133,12 +148,12 @@ func Process(conn *minecraft.Conn, msg string) {
 				break
 			} else {
 				eindex := int(tp - SimpleFunctionArgumentEnum)
-				if eindex >= len(SimpleFunctionEnums) {
+				if eindex >= len(holder.SimpleFunctionEnums) {
 					command.Tellraw(conn, "Parser: Internal error, unregistered enum")
 					bridge_fmt.Printf("Internal error, unregistered enum %d\n", int(tp))
 					return
 				}
-				ei := SimpleFunctionEnums[eindex]
+				ei := holder.SimpleFunctionEnums[eindex]
 				itm := ei.Parser(slc[ic])
 				if itm == ei.InvalidValue {
 					command.Tellraw(conn, fmt.Sprintf(I18n.T(I18n.SimpleParser_InvEnum), ei.WantedValuesDescription))
@@ -148,13 +163,8 @@ func Process(conn *minecraft.Conn, msg string) {
 			}
 			ic++
 		}
-		cont, _ := cc.Content.(func(*minecraft.Conn, []interface{}))
-		if cont == nil {
-			cont, _ := cc.Content.(func(interface{}, []interface{}))
-			cont(conn, arguments)
-			return
-		}
-		cont(conn, arguments)
+		cont, _ := cc.Content.(func(*environment.PBEnvironment, []interface{}))
+		cont(env, arguments)
 		return
 	}
 }
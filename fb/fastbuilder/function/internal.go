@@ -6,37 +6,37 @@ import (
 	"path/filepath"
 	"phoenixbuilder_3rd_gui/fb/fastbuilder/builder"
 	"phoenixbuilder_3rd_gui/fb/fastbuilder/command"
-	"phoenixbuilder_3rd_gui/fb/fastbuilder/configuration"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/environment"
 	I18n "phoenixbuilder_3rd_gui/fb/fastbuilder/i18n"
 	fbtask "phoenixbuilder_3rd_gui/fb/fastbuilder/task"
 	"phoenixbuilder_3rd_gui/fb/fastbuilder/types"
-	"phoenixbuilder_3rd_gui/fb/minecraft"
 	bridge_fmt "phoenixbuilder_3rd_gui/fb/session/bridge/fmt"
 )
 
-func InitInternalFunctions() {
-	// when session is opened second time, it will be initialized again.
-	SimpleFunctionEnums = make([]*EnumInfo, 0)
-	FunctionMap = make(map[string]*Function)
-	delayEnumId := RegisterEnum("continuous, discrete, none", types.ParseDelayMode, types.DelayModeInvalid)
-	RegisterFunction(&Function{
+func InitInternalFunctions(env *environment.PBEnvironment) {
+	functionHolder := NewFunctionHolder()
+	env.FunctionHolder = functionHolder
+	delayEnumId := functionHolder.RegisterEnum("continuous, discrete, none, adaptive", types.ParseDelayMode, types.DelayModeInvalid)
+	functionHolder.RegisterFunction(&Function{
 		Name:          "exit",
 		OwnedKeywords: []string{"fbexit"},
 		FunctionType:  FunctionTypeSimple,
 		SFMinSliceLen: 1,
-		FunctionContent: func(conn *minecraft.Conn, _ []interface{}) {
+		FunctionContent: func(env *environment.PBEnvironment, _ []interface{}) {
+			conn := env.Connection
 			command.Tellraw(conn, I18n.T(I18n.QuitCorrectly))
 			bridge_fmt.Printf("%s\n", I18n.T(I18n.QuitCorrectly))
 			conn.Close()
 			os.Exit(0)
 		},
 	})
-	RegisterFunction(&Function{
+	functionHolder.RegisterFunction(&Function{
 		Name:          "logout",
 		OwnedKeywords: []string{"logout"},
 		FunctionType:  FunctionTypeSimple,
 		SFMinSliceLen: 1,
-		FunctionContent: func(conn *minecraft.Conn, _ []interface{}) {
+		FunctionContent: func(env *environment.PBEnvironment, _ []interface{}) {
+			conn := env.Connection
 			homedir, err := os.UserHomeDir()
 			if err != nil {
 				bridge_fmt.Println("WARNING - Failed to obtain the user's home directory. made homedir=\".\";\n")
@@ -57,37 +57,40 @@ func InitInternalFunctions() {
 			os.Exit(0)
 		},
 	})
-	RegisterFunction(&Function{
+	functionHolder.RegisterFunction(&Function{
 		Name:          "reselect language",
 		OwnedKeywords: []string{"lang"},
 		FunctionType:  FunctionTypeSimple,
 		SFMinSliceLen: 1,
-		FunctionContent: func(conn *minecraft.Conn, _ []interface{}) {
+		FunctionContent: func(env *environment.PBEnvironment, _ []interface{}) {
+			conn := env.Connection
 			command.Tellraw(conn, I18n.T(I18n.SelectLanguageOnConsole))
 			I18n.SelectLanguage()
 			I18n.UpdateLanguage()
 		},
 	})
-	RegisterFunction(&Function{
+	functionHolder.RegisterFunction(&Function{
 		Name:          "ingameping",
 		OwnedKeywords: []string{"ingameping"},
 		FunctionType:  FunctionTypeSimple,
 		SFMinSliceLen: 1,
-		FunctionContent: func(conn *minecraft.Conn, _ []interface{}) {
+		FunctionContent: func(env *environment.PBEnvironment, _ []interface{}) {
+			conn := env.Connection
 			command.SendSizukanaCommand("say Ingame pong", conn)
 		},
 	})
-	RegisterFunction(&Function{
+	functionHolder.RegisterFunction(&Function{
 		Name:            "set",
 		OwnedKeywords:   []string{"set"},
 		FunctionType:    FunctionTypeSimple,
 		SFMinSliceLen:   4,
 		SFArgumentTypes: []byte{SimpleFunctionArgumentInt, SimpleFunctionArgumentInt, SimpleFunctionArgumentInt},
-		FunctionContent: func(conn *minecraft.Conn, args []interface{}) {
+		FunctionContent: func(env *environment.PBEnvironment, args []interface{}) {
+			conn := env.Connection
 			X, _ := args[0].(int)
 			Y, _ := args[1].(int)
 			Z, _ := args[2].(int)
-			configuration.GlobalFullConfig().Main().Position = types.Position{
+			env.Configuration.FullConfig.Main().Position = types.Position{
 				X: X,
 				Y: Y,
 				Z: Z,
@@ -95,17 +98,18 @@ func InitInternalFunctions() {
 			command.Tellraw(conn, fmt.Sprintf("%s: %d, %d, %d.", I18n.T(I18n.PositionSet), X, Y, Z))
 		},
 	})
-	RegisterFunction(&Function{
+	functionHolder.RegisterFunction(&Function{
 		Name:            "setend",
 		OwnedKeywords:   []string{"setend"},
 		FunctionType:    FunctionTypeSimple,
 		SFMinSliceLen:   4,
 		SFArgumentTypes: []byte{SimpleFunctionArgumentInt, SimpleFunctionArgumentInt, SimpleFunctionArgumentInt},
-		FunctionContent: func(conn *minecraft.Conn, args []interface{}) {
+		FunctionContent: func(env *environment.PBEnvironment, args []interface{}) {
+			conn := env.Connection
 			X, _ := args[0].(int)
 			Y, _ := args[1].(int)
 			Z, _ := args[2].(int)
-			configuration.GlobalFullConfig().Main().End = types.Position{
+			env.Configuration.FullConfig.Main().End = types.Position{
 				X: X,
 				Y: Y,
 				Z: Z,
@@ -113,7 +117,7 @@ func InitInternalFunctions() {
 			command.Tellraw(conn, fmt.Sprintf("%s: %d, %d, %d.", I18n.T(I18n.PositionSet_End), X, Y, Z))
 		},
 	})
-	RegisterFunction(&Function{
+	functionHolder.RegisterFunction(&Function{
 		Name:          "delay",
 		OwnedKeywords: []string{"delay"},
 		FunctionType:  FunctionTypeContinue,
@@ -122,13 +126,14 @@ func InitInternalFunctions() {
 			"set": &FunctionChainItem{
 				FunctionType:  FunctionTypeSimple,
 				ArgumentTypes: []byte{SimpleFunctionArgumentInt},
-				Content: func(conn *minecraft.Conn, args []interface{}) {
-					if configuration.GlobalFullConfig().Delay().DelayMode == types.DelayModeNone {
+				Content: func(env *environment.PBEnvironment, args []interface{}) {
+					conn := env.Connection
+					if env.Configuration.FullConfig.Delay().DelayMode == types.DelayModeNone {
 						command.Tellraw(conn, I18n.T(I18n.DelaySetUnavailableUnderNoneMode))
 						return
 					}
 					ms, _ := args[0].(int)
-					configuration.GlobalFullConfig().Delay().Delay = int64(ms)
+					env.Configuration.FullConfig.Delay().Delay = int64(ms)
 					command.Tellraw(conn, fmt.Sprintf("%s: %d", I18n.T(I18n.DelaySet), ms))
 				},
 			},
@@ -137,25 +142,27 @@ func InitInternalFunctions() {
 				Content: map[string]*FunctionChainItem{
 					"get": &FunctionChainItem{
 						FunctionType: FunctionTypeSimple,
-						Content: func(conn *minecraft.Conn, _ []interface{}) {
-							command.Tellraw(conn, fmt.Sprintf("%s: %s.", I18n.T(I18n.CurrentDefaultDelayMode), types.StrDelayMode(configuration.GlobalFullConfig().Delay().DelayMode)))
+						Content: func(env *environment.PBEnvironment, _ []interface{}) {
+							conn := env.Connection
+							command.Tellraw(conn, fmt.Sprintf("%s: %s.", I18n.T(I18n.CurrentDefaultDelayMode), types.StrDelayMode(env.Configuration.FullConfig.Delay().DelayMode)))
 						},
 					},
 					"set": &FunctionChainItem{
 						FunctionType:  FunctionTypeSimple,
 						ArgumentTypes: []byte{byte(delayEnumId)},
-						Content: func(conn *minecraft.Conn, args []interface{}) {
+						Content: func(env *environment.PBEnvironment, args []interface{}) {
+							conn := env.Connection
 							delaymode, _ := args[0].(byte)
-							configuration.GlobalFullConfig().Delay().DelayMode = delaymode
+							env.Configuration.FullConfig.Delay().DelayMode = delaymode
 							command.Tellraw(conn, fmt.Sprintf("%s: %s", I18n.T(I18n.DelayModeSet), types.StrDelayMode(delaymode)))
 							if delaymode != types.DelayModeNone {
 								dl := decideDelay(delaymode)
-								configuration.GlobalFullConfig().Delay().Delay = dl
+								env.Configuration.FullConfig.Delay().Delay = dl
 								command.Tellraw(conn, fmt.Sprintf(I18n.T(I18n.DelayModeSet_DelayAuto), dl))
 							}
 							if delaymode == types.DelayModeDiscrete {
-								configuration.GlobalFullConfig().Delay().DelayThreshold = decideDelayThreshold()
-								command.Tellraw(conn, fmt.Sprintf(I18n.T(I18n.DelayModeSet_ThresholdAuto), configuration.GlobalFullConfig().Delay().DelayThreshold))
+								env.Configuration.FullConfig.Delay().DelayThreshold = decideDelayThreshold()
+								command.Tellraw(conn, fmt.Sprintf(I18n.T(I18n.DelayModeSet_ThresholdAuto), env.Configuration.FullConfig.Delay().DelayThreshold))
 							}
 						},
 					},
@@ -164,19 +171,20 @@ func InitInternalFunctions() {
 			"threshold": &FunctionChainItem{
 				FunctionType:  FunctionTypeSimple,
 				ArgumentTypes: []byte{SimpleFunctionArgumentInt},
-				Content: func(conn *minecraft.Conn, args []interface{}) {
-					if configuration.GlobalFullConfig().Delay().DelayMode != types.DelayModeDiscrete {
+				Content: func(env *environment.PBEnvironment, args []interface{}) {
+					conn := env.Connection
+					if env.Configuration.FullConfig.Delay().DelayMode != types.DelayModeDiscrete {
 						command.Tellraw(conn, I18n.T(I18n.DelayThreshold_OnlyDiscrete))
 						return
 					}
 					thr, _ := args[0].(int)
-					configuration.GlobalFullConfig().Delay().DelayThreshold = thr
+					env.Configuration.FullConfig.Delay().DelayThreshold = thr
 					command.Tellraw(conn, fmt.Sprintf(I18n.T(I18n.DelayThreshold_Set), thr))
 				},
 			},
 		},
 	})
-	RegisterFunction(&Function{
+	functionHolder.RegisterFunction(&Function{
 		Name:          "get-pos",
 		OwnedKeywords: []string{"get"},
 		FunctionType:  FunctionTypeContinue,
@@ -185,37 +193,40 @@ func InitInternalFunctions() {
 			"": &FunctionChainItem{
 				FunctionType:  FunctionTypeSimple,
 				ArgumentTypes: []byte{},
-				Content: func(conn *minecraft.Conn, _ []interface{}) {
+				Content: func(env *environment.PBEnvironment, _ []interface{}) {
+					conn := env.Connection
 					if I18n.HasTranslationFor(I18n.Get_Warning) {
 						command.Tellraw(conn, I18n.T(I18n.Get_Warning))
 					}
 					command.SendSizukanaCommand("gamerule sendcommandfeedback true", conn)
-					command.SendCommand(fmt.Sprintf("execute @a[name=\"%s\"] ~ ~ ~ testforblock ~ ~ ~ air", configuration.RespondUser), configuration.ZeroId, conn)
+					command.SendCommand(fmt.Sprintf("execute @a[name=\"%s\"] ~ ~ ~ testforblock ~ ~ ~ air", env.Configuration.RespondUser), env.Configuration.ZeroId, conn)
 				},
 			},
 			"begin": &FunctionChainItem{
 				FunctionType: FunctionTypeSimple,
-				Content: func(conn *minecraft.Conn, _ []interface{}) {
+				Content: func(env *environment.PBEnvironment, _ []interface{}) {
+					conn := env.Connection
 					if I18n.HasTranslationFor(I18n.Get_Warning) {
 						command.Tellraw(conn, I18n.T(I18n.Get_Warning))
 					}
 					command.SendSizukanaCommand("gamerule sendcommandfeedback true", conn)
-					command.SendCommand(fmt.Sprintf("execute @a[name=\"%s\"] ~ ~ ~ testforblock ~ ~ ~ air", configuration.RespondUser), configuration.ZeroId, conn)
+					command.SendCommand(fmt.Sprintf("execute @a[name=\"%s\"] ~ ~ ~ testforblock ~ ~ ~ air", env.Configuration.RespondUser), env.Configuration.ZeroId, conn)
 				},
 			},
 			"end": &FunctionChainItem{
 				FunctionType: FunctionTypeSimple,
-				Content: func(conn *minecraft.Conn, _ []interface{}) {
+				Content: func(env *environment.PBEnvironment, _ []interface{}) {
+					conn := env.Connection
 					if I18n.HasTranslationFor(I18n.Get_Warning) {
 						command.Tellraw(conn, I18n.T(I18n.Get_Warning))
 					}
 					command.SendSizukanaCommand("gamerule sendcommandfeedback true", conn)
-					command.SendCommand(fmt.Sprintf("execute @a[name=\"%s\"] ~ ~ ~ testforblock ~ ~ ~ air", configuration.RespondUser), configuration.OneId, conn)
+					command.SendCommand(fmt.Sprintf("execute @a[name=\"%s\"] ~ ~ ~ testforblock ~ ~ ~ air", env.Configuration.RespondUser), env.Configuration.OneId, conn)
 				},
 			},
 		},
 	})
-	RegisterFunction(&Function{
+	functionHolder.RegisterFunction(&Function{
 		Name:          "task",
 		OwnedKeywords: []string{"task"},
 		FunctionType:  FunctionTypeContinue,
@@ -223,10 +234,11 @@ func InitInternalFunctions() {
 		FunctionContent: map[string]*FunctionChainItem{
 			"list": &FunctionChainItem{
 				FunctionType: FunctionTypeSimple,
-				Content: func(conn *minecraft.Conn, _ []interface{}) {
+				Content: func(env *environment.PBEnvironment, _ []interface{}) {
+					conn := env.Connection
 					total := 0
 					command.Tellraw(conn, I18n.T(I18n.CurrentTasks))
-					fbtask.TaskMap.Range(func(_tid interface{}, _v interface{}) bool {
+					fbtask.GetTaskHolder(env).TaskMap.Range(func(_tid interface{}, _v interface{}) bool {
 						tid, _ := _tid.(int64)
 						v, _ := _v.(*fbtask.Task)
 						dt := -1
@@ -235,7 +247,7 @@ func InitInternalFunctions() {
 							dt = v.Config.Delay().DelayThreshold
 						}
 						if v.Config.Delay().DelayMode != types.DelayModeNone {
-							dv = v.Config.Delay().Delay
+							dv = v.CurrentDelay()
 						}
 						command.Tellraw(conn, fmt.Sprintf(I18n.T(I18n.TaskStateLine), tid, v.CommandLine, fbtask.GetStateDesc(v.State), dv, types.StrDelayMode(v.Config.Delay().DelayMode), dt))
 						total++
@@ -247,9 +259,10 @@ func InitInternalFunctions() {
 			"pause": &FunctionChainItem{
 				FunctionType:  FunctionTypeSimple,
 				ArgumentTypes: []byte{SimpleFunctionArgumentInt},
-				Content: func(conn *minecraft.Conn, args []interface{}) {
+				Content: func(env *environment.PBEnvironment, args []interface{}) {
+					conn := env.Connection
 					tid, _ := args[0].(int)
-					task := fbtask.FindTask(int64(tid))
+					task := fbtask.GetTaskHolder(env).FindTask(int64(tid))
 					if task == nil {
 						command.Tellraw(conn, I18n.T(I18n.TaskNotFoundMessage))
 						return
@@ -261,23 +274,172 @@ func InitInternalFunctions() {
 			"resume": &FunctionChainItem{
 				FunctionType:  FunctionTypeSimple,
 				ArgumentTypes: []byte{SimpleFunctionArgumentInt},
-				Content: func(conn *minecraft.Conn, args []interface{}) {
+				Content: func(env *environment.PBEnvironment, args []interface{}) {
+					conn := env.Connection
 					tid, _ := args[0].(int)
-					task := fbtask.FindTask(int64(tid))
+					task := fbtask.GetTaskHolder(env).FindTask(int64(tid))
 					if task == nil {
-						command.Tellraw(conn, I18n.T(I18n.TaskNotFoundMessage))
+						if fbtask.GetTaskHolder(env).Checkpoints == nil {
+							command.Tellraw(conn, I18n.T(I18n.TaskNotFoundMessage))
+							return
+						}
+						// not a running task, resume the unfinished task saved with the id
+						_, err := fbtask.ResumeTask(int64(tid), env)
+						if err != nil {
+							command.Tellraw(conn, fmt.Sprintf(I18n.T(I18n.Task_CheckpointResumeFailed), tid, err))
+						}
 						return
 					}
 					task.Resume()
 					command.Tellraw(conn, fmt.Sprintf(I18n.T(I18n.TaskResumedNotice), task.TaskId))
 				},
 			},
+			"unfinished": &FunctionChainItem{
+				FunctionType: FunctionTypeSimple,
+				Content: func(env *environment.PBEnvironment, _ []interface{}) {
+					conn := env.Connection
+					store := fbtask.GetTaskHolder(env).Checkpoints
+					if store == nil {
+						return
+					}
+					checkpoints, err := store.List()
+					if err != nil {
+						command.Tellraw(conn, fmt.Sprintf("%s: %v", I18n.T(I18n.ERRORStr), err))
+						return
+					}
+					command.Tellraw(conn, I18n.T(I18n.Task_UnfinishedTasks))
+					for _, checkpoint := range checkpoints {
+						command.Tellraw(conn, fmt.Sprintf(I18n.T(I18n.Task_UnfinishedTaskLine), checkpoint.Id, checkpoint.CommandLine, checkpoint.BlockIndex, checkpoint.SavedAt.Format("2006-01-02 15:04:05")))
+					}
+					command.Tellraw(conn, fmt.Sprintf(I18n.T(I18n.TaskTotalCount), len(checkpoints)))
+				},
+			},
+			"undo": &FunctionChainItem{
+				FunctionType:  FunctionTypeSimple,
+				ArgumentTypes: []byte{SimpleFunctionArgumentInt},
+				Content: func(env *environment.PBEnvironment, args []interface{}) {
+					conn := env.Connection
+					tid, _ := args[0].(int)
+					task, err := fbtask.UndoTask(int64(tid), env)
+					if err != nil {
+						command.Tellraw(conn, fmt.Sprintf(I18n.T(I18n.Task_UndoFailed), tid, err))
+						return
+					}
+					command.Tellraw(conn, fmt.Sprintf(I18n.T(I18n.Task_Undoing), tid, task.TaskId))
+				},
+			},
+			"redo": &FunctionChainItem{
+				FunctionType:  FunctionTypeSimple,
+				ArgumentTypes: []byte{SimpleFunctionArgumentInt},
+				Content: func(env *environment.PBEnvironment, args []interface{}) {
+					conn := env.Connection
+					tid, _ := args[0].(int)
+					task, err := fbtask.RedoTask(int64(tid), env)
+					if err != nil {
+						command.Tellraw(conn, fmt.Sprintf(I18n.T(I18n.Task_UndoFailed), tid, err))
+						return
+					}
+					command.Tellraw(conn, fmt.Sprintf(I18n.T(I18n.Task_Redoing), tid, task.TaskId))
+				},
+			},
+			"discard": &FunctionChainItem{
+				FunctionType:  FunctionTypeSimple,
+				ArgumentTypes: []byte{SimpleFunctionArgumentInt},
+				Content: func(env *environment.PBEnvironment, args []interface{}) {
+					conn := env.Connection
+					id, _ := args[0].(int)
+					store := fbtask.GetTaskHolder(env).Checkpoints
+					if store == nil {
+						return
+					}
+					if err := store.Remove(int64(id)); err != nil {
+						command.Tellraw(conn, fmt.Sprintf("%s: %v", I18n.T(I18n.ERRORStr), err))
+						return
+					}
+					command.Tellraw(conn, fmt.Sprintf("Checkpoint %d discarded", id))
+				},
+			},
+			"queue": &FunctionChainItem{
+				FunctionType: FunctionTypeSimple,
+				Content: func(env *environment.PBEnvironment, _ []interface{}) {
+					conn := env.Connection
+					queued := fbtask.GetTaskHolder(env).Queued()
+					command.Tellraw(conn, I18n.T(I18n.Task_QueuedTasks))
+					for _, task := range queued {
+						command.Tellraw(conn, fmt.Sprintf(I18n.T(I18n.Task_QueueLine), task.TaskId, task.CommandLine, task.Priority, task.After))
+					}
+					command.Tellraw(conn, fmt.Sprintf(I18n.T(I18n.TaskTotalCount), len(queued)))
+				},
+			},
+			"priority": &FunctionChainItem{
+				FunctionType:  FunctionTypeSimple,
+				ArgumentTypes: []byte{SimpleFunctionArgumentInt, SimpleFunctionArgumentInt},
+				Content: func(env *environment.PBEnvironment, args []interface{}) {
+					conn := env.Connection
+					tid, _ := args[0].(int)
+					priority, _ := args[1].(int)
+					if err := fbtask.GetTaskHolder(env).SetPriority(int64(tid), priority); err != nil {
+						command.Tellraw(conn, fmt.Sprintf(I18n.T(I18n.Task_QueueFailed), err))
+						return
+					}
+					command.Tellraw(conn, fmt.Sprintf(I18n.T(I18n.Task_PrioritySet), tid, priority))
+				},
+			},
+			"after": &FunctionChainItem{
+				FunctionType:  FunctionTypeSimple,
+				ArgumentTypes: []byte{SimpleFunctionArgumentInt, SimpleFunctionArgumentInt},
+				Content: func(env *environment.PBEnvironment, args []interface{}) {
+					conn := env.Connection
+					tid, _ := args[0].(int)
+					after, _ := args[1].(int)
+					if err := fbtask.GetTaskHolder(env).SetAfter(int64(tid), int64(after)); err != nil {
+						command.Tellraw(conn, fmt.Sprintf(I18n.T(I18n.Task_QueueFailed), err))
+						return
+					}
+					command.Tellraw(conn, fmt.Sprintf(I18n.T(I18n.Task_AfterSet), tid, after))
+				},
+			},
+			"up": &FunctionChainItem{
+				FunctionType:  FunctionTypeSimple,
+				ArgumentTypes: []byte{SimpleFunctionArgumentInt},
+				Content: func(env *environment.PBEnvironment, args []interface{}) {
+					tid, _ := args[0].(int)
+					if err := fbtask.GetTaskHolder(env).MoveQueued(int64(tid), true); err != nil {
+						command.Tellraw(env.Connection, fmt.Sprintf(I18n.T(I18n.Task_QueueFailed), err))
+					}
+				},
+			},
+			"down": &FunctionChainItem{
+				FunctionType:  FunctionTypeSimple,
+				ArgumentTypes: []byte{SimpleFunctionArgumentInt},
+				Content: func(env *environment.PBEnvironment, args []interface{}) {
+					tid, _ := args[0].(int)
+					if err := fbtask.GetTaskHolder(env).MoveQueued(int64(tid), false); err != nil {
+						command.Tellraw(env.Connection, fmt.Sprintf(I18n.T(I18n.Task_QueueFailed), err))
+					}
+				},
+			},
+			"concurrency": &FunctionChainItem{
+				FunctionType:  FunctionTypeSimple,
+				ArgumentTypes: []byte{SimpleFunctionArgumentInt},
+				Content: func(env *environment.PBEnvironment, args []interface{}) {
+					max, _ := args[0].(int)
+					if max < 0 {
+						max = 0
+					}
+					env.Configuration.FullConfig.Global().MaxConcurrentTasks = max
+					command.Tellraw(env.Connection, fmt.Sprintf(I18n.T(I18n.Task_MaxConcurrentSet), max))
+					// more tasks may be started now
+					fbtask.GetTaskHolder(env).Schedule()
+				},
+			},
 			"break": &FunctionChainItem{
 				FunctionType:  FunctionTypeSimple,
 				ArgumentTypes: []byte{SimpleFunctionArgumentInt},
-				Content: func(conn *minecraft.Conn, args []interface{}) {
+				Content: func(env *environment.PBEnvironment, args []interface{}) {
+					conn := env.Connection
 					tid, _ := args[0].(int)
-					task := fbtask.FindTask(int64(tid))
+					task := fbtask.GetTaskHolder(env).FindTask(int64(tid))
 					if task == nil {
 						command.Tellraw(conn, I18n.T(I18n.TaskNotFoundMessage))
 						return
@@ -289,10 +451,11 @@ func InitInternalFunctions() {
 			"setdelay": &FunctionChainItem{
 				FunctionType:  FunctionTypeSimple,
 				ArgumentTypes: []byte{SimpleFunctionArgumentInt, SimpleFunctionArgumentInt},
-				Content: func(conn *minecraft.Conn, args []interface{}) {
+				Content: func(env *environment.PBEnvironment, args []interface{}) {
+					conn := env.Connection
 					tid, _ := args[0].(int)
 					del, _ := args[1].(int)
-					task := fbtask.FindTask(int64(tid))
+					task := fbtask.GetTaskHolder(env).FindTask(int64(tid))
 					if task == nil {
 						command.Tellraw(conn, I18n.T(I18n.TaskNotFoundMessage))
 						return
@@ -308,10 +471,11 @@ func InitInternalFunctions() {
 			"setdelaymode": &FunctionChainItem{
 				FunctionType:  FunctionTypeSimple,
 				ArgumentTypes: []byte{SimpleFunctionArgumentInt, byte(delayEnumId)},
-				Content: func(conn *minecraft.Conn, args []interface{}) {
+				Content: func(env *environment.PBEnvironment, args []interface{}) {
+					conn := env.Connection
 					tid, _ := args[0].(int)
 					delaymode, _ := args[1].(byte)
-					task := fbtask.FindTask(int64(tid))
+					task := fbtask.GetTaskHolder(env).FindTask(int64(tid))
 					if task == nil {
 						command.Tellraw(conn, I18n.T(I18n.TaskNotFoundMessage))
 						return
@@ -333,10 +497,11 @@ func InitInternalFunctions() {
 			"setdelaythreshold": &FunctionChainItem{
 				FunctionType:  FunctionTypeSimple,
 				ArgumentTypes: []byte{SimpleFunctionArgumentInt, SimpleFunctionArgumentInt},
-				Content: func(conn *minecraft.Conn, args []interface{}) {
+				Content: func(env *environment.PBEnvironment, args []interface{}) {
+					conn := env.Connection
 					tid, _ := args[0].(int)
 					delayt, _ := args[1].(int)
-					task := fbtask.FindTask(int64(tid))
+					task := fbtask.GetTaskHolder(env).FindTask(int64(tid))
 					if task == nil {
 						command.Tellraw(conn, I18n.T(I18n.TaskNotFoundMessage))
 						return
@@ -351,68 +516,86 @@ func InitInternalFunctions() {
 			},
 		},
 	})
-	taskTypeEnumId := RegisterEnum("async, sync", types.ParseTaskType, types.TaskTypeInvalid)
-	RegisterFunction(&Function{
+	taskTypeEnumId := functionHolder.RegisterEnum("async, sync", types.ParseTaskType, types.TaskTypeInvalid)
+	functionHolder.RegisterFunction(&Function{
 		Name:            "set task type",
 		OwnedKeywords:   []string{"tasktype"},
 		FunctionType:    FunctionTypeSimple,
 		SFMinSliceLen:   2,
 		SFArgumentTypes: []byte{byte(taskTypeEnumId)},
-		FunctionContent: func(conn *minecraft.Conn, args []interface{}) {
+		FunctionContent: func(env *environment.PBEnvironment, args []interface{}) {
+			conn := env.Connection
 			ev, _ := args[0].(byte)
-			configuration.GlobalFullConfig().Global().TaskCreationType = ev
+			env.Configuration.FullConfig.Global().TaskCreationType = ev
 			command.Tellraw(conn, fmt.Sprintf(I18n.T(I18n.TaskTypeSwitchedTo), types.MakeTaskType(ev)))
 		},
 	})
-	taskDMEnumId := RegisterEnum("true, false", types.ParseTaskDisplayMode, types.TaskDisplayInvalid)
-	RegisterFunction(&Function{
+	taskDMEnumId := functionHolder.RegisterEnum("true, false", types.ParseTaskDisplayMode, types.TaskDisplayInvalid)
+	functionHolder.RegisterFunction(&Function{
 		Name:            "set progress title display type",
 		OwnedKeywords:   []string{"progress"},
 		FunctionType:    FunctionTypeSimple,
 		SFMinSliceLen:   2,
 		SFArgumentTypes: []byte{byte(taskDMEnumId)},
-		FunctionContent: func(conn *minecraft.Conn, args []interface{}) {
+		FunctionContent: func(env *environment.PBEnvironment, args []interface{}) {
+			conn := env.Connection
 			ev, _ := args[0].(byte)
-			configuration.GlobalFullConfig().Global().TaskDisplayMode = ev
+			env.Configuration.FullConfig.Global().TaskDisplayMode = ev
 			command.Tellraw(conn, fmt.Sprintf(I18n.T(I18n.TaskDisplayModeSet), types.MakeTaskDisplayMode(ev)))
 		},
 	})
+	functionHolder.RegisterFunction(&Function{
+		Name:            "set fill optimization",
+		OwnedKeywords:   []string{"filloptimization"},
+		FunctionType:    FunctionTypeSimple,
+		SFMinSliceLen:   2,
+		SFArgumentTypes: []byte{byte(taskDMEnumId)},
+		FunctionContent: func(env *environment.PBEnvironment, args []interface{}) {
+			conn := env.Connection
+			ev, _ := args[0].(byte)
+			env.Configuration.FullConfig.Delay().FillOptimization = ev == types.TaskDisplayYes
+			command.Tellraw(conn, fmt.Sprintf(I18n.T(I18n.FillOptimizationSet), types.MakeTaskDisplayMode(ev)))
+		},
+	})
 	// ippan
 	var builderMethods []string
 	for met, _ := range builder.Builder {
 		builderMethods = append(builderMethods, met)
 	}
-	RegisterFunction(&Function{
+	functionHolder.RegisterFunction(&Function{
 		Name:          "ippanbrd",
 		OwnedKeywords: builderMethods,
 		FunctionType:  FunctionTypeRegular,
-		FunctionContent: func(conn *minecraft.Conn, msg string) {
-			task := fbtask.CreateTask(msg, conn)
+		FunctionContent: func(env *environment.PBEnvironment, msg string) {
+			conn := env.Connection
+			task := fbtask.CreateTask(msg, env)
 			if task == nil {
 				return
 			}
 			command.Tellraw(conn, fmt.Sprintf("%s, ID=%d.", I18n.T(I18n.TaskCreated), task.TaskId))
 		},
 	})
-	RegisterFunction(&Function{
+	functionHolder.RegisterFunction(&Function{
 		Name:          "export",
 		OwnedKeywords: []string{"export"},
 		FunctionType:  FunctionTypeRegular,
-		FunctionContent: func(conn *minecraft.Conn, msg string) {
-			task := fbtask.CreateExportTask(msg, conn)
+		FunctionContent: func(env *environment.PBEnvironment, msg string) {
+			conn := env.Connection
+			task := fbtask.CreateExportTask(msg, env)
 			if task == nil {
 				return
 			}
 			command.Tellraw(conn, fmt.Sprintf("%s, ID=%d.", I18n.T(I18n.TaskCreated), task.TaskId))
 		},
 	})
-	RegisterFunction(&Function{
+	functionHolder.RegisterFunction(&Function{
 		Name:            "say",
 		OwnedKeywords:   []string{"say"},
 		FunctionType:    FunctionTypeSimple,
 		SFArgumentTypes: []byte{SimpleFunctionArgumentMessage},
 		SFMinSliceLen:   1,
-		FunctionContent: func(conn *minecraft.Conn, args []interface{}) {
+		FunctionContent: func(env *environment.PBEnvironment, args []interface{}) {
+			conn := env.Connection
 			str := args[0].(string)
 			command.Tellraw(conn, str)
 		},
@@ -421,7 +604,8 @@ func InitInternalFunctions() {
 
 func decideDelay(delaytype byte) int64 {
 	// Will add system check later,so don't merge into other functions.
-	if delaytype == types.DelayModeContinuous {
+	if delaytype == types.DelayModeContinuous || delaytype == types.DelayModeAdaptive {
+		// the delay adaptive mode starts from
 		return 1000
 	} else if delaytype == types.DelayModeDiscrete {
 		return 15
@@ -114,5 +114,28 @@ var I18nDict_cn map[uint16]string = map[uint16]string{
 	Auth_InvalidFBVersion: "FastBuilder 版本无效，请更新。",
 	Notify_TurnOnCmdFeedBack:            "FastBuilder 需要 sendcommandfeedback 为 true，请输入:\"/gamerule sendcommandfeedfack true\"并重启 FastBuilder。",
 	Notify_NeedOp:                       "需要 OP 权限以正常工作。",
+	TaskTypeReconnecting:                "等待重连",
+	Task_ResumedFromCheckpoint:          "[任务 %d] 已从存档点 %d 恢复，跳过已建造的 %d 个方块。",
+	Task_UnfinishedTasks:                "未完成的任务:",
+	Task_UnfinishedTaskLine:             "存档点 %d - %s - 已建造 %d 个方块，保存于 %s",
+	Task_CheckpointResumeFailed:         "无法恢复存档点 %d: %v",
+	FillOptimizationSet:                 "fill 合并优化已经设置为: %s.",
+	Task_FillOptimized:                  "[任务 %d] %d 个方块被合并为 %d 条 fill 指令, 节省了 %d 条指令。",
+	Task_VerifyPassed:                   "[任务 %d] 校验完成, 所有方块都正确。",
+	Task_VerifyRepairing:                "[任务 %d] %d 个方块不正确 (%s), 正在重新建造 (%d/%d)。",
+	Task_VerifyFailed:                   "[任务 %d] 仍有 %d 个方块不正确, 已重新建造 %d 次: %s",
+	Task_JournalFailed:                  "[任务 %d] 无法保存任务之前的方块, 此任务将无法撤销: %v",
+	Task_Undoing:                        "正在撤销任务 %d, ID=%d.",
+	Task_Redoing:                        "正在重做任务 %d, ID=%d.",
+	Task_UndoFailed:                     "无法撤销或重做任务 %d: %v",
+	TaskTypeQueued:                      "排队中",
+	Task_QueuedTasks:                    "排队中的任务:",
+	Task_QueueLine:                      "ID %d - %s - 优先级: %d, 在任务 %d 之后",
+	Task_MaxConcurrentSet:               "现在最多同时运行 %d 个任务, 0 为不限制。",
+	Task_PrioritySet:                    "[任务 %d] 优先级已设为 %d。",
+	Task_AfterSet:                       "[任务 %d] 将在任务 %d 完成后开始。",
+	Task_QueueFailed:                    "无法调整任务队列: %v",
+	Task_ConnectionRestored:             "[任务 %d] 连接已恢复, 从第 %d 个方块继续",
+	Task_CheckpointSaveFailed:           "[任务 %d] 无法保存存档点: %v",
 
 }
\ No newline at end of file
@@ -106,5 +106,28 @@ var I18nDict_en map[uint16]string = map[uint16]string{
 	Auth_InvalidFBVersion: "Invalid FastBuilder version, please update.",
 	Notify_TurnOnCmdFeedBack:            "FastBuilder requires gamerule sendcommandfeedback to be true, please execute command:\"/gamerule sendcommandfeedfack true\" and restart FastBuilder.",
 	Notify_NeedOp:                       "FastBuilder requires operator privilege.",
+	TaskTypeReconnecting:                "Reconnecting",
+	Task_ResumedFromCheckpoint:          "[Task %d] Resumed from checkpoint %d, skipping %d built block(s).",
+	Task_UnfinishedTasks:                "Unfinished tasks:",
+	Task_UnfinishedTaskLine:             "Checkpoint %d - %s - %d block(s) built, saved at %s",
+	Task_CheckpointResumeFailed:         "Failed to resume checkpoint %d: %v",
+	FillOptimizationSet:                 "Fill optimization set to: %s.",
+	Task_FillOptimized:                  "[Task %d] %d block(s) are merged into %d fill command(s), %d command(s) saved.",
+	Task_VerifyPassed:                   "[Task %d] Verified, all blocks are right.",
+	Task_VerifyRepairing:                "[Task %d] %d block(s) are wrong (%s), building them again (%d/%d).",
+	Task_VerifyFailed:                   "[Task %d] %d block(s) are still wrong after building them again %d time(s): %s",
+	Task_JournalFailed:                  "[Task %d] Failed to save the blocks before the task, it can't be undone: %v",
+	Task_Undoing:                        "Undoing task %d, ID=%d.",
+	Task_Redoing:                        "Redoing task %d, ID=%d.",
+	Task_UndoFailed:                     "Failed to undo or redo task %d: %v",
+	TaskTypeQueued:                      "Queued",
+	Task_QueuedTasks:                    "Queued tasks:",
+	Task_QueueLine:                      "ID %d - %s - priority: %d, after task: %d",
+	Task_MaxConcurrentSet:               "At most %d task(s) run at once now, 0 for no limit.",
+	Task_PrioritySet:                    "[Task %d] Priority set to %d.",
+	Task_AfterSet:                       "[Task %d] Will be started after task %d is done.",
+	Task_QueueFailed:                    "Failed to change the queue: %v",
+	Task_ConnectionRestored:             "[Task %d] Connection restored, resuming from block %d",
+	Task_CheckpointSaveFailed:           "[Task %d] Failed to save checkpoint: %v",
 
 }
\ No newline at end of file
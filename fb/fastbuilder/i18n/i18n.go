@@ -133,6 +133,29 @@ const (
 	Auth_InvalidFBVersion               //113
 	Notify_TurnOnCmdFeedBack
 	Notify_NeedOp
+	TaskTypeReconnecting
+	Task_ResumedFromCheckpoint
+	Task_UnfinishedTasks
+	Task_UnfinishedTaskLine
+	Task_CheckpointResumeFailed
+	FillOptimizationSet
+	Task_FillOptimized
+	Task_VerifyPassed
+	Task_VerifyRepairing
+	Task_VerifyFailed
+	Task_JournalFailed
+	Task_Undoing
+	Task_Redoing
+	Task_UndoFailed
+	TaskTypeQueued
+	Task_QueuedTasks
+	Task_QueueLine
+	Task_MaxConcurrentSet
+	Task_PrioritySet
+	Task_AfterSet
+	Task_QueueFailed
+	Task_ConnectionRestored
+	Task_CheckpointSaveFailed
 )
 
 var LangDict map[string]map[uint16]string = map[string]map[uint16]string{
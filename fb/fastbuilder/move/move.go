@@ -8,59 +8,89 @@ import (
 	"phoenixbuilder_3rd_gui/fb/minecraft/protocol"
 	"phoenixbuilder_3rd_gui/fb/minecraft/protocol/packet"
 	"time"
+
+	"go.uber.org/atomic"
 )
 
-var ConnectTime time.Time
-var Position mgl32.Vec3
-var Pitch, Yaw float32
-var HeadYaw float32
-var Connection *minecraft.Conn
-var RuntimeID uint64
-var MoveP float32
-var Target mgl32.Vec3
-var TargetRuntimeID uint64 = 0
+// MoveHolder keeps the movement state of the bot of one session.
+type MoveHolder struct {
+	ConnectTime     time.Time
+	Position        mgl32.Vec3
+	Pitch, Yaw      float32
+	HeadYaw         float32
+	Connection      *minecraft.Conn
+	RuntimeID       uint64
+	MoveP           float32
+	Target          mgl32.Vec3
+	TargetRuntimeID uint64
+	// the number of movement corrections sent by the server, it grows
+	// faster when the server lags
+	Corrections atomic.Int64
+
+	nextAttack int
+}
 
-func calculateTick() uint64 {
-	return uint64(time.Now().Sub(ConnectTime).Milliseconds() / 50)
+func NewMoveHolder(conn *minecraft.Conn) *MoveHolder {
+	holder := &MoveHolder{}
+	holder.SetConnection(conn)
+	return holder
 }
 
-func Move(x, y, z float32) {
+// SetConnection makes the bot move on the connection, e.g. after the
+// session is connected again
+func (m *MoveHolder) SetConnection(conn *minecraft.Conn) {
+	m.Connection = conn
+	if conn != nil {
+		gameData := conn.GameData()
+		m.ConnectTime = gameData.ConnectTime
+		m.Position = gameData.PlayerPosition
+		m.Pitch = gameData.Pitch
+		m.Yaw = gameData.Yaw
+		m.RuntimeID = gameData.EntityRuntimeID
+	}
+}
+
+func (m *MoveHolder) calculateTick() uint64 {
+	return uint64(time.Now().Sub(m.ConnectTime).Milliseconds() / 50)
+}
+
+func (m *MoveHolder) Move(x, y, z float32) {
 	//fmt.Printf("Decided: %f, %f\n",x,z)
 	moveVector3 := mgl32.Vec3{x, y /*+256*/, z}
 	moveVector := mgl32.Vec2{x, z}
-	Position = Position.Add(moveVector3)
-	Connection.WritePacket(&packet.PlayerAuthInput{
-		Pitch:      Pitch,
-		Yaw:        Yaw,
-		Position:   Position,
+	m.Position = m.Position.Add(moveVector3)
+	m.Connection.WritePacket(&packet.PlayerAuthInput{
+		Pitch:      m.Pitch,
+		Yaw:        m.Yaw,
+		Position:   m.Position,
 		MoveVector: moveVector,
-		HeadYaw:    HeadYaw,
+		HeadYaw:    m.HeadYaw,
 		InputData:  0 | packet.InputFlagAutoJumpingInWater,
 		InputMode:  packet.InputModeTouch,
 		PlayMode:   packet.PlayModeScreen,
-		Tick:       calculateTick(),
+		Tick:       m.calculateTick(),
 		Delta:      moveVector3,
 	})
 }
 
-func Jump() {
-	Connection.WritePacket(&packet.PlayerAction{
-		EntityRuntimeID: RuntimeID,
+func (m *MoveHolder) Jump() {
+	m.Connection.WritePacket(&packet.PlayerAction{
+		EntityRuntimeID: m.RuntimeID,
 		ActionType:      protocol.PlayerActionJump,
 	})
 	moveVector3 := mgl32.Vec3{0, 256, 0}
 	moveVector := mgl32.Vec2{0, 0}
-	Position = Position.Add(moveVector3)
-	Connection.WritePacket(&packet.PlayerAuthInput{
-		Pitch:      Pitch,
-		Yaw:        Yaw,
-		Position:   Position,
+	m.Position = m.Position.Add(moveVector3)
+	m.Connection.WritePacket(&packet.PlayerAuthInput{
+		Pitch:      m.Pitch,
+		Yaw:        m.Yaw,
+		Position:   m.Position,
 		MoveVector: moveVector,
-		HeadYaw:    HeadYaw,
+		HeadYaw:    m.HeadYaw,
 		InputData:  packet.InputFlagJumping,
 		InputMode:  packet.InputModeTouch,
 		PlayMode:   packet.PlayModeScreen,
-		Tick:       calculateTick(),
+		Tick:       m.calculateTick(),
 		Delta:      moveVector3,
 	})
 }
@@ -72,10 +102,8 @@ func getz(v float32) float32 {
 	return -v
 }
 
-var nextAttack = 0
-
-func Auto() {
-	delta := Target.Sub(Position)
+func (m *MoveHolder) Auto() {
+	delta := m.Target.Sub(m.Position)
 	deltax := delta[0]
 	deltaz := delta[2]
 	if math.IsNaN(float64(deltax)) {
@@ -87,12 +115,12 @@ func Auto() {
 	zdx := getz(deltax)
 	zdz := getz(deltaz)
 	if zdx < 6 && zdz < 6 {
-		if nextAttack != 0 {
-			nextAttack--
+		if m.nextAttack != 0 {
+			m.nextAttack--
 		} else {
-			Connection.WritePacket(&packet.InventoryTransaction{
+			m.Connection.WritePacket(&packet.InventoryTransaction{
 				TransactionData: &protocol.UseItemOnEntityTransactionData{
-					TargetEntityRuntimeID: TargetRuntimeID,
+					TargetEntityRuntimeID: m.TargetRuntimeID,
 					ActionType:            0,
 					HotBarSlot:            0,
 					HeldItem: protocol.ItemInstance{
@@ -106,11 +134,11 @@ func Auto() {
 							Count:          0,
 						},
 					},
-					Position:        Target,
-					ClickedPosition: Target.Sub(mgl32.Vec3{0, -1, 0}),
+					Position:        m.Target,
+					ClickedPosition: m.Target.Sub(mgl32.Vec3{0, -1, 0}),
 				},
 			})
-			nextAttack = 10
+			m.nextAttack = 10
 		}
 	}
 	maxItem := zdx
@@ -118,5 +146,5 @@ func Auto() {
 		maxItem = zdz
 	}
 	//fmt.Printf("Target: %v, delta: %v, max: %f, ",Target,delta,maxItem)
-	Move(2*(zdx/maxItem)*(zdx/deltax), 0, 2*(zdz/maxItem)*(zdz/deltaz))
+	m.Move(2*(zdx/maxItem)*(zdx/deltax), 0, 2*(zdz/maxItem)*(zdz/deltaz))
 }
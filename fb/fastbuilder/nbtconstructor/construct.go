@@ -3,6 +3,7 @@ package nbtconstructor
 import (
 	"fmt"
 	"phoenixbuilder_3rd_gui/fb/fastbuilder/command"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/environment"
 	"phoenixbuilder_3rd_gui/fb/minecraft"
 	"phoenixbuilder_3rd_gui/fb/minecraft/protocol"
 	"phoenixbuilder_3rd_gui/fb/minecraft/protocol/packet"
@@ -11,31 +12,38 @@ import (
 	"github.com/google/uuid"
 )
 
-var isOccupied bool = false
-var AddVillagerChannel chan *packet.AddActor = nil
-var InventoryContentChannel chan *packet.InventoryContent = nil
-
-// ^ WindowID=0 only
-var ItemStackResponseChannel chan *packet.ItemStackResponse = nil
+// NBTConstructorHolder keeps the state of the nbt construction of one session,
+// the channels are only non-nil while the construction is waiting for them
+type NBTConstructorHolder struct {
+	isOccupied              bool
+	AddVillagerChannel      chan *packet.AddActor
+	InventoryContentChannel chan *packet.InventoryContent
+	// ^ WindowID=0 only
+	ItemStackResponseChannel chan *packet.ItemStackResponse
+	//TradeWindowIDChannel chan byte
+	IsWorking     bool
+	TradeWindowID byte
+}
 
-//var TradeWindowIDChannel chan byte = nil
-var IsWorking bool = false
-var TradeWindowID byte
+func GetNBTConstructorHolder(env *environment.PBEnvironment) *NBTConstructorHolder {
+	holder, _ := env.NBTConstructorHolder.(*NBTConstructorHolder)
+	return holder
+}
 
-func StartSessionWithCustomNBT(conn *minecraft.Conn, itemNetworkID int32, metadataValue uint32, nbtContent map[string]interface{}) {
-	if isOccupied {
+func (holder *NBTConstructorHolder) StartSessionWithCustomNBT(conn *minecraft.Conn, itemNetworkID int32, metadataValue uint32, nbtContent map[string]interface{}) {
+	if holder.isOccupied {
 		command.Tellraw(conn, "There's already a working nbt construction session.")
 		return
 	}
-	isOccupied = true
+	holder.isOccupied = true
 	reqID := int32(-3)
-	InventoryContentChannel = make(chan *packet.InventoryContent)
-	AddVillagerChannel = make(chan *packet.AddActor)
+	holder.InventoryContentChannel = make(chan *packet.InventoryContent)
+	holder.AddVillagerChannel = make(chan *packet.AddActor)
 	command.SendWSCommand("summon villager ~ ~ ~ minecraft:become_butcher", uuid.New(), conn)
-	villagerPkt := <-AddVillagerChannel
-	close(AddVillagerChannel)
-	AddVillagerChannel = nil
-	IsWorking = true
+	villagerPkt := <-holder.AddVillagerChannel
+	close(holder.AddVillagerChannel)
+	holder.AddVillagerChannel = nil
+	holder.IsWorking = true
 	err := conn.WritePacket(&packet.InventoryTransaction{
 		LegacyRequestID:    0,
 		LegacySetItemSlots: []protocol.LegacySetItemSlot{},
@@ -67,18 +75,18 @@ func StartSessionWithCustomNBT(conn *minecraft.Conn, itemNetworkID int32, metada
 		panic(err)
 	}
 	command.SendWSCommand("clear @s", uuid.New(), conn)
-	<-InventoryContentChannel
+	<-holder.InventoryContentChannel
 	command.SendWSCommand("give @s emerald 64", uuid.New(), conn)
-	content := <-InventoryContentChannel
+	content := <-holder.InventoryContentChannel
 	emeraldStackID := content.Content[0].StackNetworkID
 	if emeraldStackID == 0 {
-		isOccupied = false
+		holder.isOccupied = false
 		command.Tellraw(conn, "Failed to get the emerald stack.")
 		return
 	}
-	close(InventoryContentChannel)
-	InventoryContentChannel = nil
-	ItemStackResponseChannel = make(chan *packet.ItemStackResponse)
+	close(holder.InventoryContentChannel)
+	holder.InventoryContentChannel = nil
+	holder.ItemStackResponseChannel = make(chan *packet.ItemStackResponse)
 	placeReq := &packet.ItemStackRequest{
 		Requests: []protocol.ItemStackRequest{
 			{
@@ -102,9 +110,9 @@ func StartSessionWithCustomNBT(conn *minecraft.Conn, itemNetworkID int32, metada
 	}
 	time.Sleep(time.Second)
 	conn.WritePacket(placeReq)
-	resp := <-ItemStackResponseChannel
+	resp := <-holder.ItemStackResponseChannel
 	if resp.Responses[0].Status != 0 {
-		isOccupied = false
+		holder.isOccupied = false
 		command.Tellraw(conn, fmt.Sprintf("Unexpected item stack response: %+v", resp.Responses[0]))
 		return
 	}
@@ -162,9 +170,9 @@ func StartSessionWithCustomNBT(conn *minecraft.Conn, itemNetworkID int32, metada
 	}
 	command.Tellraw(conn, "Fetching item")
 	conn.WritePacket(tradereq)
-	resp = <-ItemStackResponseChannel
+	resp = <-holder.ItemStackResponseChannel
 	if resp.Responses[0].Status != 0 {
-		isOccupied = false
+		holder.isOccupied = false
 		command.Tellraw(conn, fmt.Sprintf("[2] Unexpected item stack response: %+v", resp.Responses[0]))
 		return
 	}
@@ -189,19 +197,19 @@ func StartSessionWithCustomNBT(conn *minecraft.Conn, itemNetworkID int32, metada
 	}
 	command.Tellraw(conn, "Dropping the item")
 	conn.WritePacket(dropItem)
-	resp = <-ItemStackResponseChannel
+	resp = <-holder.ItemStackResponseChannel
 	if resp.Responses[0].Status != 0 {
-		isOccupied = false
+		holder.isOccupied = false
 		command.Tellraw(conn, fmt.Sprintf("[3] Unexpected item stack response: %+v", resp.Responses[0]))
 		return
 	}
-	close(ItemStackResponseChannel)
-	ItemStackResponseChannel = nil
-	IsWorking = false
+	close(holder.ItemStackResponseChannel)
+	holder.ItemStackResponseChannel = nil
+	holder.IsWorking = false
 	conn.WritePacket(&packet.ContainerClose{
-		WindowID:   TradeWindowID,
+		WindowID:   holder.TradeWindowID,
 		ServerSide: false,
 	})
-	isOccupied = false
+	holder.isOccupied = false
 	command.Tellraw(conn, "[NBTConstructor] Process finished.")
 }
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"phoenixbuilder_3rd_gui/fb/fastbuilder/command"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/environment"
 	"phoenixbuilder_3rd_gui/fb/fastbuilder/function"
 	I18n "phoenixbuilder_3rd_gui/fb/fastbuilder/i18n"
 	"phoenixbuilder_3rd_gui/fb/minecraft"
@@ -338,7 +339,7 @@ func parseJsonNBT(st map[string]interface{}, isRoot bool) (map[string]interface{
 	return out, nil
 }
 
-func evalNBT(content string, conn *minecraft.Conn) {
+func (holder *NBTConstructorHolder) evalNBT(content string, conn *minecraft.Conn) {
 	parsedstruct := make(map[string]interface{})
 	err := json.Unmarshal([]byte(content), &parsedstruct)
 	if err != nil {
@@ -388,30 +389,35 @@ func evalNBT(content string, conn *minecraft.Conn) {
 		return
 	}
 	go func() {
-		StartSessionWithCustomNBT(conn, int32(item.NetworkID), uint32(dataVal), nbtmap)
+		holder.StartSessionWithCustomNBT(conn, int32(item.NetworkID), uint32(dataVal), nbtmap)
 	}()
 	return
 }
 
-func InitNBTConstructor() {
-	function.RegisterFunction(&function.Function{
+func InitNBTConstructor(env *environment.PBEnvironment) {
+	holder := &NBTConstructorHolder{}
+	env.NBTConstructorHolder = holder
+	functionHolder := function.GetFunctionHolder(env)
+	functionHolder.RegisterFunction(&function.Function{
 		Name:            "constructItem_simple",
 		OwnedKeywords:   []string{"simpleconstruct"},
 		FunctionType:    function.FunctionTypeSimple,
 		SFArgumentTypes: []byte{function.SimpleFunctionArgumentMessage},
 		SFMinSliceLen:   1,
-		FunctionContent: func(conn *minecraft.Conn, args []interface{}) {
+		FunctionContent: func(env *environment.PBEnvironment, args []interface{}) {
+			conn := env.Connection
 			toEval := args[0].(string)
-			evalNBT(toEval, conn)
+			holder.evalNBT(toEval, conn)
 		},
 	})
-	function.RegisterFunction(&function.Function{
+	functionHolder.RegisterFunction(&function.Function{
 		Name:            "constructItem",
 		OwnedKeywords:   []string{"construct"},
 		FunctionType:    function.FunctionTypeSimple,
 		SFArgumentTypes: []byte{function.SimpleFunctionArgumentMessage},
 		SFMinSliceLen:   1,
-		FunctionContent: func(conn *minecraft.Conn, args []interface{}) {
+		FunctionContent: func(env *environment.PBEnvironment, args []interface{}) {
+			conn := env.Connection
 			path := args[0].(string)
 			// file, hasK := configuration.MonkeyPathFileReader[path]
 			// if !hasK {
@@ -435,7 +441,7 @@ func InitNBTConstructor() {
 				command.Tellraw(conn, fmt.Sprintf("Error: %v", err))
 				return
 			}
-			evalNBT(string(content), conn)
+			holder.evalNBT(string(content), conn)
 		},
 	})
 }
@@ -0,0 +1,85 @@
+// Package noise has seeded gradient noise for patterns and terrain
+package noise
+
+import (
+	"math"
+	"math/rand"
+)
+
+// Perlin is improved Perlin noise with a permutation decided by a seed,
+// the same seed always gives the same noise
+type Perlin struct {
+	perm [512]int
+}
+
+func NewPerlin(seed int64) *Perlin {
+	p := &Perlin{}
+	r := rand.New(rand.NewSource(seed))
+	for i, v := range r.Perm(256) {
+		p.perm[i] = v
+		p.perm[i+256] = v
+	}
+	return p
+}
+
+func fade(t float64) float64 {
+	return t * t * t * (t*(t*6-15) + 10)
+}
+
+func lerp(t, a, b float64) float64 {
+	return a + t*(b-a)
+}
+
+func grad(hash int, x, y, z float64) float64 {
+	h := hash & 15
+	u, v := y, z
+	if h < 8 {
+		u = x
+	}
+	if h < 4 {
+		v = y
+	} else if h == 12 || h == 14 {
+		v = x
+	}
+	if h&1 != 0 {
+		u = -u
+	}
+	if h&2 != 0 {
+		v = -v
+	}
+	return u + v
+}
+
+// Noise3 returns the noise at a point, it's in [-1, 1] and is 0 at
+// integer points
+func (p *Perlin) Noise3(x, y, z float64) float64 {
+	fx, fy, fz := math.Floor(x), math.Floor(y), math.Floor(z)
+	X, Y, Z := int(fx)&255, int(fy)&255, int(fz)&255
+	x, y, z = x-fx, y-fy, z-fz
+	u, v, w := fade(x), fade(y), fade(z)
+	perm := &p.perm
+	A := perm[X] + Y
+	AA, AB := perm[A]+Z, perm[A+1]+Z
+	B := perm[X+1] + Y
+	BA, BB := perm[B]+Z, perm[B+1]+Z
+	return lerp(w,
+		lerp(v,
+			lerp(u, grad(perm[AA], x, y, z), grad(perm[BA], x-1, y, z)),
+			lerp(u, grad(perm[AB], x, y-1, z), grad(perm[BB], x-1, y-1, z))),
+		lerp(v,
+			lerp(u, grad(perm[AA+1], x, y, z-1), grad(perm[BA+1], x-1, y, z-1)),
+			lerp(u, grad(perm[AB+1], x, y-1, z-1), grad(perm[BB+1], x-1, y-1, z-1))))
+}
+
+// Hash returns a pseudo random number in [0, 1) for a position, it's
+// used where each block needs its own random value that doesn't change
+// when the blocks are generated again
+func Hash(x, y, z int, seed int64) float64 {
+	h := uint64(seed) ^ uint64(x)*0x9e3779b97f4a7c15 ^ uint64(y)*0xc2b2ae3d27d4eb4f ^ uint64(z)*0x165667b19e3779f9
+	h ^= h >> 30
+	h *= 0xbf58476d1ce4e5b9
+	h ^= h >> 27
+	h *= 0x94d049bb133111eb
+	h ^= h >> 31
+	return float64(h>>11) / float64(1<<53)
+}
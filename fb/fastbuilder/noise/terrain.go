@@ -0,0 +1,183 @@
+package noise
+
+import "math"
+
+// Material is what a block of the terrain is made of, the users of the
+// terrain decide the blocks of the materials
+type Material uint8
+
+const (
+	MaterialStone Material = iota
+	MaterialSoil
+	MaterialTop
+	MaterialSand
+	MaterialWater
+	MaterialLog
+	MaterialLeaves
+)
+
+// TerrainBlock is a block of a column of the terrain, Y is from the
+// bottom of the terrain
+type TerrainBlock struct {
+	Y        int
+	Material Material
+}
+
+// the trees are in cells of this many blocks, at most one a cell, so
+// that their leaves never overlap
+const treeCell = 7
+
+// Terrain is hills of layered Perlin noise with lakes and trees. It only
+// depends on the seed, the settings and the x-z coordinates, so any part
+// of it can be generated on its own and the parts match.
+type Terrain struct {
+	// the width of the largest hills in blocks
+	Size float64
+	// the layers of noise, each is half as wide and high as the one before
+	Octaves int
+	// the highest the surface goes above the bottom
+	Height int
+	// the low parts are filled with water up to this height, 0 for no water
+	// and no lakes
+	WaterLevel int
+	// the blocks of soil under the top of the surface
+	SoilDepth int
+	// the chance of a tree in a cell, from 0 to 1
+	Trees float64
+
+	seed   int64
+	perlin *Perlin
+}
+
+// NewTerrain returns the terrain of the seed with the default settings,
+// they may be changed before the terrain is used
+func NewTerrain(seed int64) *Terrain {
+	return &Terrain{
+		Size:       64,
+		Octaves:    4,
+		Height:     32,
+		WaterLevel: 10,
+		SoilDepth:  3,
+		Trees:      0.3,
+		seed:       seed,
+		perlin:     NewPerlin(seed),
+	}
+}
+
+// Surface returns the height of the highest solid block at x z
+func (t *Terrain) Surface(x, z int) int {
+	sum, total := 0.0, 0.0
+	amplitude, size := 1.0, t.Size
+	for i := 0; i < t.Octaves; i++ {
+		// each layer is taken from another part of the noise so they don't line up
+		offset := float64(i) * 31.7
+		sum += amplitude * t.perlin.Noise3(float64(x)/size+offset, float64(z)/size+offset, 0.5)
+		total += amplitude
+		amplitude, size = amplitude/2, size/2
+	}
+	h := 0.5
+	if total > 0 {
+		// the sum rarely goes beyond 0.7 of the total
+		h = math.Max(0, math.Min(1, (sum/total*1.4+1)/2))
+	}
+	surface := h * float64(t.Height-1)
+	if t.WaterLevel > 0 {
+		// lakes are where the noise of twice the size is high, the ground
+		// sinks to 3 blocks below the water
+		lake := t.perlin.Noise3(float64(x)/t.Size/2-71.3, float64(z)/t.Size/2-71.3, 0.5)
+		if k := math.Min(1, (lake-0.2)/0.2); k > 0 {
+			bottom := math.Max(0, float64(t.WaterLevel-3))
+			surface = math.Min(surface, surface*(1-k)+bottom*k)
+		}
+	}
+	return int(math.Round(surface))
+}
+
+// beach tells if the surface at the height is sand
+func (t *Terrain) beach(surface int) bool {
+	return t.WaterLevel > 0 && surface <= t.WaterLevel+1
+}
+
+// Column returns the blocks of the column at x z from the bottom up, with
+// the parts of the trees around it
+func (t *Terrain) Column(x, z int) []TerrainBlock {
+	surface := t.Surface(x, z)
+	blocks := make([]TerrainBlock, 0, surface+8)
+	top, soil := MaterialTop, MaterialSoil
+	if t.beach(surface) {
+		top, soil = MaterialSand, MaterialSand
+	}
+	for y := 0; y <= surface; y++ {
+		material := MaterialStone
+		if y == surface {
+			material = top
+		} else if y >= surface-t.SoilDepth {
+			material = soil
+		}
+		blocks = append(blocks, TerrainBlock{Y: y, Material: material})
+	}
+	for y := surface + 1; y <= t.WaterLevel; y++ {
+		blocks = append(blocks, TerrainBlock{Y: y, Material: MaterialWater})
+	}
+	// the leaves reach 2 blocks from the trunk, the ones in the ground or
+	// the water of this column are left out
+	for cx := floorDiv(x-2, treeCell); cx <= floorDiv(x+2, treeCell); cx++ {
+		for cz := floorDiv(z-2, treeCell); cz <= floorDiv(z+2, treeCell); cz++ {
+			for _, block := range t.treeAt(cx, cz, x, z) {
+				if block.Y > surface && block.Y > t.WaterLevel {
+					blocks = append(blocks, block)
+				}
+			}
+		}
+	}
+	return blocks
+}
+
+// treeAt returns the blocks of the tree of the cell in the column at x z
+func (t *Terrain) treeAt(cx, cz, x, z int) []TerrainBlock {
+	if Hash(cx, 0, cz, t.seed) >= t.Trees {
+		return nil
+	}
+	// the trunk is in the middle 3x3 of the cell
+	tx := cx*treeCell + 2 + int(Hash(cx, 1, cz, t.seed)*3)
+	tz := cz*treeCell + 2 + int(Hash(cx, 2, cz, t.seed)*3)
+	dx, dz := x-tx, z-tz
+	if dx < -2 || dx > 2 || dz < -2 || dz > 2 {
+		return nil
+	}
+	ground := t.Surface(tx, tz)
+	if t.beach(ground) {
+		return nil
+	}
+	trunk := 4 + int(Hash(cx, 3, cz, t.seed)*3)
+	top := ground + trunk
+	var blocks []TerrainBlock
+	for y := ground + 1; y <= top+1; y++ {
+		if dx == 0 && dz == 0 && y <= top {
+			blocks = append(blocks, TerrainBlock{Y: y, Material: MaterialLog})
+			continue
+		}
+		var leaves bool
+		switch {
+		case y >= top-2 && y < top:
+			// wide layers without the corners
+			leaves = dx*dx+dz*dz < 8
+		case y == top:
+			leaves = dx*dx+dz*dz <= 2
+		case y == top+1:
+			leaves = dx*dx+dz*dz <= 1
+		}
+		if leaves {
+			blocks = append(blocks, TerrainBlock{Y: y, Material: MaterialLeaves})
+		}
+	}
+	return blocks
+}
+
+func floorDiv(a, b int) int {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return q
+}
@@ -0,0 +1,93 @@
+package noise
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTerrainColumn(t *testing.T) {
+	for _, c := range []struct {
+		name       string
+		waterLevel int
+		soilDepth  int
+		trees      float64
+	}{
+		{"default", 10, 3, 0.3},
+		{"no water", 0, 3, 0.3},
+		{"no soil", 10, 0, 0},
+		{"deep soil", 16, 6, 1},
+	} {
+		terrain := NewTerrain(42)
+		terrain.WaterLevel, terrain.SoilDepth, terrain.Trees = c.waterLevel, c.soilDepth, c.trees
+		logs := 0
+		for x := -16; x < 16; x++ {
+			for z := -16; z < 16; z++ {
+				surface := terrain.Surface(x, z)
+				if surface < 0 || surface >= terrain.Height {
+					t.Fatalf("%s: the surface at %d %d is %d", c.name, x, z, surface)
+				}
+				beach := c.waterLevel > 0 && surface <= c.waterLevel+1
+				column := terrain.Column(x, z)
+				for y := 0; y <= surface; y++ {
+					want := MaterialStone
+					switch {
+					case y == surface && beach:
+						want = MaterialSand
+					case y == surface:
+						want = MaterialTop
+					case y >= surface-c.soilDepth && beach:
+						want = MaterialSand
+					case y >= surface-c.soilDepth:
+						want = MaterialSoil
+					}
+					if column[y] != (TerrainBlock{Y: y, Material: want}) {
+						t.Fatalf("%s: %+v at %d %d, it should be %d, the surface is %d", c.name, column[y], x, z, want, surface)
+					}
+				}
+				water := 0
+				for _, block := range column[surface+1:] {
+					switch block.Material {
+					case MaterialWater:
+						water++
+						if block.Y <= surface || block.Y > c.waterLevel {
+							t.Fatalf("%s: water at %d %d %d", c.name, x, block.Y, z)
+						}
+					case MaterialLog, MaterialLeaves:
+						if block.Y <= surface || block.Y <= c.waterLevel {
+							t.Fatalf("%s: a tree at %d %d %d", c.name, x, block.Y, z)
+						}
+						if block.Material == MaterialLog {
+							logs++
+						}
+					default:
+						t.Fatalf("%s: %+v above the surface at %d %d", c.name, block, x, z)
+					}
+				}
+				if surface < c.waterLevel && water != c.waterLevel-surface || surface >= c.waterLevel && water != 0 {
+					t.Fatalf("%s: %d blocks of water at %d %d", c.name, water, x, z)
+				}
+			}
+		}
+		if (logs > 0) != (c.trees > 0) {
+			t.Fatalf("%s: %d logs with the chance of trees %v", c.name, logs, c.trees)
+		}
+	}
+}
+
+func TestTerrainIsStable(t *testing.T) {
+	a, b, other := NewTerrain(7), NewTerrain(7), NewTerrain(8)
+	differs := false
+	for x := -40; x < 40; x += 3 {
+		for z := -40; z < 40; z += 3 {
+			if !reflect.DeepEqual(a.Column(x, z), b.Column(x, z)) {
+				t.Fatalf("the columns at %d %d differ with the same seed", x, z)
+			}
+			if a.Surface(x, z) != other.Surface(x, z) {
+				differs = true
+			}
+		}
+	}
+	if !differs {
+		t.Fatal("the terrains of two seeds are the same")
+	}
+}
@@ -65,6 +65,11 @@ func Parse(Message string, defaultConfig *types.MainConfig) (*types.MainConfig,
 		InvalidateCommands: false,
 	}
 
+	if len(SLC) == 0 {
+		return nil, fmt.Errorf(I18n.T(I18n.CommandNotFound))
+	}
+	Config.Args = SLC[1:]
+
 	FlagSet := flag.NewFlagSet("Parser", 0)
 	var tempBlockData int
 	var tempOldBlockData int
@@ -94,6 +99,39 @@ func Parse(Message string, defaultConfig *types.MainConfig) (*types.MainConfig,
 	FlagSet.StringVar(&Config.Path, "p", defaultConfig.Path, "The path of file")
 	FlagSet.StringVar(&Config.Shape, "shape", defaultConfig.Shape, "The path of file")
 	FlagSet.StringVar(&Config.Shape, "s", defaultConfig.Shape, "The path of file")
+	FlagSet.StringVar(&Config.Format, "format", defaultConfig.Format, "The format of exported file (bdx, mcstructure, schem)")
+	//Dry run
+	FlagSet.BoolVar(&Config.DryRun, "dryrun", defaultConfig.DryRun, "Build into a local world instead of the server")
+	FlagSet.StringVar(&Config.DryRunPath, "dryrun_path", defaultConfig.DryRunPath, "Save the result of dry run to a world folder, or a .mcstructure or .schem file")
+	FlagSet.StringVar(&Config.DryRunGenerator, "generator", defaultConfig.DryRunGenerator, "Generate the world of dry run: landscape (with the flags of landscape), or empty for an empty world")
+	//Patterns and masks
+	FlagSet.StringVar(&Config.Mask, "mask", defaultConfig.Mask, "Only build where the block in the world is one of these")
+	FlagSet.Int64Var(&Config.Seed, "seed", defaultConfig.Seed, "The seed of random patterns")
+	//Region operations
+	FlagSet.StringVar(&Config.Method, "method", Config.Method, "How blocks are set: replace, keep or destroy")
+	FlagSet.StringVar(&Config.OldMethod, "old_method", Config.OldMethod, "How old blocks are matched: keep (-od must match) or any")
+	//Orientation of imported structures
+	FlagSet.IntVar(&Config.Rotation, "rotate", defaultConfig.Rotation, "Rotate the structure clockwise by 90, 180 or 270 degrees")
+	FlagSet.IntVar(&Config.Rotation, "deg", defaultConfig.Rotation, "Rotate the structure clockwise by 90, 180 or 270 degrees")
+	FlagSet.StringVar(&Config.Mirror, "mirror", defaultConfig.Mirror, "Mirror the structure along x or z")
+	FlagSet.IntVar(&Config.Scale, "scale", defaultConfig.Scale, "Scale the structure by an integer")
+	//Verification
+	FlagSet.IntVar(&Config.Verify, "verify", defaultConfig.Verify, "Verify the blocks after building and build the wrong ones again at most this many times")
+	//Curves
+	FlagSet.Var((*pointsFlag)(&Config.Points), "point", "A control point of the curve as x,y,z, it can be repeated")
+	FlagSet.StringVar(&Config.Curve, "curve", defaultConfig.Curve, "How the curve goes through the points: bezier, catmullrom or polyline")
+	//Terrain
+	FlagSet.StringVar(&Config.Top, "top", defaultConfig.Top, "The block on the surface of the terrain")
+	FlagSet.StringVar(&Config.Soil, "soil", defaultConfig.Soil, "The block under the surface of the terrain")
+	FlagSet.IntVar(&Config.SoilDepth, "soil_depth", defaultConfig.SoilDepth, "How many blocks of soil are under the surface")
+	FlagSet.IntVar(&Config.WaterLevel, "water_level", defaultConfig.WaterLevel, "Fill water up to this many blocks above the bottom of the terrain, 0 for no water")
+	//Landscape
+	FlagSet.IntVar(&Config.Octaves, "octaves", defaultConfig.Octaves, "The layers of noise of the landscape, each is half as wide as the one before")
+	FlagSet.IntVar(&Config.NoiseSize, "noise_size", defaultConfig.NoiseSize, "The width of the largest hills of the landscape")
+	FlagSet.IntVar(&Config.Trees, "trees", defaultConfig.Trees, "The chance of a tree in each 7x7 cell of the landscape, in percent")
+	//Queue
+	FlagSet.IntVar(&Config.Priority, "priority", defaultConfig.Priority, "Tasks of higher priority are started first")
+	FlagSet.Int64Var(&Config.After, "after", defaultConfig.After, "Start the task after the task of this id is done")
 	//Block
 	FlagSet.StringVar(&Config.Block.Name, "block", defaultConfig.Block.Name, "Blocks that make up the building")
 	FlagSet.StringVar(&Config.Block.Name, "b", defaultConfig.Block.Name, "Blocks that make up the building")
@@ -129,11 +167,34 @@ func Parse(Message string, defaultConfig *types.MainConfig) (*types.MainConfig,
 	return Config, nil
 }
 
+// pointsFlag appends a point to the list every time the flag is given
+type pointsFlag []types.Position
+
+func (points *pointsFlag) String() string {
+	if points == nil {
+		return ""
+	}
+	items := make([]string, len(*points))
+	for i, point := range *points {
+		items[i] = fmt.Sprintf("%d,%d,%d", point.X, point.Y, point.Z)
+	}
+	return strings.Join(items, " ")
+}
+
+func (points *pointsFlag) Set(value string) error {
+	var point types.Position
+	if _, err := fmt.Sscanf(value, "%d,%d,%d", &point.X, &point.Y, &point.Z); err != nil {
+		return fmt.Errorf("invalid point %q, it should be x,y,z", value)
+	}
+	*points = append(*points, point)
+	return nil
+}
+
 func PipeParse(Message string, config *types.MainConfig) ([]*types.MainConfig, error) {
 	ChatSlice := strings.Split(Message, "|")
 	var Configs []*types.MainConfig
 	for _, v := range ChatSlice {
-		pv, err := Parse(v, config)
+		pv, err := Parse(strings.TrimSpace(v), config)
 		if err != nil {
 			return nil, err
 		}
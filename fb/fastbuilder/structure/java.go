@@ -0,0 +1,377 @@
+package structure
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/bits"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/types"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/world_provider"
+	"phoenixbuilder_3rd_gui/fb/minecraft/nbt"
+	"phoenixbuilder_3rd_gui/fb/minecraft/protocol/packet"
+	"reflect"
+	"strings"
+)
+
+// nbtInts reads a TAG_Int_Array, a TAG_Long_Array or a TAG_List of numbers
+func nbtInts(v interface{}) []int64 {
+	val := reflect.ValueOf(v)
+	if val.Kind() != reflect.Array && val.Kind() != reflect.Slice {
+		return nil
+	}
+	ints := make([]int64, val.Len())
+	for i := range ints {
+		elem := val.Index(i)
+		if elem.Kind() == reflect.Interface {
+			elem = elem.Elem()
+		}
+		switch elem.Kind() {
+		case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			ints[i] = elem.Int()
+		case reflect.Uint8:
+			ints[i] = int64(elem.Uint())
+		}
+	}
+	return ints
+}
+
+// nbtBytes reads a TAG_Byte_Array
+func nbtBytes(v interface{}) []byte {
+	val := reflect.ValueOf(v)
+	if val.Kind() != reflect.Array || val.Type().Elem().Kind() != reflect.Uint8 {
+		return nil
+	}
+	data := make([]byte, val.Len())
+	reflect.Copy(reflect.ValueOf(data), val)
+	return data
+}
+
+// javaText returns the plain text of a json text component
+func javaText(text string) string {
+	var component struct {
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal([]byte(text), &component); err != nil {
+		return text
+	}
+	return component.Text
+}
+
+// javaPaletteBlock is a java block state converted into bedrock
+type javaPaletteBlock struct {
+	state     string
+	name      string
+	data      uint16
+	runtimeId uint32
+	skip      bool
+	unmapped  bool
+}
+
+func (s *Structure) convertJavaState(state string, properties map[string]string) javaPaletteBlock {
+	name, data, exact := JavaToBedrock(state, properties)
+	runtimeId, found := RuntimeIdOf(name, data)
+	blk := javaPaletteBlock{
+		state:     state,
+		name:      name,
+		data:      data,
+		runtimeId: runtimeId,
+		skip:      name == "air" || name == "structure_void",
+	}
+	blk.unmapped = !blk.skip && runtimeId == world_provider.AirRuntimeId
+	if !blk.skip && !blk.unmapped && (!found || !exact) {
+		s.addInexact(strings.TrimPrefix(state, "minecraft:"))
+	}
+	return blk
+}
+
+// addJavaBlock adds a block at the point, with the block entity if there is one
+func (s *Structure) addJavaBlock(blk javaPaletteBlock, point types.Position, blockEntity map[string]interface{}) {
+	if blk.skip {
+		return
+	}
+	if blk.unmapped {
+		s.Unmapped[strings.TrimPrefix(blk.state, "minecraft:")]++
+		return
+	}
+	module := &types.RuntimeModule{
+		BlockRuntimeId: blk.runtimeId,
+		Point:          point,
+	}
+	if blockEntity != nil {
+		if strings.Contains(blk.name, "command_block") {
+			module.CommandBlockData = javaCommandBlockData(blk.name, blk.data, blockEntity)
+		} else if _, hasItems := blockEntity["Items"]; hasItems {
+			module.ChestData = javaChestData(blockEntity)
+		}
+	}
+	s.Blocks = append(s.Blocks, module)
+}
+
+func javaCommandBlockData(name string, data uint16, tag map[string]interface{}) *types.CommandBlockData {
+	var mode uint32 = packet.CommandBlockImpulse
+	switch name {
+	case "repeating_command_block":
+		mode = packet.CommandBlockRepeat
+	case "chain_command_block":
+		mode = packet.CommandBlockChain
+	}
+	return &types.CommandBlockData{
+		Mode:       mode,
+		Command:    nbtString(tag["Command"]),
+		CustomName: javaText(nbtString(tag["CustomName"])),
+		// java command blocks always run on the first tick
+		ExecuteOnFirstTick: true,
+		TrackOutput:        nbtBool(tag["TrackOutput"]),
+		Conditional:        data&8 != 0,
+		NeedRedstone:       !nbtBool(tag["auto"]),
+	}
+}
+
+func javaChestData(tag map[string]interface{}) *types.ChestData {
+	items, _ := tag["Items"].([]interface{})
+	chest := make(types.ChestData, 0, len(items))
+	for _, iface := range items {
+		item, ok := iface.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name := strings.TrimPrefix(nbtString(item["id"]), "minecraft:")
+		if name == "" {
+			continue
+		}
+		var damage int
+		if itemTag, ok := item["tag"].(map[string]interface{}); ok {
+			damage = nbtInt(itemTag["Damage"])
+		}
+		chest = append(chest, types.ChestSlot{
+			Name:   name,
+			Count:  uint8(nbtInt(item["Count"])),
+			Damage: uint16(damage),
+			Slot:   uint8(nbtInt(item["Slot"])),
+		})
+	}
+	return &chest
+}
+
+// ReadJavaStructure reads a Sponge schematic (version 1 to 3) or a
+// litematica schematic, java blocks are converted into bedrock blocks.
+func ReadJavaStructure(r io.Reader) (*Structure, error) {
+	gz, err := gzip.NewReader(bufio.NewReader(r))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to resolve file (gzip)")
+	}
+	defer gz.Close()
+	buffer, err := ioutil.ReadAll(gz)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to resolve file (gzip)")
+	}
+	var content map[string]interface{}
+	if err := nbt.UnmarshalEncoding(buffer, &content, nbt.BigEndian); err != nil {
+		// Won't return the error since it may contain a large content
+		return nil, fmt.Errorf("Failed to resolve file (nbt)")
+	}
+	if regions, ok := content["Regions"].(map[string]interface{}); ok {
+		return readLitematic(regions)
+	}
+	// The schematic is wrapped in an unnamed root compound since version 3
+	if schematic, ok := content["Schematic"].(map[string]interface{}); ok {
+		content = schematic
+	}
+	if _, ok := content["Palette"]; ok {
+		return readSpongeSchematic(content)
+	}
+	if _, ok := content["Blocks"].(map[string]interface{}); ok {
+		return readSpongeSchematic(content)
+	}
+	return nil, fmt.Errorf("Not a sponge or litematica schematic")
+}
+
+func readSpongeSchematic(content map[string]interface{}) (*Structure, error) {
+	s := newStructure()
+	s.Size = [3]int{
+		int(uint16(nbtInt(content["Width"]))),
+		int(uint16(nbtInt(content["Height"]))),
+		int(uint16(nbtInt(content["Length"]))),
+	}
+	version := nbtInt(content["Version"])
+	var rawPalette map[string]interface{}
+	var blockData []byte
+	var blockEntities []interface{}
+	if version >= 3 {
+		blocks, _ := content["Blocks"].(map[string]interface{})
+		rawPalette, _ = blocks["Palette"].(map[string]interface{})
+		blockData = nbtBytes(blocks["Data"])
+		blockEntities, _ = blocks["BlockEntities"].([]interface{})
+	} else {
+		rawPalette, _ = content["Palette"].(map[string]interface{})
+		blockData = nbtBytes(content["BlockData"])
+		blockEntities, _ = content["BlockEntities"].([]interface{})
+		if version == 1 {
+			blockEntities, _ = content["TileEntities"].([]interface{})
+		}
+	}
+	palette := make(map[int]javaPaletteBlock, len(rawPalette))
+	for state, index := range rawPalette {
+		name, properties := ParseJavaState(state)
+		palette[nbtInt(index)] = s.convertJavaState(name, properties)
+	}
+	entities := map[types.Position]map[string]interface{}{}
+	for _, iface := range blockEntities {
+		entity, ok := iface.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		pos := nbtInts(entity["Pos"])
+		if len(pos) != 3 {
+			continue
+		}
+		if data, ok := entity["Data"].(map[string]interface{}); ok {
+			// version 3 keeps the block entity data in a separate compound
+			entity = data
+		}
+		entities[types.Position{X: int(pos[0]), Y: int(pos[1]), Z: int(pos[2])}] = entity
+	}
+	width, length := s.Size[0], s.Size[2]
+	volume := s.Size[0] * s.Size[1] * s.Size[2]
+	offset := 0
+	for i := 0; i < volume; i++ {
+		// varint encoded palette indices
+		var value, shift uint
+		for {
+			if offset >= len(blockData) {
+				return nil, fmt.Errorf("Unexpected end of block data")
+			}
+			b := blockData[offset]
+			offset++
+			value |= uint(b&0x7f) << shift
+			if b&0x80 == 0 {
+				break
+			}
+			shift += 7
+		}
+		blk, ok := palette[int(value)]
+		if !ok {
+			return nil, fmt.Errorf("Invalid palette index: %d", value)
+		}
+		point := types.Position{X: i % width, Y: i / (width * length), Z: (i / width) % length}
+		s.addJavaBlock(blk, point, entities[point])
+	}
+	return s, nil
+}
+
+type litematicRegion struct {
+	min, size [3]int
+	content   map[string]interface{}
+}
+
+func readLitematic(regions map[string]interface{}) (*Structure, error) {
+	s := newStructure()
+	parsedRegions := make([]litematicRegion, 0, len(regions))
+	min := [3]int{}
+	max := [3]int{}
+	for _, iface := range regions {
+		content, ok := iface.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		position, _ := content["Position"].(map[string]interface{})
+		size, _ := content["Size"].(map[string]interface{})
+		region := litematicRegion{content: content}
+		for i, axis := range []string{"x", "y", "z"} {
+			p, l := nbtInt(position[axis]), nbtInt(size[axis])
+			// the size is negative if the region expands towards the negative axis
+			if l < 0 {
+				p += l + 1
+				l = -l
+			}
+			region.min[i], region.size[i] = p, l
+		}
+		for i := 0; i < 3; i++ {
+			if len(parsedRegions) == 0 || region.min[i] < min[i] {
+				min[i] = region.min[i]
+			}
+			if len(parsedRegions) == 0 || region.min[i]+region.size[i] > max[i] {
+				max[i] = region.min[i] + region.size[i]
+			}
+		}
+		parsedRegions = append(parsedRegions, region)
+	}
+	if len(parsedRegions) == 0 {
+		return nil, fmt.Errorf("The schematic has no region")
+	}
+	s.Size = [3]int{max[0] - min[0], max[1] - min[1], max[2] - min[2]}
+	for _, region := range parsedRegions {
+		if err := s.readLitematicRegion(region, min); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func (s *Structure) readLitematicRegion(region litematicRegion, min [3]int) error {
+	rawPalette, _ := region.content["BlockStatePalette"].([]interface{})
+	if len(rawPalette) == 0 {
+		return fmt.Errorf("Invalid block state palette")
+	}
+	palette := make([]javaPaletteBlock, len(rawPalette))
+	for i, iface := range rawPalette {
+		entry, _ := iface.(map[string]interface{})
+		properties := map[string]string{}
+		if rawProperties, ok := entry["Properties"].(map[string]interface{}); ok {
+			for k, v := range rawProperties {
+				properties[k] = nbtString(v)
+			}
+		}
+		palette[i] = s.convertJavaState(nbtString(entry["Name"]), properties)
+	}
+	entities := map[types.Position]map[string]interface{}{}
+	tileEntities, _ := region.content["TileEntities"].([]interface{})
+	for _, iface := range tileEntities {
+		entity, ok := iface.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		entities[types.Position{X: nbtInt(entity["x"]), Y: nbtInt(entity["y"]), Z: nbtInt(entity["z"])}] = entity
+	}
+	states := nbtInts(region.content["BlockStates"])
+	// at least 2 bits are used for each block
+	bitsPerBlock := bits.Len(uint(len(palette) - 1))
+	if bitsPerBlock < 2 {
+		bitsPerBlock = 2
+	}
+	mask := uint64(1)<<uint(bitsPerBlock) - 1
+	sizeX, sizeY, sizeZ := region.size[0], region.size[1], region.size[2]
+	if len(states)*64 < sizeX*sizeY*sizeZ*bitsPerBlock {
+		return fmt.Errorf("Unexpected end of block states")
+	}
+	for y := 0; y < sizeY; y++ {
+		for z := 0; z < sizeZ; z++ {
+			for x := 0; x < sizeX; x++ {
+				// the indices are packed into longs, and may span two longs
+				index := (y*sizeZ+z)*sizeX + x
+				startBit := index * bitsPerBlock
+				startLong, endLong := startBit/64, (startBit+bitsPerBlock-1)/64
+				offset := uint(startBit % 64)
+				value := uint64(states[startLong]) >> offset
+				if startLong != endLong {
+					value |= uint64(states[endLong]) << (64 - offset)
+				}
+				value &= mask
+				if int(value) >= len(palette) {
+					return fmt.Errorf("Invalid palette index: %d", value)
+				}
+				local := types.Position{X: x, Y: y, Z: z}
+				point := types.Position{
+					X: region.min[0] - min[0] + x,
+					Y: region.min[1] - min[1] + y,
+					Z: region.min[2] - min[2] + z,
+				}
+				s.addJavaBlock(palette[value], point, entities[local])
+			}
+		}
+	}
+	return nil
+}
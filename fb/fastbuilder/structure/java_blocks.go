@@ -0,0 +1,372 @@
+package structure
+
+import (
+	"fmt"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/types"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Bedrock (1.17) blocks are identified by a name and a legacy data value,
+// while java blocks are identified by a flattened name and block states.
+// The tables below cover the blocks whose name or data differs between
+// the two editions, other blocks are assumed to share the same name.
+
+var colors = []string{
+	"white", "orange", "magenta", "light_blue",
+	"yellow", "lime", "pink", "gray",
+	"light_gray", "cyan", "purple", "blue",
+	"brown", "green", "red", "black",
+}
+
+var woodTypes = []string{"oak", "spruce", "birch", "jungle", "acacia", "dark_oak"}
+
+// bedrock name -> java names indexed by data
+var dataVariants = map[string][]string{
+	"stone":            {"stone", "granite", "polished_granite", "diorite", "polished_diorite", "andesite", "polished_andesite"},
+	"dirt":             {"dirt", "coarse_dirt"},
+	"sand":             {"sand", "red_sand"},
+	"sandstone":        {"sandstone", "chiseled_sandstone", "cut_sandstone", "smooth_sandstone"},
+	"red_sandstone":    {"red_sandstone", "chiseled_red_sandstone", "cut_red_sandstone", "smooth_red_sandstone"},
+	"stonebrick":       {"stone_bricks", "mossy_stone_bricks", "cracked_stone_bricks", "chiseled_stone_bricks"},
+	"prismarine":       {"prismarine", "dark_prismarine", "prismarine_bricks"},
+	"sponge":           {"sponge", "wet_sponge"},
+	"cobblestone_wall": {"cobblestone_wall", "mossy_cobblestone_wall"},
+	"red_flower":       {"poppy", "blue_orchid", "allium", "azure_bluet", "red_tulip", "orange_tulip", "white_tulip", "pink_tulip", "oxeye_daisy", "cornflower", "lily_of_the_valley"},
+	"tallgrass":        {"dead_bush", "grass", "fern"},
+	"monster_egg":      {"infested_stone", "infested_cobblestone", "infested_stone_bricks", "infested_mossy_stone_bricks", "infested_cracked_stone_bricks", "infested_chiseled_stone_bricks"},
+}
+
+// bedrock name -> java suffix, the data is the color
+var colorVariants = map[string]string{
+	"wool":                  "_wool",
+	"carpet":                "_carpet",
+	"concrete":              "_concrete",
+	"concretePowder":        "_concrete_powder",
+	"stained_glass":         "_stained_glass",
+	"stained_glass_pane":    "_stained_glass_pane",
+	"stained_hardened_clay": "_terracotta",
+	"shulker_box":           "_shulker_box",
+}
+
+// bedrock name -> java suffix, the data is the wood type
+var woodVariants = map[string]string{
+	"planks":  "_planks",
+	"sapling": "_sapling",
+}
+
+// blocks that are only renamed
+var renamedBlocks = map[string]string{
+	"grass":                 "grass_block",
+	"hardened_clay":         "terracotta",
+	"brick_block":           "bricks",
+	"snow":                  "snow_block",
+	"snow_layer":            "snow",
+	"lit_pumpkin":           "jack_o_lantern",
+	"web":                   "cobweb",
+	"noteblock":             "note_block",
+	"nether_brick":          "nether_bricks",
+	"red_nether_brick":      "red_nether_bricks",
+	"end_bricks":            "end_stone_bricks",
+	"mob_spawner":           "spawner",
+	"golden_rail":           "powered_rail",
+	"quartz_ore":            "nether_quartz_ore",
+	"magma":                 "magma_block",
+	"slime":                 "slime_block",
+	"melon_block":           "melon",
+	"yellow_flower":         "dandelion",
+	"waterlily":             "lily_pad",
+	"deadbush":              "dead_bush",
+	"lit_redstone_lamp":     "redstone_lamp[lit=true]",
+	"trapdoor":              "oak_trapdoor",
+	"fence_gate":            "oak_fence_gate",
+	"wooden_pressure_plate": "oak_pressure_plate",
+	"wooden_button":         "oak_button",
+	"unlit_redstone_torch":  "redstone_torch[lit=false]",
+	"invisibleBedrock":      "barrier",
+	"seaLantern":            "sea_lantern",
+	"movingBlock":           "air",
+	"reserved6":             "air",
+}
+
+var commandBlockFacings = []string{"down", "up", "north", "south", "west", "east"}
+
+// BedrockToJava returns the java block state of a bedrock block,
+// exact is false if the data of the block couldn't be represented and
+// the default state of the block is used instead.
+func BedrockToJava(name string, data uint16) (state string, exact bool) {
+	name = strings.TrimPrefix(name, "minecraft:")
+	if variants, ok := dataVariants[name]; ok {
+		if int(data) < len(variants) {
+			return "minecraft:" + variants[data], true
+		}
+		return "minecraft:" + variants[0], false
+	}
+	if suffix, ok := colorVariants[name]; ok {
+		return "minecraft:" + colors[data&15] + suffix, data < 16
+	}
+	if suffix, ok := woodVariants[name]; ok {
+		if int(data&7) < len(woodTypes) {
+			return "minecraft:" + woodTypes[data&7] + suffix, data < 8
+		}
+		return "minecraft:oak" + suffix, false
+	}
+	if strings.HasSuffix(name, "_stairs") {
+		javaName := name
+		if name == "stone_stairs" {
+			javaName = "cobblestone_stairs"
+		} else if name == "normal_stone_stairs" {
+			javaName = "stone_stairs"
+		}
+		half := "bottom"
+		if data&4 != 0 {
+			half = "top"
+		}
+		facing := []string{"east", "west", "south", "north"}[data&3]
+		return fmt.Sprintf("minecraft:%s[facing=%s,half=%s]", javaName, facing, half), data < 8
+	}
+	switch name {
+	case "wooden_slab", "double_wooden_slab", "stone_slab", "double_stone_slab":
+		materials := stoneSlabTypes
+		if strings.Contains(name, "wooden") {
+			materials = woodTypes
+		}
+		if int(data&7) >= len(materials) {
+			return "minecraft:oak_slab", false
+		}
+		slabType := "bottom"
+		if strings.HasPrefix(name, "double_") {
+			slabType = "double"
+		} else if data&8 != 0 {
+			slabType = "top"
+		}
+		return fmt.Sprintf("minecraft:%s_slab[type=%s]", materials[data&7], slabType), true
+	case "log", "log2":
+		// data&3 is the type, data>>2 is the axis
+		woodType := int(data & 3)
+		if name == "log2" {
+			woodType += 4
+		}
+		if woodType >= len(woodTypes) {
+			return "minecraft:oak_log", false
+		}
+		axis := []string{"y", "x", "z", "y"}[(data>>2)&3]
+		return fmt.Sprintf("minecraft:%s_log[axis=%s]", woodTypes[woodType], axis), data>>2 != 3
+	case "leaves", "leaves2":
+		woodType := int(data & 3)
+		if name == "leaves2" {
+			woodType += 4
+		}
+		if woodType >= len(woodTypes) {
+			return "minecraft:oak_leaves", false
+		}
+		return fmt.Sprintf("minecraft:%s_leaves[persistent=true]", woodTypes[woodType]), true
+	case "quartz_block":
+		switch data {
+		case 0:
+			return "minecraft:quartz_block", true
+		case 1:
+			return "minecraft:chiseled_quartz_block", true
+		case 2, 6, 10:
+			axis := map[uint16]string{2: "y", 6: "x", 10: "z"}[data]
+			return "minecraft:quartz_pillar[axis=" + axis + "]", true
+		case 3:
+			return "minecraft:smooth_quartz", true
+		}
+		return "minecraft:quartz_block", false
+	case "command_block", "repeating_command_block", "chain_command_block":
+		facing := int(data & 7)
+		if facing >= len(commandBlockFacings) {
+			facing = 1
+		}
+		return fmt.Sprintf("minecraft:%s[conditional=%v,facing=%s]", name, data&8 != 0, commandBlockFacings[facing]), true
+	case "chest", "trapped_chest", "ender_chest":
+		// 2-5: north south west east
+		facings := map[uint16]string{2: "north", 3: "south", 4: "west", 5: "east"}
+		if facing, ok := facings[data]; ok {
+			return fmt.Sprintf("minecraft:%s[facing=%s]", name, facing), true
+		}
+		return "minecraft:" + name, data == 0
+	}
+	if renamed, ok := renamedBlocks[name]; ok {
+		return "minecraft:" + renamed, data == 0
+	}
+	return "minecraft:" + name, data == 0
+}
+
+// java name -> bedrock block, built from the tables above
+var javaBlocks map[string]types.ConstBlock
+var javaBlocksOnce sync.Once
+
+func initJavaBlocks() {
+	javaBlocks = map[string]types.ConstBlock{}
+	for name, variants := range dataVariants {
+		for data, variant := range variants {
+			javaBlocks[variant] = types.ConstBlock{Name: name, Data: uint16(data)}
+		}
+	}
+	for name, suffix := range colorVariants {
+		for data, color := range colors {
+			javaBlocks[color+suffix] = types.ConstBlock{Name: name, Data: uint16(data)}
+		}
+	}
+	for name, suffix := range woodVariants {
+		for data, woodType := range woodTypes {
+			javaBlocks[woodType+suffix] = types.ConstBlock{Name: name, Data: uint16(data)}
+		}
+	}
+	for name, renamed := range renamedBlocks {
+		// only the blocks that are renamed in both directions
+		if renamed == "air" || renamed == "barrier" || strings.Contains(renamed, "[") {
+			continue
+		}
+		javaBlocks[renamed] = types.ConstBlock{Name: name, Data: 0}
+	}
+	for i, color := range colors {
+		javaBlocks[color+"_glazed_terracotta"] = types.ConstBlock{Name: bedrockColors[i] + "_glazed_terracotta", Data: 0}
+	}
+	javaBlocks["terracotta"] = types.ConstBlock{Name: "hardened_clay", Data: 0}
+	javaBlocks["stone_stairs"] = types.ConstBlock{Name: "normal_stone_stairs", Data: 0}
+	javaBlocks["cobblestone_stairs"] = types.ConstBlock{Name: "stone_stairs", Data: 0}
+	javaBlocks["cave_air"] = types.ConstBlock{Name: "air", Data: 0}
+	javaBlocks["void_air"] = types.ConstBlock{Name: "air", Data: 0}
+}
+
+var stoneSlabTypes = []string{"smooth_stone", "sandstone", "petrified_oak", "cobblestone", "brick", "stone_brick", "quartz", "nether_brick"}
+
+var horizontalFacings = map[string]uint16{"north": 2, "south": 3, "west": 4, "east": 5}
+var stairFacings = map[string]uint16{"east": 0, "west": 1, "south": 2, "north": 3}
+var pillarAxes = map[string]uint16{"y": 0, "x": 4, "z": 8}
+
+// ParseJavaState splits a java block state like minecraft:oak_log[axis=x]
+// into the name without namespace and the properties
+func ParseJavaState(state string) (name string, properties map[string]string) {
+	properties = map[string]string{}
+	name = state
+	if i := strings.Index(state, "["); i != -1 {
+		name = state[:i]
+		for _, kv := range strings.Split(strings.TrimSuffix(state[i+1:], "]"), ",") {
+			pair := strings.SplitN(kv, "=", 2)
+			if len(pair) == 2 {
+				properties[strings.TrimSpace(pair[0])] = strings.TrimSpace(pair[1])
+			}
+		}
+	}
+	return strings.TrimPrefix(name, "minecraft:"), properties
+}
+
+// JavaToBedrock converts a java block into bedrock name and data,
+// exact is false if some properties of the block couldn't be represented.
+func JavaToBedrock(name string, properties map[string]string) (bedrockName string, data uint16, exact bool) {
+	javaBlocksOnce.Do(initJavaBlocks)
+	name = strings.TrimPrefix(name, "minecraft:")
+	// the properties that are understood
+	used := map[string]bool{}
+	use := func(key string) string {
+		used[key] = true
+		return properties[key]
+	}
+	bedrockName = name
+	if blk, ok := javaBlocks[name]; ok {
+		bedrockName, data = blk.Name, blk.Data
+	}
+	switch {
+	case strings.HasSuffix(name, "_log") || strings.HasSuffix(name, "_wood") || name == "quartz_pillar" || name == "purpur_pillar" || name == "bone_block" || name == "hay_block" || name == "basalt" || name == "polished_basalt":
+		if woodType, isLog := indexOf(woodTypes, strings.TrimSuffix(name, "_log")); isLog && strings.HasSuffix(name, "_log") {
+			bedrockName, data = "log", uint16(woodType)
+			if woodType >= 4 {
+				bedrockName, data = "log2", uint16(woodType-4)
+			}
+		} else if name == "quartz_pillar" {
+			bedrockName, data = "quartz_block", 2
+		} else if name == "purpur_pillar" {
+			bedrockName, data = "purpur_block", 2
+		}
+		if strings.HasPrefix(name, "stripped_") || strings.HasSuffix(name, "basalt") {
+			// newer blocks use the axis as data directly
+			data = pillarAxes[use("axis")] >> 2
+		} else {
+			data |= pillarAxes[use("axis")]
+		}
+	case strings.HasSuffix(name, "_leaves"):
+		if woodType, ok := indexOf(woodTypes, strings.TrimSuffix(name, "_leaves")); ok {
+			bedrockName, data = "leaves", uint16(woodType)
+			if woodType >= 4 {
+				bedrockName, data = "leaves2", uint16(woodType-4)
+			}
+		}
+		if use("persistent") == "true" {
+			data |= 4
+		}
+		use("distance")
+	case strings.HasSuffix(name, "_slab"):
+		slabType := use("type")
+		material := strings.TrimSuffix(name, "_slab")
+		if woodType, ok := indexOf(woodTypes, material); ok {
+			bedrockName, data = "wooden_slab", uint16(woodType)
+			if slabType == "double" {
+				bedrockName = "double_wooden_slab"
+			}
+		} else if stoneType, ok := indexOf(stoneSlabTypes, material); ok {
+			bedrockName, data = "stone_slab", uint16(stoneType)
+			if slabType == "double" {
+				bedrockName = "double_stone_slab"
+			}
+		} else {
+			return bedrockName, 0, false
+		}
+		if slabType == "top" {
+			data |= 8
+		}
+		use("waterlogged")
+	case strings.HasSuffix(name, "_stairs"):
+		data |= stairFacings[use("facing")]
+		if use("half") == "top" {
+			data |= 4
+		}
+		use("shape")
+		use("waterlogged")
+	case name == "command_block" || name == "repeating_command_block" || name == "chain_command_block":
+		facing, _ := indexOf(commandBlockFacings, use("facing"))
+		data = uint16(facing)
+		if use("conditional") == "true" {
+			data |= 8
+		}
+	case name == "chest" || name == "trapped_chest" || name == "ender_chest" || name == "furnace" || name == "ladder":
+		if facing, ok := horizontalFacings[use("facing")]; ok {
+			data = facing
+		}
+		use("type")
+		use("waterlogged")
+		use("lit")
+	case name == "water" || name == "lava":
+		level, _ := strconv.Atoi(use("level"))
+		data = uint16(level)
+	case name == "redstone_lamp":
+		if use("lit") == "true" {
+			bedrockName = "lit_redstone_lamp"
+		}
+	case name == "snow":
+		layers, _ := strconv.Atoi(use("layers"))
+		if layers > 0 {
+			data = uint16(layers - 1)
+		}
+	}
+	exact = true
+	for key := range properties {
+		if !used[key] {
+			exact = false
+		}
+	}
+	return bedrockName, data, exact
+}
+
+func indexOf(values []string, value string) (int, bool) {
+	for i, v := range values {
+		if v == value {
+			return i, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,180 @@
+package structure
+
+import (
+	"bytes"
+	"compress/gzip"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/types"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/world_provider"
+	"phoenixbuilder_3rd_gui/fb/minecraft/nbt"
+	"testing"
+)
+
+// gzipNBT encodes a schematic like the files of java edition
+func gzipNBT(t *testing.T, content map[string]interface{}) *bytes.Buffer {
+	payload, err := nbt.MarshalEncoding(content, nbt.BigEndian)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	gz.Write(payload)
+	gz.Close()
+	return buf
+}
+
+// blockNames returns the bedrock names of the blocks of a structure by
+// their points
+func blockNames(s *Structure) map[types.Position]string {
+	names := map[types.Position]string{}
+	for _, blk := range s.Blocks {
+		names[blk.Point] = world_provider.RuntimeIdArray_117[blk.BlockRuntimeId].Name
+	}
+	return names
+}
+
+// packLitematic packs palette indices into longs like litematica, an
+// index may span two longs
+func packLitematic(indices []int, bitsPerBlock int) []int64 {
+	longs := make([]int64, (len(indices)*bitsPerBlock+63)/64)
+	for i, index := range indices {
+		startBit := i * bitsPerBlock
+		longs[startBit/64] |= int64(uint64(index) << uint(startBit%64))
+		if endLong := (startBit + bitsPerBlock - 1) / 64; endLong != startBit/64 {
+			longs[endLong] |= int64(uint64(index) >> uint(64-startBit%64))
+		}
+	}
+	return longs
+}
+
+func TestReadSpongeSchematic(t *testing.T) {
+	// index 200 takes two bytes as a varint
+	blockData := []byte{200, 1, 0, 1, 2}
+	palette := map[string]interface{}{
+		"minecraft:air":            int32(0),
+		"minecraft:command_block":  int32(1),
+		"minecraft:stone[foo=bar]": int32(2),
+		"minecraft:no_such_block":  int32(200),
+	}
+	command := map[string]interface{}{"Command": "say hi"}
+	for _, c := range []struct {
+		name    string
+		content map[string]interface{}
+	}{
+		{"version 1", map[string]interface{}{
+			"Version": int32(1), "Width": int16(2), "Height": int16(1), "Length": int16(2),
+			"Palette": palette, "BlockData": byteArray(blockData),
+			"TileEntities": []interface{}{map[string]interface{}{"Pos": [3]int32{0, 0, 1}, "Id": "minecraft:command_block", "Command": "say hi"}},
+		}},
+		{"version 2", map[string]interface{}{
+			"Version": int32(2), "Width": int16(2), "Height": int16(1), "Length": int16(2),
+			"Palette": palette, "BlockData": byteArray(blockData),
+			"BlockEntities": []interface{}{map[string]interface{}{"Pos": [3]int32{0, 0, 1}, "Id": "minecraft:command_block", "Command": "say hi"}},
+		}},
+		{"version 3", map[string]interface{}{"Schematic": map[string]interface{}{
+			"Version": int32(3), "Width": int16(2), "Height": int16(1), "Length": int16(2),
+			"Blocks": map[string]interface{}{
+				"Palette": palette, "Data": byteArray(blockData),
+				"BlockEntities": []interface{}{map[string]interface{}{"Pos": [3]int32{0, 0, 1}, "Id": "minecraft:command_block", "Data": command}},
+			},
+		}}},
+	} {
+		s, err := ReadJavaStructure(gzipNBT(t, c.content))
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if s.Size != [3]int{2, 1, 2} {
+			t.Fatalf("%s: size %v", c.name, s.Size)
+		}
+		names := blockNames(s)
+		if len(names) != 2 || names[types.Position{X: 0, Y: 0, Z: 1}] != "command_block" || names[types.Position{X: 1, Y: 0, Z: 1}] != "stone" {
+			t.Fatalf("%s: blocks %v", c.name, names)
+		}
+		for _, blk := range s.Blocks {
+			if blk.Point == (types.Position{X: 0, Y: 0, Z: 1}) && (blk.CommandBlockData == nil || blk.CommandBlockData.Command != "say hi") {
+				t.Fatalf("%s: command block data %+v", c.name, blk.CommandBlockData)
+			}
+		}
+		if s.Unmapped["no_such_block"] != 1 || len(s.Unmapped) != 1 {
+			t.Fatalf("%s: unmapped blocks %v", c.name, s.Unmapped)
+		}
+		if len(s.Inexact) != 1 || s.Inexact[0] != "stone" {
+			t.Fatalf("%s: inexact blocks %v", c.name, s.Inexact)
+		}
+	}
+
+	// the data ends in the middle of a varint
+	_, err := ReadJavaStructure(gzipNBT(t, map[string]interface{}{
+		"Version": int32(2), "Width": int16(1), "Height": int16(1), "Length": int16(1),
+		"Palette": palette, "BlockData": byteArray([]byte{200}),
+	}))
+	if err == nil {
+		t.Fatal("a truncated varint is read")
+	}
+}
+
+func TestReadLitematic(t *testing.T) {
+	palette := []interface{}{
+		map[string]interface{}{"Name": "minecraft:air"},
+		map[string]interface{}{"Name": "minecraft:stone"},
+		map[string]interface{}{"Name": "minecraft:glass"},
+		map[string]interface{}{"Name": "minecraft:oak_log", "Properties": map[string]interface{}{"axis": "x"}},
+		map[string]interface{}{"Name": "minecraft:no_such_block"},
+	}
+	// 3 bits for each of the 27 blocks, the 22nd one spans two longs
+	indices := make([]int, 27)
+	for i := range indices {
+		indices[i] = i%4 + 1
+	}
+	indices[21] = 3
+	vector := func(x, y, z int32) map[string]interface{} {
+		return map[string]interface{}{"x": x, "y": y, "z": z}
+	}
+	s, err := ReadJavaStructure(gzipNBT(t, map[string]interface{}{
+		"Regions": map[string]interface{}{
+			// the size is negative, it expands from x 2 to x 0
+			"a": map[string]interface{}{
+				"Position":          vector(2, 0, 0),
+				"Size":              vector(-3, 3, 3),
+				"BlockStatePalette": palette,
+				"BlockStates":       packLitematic(indices, 3),
+			},
+			// a block below and behind the other region
+			"b": map[string]interface{}{
+				"Position":          vector(-1, -1, -1),
+				"Size":              vector(1, 1, 1),
+				"BlockStatePalette": palette[:2],
+				"BlockStates":       packLitematic([]int{1}, 2),
+			},
+		},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Size != [3]int{4, 4, 4} {
+		t.Fatalf("size %v", s.Size)
+	}
+	names := blockNames(s)
+	if names[types.Position{}] != "stone" {
+		t.Fatalf("%s in the corner of the second region", names[types.Position{}])
+	}
+	for i, index := range indices {
+		// x goes first, then z and y
+		point := types.Position{X: i%3 + 1, Y: i/9 + 1, Z: i/3%3 + 1}
+		want := map[int]string{1: "stone", 2: "glass", 3: "log", 4: ""}[index]
+		if names[point] != want {
+			t.Fatalf("%s at %v, it should be %s", names[point], point, want)
+		}
+	}
+	unknown := 0
+	for _, index := range indices {
+		if index == 4 {
+			unknown++
+		}
+	}
+	if s.Unmapped["no_such_block"] != unknown || len(s.Unmapped) != 1 {
+		t.Fatalf("unmapped blocks %v, there should be %d no_such_block", s.Unmapped, unknown)
+	}
+	if len(s.Inexact) != 0 {
+		t.Fatalf("inexact blocks %v", s.Inexact)
+	}
+}
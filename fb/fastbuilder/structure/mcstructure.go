@@ -0,0 +1,379 @@
+package structure
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"phoenixbuilder_3rd_gui/fb/dragonfly/server/world/chunk"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/types"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/world_provider"
+	"phoenixbuilder_3rd_gui/fb/minecraft/nbt"
+	"phoenixbuilder_3rd_gui/fb/minecraft/protocol/packet"
+	"strconv"
+	"strings"
+)
+
+// Size returns the size of the smallest box containing all blocks,
+// the points of the blocks should be relative to the origin of the box.
+func Size(blocks []*types.RuntimeModule) [3]int {
+	size := [3]int{0, 0, 0}
+	for _, blk := range blocks {
+		if blk.Point.X+1 > size[0] {
+			size[0] = blk.Point.X + 1
+		}
+		if blk.Point.Y+1 > size[1] {
+			size[1] = blk.Point.Y + 1
+		}
+		if blk.Point.Z+1 > size[2] {
+			size[2] = blk.Point.Z + 1
+		}
+	}
+	return size
+}
+
+// CommandBlockNBT returns the block entity of a bedrock command block
+func CommandBlockNBT(cb *types.CommandBlockData, x, y, z int32) map[string]interface{} {
+	return map[string]interface{}{
+		"id":                 "CommandBlock",
+		"Command":            cb.Command,
+		"CustomName":         cb.CustomName,
+		"LastOutput":         cb.LastOutput,
+		"TickDelay":          cb.TickDelay,
+		"ExecuteOnFirstTick": cb.ExecuteOnFirstTick,
+		"TrackOutput":        cb.TrackOutput,
+		"conditionalMode":    cb.Conditional,
+		"auto":               !cb.NeedRedstone,
+		"powered":            false,
+		"SuccessCount":       int32(0),
+		"Version":            int32(19),
+		"isMovable":          true,
+		"x":                  x,
+		"y":                  y,
+		"z":                  z,
+	}
+}
+
+// ChestNBT returns the block entity of a bedrock chest
+func ChestNBT(chest *types.ChestData, x, y, z int32) map[string]interface{} {
+	items := make([]interface{}, len(*chest))
+	for i, slot := range *chest {
+		items[i] = map[string]interface{}{
+			"Name":        "minecraft:" + slot.Name,
+			"Count":       slot.Count,
+			"Damage":      int16(slot.Damage),
+			"Slot":        slot.Slot,
+			"WasPickedUp": false,
+		}
+	}
+	return map[string]interface{}{
+		"id":        "Chest",
+		"Items":     items,
+		"isMovable": true,
+		"x":         x,
+		"y":         y,
+		"z":         z,
+	}
+}
+
+// WriteMCStructure writes the blocks as a bedrock .mcstructure file, points
+// of the blocks are relative to the origin, which is saved as the world
+// origin of the structure.
+func WriteMCStructure(w io.Writer, blocks []*types.RuntimeModule, size [3]int, origin types.Position) error {
+	root, err := MCStructureNBT(blocks, size, origin)
+	if err != nil {
+		return err
+	}
+	return nbt.NewEncoderWithEncoding(w, nbt.LittleEndian).Encode(root)
+}
+
+// paletteEntry returns the palette entry of a block, the game finds the
+// block by its name and states. The blocks only netease has don't have
+// states, they are saved with their data.
+func paletteEntry(runtimeId uint32, block *types.ConstBlock) map[string]interface{} {
+	if state, found := world_provider.BlockStateOf(runtimeId); found {
+		return map[string]interface{}{
+			"name":    state.Name,
+			"states":  state.Properties,
+			"version": state.Version,
+		}
+	}
+	return map[string]interface{}{
+		"name":    "minecraft:" + block.Name,
+		"states":  map[string]interface{}{},
+		"val":     int16(block.Data),
+		"version": chunk.CurrentBlockVersion,
+	}
+}
+
+// MCStructureNBT returns the NBT of a bedrock structure, as it is saved in
+// .mcstructure files and sent in StructureTemplateDataResponse packets.
+func MCStructureNBT(blocks []*types.RuntimeModule, size [3]int, origin types.Position) (map[string]interface{}, error) {
+	volume := size[0] * size[1] * size[2]
+	palette := []interface{}{paletteEntry(world_provider.AirRuntimeId, &types.ConstBlock{Name: "air"})}
+	paletteIndex := map[uint32]int32{world_provider.AirRuntimeId: 0}
+	layer := make([]int32, volume)
+	waterLayer := make([]int32, volume)
+	for i := range waterLayer {
+		waterLayer[i] = -1
+	}
+	positionData := map[string]interface{}{}
+	for _, blk := range blocks {
+		p := blk.Point
+		if p.X < 0 || p.Y < 0 || p.Z < 0 || p.X >= size[0] || p.Y >= size[1] || p.Z >= size[2] {
+			return nil, fmt.Errorf("Block at %d %d %d is out of the structure", p.X, p.Y, p.Z)
+		}
+		index, found := paletteIndex[blk.BlockRuntimeId]
+		if !found {
+			block, err := lookupRuntimeId(blk.BlockRuntimeId)
+			if err != nil {
+				return nil, err
+			}
+			index = int32(len(palette))
+			paletteIndex[blk.BlockRuntimeId] = index
+			palette = append(palette, paletteEntry(blk.BlockRuntimeId, block))
+		}
+		offset := (p.X*size[1]+p.Y)*size[2] + p.Z
+		layer[offset] = index
+		wx, wy, wz := int32(origin.X+p.X), int32(origin.Y+p.Y), int32(origin.Z+p.Z)
+		if blk.CommandBlockData != nil {
+			positionData[strconv.Itoa(offset)] = map[string]interface{}{
+				"block_entity_data": CommandBlockNBT(blk.CommandBlockData, wx, wy, wz),
+			}
+		} else if blk.ChestData != nil {
+			positionData[strconv.Itoa(offset)] = map[string]interface{}{
+				"block_entity_data": ChestNBT(blk.ChestData, wx, wy, wz),
+			}
+		}
+	}
+	root := map[string]interface{}{
+		"format_version":         int32(1),
+		"size":                   []int32{int32(size[0]), int32(size[1]), int32(size[2])},
+		"structure_world_origin": []int32{int32(origin.X), int32(origin.Y), int32(origin.Z)},
+		"structure": map[string]interface{}{
+			"block_indices": [][]int32{layer, waterLayer},
+			"entities":      []interface{}{},
+			"palette": map[string]interface{}{
+				"default": map[string]interface{}{
+					"block_palette":       palette,
+					"block_position_data": positionData,
+				},
+			},
+		},
+	}
+	return root, nil
+}
+
+// Structure is a structure read from a file, the points of the
+// blocks are relative to the origin of the structure.
+type Structure struct {
+	Size   [3]int
+	Blocks []*types.RuntimeModule
+	// Names of blocks that couldn't be converted exactly
+	Inexact []string
+	// Blocks that are unknown and left out, with their count
+	Unmapped map[string]int
+}
+
+func newStructure() *Structure {
+	return &Structure{Unmapped: map[string]int{}}
+}
+
+func (s *Structure) addInexact(name string) {
+	for _, n := range s.Inexact {
+		if n == name {
+			return
+		}
+	}
+	s.Inexact = append(s.Inexact, name)
+}
+
+func nbtInt(v interface{}) int {
+	switch i := v.(type) {
+	case uint8:
+		return int(i)
+	case int16:
+		return int(i)
+	case int32:
+		return int(i)
+	case int64:
+		return int(i)
+	}
+	return 0
+}
+
+func nbtBool(v interface{}) bool {
+	return nbtInt(v) != 0
+}
+
+func nbtString(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
+
+// ReadCommandBlockData reads the command block data from the NBT of a bedrock command block
+func ReadCommandBlockData(name string, data uint16, tag map[string]interface{}) *types.CommandBlockData {
+	var mode uint32 = packet.CommandBlockImpulse
+	switch strings.TrimPrefix(name, "minecraft:") {
+	case "repeating_command_block":
+		mode = packet.CommandBlockRepeat
+	case "chain_command_block":
+		mode = packet.CommandBlockChain
+	}
+	conditional := data&8 != 0
+	if v, ok := tag["conditionalMode"]; ok {
+		conditional = nbtBool(v)
+	}
+	return &types.CommandBlockData{
+		Mode:               mode,
+		Command:            nbtString(tag["Command"]),
+		CustomName:         nbtString(tag["CustomName"]),
+		LastOutput:         nbtString(tag["LastOutput"]),
+		TickDelay:          int32(nbtInt(tag["TickDelay"])),
+		ExecuteOnFirstTick: nbtBool(tag["ExecuteOnFirstTick"]),
+		TrackOutput:        nbtBool(tag["TrackOutput"]),
+		Conditional:        conditional,
+		NeedRedstone:       !nbtBool(tag["auto"]),
+	}
+}
+
+// ReadChestData reads the items from the NBT of a bedrock container
+func ReadChestData(tag map[string]interface{}) *types.ChestData {
+	items, _ := tag["Items"].([]interface{})
+	chest := make(types.ChestData, 0, len(items))
+	for _, iface := range items {
+		item, ok := iface.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name := strings.TrimPrefix(nbtString(item["Name"]), "minecraft:")
+		if name == "" {
+			continue
+		}
+		chest = append(chest, types.ChestSlot{
+			Name:   name,
+			Count:  uint8(nbtInt(item["Count"])),
+			Damage: uint16(nbtInt(item["Damage"])),
+			Slot:   uint8(nbtInt(item["Slot"])),
+		})
+	}
+	return &chest
+}
+
+// ReadMCStructure reads a bedrock .mcstructure file
+func ReadMCStructure(r io.Reader) (*Structure, error) {
+	buffer, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var content map[string]interface{}
+	if err := nbt.UnmarshalEncoding(buffer, &content, nbt.LittleEndian); err != nil {
+		// Won't return the error since it may contain a large content
+		return nil, fmt.Errorf("Failed to resolve mcstructure file (nbt)")
+	}
+	sizeList, _ := content["size"].([]interface{})
+	if len(sizeList) != 3 {
+		return nil, fmt.Errorf("Invalid size for structure")
+	}
+	s := newStructure()
+	for i := range s.Size {
+		s.Size[i] = nbtInt(sizeList[i])
+		if s.Size[i] < 0 {
+			return nil, fmt.Errorf("Invalid size for structure")
+		}
+	}
+	volume := s.Size[0] * s.Size[1] * s.Size[2]
+	structure, _ := content["structure"].(map[string]interface{})
+	layers, _ := structure["block_indices"].([]interface{})
+	if len(layers) == 0 {
+		return nil, fmt.Errorf("Unexpected indices data")
+	}
+	indices, _ := layers[0].([]interface{})
+	var waterIndices []interface{}
+	if len(layers) > 1 {
+		waterIndices, _ = layers[1].([]interface{})
+	}
+	if len(indices) != volume || (waterIndices != nil && len(waterIndices) != volume) {
+		return nil, fmt.Errorf("Unexpected indices data")
+	}
+	palettes, _ := structure["palette"].(map[string]interface{})
+	defaultPalette, _ := palettes["default"].(map[string]interface{})
+	blockPalette, _ := defaultPalette["block_palette"].([]interface{})
+	positionData, _ := defaultPalette["block_position_data"].(map[string]interface{})
+
+	// palette index -> block
+	type paletteBlock struct {
+		name      string
+		data      uint16
+		runtimeId uint32
+		skip      bool
+		unmapped  bool
+	}
+	palette := make([]paletteBlock, len(blockPalette))
+	for i, iface := range blockPalette {
+		entry, _ := iface.(map[string]interface{})
+		name := strings.TrimPrefix(nbtString(entry["name"]), "minecraft:")
+		states, _ := entry["states"].(map[string]interface{})
+		var data uint16
+		exact := true
+		runtimeId, found := world_provider.RuntimeIdOfState(name, states)
+		if found {
+			data = world_provider.RuntimeIdArray_117[runtimeId].Data
+		} else {
+			if val, ok := entry["val"]; ok {
+				data = uint16(nbtInt(val))
+			} else {
+				data, exact = LegacyData(name, states)
+			}
+			runtimeId, found = RuntimeIdOf(name, data)
+		}
+		skip := name == "air" || name == "structure_void"
+		unmapped := !skip && runtimeId == world_provider.AirRuntimeId
+		if !skip && !unmapped && (!found || !exact) {
+			s.addInexact(name)
+		}
+		palette[i] = paletteBlock{
+			name:      name,
+			data:      data,
+			runtimeId: runtimeId,
+			skip:      skip,
+			unmapped:  unmapped,
+		}
+	}
+	i := 0
+	for x := 0; x < s.Size[0]; x++ {
+		for y := 0; y < s.Size[1]; y++ {
+			for z := 0; z < s.Size[2]; z++ {
+				index := nbtInt(indices[i])
+				if index == -1 && waterIndices != nil {
+					index = nbtInt(waterIndices[i])
+				}
+				if index < 0 || index >= len(palette) || palette[index].skip {
+					i++
+					continue
+				}
+				blk := palette[index]
+				if blk.unmapped {
+					s.Unmapped[blk.name]++
+					i++
+					continue
+				}
+				module := &types.RuntimeModule{
+					BlockRuntimeId: blk.runtimeId,
+					Point:          types.Position{X: x, Y: y, Z: z},
+				}
+				if posData, ok := positionData[strconv.Itoa(i)].(map[string]interface{}); ok {
+					if tag, ok := posData["block_entity_data"].(map[string]interface{}); ok {
+						if strings.Contains(blk.name, "command_block") {
+							module.CommandBlockData = ReadCommandBlockData(blk.name, blk.data, tag)
+						} else if _, hasItems := tag["Items"]; hasItems {
+							module.ChestData = ReadChestData(tag)
+						}
+					}
+				}
+				s.Blocks = append(s.Blocks, module)
+				i++
+			}
+		}
+	}
+	return s, nil
+}
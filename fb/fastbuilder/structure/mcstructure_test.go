@@ -0,0 +1,50 @@
+package structure
+
+import (
+	"bytes"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/types"
+	"testing"
+)
+
+func TestMCStructureStates(t *testing.T) {
+	for _, c := range []struct {
+		name   string
+		data   uint16
+		states map[string]interface{}
+	}{
+		{"oak_stairs", 2, map[string]interface{}{"upside_down_bit": uint8(0), "weirdo_direction": int32(2)}},
+		{"wool", 14, map[string]interface{}{"color": "red"}},
+		{"log", 4, map[string]interface{}{"old_log_type": "oak", "pillar_axis": "x"}},
+		{"amethyst_cluster", 3, map[string]interface{}{"facing_direction": int32(3)}},
+	} {
+		runtimeId, _ := RuntimeIdOf(c.name, c.data)
+		blocks := []*types.RuntimeModule{{BlockRuntimeId: runtimeId}}
+		root, err := MCStructureNBT(blocks, [3]int{1, 1, 1}, types.Position{})
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		palette := root["structure"].(map[string]interface{})["palette"].(map[string]interface{})["default"].(map[string]interface{})["block_palette"].([]interface{})
+		entry := palette[1].(map[string]interface{})
+		states := entry["states"].(map[string]interface{})
+		if entry["name"] != "minecraft:"+c.name || len(states) != len(c.states) {
+			t.Fatalf("%s: palette entry %v", c.name, entry)
+		}
+		for state, value := range c.states {
+			if states[state] != value {
+				t.Fatalf("%s: %s is %v, it should be %v", c.name, state, states[state], value)
+			}
+		}
+
+		buf := &bytes.Buffer{}
+		if err := WriteMCStructure(buf, blocks, [3]int{1, 1, 1}, types.Position{}); err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		s, err := ReadMCStructure(buf)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if len(s.Blocks) != 1 || s.Blocks[0].BlockRuntimeId != runtimeId || len(s.Inexact) != 0 {
+			t.Fatalf("%s: read back as %+v, inexact %v", c.name, s.Blocks, s.Inexact)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package structure
+
+import (
+	"fmt"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/types"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/world_provider"
+	"strings"
+	"sync"
+)
+
+var (
+	runtimeIdMapOnce sync.Once
+	runtimeIdMap     map[types.ConstBlock]uint32
+)
+
+func lookupRuntimeId(runtimeId uint32) (*types.ConstBlock, error) {
+	if int(runtimeId) >= len(world_provider.RuntimeIdArray_117) {
+		return nil, fmt.Errorf("Unknown runtime id: %d", runtimeId)
+	}
+	return world_provider.RuntimeIdArray_117[runtimeId], nil
+}
+
+// RuntimeIdOf returns the runtime id of a bedrock block, if the data isn't
+// valid for the block, the runtime id of data 0 is returned with ok=false.
+func RuntimeIdOf(name string, data uint16) (runtimeId uint32, ok bool) {
+	runtimeIdMapOnce.Do(func() {
+		runtimeIdMap = make(map[types.ConstBlock]uint32, len(world_provider.RuntimeIdArray_117))
+		for rid, blk := range world_provider.RuntimeIdArray_117 {
+			runtimeIdMap[*blk] = uint32(rid)
+		}
+	})
+	name = strings.TrimPrefix(name, "minecraft:")
+	if rid, found := runtimeIdMap[types.ConstBlock{Name: name, Data: data}]; found {
+		return rid, true
+	}
+	if rid, found := runtimeIdMap[types.ConstBlock{Name: name, Data: 0}]; found {
+		return rid, false
+	}
+	return world_provider.AirRuntimeId, false
+}
+
+var bedrockColors = []string{
+	"white", "orange", "magenta", "light_blue",
+	"yellow", "lime", "pink", "gray",
+	"silver", "cyan", "purple", "blue",
+	"brown", "green", "red", "black",
+}
+
+// block state -> values indexed by data
+var enumStates = map[string][]string{
+	"color":                 bedrockColors,
+	"wood_type":             {"oak", "spruce", "birch", "jungle", "acacia", "dark_oak"},
+	"old_log_type":          {"oak", "spruce", "birch", "jungle"},
+	"new_log_type":          {"acacia", "dark_oak"},
+	"old_leaf_type":         {"oak", "spruce", "birch", "jungle"},
+	"new_leaf_type":         {"acacia", "dark_oak"},
+	"stone_type":            {"stone", "granite", "granite_smooth", "diorite", "diorite_smooth", "andesite", "andesite_smooth"},
+	"sand_type":             {"normal", "red"},
+	"dirt_type":             {"normal", "coarse"},
+	"sand_stone_type":       {"default", "heiroglyphs", "cut", "smooth"},
+	"stone_brick_type":      {"default", "mossy", "cracked", "chiseled", "smooth"},
+	"prismarine_block_type": {"default", "dark", "bricks"},
+	"chisel_type":           {"default", "chiseled", "lines", "smooth"},
+	"sponge_type":           {"dry", "wet"},
+	"wall_block_type":       {"cobblestone", "mossy_cobblestone"},
+	"flower_type":           {"poppy", "orchid", "allium", "houstonia", "tulip_red", "tulip_orange", "tulip_white", "tulip_pink", "oxeye", "cornflower", "lily_of_the_valley"},
+	"stone_slab_type":       {"smooth_stone", "sandstone", "wood", "cobblestone", "brick", "stone_brick", "quartz", "nether_brick"},
+}
+
+// block state -> the bit it sets
+var bitStates = map[string]uint16{
+	"conditional_bit":    8,
+	"upside_down_bit":    4,
+	"top_slot_bit":       8,
+	"persistent_bit":     4,
+	"update_bit":         8,
+	"open_bit":           4,
+	"powered_bit":        8,
+	"button_pressed_bit": 8,
+}
+
+// the bits that are different for a family of blocks, by the end of the
+// names of the blocks. The states of doors are left out, their upper
+// half has different data, so they are inexact.
+var familyBitStates = []struct {
+	suffix string
+	bits   map[string]uint16
+}{
+	{"trapdoor", map[string]uint16{"open_bit": 8}},
+	{"fence_gate", map[string]uint16{"in_wall_bit": 8}},
+}
+
+// bitOf returns the bit a block state of the block sets
+func bitOf(name, state string) (bit uint16, found bool) {
+	for _, family := range familyBitStates {
+		if strings.HasSuffix(name, family.suffix) {
+			if bit, found = family.bits[state]; found {
+				return
+			}
+			break
+		}
+	}
+	bit, found = bitStates[state]
+	return
+}
+
+// LegacyData converts the block states of a bedrock block into the legacy
+// data value, ok is false if some of the states are not understood.
+func LegacyData(name string, states map[string]interface{}) (data uint16, ok bool) {
+	name = strings.TrimPrefix(name, "minecraft:")
+	ok = true
+	for state, value := range states {
+		switch v := value.(type) {
+		case string:
+			if state == "pillar_axis" {
+				// handled below, since it's shifted
+				continue
+			}
+			values, found := enumStates[state]
+			if !found {
+				ok = false
+				continue
+			}
+			index := -1
+			for i, s := range values {
+				if s == v {
+					index = i
+					break
+				}
+			}
+			if index == -1 {
+				ok = false
+				continue
+			}
+			data |= uint16(index)
+		case uint8:
+			bit, found := bitOf(name, state)
+			if !found {
+				ok = false
+				continue
+			}
+			if v != 0 {
+				data |= bit
+			}
+		case int32:
+			switch state {
+			case "facing_direction", "weirdo_direction", "direction", "rail_direction", "height", "age", "growth", "redstone_signal":
+				data |= uint16(v)
+			default:
+				ok = false
+			}
+		default:
+			ok = false
+		}
+	}
+	if axis, found := states["pillar_axis"].(string); found {
+		switch axis {
+		case "x":
+			data |= 4
+		case "z":
+			data |= 8
+		}
+	}
+	return
+}
@@ -0,0 +1,28 @@
+package structure
+
+import (
+	"testing"
+)
+
+func TestLegacyData(t *testing.T) {
+	for _, c := range []struct {
+		name   string
+		states map[string]interface{}
+		data   uint16
+		ok     bool
+	}{
+		{"wool", map[string]interface{}{"color": "red"}, 14, true},
+		{"log", map[string]interface{}{"old_log_type": "birch", "pillar_axis": "z"}, 10, true},
+		{"oak_stairs", map[string]interface{}{"weirdo_direction": int32(2), "upside_down_bit": uint8(1)}, 6, true},
+		{"trapdoor", map[string]interface{}{"direction": int32(1), "open_bit": uint8(1), "upside_down_bit": uint8(1)}, 13, true},
+		{"minecraft:iron_trapdoor", map[string]interface{}{"direction": int32(0), "open_bit": uint8(1), "upside_down_bit": uint8(0)}, 8, true},
+		{"fence_gate", map[string]interface{}{"direction": int32(3), "open_bit": uint8(1), "in_wall_bit": uint8(1)}, 15, true},
+		{"wooden_door", map[string]interface{}{"direction": int32(1), "open_bit": uint8(0), "upper_block_bit": uint8(1), "door_hinge_bit": uint8(0)}, 1, false},
+		{"stone", map[string]interface{}{"stone_type": "no_such_stone"}, 0, false},
+	} {
+		data, ok := LegacyData(c.name, c.states)
+		if data != c.data || ok != c.ok {
+			t.Fatalf("%s %v: data %d ok %v, it should be %d %v", c.name, c.states, data, ok, c.data, c.ok)
+		}
+	}
+}
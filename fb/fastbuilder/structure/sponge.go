@@ -0,0 +1,168 @@
+package structure
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/types"
+	"phoenixbuilder_3rd_gui/fb/minecraft/nbt"
+	"reflect"
+	"strings"
+)
+
+// java 1.16.5
+const SpongeDataVersion = 2586
+
+// byteArray converts a slice into an array, which is encoded as a
+// TAG_Byte_Array instead of a TAG_List
+func byteArray(data []byte) interface{} {
+	arr := reflect.New(reflect.ArrayOf(len(data), reflect.TypeOf(byte(0)))).Elem()
+	reflect.Copy(arr, reflect.ValueOf(data))
+	return arr.Interface()
+}
+
+func putVarint(buf *bytes.Buffer, v int32) {
+	u := uint32(v)
+	for u >= 0x80 {
+		buf.WriteByte(byte(u&0x7f) | 0x80)
+		u >>= 7
+	}
+	buf.WriteByte(byte(u))
+}
+
+func javaCommandBlockNBT(cb *types.CommandBlockData, pos [3]int32) map[string]interface{} {
+	tag := map[string]interface{}{
+		"Id":                  "minecraft:command_block",
+		"Pos":                 pos,
+		"Command":             cb.Command,
+		"auto":                !cb.NeedRedstone,
+		"powered":             false,
+		"conditionMet":        false,
+		"TrackOutput":         cb.TrackOutput,
+		"SuccessCount":        int32(0),
+		"UpdateLastExecution": true,
+	}
+	if cb.CustomName != "" {
+		customName, _ := json.Marshal(map[string]string{"text": cb.CustomName})
+		tag["CustomName"] = string(customName)
+	}
+	return tag
+}
+
+// javaBlockEntityId returns the id of the block entity of a java block
+// state, which is the name of the block except for the shulker boxes of
+// all the colors
+func javaBlockEntityId(state string) string {
+	if i := strings.IndexByte(state, '['); i >= 0 {
+		state = state[:i]
+	}
+	if strings.HasSuffix(state, "_shulker_box") {
+		return "minecraft:shulker_box"
+	}
+	return state
+}
+
+func javaChestNBT(chest *types.ChestData, id string, pos [3]int32) map[string]interface{} {
+	items := make([]interface{}, len(*chest))
+	for i, slot := range *chest {
+		items[i] = map[string]interface{}{
+			"Slot":  slot.Slot,
+			"id":    "minecraft:" + slot.Name,
+			"Count": slot.Count,
+		}
+	}
+	return map[string]interface{}{
+		"Id":    id,
+		"Pos":   pos,
+		"Items": items,
+	}
+}
+
+// WriteSpongeSchematic writes the blocks as a gzipped Sponge schematic (version 2),
+// points of the blocks are relative to the origin of the schematic.
+// Blocks that have no exact java equivalent are returned as inexact.
+func WriteSpongeSchematic(w io.Writer, blocks []*types.RuntimeModule, size [3]int) (inexact []string, err error) {
+	if size[0] > 65535 || size[1] > 65535 || size[2] > 65535 {
+		return nil, fmt.Errorf("Structure is too large for a schematic: %v", size)
+	}
+	width, height, length := size[0], size[1], size[2]
+	volume := width * height * length
+	palette := map[string]int32{"minecraft:air": 0}
+	paletteIndex := map[uint32]int32{}
+	inexactSet := map[string]bool{}
+	indices := make([]int32, volume)
+	blockEntities := []interface{}{}
+	for _, blk := range blocks {
+		p := blk.Point
+		if p.X < 0 || p.Y < 0 || p.Z < 0 || p.X >= width || p.Y >= height || p.Z >= length {
+			return nil, fmt.Errorf("Block at %d %d %d is out of the structure", p.X, p.Y, p.Z)
+		}
+		index, found := paletteIndex[blk.BlockRuntimeId]
+		if !found {
+			block, err := lookupRuntimeId(blk.BlockRuntimeId)
+			if err != nil {
+				return nil, err
+			}
+			state, exact := BedrockToJava(block.Name, block.Data)
+			if !exact && !inexactSet[block.Name] {
+				inexactSet[block.Name] = true
+				inexact = append(inexact, block.Name)
+			}
+			index, found = palette[state]
+			if !found {
+				index = int32(len(palette))
+				palette[state] = index
+			}
+			paletteIndex[blk.BlockRuntimeId] = index
+		}
+		indices[p.X+p.Z*width+p.Y*width*length] = index
+		pos := [3]int32{int32(p.X), int32(p.Y), int32(p.Z)}
+		if blk.CommandBlockData != nil {
+			blockEntities = append(blockEntities, javaCommandBlockNBT(blk.CommandBlockData, pos))
+		} else if blk.ChestData != nil {
+			block, err := lookupRuntimeId(blk.BlockRuntimeId)
+			if err != nil {
+				return nil, err
+			}
+			state, _ := BedrockToJava(block.Name, block.Data)
+			blockEntities = append(blockEntities, javaChestNBT(blk.ChestData, javaBlockEntityId(state), pos))
+		}
+	}
+	blockData := &bytes.Buffer{}
+	for _, index := range indices {
+		putVarint(blockData, index)
+	}
+	schematic := map[string]interface{}{
+		"Version":       int32(2),
+		"DataVersion":   int32(SpongeDataVersion),
+		"Width":         int16(uint16(width)),
+		"Height":        int16(uint16(height)),
+		"Length":        int16(uint16(length)),
+		"Offset":        [3]int32{0, 0, 0},
+		"PaletteMax":    int32(len(palette)),
+		"Palette":       palette,
+		"BlockData":     byteArray(blockData.Bytes()),
+		"BlockEntities": blockEntities,
+	}
+	payload, err := nbt.MarshalEncoding(schematic, nbt.BigEndian)
+	if err != nil {
+		return nil, err
+	}
+	// The root compound of a schematic is named "Schematic", while the
+	// encoder always writes a root compound without name.
+	gz := gzip.NewWriter(w)
+	header := &bytes.Buffer{}
+	header.WriteByte(payload[0])
+	binary.Write(header, binary.BigEndian, uint16(len("Schematic")))
+	header.WriteString("Schematic")
+	if _, err = gz.Write(header.Bytes()); err != nil {
+		return nil, err
+	}
+	if _, err = gz.Write(payload[3:]); err != nil {
+		return nil, err
+	}
+	return inexact, gz.Close()
+}
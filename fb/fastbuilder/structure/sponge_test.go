@@ -0,0 +1,59 @@
+package structure
+
+import (
+	"bytes"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/types"
+	"testing"
+)
+
+func TestSpongeRoundTrip(t *testing.T) {
+	stone, _ := RuntimeIdOf("stone", 0)
+	glass, _ := RuntimeIdOf("glass", 0)
+	commandBlock, _ := RuntimeIdOf("command_block", 0)
+	blocks := []*types.RuntimeModule{
+		{BlockRuntimeId: stone, Point: types.Position{X: 0, Y: 0, Z: 0}},
+		{BlockRuntimeId: glass, Point: types.Position{X: 2, Y: 1, Z: 0}},
+		{BlockRuntimeId: commandBlock, Point: types.Position{X: 1, Y: 0, Z: 2}, CommandBlockData: &types.CommandBlockData{Command: "say hi"}},
+	}
+	buf := &bytes.Buffer{}
+	if _, err := WriteSpongeSchematic(buf, blocks, [3]int{3, 2, 3}); err != nil {
+		t.Fatal(err)
+	}
+	s, err := ReadJavaStructure(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Size != [3]int{3, 2, 3} {
+		t.Fatalf("size %v", s.Size)
+	}
+	names := blockNames(s)
+	if len(names) != 3 || names[blocks[0].Point] != "stone" || names[blocks[1].Point] != "glass" || names[blocks[2].Point] != "command_block" {
+		t.Fatalf("blocks %v", names)
+	}
+	for _, blk := range s.Blocks {
+		if blk.Point == blocks[2].Point && (blk.CommandBlockData == nil || blk.CommandBlockData.Command != "say hi") {
+			t.Fatalf("command block data %+v", blk.CommandBlockData)
+		}
+	}
+}
+
+func TestJavaBlockEntityId(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		data uint16
+		id   string
+	}{
+		{"chest", 2, "minecraft:chest"},
+		{"trapped_chest", 0, "minecraft:trapped_chest"},
+		{"shulker_box", 14, "minecraft:shulker_box"},
+		{"undyed_shulker_box", 0, "minecraft:shulker_box"},
+		{"barrel", 1, "minecraft:barrel"},
+		{"hopper", 0, "minecraft:hopper"},
+		{"furnace", 2, "minecraft:furnace"},
+	} {
+		state, _ := BedrockToJava(c.name, c.data)
+		if id := javaBlockEntityId(state); id != c.id {
+			t.Fatalf("%s %d: the block entity of %s is %s, it should be %s", c.name, c.data, state, id, c.id)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package task
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/types"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+)
+
+// how often the progress of a task is written to its checkpoint
+const CheckpointInterval = 10 * time.Second
+
+// Checkpoint is the progress of an unfinished task, the task can be
+// created again from it and continue from the block at BlockIndex
+type Checkpoint struct {
+	Id          int64
+	CommandLine string
+	Builder     string
+	Main        *types.MainConfig
+	// the stages after the builder, see builder.PipeGenerate
+	Pipeline []*types.MainConfig
+	Delay    *types.DelayConfig
+	TaskType byte
+	// if the blocks are merged into fill commands, BlockIndex counts the
+	// merged modules then
+	FillOptimization bool
+	// if the blocks are ordered by builder.ScheduleByChunk
+	ChunkOrdered bool
+	// the blocks before it are built
+	BlockIndex int
+	SavedAt    time.Time
+}
+
+// CheckpointStore keeps the checkpoints of unfinished tasks,
+// they are kept after the session is closed
+type CheckpointStore interface {
+	Save(checkpoint *Checkpoint) error
+	Load(id int64) (*Checkpoint, error)
+	Remove(id int64) error
+	// List returns the checkpoints ordered by id
+	List() ([]*Checkpoint, error)
+}
+
+const checkpointFilePrefix = "task_checkpoint_"
+
+// StorageCheckpointStore keeps checkpoints as json files in the storage
+// of the app
+type StorageCheckpointStore struct {
+	storage fyne.Storage
+}
+
+func NewStorageCheckpointStore(storage fyne.Storage) *StorageCheckpointStore {
+	return &StorageCheckpointStore{storage: storage}
+}
+
+func checkpointFileName(id int64) string {
+	return fmt.Sprintf("%s%d.json", checkpointFilePrefix, id)
+}
+
+func (store *StorageCheckpointStore) Save(checkpoint *Checkpoint) error {
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+	name := checkpointFileName(checkpoint.Id)
+	writer, err := store.storage.Save(name)
+	if err != nil {
+		// doesn't exist yet
+		writer, err = store.storage.Create(name)
+		if err != nil {
+			return fmt.Errorf("cannot create checkpoint file %s: %v", name, err)
+		}
+	}
+	defer writer.Close()
+	_, err = writer.Write(data)
+	return err
+}
+
+func (store *StorageCheckpointStore) Load(id int64) (*Checkpoint, error) {
+	name := checkpointFileName(id)
+	reader, err := store.storage.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open checkpoint file %s: %v", name, err)
+	}
+	defer reader.Close()
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	checkpoint := &Checkpoint{}
+	if err := json.Unmarshal(data, checkpoint); err != nil {
+		return nil, fmt.Errorf("broken checkpoint file %s: %v", name, err)
+	}
+	return checkpoint, nil
+}
+
+func (store *StorageCheckpointStore) Remove(id int64) error {
+	return store.storage.Remove(checkpointFileName(id))
+}
+
+func (store *StorageCheckpointStore) List() ([]*Checkpoint, error) {
+	var checkpoints []*Checkpoint
+	for _, name := range store.storage.List() {
+		if !strings.HasPrefix(name, checkpointFilePrefix) || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		id, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimPrefix(name, checkpointFilePrefix), ".json"), 10, 64)
+		if err != nil {
+			continue
+		}
+		checkpoint, err := store.Load(id)
+		if err != nil {
+			return nil, err
+		}
+		checkpoints = append(checkpoints, checkpoint)
+	}
+	sort.Slice(checkpoints, func(i, j int) bool {
+		return checkpoints[i].Id < checkpoints[j].Id
+	})
+	return checkpoints, nil
+}
+
+// newCheckpointId returns an id that is unlikely to be used by the
+// tasks of the session or other checkpoints
+func newCheckpointId(store CheckpointStore) int64 {
+	id := time.Now().Unix()
+	if checkpoints, err := store.List(); err == nil {
+		for _, checkpoint := range checkpoints {
+			if checkpoint.Id >= id {
+				id = checkpoint.Id + 1
+			}
+		}
+	}
+	return id
+}
@@ -0,0 +1,282 @@
+package task
+
+import (
+	"fmt"
+	"phoenixbuilder_3rd_gui/fb/dragonfly/server/block/cube"
+	"phoenixbuilder_3rd_gui/fb/dragonfly/server/world"
+	"phoenixbuilder_3rd_gui/fb/dragonfly/server/world/mcdb"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/builder"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/command"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/configuration"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/environment"
+	I18n "phoenixbuilder_3rd_gui/fb/fastbuilder/i18n"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/structure"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/types"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/world_provider"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2/storage"
+)
+
+// DryRunResult is the summary of a dry run
+type DryRunResult struct {
+	Blocks        int
+	CommandBlocks int
+	ChestSlots    int
+	// Blocks that are not known and not placed, with their count
+	UnknownBlocks map[string]int
+	TimeUsed      time.Duration
+	// the error of saving the result to -dryrun_path, if it's given
+	SaveError error
+}
+
+// createDryRunTask builds into a local world instead of the server,
+// nothing is sent to the server except the messages of the task.
+func createDryRunTask(commandLine string, env *environment.PBEnvironment, configs []*types.MainConfig, fcfg *configuration.FullConfig) *Task {
+	conn := env.Connection
+	holder := GetTaskHolder(env)
+	blockschannel := make(chan *types.Module, 10240)
+	task := &Task{
+		TaskId:        holder.TaskIdCounter.Add(1),
+		CommandLine:   commandLine,
+		OutputChannel: blockschannel,
+		State:         TaskStateRunning,
+		Type:          types.TaskTypeSync,
+		Config:        fcfg,
+		holder:        holder,
+	}
+	taskid := task.TaskId
+	holder.TaskMap.Store(taskid, task)
+	go func() {
+		provider := world_provider.NewMemoryProvider()
+		result, err := runDryRun(env, configs, provider, blockschannel, &task.ContinueLock)
+		if err != nil {
+			command.Tellraw(conn, fmt.Sprintf("[%s %d] %s: %v", I18n.T(I18n.TaskTTeIuKoto), taskid, I18n.T(I18n.ERRORStr), err))
+		}
+		ReportDryRun(taskid, configs[0], result, provider, func(message string) {
+			command.Tellraw(conn, message)
+		})
+		runtime.GC()
+		task.Finalize()
+	}()
+	return task
+}
+
+// DryRun builds the pipeline of configs into the provider without a
+// server, it returns after all the blocks are placed. The error is the
+// one of the builders, the blocks generated before it are still placed.
+func DryRun(env *environment.PBEnvironment, configs []*types.MainConfig, provider *world_provider.MemoryProvider) (*DryRunResult, error) {
+	return runDryRun(env, configs, provider, make(chan *types.Module, 10240), nil)
+}
+
+// runDryRun is DryRun that generates the blocks into blockschannel, it
+// waits for pause before each block if it's not nil
+func runDryRun(env *environment.PBEnvironment, configs []*types.MainConfig, provider *world_provider.MemoryProvider, blockschannel chan *types.Module, pause *sync.Mutex) (*DryRunResult, error) {
+	generator, err := dryRunGenerator(configs[0])
+	if err != nil {
+		return &DryRunResult{UnknownBlocks: map[string]int{}}, err
+	}
+	w := world.New(&world_provider.StubLogger{}, 0)
+	w.Provider(provider)
+	w.Generator(generator)
+	// the builders that read the world read the world of the dry run
+	// instead of the one of the server
+	dryRunEnv := &environment.PBEnvironment{
+		Connection:    env.Connection,
+		Configuration: env.Configuration,
+		WorldHolder:   world_provider.NewLocalWorldHolder(w),
+		BrokSender:    env.BrokSender,
+	}
+	done := make(chan error, 1)
+	go func() {
+		err := builder.PipeGenerate(dryRunEnv, configs, blockschannel)
+		close(blockschannel)
+		done <- err
+	}()
+	result := dryRun(pause, configs[0], blockschannel, w, provider)
+	return result, <-done
+}
+
+// dryRunGenerator returns the generator of the chunks of a dry run that
+// are not in the provider, nil for empty chunks
+func dryRunGenerator(cfg *types.MainConfig) (world.Generator, error) {
+	switch cfg.DryRunGenerator {
+	case "":
+		return nil, nil
+	case "landscape":
+		terrain, err := builder.LandscapeTerrain(cfg)
+		if err != nil {
+			return nil, err
+		}
+		// at the bottom of the landscape builder
+		base := cfg.Position.Y
+		if cfg.End.Y < base {
+			base = cfg.End.Y
+		}
+		return &world_provider.NoiseGenerator{Terrain: terrain, Base: base}, nil
+	}
+	return nil, fmt.Errorf("unknown generator of dry run: %s", cfg.DryRunGenerator)
+}
+
+// ReportDryRun writes the summary of a dry run to output, output is called
+// once per message
+func ReportDryRun(taskid int64, cfg *types.MainConfig, result *DryRunResult, provider *world_provider.MemoryProvider, output func(message string)) {
+	if result.Blocks == 0 {
+		output(fmt.Sprintf(I18n.T(I18n.Task_D_NothingGenerated), taskid))
+		return
+	}
+	output(fmt.Sprintf("[Task %d] Dry run, nothing was sent to the server.", taskid))
+	output(fmt.Sprintf(I18n.T(I18n.Task_Summary_1), taskid, result.Blocks))
+	output(fmt.Sprintf(I18n.T(I18n.Task_Summary_2), taskid, result.TimeUsed.Seconds()))
+	output(fmt.Sprintf(I18n.T(I18n.Task_Summary_3), taskid, float64(result.Blocks)/result.TimeUsed.Seconds()))
+	output(fmt.Sprintf("[Task %d] Command blocks: %d, chest slots: %d, chunks: %d", taskid, result.CommandBlocks, result.ChestSlots, provider.ChunkCount()))
+	for name, count := range result.UnknownBlocks {
+		output(fmt.Sprintf("[Task %d] Unknown block %s x%d", taskid, name, count))
+	}
+	if cfg.DryRunPath != "" {
+		if result.SaveError != nil {
+			output(fmt.Sprintf("[Task %d] %s: %v", taskid, I18n.T(I18n.ERRORStr), result.SaveError))
+		} else {
+			output(fmt.Sprintf("[Task %d] Saved to %s", taskid, cfg.DryRunPath))
+		}
+	}
+}
+
+// dryRun places the blocks from the channel into w that uses the
+// provider, w is closed after the channel is closed.
+func dryRun(pause *sync.Mutex, cfg *types.MainConfig, blockschannel chan *types.Module, w *world.World, provider *world_provider.MemoryProvider) *DryRunResult {
+	result := &DryRunResult{UnknownBlocks: map[string]int{}}
+	commandBlocks := map[cube.Pos]*types.CommandBlockData{}
+	chests := map[cube.Pos]types.ChestData{}
+	// the blocks are only kept when they are saved
+	var placed map[cube.Pos]uint32
+	if cfg.DryRunPath != "" {
+		placed = map[cube.Pos]uint32{}
+	}
+	t1 := time.Now()
+	for {
+		if pause != nil {
+			pause.Lock()
+			pause.Unlock()
+		}
+		curblock, ok := <-blockschannel
+		if !ok {
+			break
+		}
+		pos := cube.Pos{curblock.Point.X, curblock.Point.Y, curblock.Point.Z}
+		if curblock.ChestSlot != nil {
+			chests[pos] = append(chests[pos], *curblock.ChestSlot)
+			result.ChestSlots++
+			continue
+		}
+		if curblock.CommandBlockData != nil {
+			if cfg.ExcludeCommands {
+				continue
+			}
+			commandBlocks[pos] = curblock.CommandBlockData
+			result.CommandBlocks++
+			if curblock.Block == nil {
+				// only the data of the command block is updated
+				continue
+			}
+		}
+		name, data := cfg.Block.Name, cfg.Block.Data
+		if curblock.Block != nil {
+			name, data = *curblock.Block.Name, curblock.Block.Data
+		}
+		runtimeId, found := structure.RuntimeIdOf(name, data)
+		if !found {
+			result.UnknownBlocks[fmt.Sprintf("%s %d", name, data)]++
+			continue
+		}
+		blk, _ := world.BlockByRuntimeID(runtimeId)
+		w.SetBlock(pos, blk)
+		if placed != nil {
+			placed[pos] = runtimeId
+		}
+		result.Blocks++
+	}
+	result.TimeUsed = time.Now().Sub(t1)
+	// the chunks are saved to the provider
+	w.Close()
+	w.CloseChunkCacheJanitor()
+	for pos, cbdata := range commandBlocks {
+		provider.AddBlockNBT(structure.CommandBlockNBT(cbdata, int32(pos[0]), int32(pos[1]), int32(pos[2])))
+	}
+	for pos, chest := range chests {
+		chestData := chest
+		provider.AddBlockNBT(structure.ChestNBT(&chestData, int32(pos[0]), int32(pos[1]), int32(pos[2])))
+	}
+	if placed != nil && result.Blocks > 0 {
+		if isStructurePath(cfg.DryRunPath) {
+			result.SaveError = saveDryRunStructure(cfg.DryRunPath, placed, commandBlocks, chests)
+		} else {
+			result.SaveError = saveDryRunWorld(provider, cfg.DryRunPath)
+		}
+	}
+	return result
+}
+
+// isStructurePath tells if the result of a dry run is saved as a
+// structure file instead of a world folder
+func isStructurePath(path string) bool {
+	lowerPath := strings.ToLower(path)
+	return strings.HasSuffix(lowerPath, ".mcstructure") || strings.HasSuffix(lowerPath, ".schem")
+}
+
+// saveDryRunWorld saves the world of a dry run as a bedrock world folder,
+// the folder is created if it doesn't exist.
+func saveDryRunWorld(provider *world_provider.MemoryProvider, path string) error {
+	if uri, err := storage.ParseURI(path); err == nil && uri.Scheme() == "file" {
+		path = uri.Path()
+	}
+	db, err := mcdb.New(path)
+	if err != nil {
+		return err
+	}
+	if err := provider.SaveTo(db); err != nil {
+		db.Close()
+		return err
+	}
+	return db.Close()
+}
+
+// saveDryRunStructure saves the blocks of a dry run as a .mcstructure or
+// .schem file, with the lowest corner of the blocks as its origin.
+func saveDryRunStructure(path string, placed map[cube.Pos]uint32, commandBlocks map[cube.Pos]*types.CommandBlockData, chests map[cube.Pos]types.ChestData) error {
+	first := true
+	var origin types.Position
+	for pos := range placed {
+		if first || pos[0] < origin.X {
+			origin.X = pos[0]
+		}
+		if first || pos[1] < origin.Y {
+			origin.Y = pos[1]
+		}
+		if first || pos[2] < origin.Z {
+			origin.Z = pos[2]
+		}
+		first = false
+	}
+	blocks := make([]*types.RuntimeModule, 0, len(placed))
+	for pos, runtimeId := range placed {
+		blk := &types.RuntimeModule{
+			BlockRuntimeId:   runtimeId,
+			CommandBlockData: commandBlocks[pos],
+			Point:            types.Position{X: pos[0] - origin.X, Y: pos[1] - origin.Y, Z: pos[2] - origin.Z},
+		}
+		if chest, found := chests[pos]; found {
+			blk.ChestData = &chest
+		}
+		blocks = append(blocks, blk)
+	}
+	format, err := exportFormat("", path)
+	if err != nil {
+		return err
+	}
+	_, err = writeStructure(format, path, blocks, structure.Size(blocks), origin)
+	return err
+}
@@ -0,0 +1,195 @@
+package task
+
+import (
+	"os"
+	"path/filepath"
+	"phoenixbuilder_3rd_gui/fb/dragonfly/server/world"
+	"phoenixbuilder_3rd_gui/fb/dragonfly/server/world/mcdb"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/configuration"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/environment"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/parsing"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/structure"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/world_provider"
+	"testing"
+
+	"fyne.io/fyne/v2/test"
+)
+
+func TestDryRun(t *testing.T) {
+	for _, c := range []struct {
+		command string
+		blocks  int
+		unknown map[string]int
+		name    string
+	}{
+		{"fill -b stone", 18, map[string]int{}, "stone"},
+		{"fill -b wool -d 14", 18, map[string]int{}, "wool"},
+		{"fill -b stone:1,no_such_block:1 -seed 3", -1, nil, ""},
+		{"fill -b no_such_block", 0, map[string]int{"no_such_block 0": 18}, "air"},
+	} {
+		defaultConfig := configuration.CreateFullConfig().Main()
+		defaultConfig.Position.X, defaultConfig.Position.Y, defaultConfig.Position.Z = 0, 64, 0
+		defaultConfig.End.X, defaultConfig.End.Y, defaultConfig.End.Z = 2, 65, 2
+		configs, err := parsing.PipeParse(c.command, defaultConfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		provider := world_provider.NewMemoryProvider()
+		result, err := DryRun(&environment.PBEnvironment{}, configs, provider)
+		if err != nil {
+			t.Fatalf("%s: %v", c.command, err)
+		}
+		if c.blocks < 0 {
+			// the blocks of a pattern are either placed or unknown
+			if result.Blocks+result.UnknownBlocks["no_such_block 0"] != 18 || result.Blocks == 0 || result.Blocks == 18 {
+				t.Fatalf("%s: %d blocks placed, unknown %v", c.command, result.Blocks, result.UnknownBlocks)
+			}
+			continue
+		}
+		if result.Blocks != c.blocks {
+			t.Fatalf("%s: %d blocks placed, it should be %d", c.command, result.Blocks, c.blocks)
+		}
+		if len(result.UnknownBlocks) != len(c.unknown) {
+			t.Fatalf("%s: unknown blocks %v, they should be %v", c.command, result.UnknownBlocks, c.unknown)
+		}
+		for name, count := range c.unknown {
+			if result.UnknownBlocks[name] != count {
+				t.Fatalf("%s: unknown blocks %v, they should be %v", c.command, result.UnknownBlocks, c.unknown)
+			}
+		}
+		chunk, _, err := provider.LoadChunk(world.ChunkPos{0, 0})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if chunk == nil {
+			if c.name != "air" {
+				t.Fatalf("%s: nothing was saved to the provider", c.command)
+			}
+			continue
+		}
+		if name := world_provider.RuntimeIdArray_117[chunk.RuntimeID(1, 65, 1, 0)].Name; name != c.name {
+			t.Fatalf("%s: %s in the world, it should be %s", c.command, name, c.name)
+		}
+	}
+}
+
+func TestDryRunSave(t *testing.T) {
+	test.NewApp()
+	path := filepath.Join(t.TempDir(), "dryrun.mcstructure")
+	defaultConfig := configuration.CreateFullConfig().Main()
+	defaultConfig.Position.X, defaultConfig.Position.Y, defaultConfig.Position.Z = 10, 64, 10
+	defaultConfig.End.X, defaultConfig.End.Y, defaultConfig.End.Z = 12, 64, 13
+	configs, err := parsing.PipeParse("fill -b stone -dryrun_path file://"+path, defaultConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := DryRun(&environment.PBEnvironment{}, configs, world_provider.NewMemoryProvider())
+	if err != nil || result.SaveError != nil {
+		t.Fatalf("dry run failed: %v, %v", err, result.SaveError)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	s, err := structure.ReadMCStructure(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Size != [3]int{3, 1, 4} {
+		t.Fatalf("the size of the structure is %v", s.Size)
+	}
+
+	// a path that isn't a structure file is saved as a world folder
+	folder := filepath.Join(t.TempDir(), "dryrun")
+	configs[0].DryRunPath = "file://" + folder
+	result, err = DryRun(&environment.PBEnvironment{}, configs, world_provider.NewMemoryProvider())
+	if err != nil || result.SaveError != nil {
+		t.Fatalf("dry run failed: %v, %v", err, result.SaveError)
+	}
+	if _, err := os.Stat(filepath.Join(folder, "level.dat")); err != nil {
+		t.Fatal(err)
+	}
+	db, err := mcdb.New(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	chunk, found, err := db.LoadChunk(world.ChunkPos{0, 0})
+	if err != nil || !found {
+		t.Fatalf("the chunk isn't saved: %v", err)
+	}
+	if name := world_provider.RuntimeIdArray_117[chunk.RuntimeID(11, 64, 12, 0)].Name; name != "stone" {
+		t.Fatalf("%s in the world folder, it should be stone", name)
+	}
+}
+
+func TestDryRunReadsItsWorld(t *testing.T) {
+	provider := world_provider.NewMemoryProvider()
+	for _, c := range []struct {
+		command string
+		end     int
+		blocks  int
+	}{
+		{"fill -b stone", 0, 6},
+		// only the air around the stone is filled
+		{"keep -b sand", 2, 12},
+	} {
+		defaultConfig := configuration.CreateFullConfig().Main()
+		defaultConfig.Position.X, defaultConfig.Position.Y, defaultConfig.Position.Z = 0, 64, 0
+		defaultConfig.End.X, defaultConfig.End.Y, defaultConfig.End.Z = c.end, 65, 2
+		configs, err := parsing.PipeParse(c.command, defaultConfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := DryRun(&environment.PBEnvironment{}, configs, provider)
+		if err != nil {
+			t.Fatalf("%s: %v", c.command, err)
+		}
+		if result.Blocks != c.blocks {
+			t.Fatalf("%s: %d blocks placed, it should be %d", c.command, result.Blocks, c.blocks)
+		}
+	}
+	chunk, _, _ := provider.LoadChunk(world.ChunkPos{0, 0})
+	if name := world_provider.RuntimeIdArray_117[chunk.RuntimeID(0, 64, 1, 0)].Name; name != "stone" {
+		t.Fatalf("%s at 0 64 1, it should be stone", name)
+	}
+	if name := world_provider.RuntimeIdArray_117[chunk.RuntimeID(2, 65, 1, 0)].Name; name != "sand" {
+		t.Fatalf("%s at 2 65 1, it should be sand", name)
+	}
+}
+
+func TestDryRunGenerator(t *testing.T) {
+	for _, c := range []struct {
+		command string
+		err     bool
+	}{
+		{"keep -b glass -generator landscape -h 12 -seed 5", false},
+		{"keep -b glass -generator landscape -h 0", true},
+		{"keep -b glass -generator caves", true},
+	} {
+		defaultConfig := configuration.CreateFullConfig().Main()
+		defaultConfig.Position.X, defaultConfig.Position.Y, defaultConfig.Position.Z = 0, 64, 0
+		defaultConfig.End.X, defaultConfig.End.Y, defaultConfig.End.Z = 2, 80, 2
+		configs, err := parsing.PipeParse(c.command, defaultConfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		provider := world_provider.NewMemoryProvider()
+		result, err := DryRun(&environment.PBEnvironment{}, configs, provider)
+		if (err != nil) != c.err {
+			t.Fatalf("%s: error %v", c.command, err)
+		}
+		if c.err {
+			continue
+		}
+		// the glass is only placed in the air above the landscape
+		if result.Blocks == 0 || result.Blocks >= 3*17*3 {
+			t.Fatalf("%s: %d blocks placed", c.command, result.Blocks)
+		}
+		chunk, _, _ := provider.LoadChunk(world.ChunkPos{0, 0})
+		if name := world_provider.RuntimeIdArray_117[chunk.RuntimeID(1, 64, 1, 0)].Name; name == "air" || name == "glass" {
+			t.Fatalf("%s: %s at the bottom of the landscape", c.command, name)
+		}
+	}
+}
@@ -2,15 +2,16 @@ package task
 
 import (
 	"fmt"
+	"fyne.io/fyne/v2/storage"
 	"phoenixbuilder_3rd_gui/fb/dragonfly/server/block/cube"
 	"phoenixbuilder_3rd_gui/fb/dragonfly/server/world"
 	"phoenixbuilder_3rd_gui/fb/fastbuilder/bdump"
 	"phoenixbuilder_3rd_gui/fb/fastbuilder/command"
-	"phoenixbuilder_3rd_gui/fb/fastbuilder/configuration"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/environment"
 	"phoenixbuilder_3rd_gui/fb/fastbuilder/parsing"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/structure"
 	"phoenixbuilder_3rd_gui/fb/fastbuilder/types"
 	"phoenixbuilder_3rd_gui/fb/fastbuilder/world_provider"
-	"phoenixbuilder_3rd_gui/fb/minecraft"
 	"phoenixbuilder_3rd_gui/fb/minecraft/protocol/packet"
 	"runtime"
 	"strings"
@@ -28,10 +29,10 @@ type SolidRet struct {
 	StatusCode int64          `json:"statusCode"`
 }
 
-var ExportWaiter chan map[string]interface{}
-
-func CreateExportTask(commandLine string, conn *minecraft.Conn) *Task {
-	cfg, err := parsing.Parse(commandLine, configuration.GlobalFullConfig().Main())
+func CreateExportTask(commandLine string, env *environment.PBEnvironment) *Task {
+	conn := env.Connection
+	worldHolder := env.WorldHolder
+	cfg, err := parsing.Parse(commandLine, env.Configuration.FullConfig.Main())
 	if err != nil {
 		command.Tellraw(conn, fmt.Sprintf("Failed to parse command: %v", err))
 		return nil
@@ -53,118 +54,59 @@ func CreateExportTask(commandLine string, conn *minecraft.Conn) *Task {
 		endPos.Z = beginPos.Z
 		beginPos.Z = temp
 	}
-	if world_provider.CurrentWorld != nil {
-		command.Tellraw(conn, "EXPORT >> World interaction interface is occupied, failing")
+	format, err := exportFormat(cfg.Format, cfg.Path)
+	if err != nil {
+		command.Tellraw(conn, fmt.Sprintf("EXPORT >> %v", err))
 		return nil
 	}
-	world_provider.NewWorld(conn)
 	go func() {
 		command.Tellraw(conn, "EXPORT >> Exporting...")
 		V := (endPos.X - beginPos.X + 1) * (endPos.Y - beginPos.Y + 1) * (endPos.Z - beginPos.Z + 1)
 		blocks := make([]*types.RuntimeModule, V)
 		counter := 0
-		for x := beginPos.X; x <= endPos.X; x++ {
-			for z := beginPos.Z; z <= endPos.Z; z++ {
-				for y := beginPos.Y; y <= endPos.Y; y++ {
-					blk := world_provider.CurrentWorld.Block(cube.Pos{x, y, z})
-					runtimeId := world.LoadRuntimeID(blk)
-					if runtimeId == world_provider.AirRuntimeId {
-						continue
-					}
-					block, item := blk.EncodeBlock()
-					var cbdata *types.CommandBlockData = nil
-					var chestData *types.ChestData = nil
-					if block == "chest" || strings.Contains(block, "shulker_box") {
-						content := item["Items"].([]interface{})
-						chest := make(types.ChestData, len(content))
-						for index, iface := range content {
-							i := iface.(map[string]interface{})
-							name := i["Name"].(string)
-							count := i["Count"].(uint8)
-							damage := i["Damage"].(int16)
-							slot := i["Slot"].(uint8)
-							name_mcnk := name[10:]
-							chest[index] = types.ChestSlot{
-								Name:   name_mcnk,
-								Count:  count,
-								Damage: uint16(int(damage)),
-								Slot:   slot,
-							}
-						}
-						chestData = &chest
-					}
-					if strings.Contains(block, "command_block") {
-						var mode uint32
-						if block == "command_block" {
-							mode = packet.CommandBlockImpulse
-						} else if block == "repeating_command_block" {
-							mode = packet.CommandBlockRepeat
-						} else if block == "chain_command_block" {
-							mode = packet.CommandBlockChain
-						}
-						cmd := item["Command"].(string)
-						cusname := item["CustomName"].(string)
-						exeft := item["ExecuteOnFirstTick"].(uint8)
-						tickdelay := item["TickDelay"].(int32)
-						aut := item["auto"].(uint8)
-						trackoutput := item["TrackOutput"].(uint8)
-						lo := item["LastOutput"].(string)
-						//conditionalmode:=item["conditionalMode"].(uint8)
-						data := item["data"].(int32)
-						var conb bool
-						if (data>>3)&1 == 1 {
-							conb = true
-						} else {
-							conb = false
+		worldHolder.Visit(func(w *world.World) {
+			for x := beginPos.X; x <= endPos.X; x++ {
+				for z := beginPos.Z; z <= endPos.Z; z++ {
+					for y := beginPos.Y; y <= endPos.Y; y++ {
+						blk := w.Block(cube.Pos{x, y, z})
+						runtimeId := world.LoadRuntimeID(blk)
+						if runtimeId == world_provider.AirRuntimeId {
+							continue
 						}
-						var exeftb bool
-						if exeft == 0 {
-							exeftb = true
-						} else {
-							exeftb = true
-						}
-						var tob bool
-						if trackoutput == 1 {
-							tob = true
-						} else {
-							tob = false
-						}
-						var nrb bool
-						if aut == 1 {
-							nrb = false
-							//REVERSED!!
-						} else {
-							nrb = true
-						}
-						cbdata = &types.CommandBlockData{
-							Mode:               mode,
-							Command:            cmd,
-							CustomName:         cusname,
-							ExecuteOnFirstTick: exeftb,
-							LastOutput:         lo,
-							TickDelay:          tickdelay,
-							TrackOutput:        tob,
-							Conditional:        conb,
-							NeedRedstone:       nrb,
+						block, item := blk.EncodeBlock()
+						cbdata, chestData := decodeBlockEntity(block, item)
+						blocks[counter] = &types.RuntimeModule{
+							BlockRuntimeId:   runtimeId,
+							CommandBlockData: cbdata,
+							ChestData:        chestData,
+							Point: types.Position{
+								X: x - beginPos.X,
+								Y: y - beginPos.Y,
+								Z: z - beginPos.Z,
+							},
 						}
+						counter++
 					}
-					blocks[counter] = &types.RuntimeModule{
-						BlockRuntimeId:   runtimeId,
-						CommandBlockData: cbdata,
-						ChestData:        chestData,
-						Point: types.Position{
-							X: x,
-							Y: y,
-							Z: z,
-						},
-					}
-					counter++
 				}
 			}
-		}
-		world_provider.DestroyWorld()
+		})
 		blocks = blocks[:counter]
 		runtime.GC()
+		command.Tellraw(conn, "EXPORT >> Writing output file")
+		if format != "bdx" {
+			size := [3]int{endPos.X - beginPos.X + 1, endPos.Y - beginPos.Y + 1, endPos.Z - beginPos.Z + 1}
+			inexact, err := writeStructure(format, cfg.Path, blocks, size, beginPos)
+			if err != nil {
+				command.Tellraw(conn, fmt.Sprintf("EXPORT >> ERROR: Failed to export: %v", err))
+				return
+			}
+			if len(inexact) > 0 {
+				command.Tellraw(conn, fmt.Sprintf("EXPORT >> Note: The following blocks have no exact java equivalent: %s", strings.Join(inexact, ", ")))
+			}
+			command.Tellraw(conn, fmt.Sprintf("EXPORT >> Successfully exported your structure to %v", cfg.Path))
+			runtime.GC()
+			return
+		}
 		out := bdump.BDump{
 			Blocks: blocks,
 			//Blocks: nil,
@@ -172,8 +114,7 @@ func CreateExportTask(commandLine string, conn *minecraft.Conn) *Task {
 		// if strings.LastIndex(cfg.Path, ".bdx") != len(cfg.Path)-4 || len(cfg.Path) < 4 {
 		// 	cfg.Path += ".bdx"
 		// }
-		command.Tellraw(conn, "EXPORT >> Writing output file")
-		err, signerr := out.WriteToFile(cfg.Path)
+		err, signerr := out.WriteToFile(cfg.Path, env.Configuration.UserToken)
 		if err != nil {
 			command.Tellraw(conn, fmt.Sprintf("EXPORT >> ERROR: Failed to export: %v", err))
 			return
@@ -187,3 +128,132 @@ func CreateExportTask(commandLine string, conn *minecraft.Conn) *Task {
 	}()
 	return nil
 }
+
+// decodeBlockEntity returns the command block data or the items of a
+// chest from the nbt of a block, nil if the block has neither
+func decodeBlockEntity(block string, item map[string]interface{}) (cbdata *types.CommandBlockData, chestData *types.ChestData) {
+	// the blocks without nbt in the chunk only have their states
+	content, hasItems := item["Items"].([]interface{})
+	if (block == "chest" || strings.Contains(block, "shulker_box")) && hasItems {
+		chest := make(types.ChestData, 0, len(content))
+		for _, iface := range content {
+			// the items that aren't like the ones of the game are skipped
+			i, ok := iface.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			name, ok := i["Name"].(string)
+			if !ok {
+				continue
+			}
+			count, ok := i["Count"].(uint8)
+			if !ok {
+				continue
+			}
+			damage, _ := i["Damage"].(int16)
+			slot, ok := i["Slot"].(uint8)
+			if !ok {
+				continue
+			}
+			chest = append(chest, types.ChestSlot{
+				Name:   strings.TrimPrefix(name, "minecraft:"),
+				Count:  count,
+				Damage: uint16(int(damage)),
+				Slot:   slot,
+			})
+		}
+		chestData = &chest
+	}
+	if cmd, hasCommand := item["Command"].(string); strings.Contains(block, "command_block") && hasCommand {
+		var mode uint32
+		if block == "command_block" {
+			mode = packet.CommandBlockImpulse
+		} else if block == "repeating_command_block" {
+			mode = packet.CommandBlockRepeat
+		} else if block == "chain_command_block" {
+			mode = packet.CommandBlockChain
+		}
+		// the missing fields are left zero
+		cusname, _ := item["CustomName"].(string)
+		exeft, _ := item["ExecuteOnFirstTick"].(uint8)
+		tickdelay, _ := item["TickDelay"].(int32)
+		aut, _ := item["auto"].(uint8)
+		trackoutput, _ := item["TrackOutput"].(uint8)
+		lo, _ := item["LastOutput"].(string)
+		//conditionalmode:=item["conditionalMode"].(uint8)
+		data, _ := item["data"].(int32)
+		var conb bool
+		if (data>>3)&1 == 1 {
+			conb = true
+		} else {
+			conb = false
+		}
+		var exeftb bool
+		if exeft == 0 {
+			exeftb = true
+		} else {
+			exeftb = true
+		}
+		var tob bool
+		if trackoutput == 1 {
+			tob = true
+		} else {
+			tob = false
+		}
+		var nrb bool
+		if aut == 1 {
+			nrb = false
+			//REVERSED!!
+		} else {
+			nrb = true
+		}
+		cbdata = &types.CommandBlockData{
+			Mode:               mode,
+			Command:            cmd,
+			CustomName:         cusname,
+			ExecuteOnFirstTick: exeftb,
+			LastOutput:         lo,
+			TickDelay:          tickdelay,
+			TrackOutput:        tob,
+			Conditional:        conb,
+			NeedRedstone:       nrb,
+		}
+	}
+	return
+}
+
+// exportFormat returns the format specified by -format,
+// or guesses it by the extension of the path if not specified.
+func exportFormat(format string, path string) (string, error) {
+	format = strings.ToLower(format)
+	if format == "" {
+		lowerPath := strings.ToLower(path)
+		if strings.HasSuffix(lowerPath, ".mcstructure") {
+			return "mcstructure", nil
+		} else if strings.HasSuffix(lowerPath, ".schem") {
+			return "schem", nil
+		}
+		return "bdx", nil
+	}
+	switch format {
+	case "bdx", "mcstructure", "schem":
+		return format, nil
+	}
+	return "", fmt.Errorf("Unknown export format: %s", format)
+}
+
+func writeStructure(format string, path string, blocks []*types.RuntimeModule, size [3]int, origin types.Position) ([]string, error) {
+	uri, err := storage.ParseURI(path)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open file: %v", path)
+	}
+	file, err := storage.Writer(uri)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open file: %v", path)
+	}
+	defer file.Close()
+	if format == "mcstructure" {
+		return nil, structure.WriteMCStructure(file, blocks, size, origin)
+	}
+	return structure.WriteSpongeSchematic(file, blocks, size)
+}
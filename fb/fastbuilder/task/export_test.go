@@ -0,0 +1,45 @@
+package task
+
+import (
+	"testing"
+)
+
+func TestDecodeBlockEntity(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		block    string
+		item     map[string]interface{}
+		command  string
+		hasCmd   bool
+		slots    int
+		hasChest bool
+	}{
+		{"states only", "stone", map[string]interface{}{}, "", false, 0, false},
+		{"command block", "command_block", map[string]interface{}{
+			"Command": "say hi", "CustomName": "", "ExecuteOnFirstTick": uint8(1), "TickDelay": int32(0),
+			"auto": uint8(1), "TrackOutput": uint8(1), "LastOutput": "", "data": int32(8),
+		}, "say hi", true, 0, false},
+		{"command block without fields", "chain_command_block", map[string]interface{}{"Command": "say hi"}, "say hi", true, 0, false},
+		{"command of another type", "command_block", map[string]interface{}{"Command": int32(1)}, "", false, 0, false},
+		{"chest", "chest", map[string]interface{}{"Items": []interface{}{
+			map[string]interface{}{"Name": "minecraft:apple", "Count": uint8(3), "Damage": int16(0), "Slot": uint8(0)},
+			map[string]interface{}{"Name": "bread", "Count": uint8(1), "Slot": uint8(1)},
+		}}, "", false, 2, true},
+		{"odd items", "shulker_box", map[string]interface{}{"Items": []interface{}{
+			"apple",
+			map[string]interface{}{"Name": "apple"},
+			map[string]interface{}{"Name": int16(1), "Count": uint8(1), "Slot": uint8(0)},
+		}}, "", false, 0, true},
+	} {
+		cbdata, chestData := decodeBlockEntity(c.block, c.item)
+		if (cbdata != nil) != c.hasCmd || (cbdata != nil && cbdata.Command != c.command) {
+			t.Fatalf("%s: command block data %+v", c.name, cbdata)
+		}
+		if (chestData != nil) != c.hasChest || (chestData != nil && len(*chestData) != c.slots) {
+			t.Fatalf("%s: chest data %+v", c.name, chestData)
+		}
+		if chestData != nil && c.slots > 0 && (*chestData)[0].Name != "apple" {
+			t.Fatalf("%s: the name of the item is %s", c.name, (*chestData)[0].Name)
+		}
+	}
+}
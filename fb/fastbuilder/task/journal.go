@@ -0,0 +1,277 @@
+package task
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"phoenixbuilder_3rd_gui/fb/dragonfly/server/world"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/builder"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/configuration"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/environment"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/types"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/world_provider"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+)
+
+// Journal keeps the blocks a task changed, so that it can be undone
+// and then redone
+type Journal struct {
+	TaskId      int64
+	CommandLine string
+	// the blocks in the world before the task
+	Before []*types.Module
+	// the blocks the task built
+	After  []*types.Module
+	Undone bool
+}
+
+// JournalStore keeps the journals of the tasks of a session
+type JournalStore interface {
+	Save(journal *Journal) error
+	Load(taskId int64) (*Journal, error)
+	// List returns the ids of the tasks that have journals, in order
+	List() ([]int64, error)
+	// Clear removes all the journals, it's called when the session is closed
+	Clear() error
+}
+
+// StorageJournalStore keeps journals as json files in the storage of
+// the app, the files of a store are named by the time it's created so
+// that the sessions don't use the journals of each other
+type StorageJournalStore struct {
+	storage fyne.Storage
+	prefix  string
+}
+
+func NewStorageJournalStore(storage fyne.Storage) *StorageJournalStore {
+	return &StorageJournalStore{
+		storage: storage,
+		prefix:  fmt.Sprintf("task_journal_%d_", time.Now().UnixNano()),
+	}
+}
+
+func (store *StorageJournalStore) fileName(taskId int64) string {
+	return fmt.Sprintf("%s%d.json", store.prefix, taskId)
+}
+
+func (store *StorageJournalStore) Save(journal *Journal) error {
+	data, err := json.Marshal(journal)
+	if err != nil {
+		return err
+	}
+	name := store.fileName(journal.TaskId)
+	writer, err := store.storage.Save(name)
+	if err != nil {
+		// doesn't exist yet
+		writer, err = store.storage.Create(name)
+		if err != nil {
+			return fmt.Errorf("cannot create journal file %s: %v", name, err)
+		}
+	}
+	defer writer.Close()
+	_, err = writer.Write(data)
+	return err
+}
+
+func (store *StorageJournalStore) Load(taskId int64) (*Journal, error) {
+	name := store.fileName(taskId)
+	reader, err := store.storage.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("task %d has no journal", taskId)
+	}
+	defer reader.Close()
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	journal := &Journal{}
+	if err := json.Unmarshal(data, journal); err != nil {
+		return nil, fmt.Errorf("broken journal file %s: %v", name, err)
+	}
+	return journal, nil
+}
+
+func (store *StorageJournalStore) List() ([]int64, error) {
+	var ids []int64
+	for _, name := range store.storage.List() {
+		if !strings.HasPrefix(name, store.prefix) || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		id, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimPrefix(name, store.prefix), ".json"), 10, 64)
+		if err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+	return ids, nil
+}
+
+func (store *StorageJournalStore) Clear() error {
+	ids, err := store.List()
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := store.storage.Remove(store.fileName(id)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// captureBlocks reads the blocks that modules are going to change from
+// the world, the blocks are decoded like the ones exported, so command
+// blocks and the items of chests are kept
+func captureBlocks(env *environment.PBEnvironment, modules []*types.Module) ([]*types.Module, error) {
+	seen := make(map[types.Position]bool)
+	var points []types.Position
+	add := func(point types.Position) {
+		if !seen[point] {
+			seen[point] = true
+			points = append(points, point)
+		}
+	}
+	for _, module := range modules {
+		if module.FillTo == nil {
+			add(module.Point)
+			continue
+		}
+		for x := module.Point.X; x <= module.FillTo.X; x++ {
+			for y := module.Point.Y; y <= module.FillTo.Y; y++ {
+				for z := module.Point.Z; z <= module.FillTo.Z; z++ {
+					add(types.Position{X: x, Y: y, Z: z})
+				}
+			}
+		}
+	}
+	captured := make([][]*types.Module, len(points))
+	err := builder.VisitWorld(env, points, func(i int, blk world.Block) {
+		runtimeId := world.LoadRuntimeID(blk)
+		if int(runtimeId) >= len(world_provider.RuntimeIdArray_117) {
+			// unknown, it can't be restored
+			return
+		}
+		constBlock := world_provider.RuntimeIdArray_117[runtimeId]
+		module := &types.Module{
+			Block: types.CreateBlock(constBlock.Name, constBlock.Data),
+			Point: points[i],
+		}
+		name, item := blk.EncodeBlock()
+		var chestData *types.ChestData
+		module.CommandBlockData, chestData = decodeBlockEntity(strings.TrimPrefix(name, "minecraft:"), item)
+		captured[i] = append(captured[i], module)
+		if chestData != nil {
+			for _, slot := range *chestData {
+				slot := slot
+				captured[i] = append(captured[i], &types.Module{ChestSlot: &slot, Point: points[i]})
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	var before []*types.Module
+	for _, blocks := range captured {
+		before = append(before, blocks...)
+	}
+	return before, nil
+}
+
+// journalModules returns copies of the modules with the blocks given by
+// config set, the modules of the builders may share their blocks
+func journalModules(modules []*types.Module, config *types.MainConfig) []*types.Module {
+	block := types.CreateBlock(config.Block.Name, config.Block.Data)
+	after := make([]*types.Module, len(modules))
+	for i, module := range modules {
+		copied := *module
+		if copied.Block == nil && copied.ChestSlot == nil {
+			copied.Block = block
+		}
+		after[i] = &copied
+	}
+	return after
+}
+
+// writeJournal saves the blocks the task is going to change before they
+// are built
+func writeJournal(env *environment.PBEnvironment, taskId int64, commandLine string, config *types.MainConfig, modules []*types.Module) error {
+	holder := GetTaskHolder(env)
+	var changed []*types.Module
+	for _, module := range modules {
+		if module.ChestSlot == nil {
+			changed = append(changed, module)
+		}
+	}
+	before, err := captureBlocks(env, changed)
+	if err != nil {
+		return err
+	}
+	return holder.Journals.Save(&Journal{
+		TaskId:      taskId,
+		CommandLine: commandLine,
+		Before:      before,
+		After:       journalModules(modules, config),
+	})
+}
+
+// UndoTask builds the blocks that were in the world before the task
+// again, RedoTask builds the blocks of the task again after it's undone
+func UndoTask(taskId int64, env *environment.PBEnvironment) (*Task, error) {
+	return replayJournal(taskId, env, true)
+}
+
+func RedoTask(taskId int64, env *environment.PBEnvironment) (*Task, error) {
+	return replayJournal(taskId, env, false)
+}
+
+func replayJournal(taskId int64, env *environment.PBEnvironment, undo bool) (*Task, error) {
+	holder := GetTaskHolder(env)
+	if holder.Journals == nil {
+		return nil, fmt.Errorf("tasks are not journaled in this session")
+	}
+	if holder.FindTask(taskId) != nil {
+		return nil, fmt.Errorf("task %d is still running", taskId)
+	}
+	if _, ok := holder.syncTasks.Load(taskId); ok {
+		return nil, fmt.Errorf("task %d is a sync task, only async tasks can be undone", taskId)
+	}
+	journal, err := holder.Journals.Load(taskId)
+	if err != nil {
+		return nil, err
+	}
+	if undo && journal.Undone {
+		return nil, fmt.Errorf("task %d is undone already", taskId)
+	} else if !undo && !journal.Undone {
+		return nil, fmt.Errorf("task %d is not undone", taskId)
+	}
+	modules, execute := journal.Before, "undo"
+	if !undo {
+		modules, execute = journal.After, "redo"
+	}
+	journal.Undone = undo
+	if err := holder.Journals.Save(journal); err != nil {
+		return nil, err
+	}
+	cfg := *env.Configuration.FullConfig.Main()
+	cfg.Execute = execute
+	cfg.Method = "replace"
+	cfg.ExcludeCommands = false
+	cfg.InvalidateCommands = false
+	cfg.Verify = 0
+	fcfg := configuration.ConcatFullConfig(&cfg, env.Configuration.FullConfig.Delay())
+	commandLine := fmt.Sprintf("task %s %d", execute, taskId)
+	return runTask(commandLine, env, []*types.MainConfig{&cfg}, fcfg, env.Configuration.FullConfig.Global().TaskCreationType, nil, func(blc chan *types.Module) error {
+		for _, module := range modules {
+			blc <- module
+		}
+		return nil
+	}), nil
+}
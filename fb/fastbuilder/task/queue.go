@@ -0,0 +1,218 @@
+package task
+
+import (
+	"fmt"
+	"sync"
+)
+
+// taskQueue keeps the tasks waiting to be started, the ones of higher
+// priority first and the ones of the same priority in the order they
+// are queued
+type taskQueue struct {
+	mu      sync.Mutex
+	waiting []*Task
+	running int
+}
+
+// insert puts task after the waiting tasks of the same or higher priority
+func (queue *taskQueue) insert(task *Task) {
+	i := 0
+	for i < len(queue.waiting) && queue.waiting[i].Priority >= task.Priority {
+		i++
+	}
+	queue.waiting = append(queue.waiting, nil)
+	copy(queue.waiting[i+1:], queue.waiting[i:])
+	queue.waiting[i] = task
+}
+
+func (queue *taskQueue) indexOf(task *Task) int {
+	for i, waiting := range queue.waiting {
+		if waiting == task {
+			return i
+		}
+	}
+	return -1
+}
+
+func (queue *taskQueue) remove(i int) {
+	queue.waiting = append(queue.waiting[:i], queue.waiting[i+1:]...)
+}
+
+// enqueue adds the task to the queue, it's started at once if nothing
+// keeps it waiting
+func (holder *TaskHolder) enqueue(task *Task) {
+	holder.queue.mu.Lock()
+	task.State = TaskStateQueued
+	holder.queue.insert(task)
+	holder.queue.mu.Unlock()
+	holder.schedule()
+}
+
+// maxConcurrent returns how many tasks may run at once, 0 if there is no limit
+func (holder *TaskHolder) maxConcurrent() int {
+	if holder.Global == nil {
+		return 0
+	}
+	return holder.Global.MaxConcurrentTasks
+}
+
+// runnable tells if the task the waiting one is after is done, tasks
+// are removed from TaskMap when they are done or broken
+func (holder *TaskHolder) runnable(task *Task) bool {
+	return task.After == 0 || holder.FindTask(task.After) == nil
+}
+
+// schedule starts the waiting tasks in order until as many tasks as
+// allowed are running, the ones waiting for other tasks are skipped
+func (holder *TaskHolder) schedule() {
+	if holder.stopped() {
+		return
+	}
+	holder.queue.mu.Lock()
+	var started []*Task
+	for {
+		if max := holder.maxConcurrent(); max > 0 && holder.queue.running >= max {
+			break
+		}
+		next := -1
+		for i, task := range holder.queue.waiting {
+			if holder.runnable(task) {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			break
+		}
+		task := holder.queue.waiting[next]
+		holder.queue.remove(next)
+		holder.queue.running++
+		task.started = true
+		task.State = TaskStateCalculating
+		started = append(started, task)
+	}
+	holder.queue.mu.Unlock()
+	for _, task := range started {
+		task.start()
+	}
+}
+
+// done is called when a task is finalized, the tasks waiting for it
+// or for a free slot may be started then
+func (holder *TaskHolder) done(task *Task) {
+	holder.queue.mu.Lock()
+	if task.started {
+		holder.queue.running--
+	} else if i := holder.queue.indexOf(task); i >= 0 {
+		holder.queue.remove(i)
+	}
+	holder.queue.mu.Unlock()
+	holder.schedule()
+}
+
+// dequeue removes a task that is not started yet from the queue, it
+// returns false if the task is started already
+func (holder *TaskHolder) dequeue(task *Task) bool {
+	holder.queue.mu.Lock()
+	defer holder.queue.mu.Unlock()
+	i := holder.queue.indexOf(task)
+	if i < 0 {
+		return false
+	}
+	holder.queue.remove(i)
+	return true
+}
+
+// Queued returns the tasks waiting to be started, in the order they
+// are started if none of them waits for another task
+func (holder *TaskHolder) Queued() []*Task {
+	holder.queue.mu.Lock()
+	defer holder.queue.mu.Unlock()
+	queued := make([]*Task, len(holder.queue.waiting))
+	copy(queued, holder.queue.waiting)
+	return queued
+}
+
+// Schedule starts the waiting tasks again after the limit of running
+// tasks is changed
+func (holder *TaskHolder) Schedule() {
+	holder.schedule()
+}
+
+// SetPriority changes the priority of a waiting task, it's moved to
+// the place of the priority in the queue
+func (holder *TaskHolder) SetPriority(taskId int64, priority int) error {
+	holder.queue.mu.Lock()
+	task := holder.FindTask(taskId)
+	if task == nil {
+		holder.queue.mu.Unlock()
+		return fmt.Errorf("task %d is not found", taskId)
+	}
+	task.Priority = priority
+	if i := holder.queue.indexOf(task); i >= 0 {
+		holder.queue.remove(i)
+		holder.queue.insert(task)
+	}
+	holder.queue.mu.Unlock()
+	holder.schedule()
+	return nil
+}
+
+// SetAfter makes a waiting task wait for another one to be done
+// before it's started, after is 0 not to wait
+func (holder *TaskHolder) SetAfter(taskId int64, after int64) error {
+	holder.queue.mu.Lock()
+	task := holder.FindTask(taskId)
+	if task == nil {
+		holder.queue.mu.Unlock()
+		return fmt.Errorf("task %d is not found", taskId)
+	}
+	if holder.queue.indexOf(task) < 0 {
+		holder.queue.mu.Unlock()
+		return fmt.Errorf("task %d is started already", taskId)
+	}
+	// tasks waiting for each other would never be started
+	for id := after; id != 0; {
+		if id == taskId {
+			holder.queue.mu.Unlock()
+			return fmt.Errorf("task %d would wait for itself", taskId)
+		}
+		other := holder.FindTask(id)
+		if other == nil {
+			break
+		}
+		id = other.After
+	}
+	task.After = after
+	holder.queue.mu.Unlock()
+	holder.schedule()
+	return nil
+}
+
+// MoveQueued moves a waiting task one place up or down in the queue,
+// it takes the priority of the task it passes so that it stays there
+func (holder *TaskHolder) MoveQueued(taskId int64, up bool) error {
+	holder.queue.mu.Lock()
+	task := holder.FindTask(taskId)
+	i := -1
+	if task != nil {
+		i = holder.queue.indexOf(task)
+	}
+	if i < 0 {
+		holder.queue.mu.Unlock()
+		return fmt.Errorf("task %d is not waiting in the queue", taskId)
+	}
+	j := i + 1
+	if up {
+		j = i - 1
+	}
+	if j < 0 || j >= len(holder.queue.waiting) {
+		holder.queue.mu.Unlock()
+		return nil
+	}
+	task.Priority = holder.queue.waiting[j].Priority
+	holder.queue.waiting[i], holder.queue.waiting[j] = holder.queue.waiting[j], task
+	holder.queue.mu.Unlock()
+	holder.schedule()
+	return nil
+}
@@ -0,0 +1,89 @@
+package task
+
+import (
+	"testing"
+)
+
+// stoppedHolder returns a holder with the tasks of the priorities in
+// its queue, nothing is started since it's stopped
+func stoppedHolder(priorities ...int) *TaskHolder {
+	holder := &TaskHolder{stopChan: make(chan struct{})}
+	close(holder.stopChan)
+	for i, priority := range priorities {
+		task := &Task{TaskId: int64(i + 1), Priority: priority}
+		holder.TaskMap.Store(task.TaskId, task)
+		holder.enqueue(task)
+	}
+	return holder
+}
+
+func queuedIds(holder *TaskHolder) []int64 {
+	var ids []int64
+	for _, task := range holder.Queued() {
+		ids = append(ids, task.TaskId)
+	}
+	return ids
+}
+
+func TestTaskQueue(t *testing.T) {
+	for _, c := range []struct {
+		name       string
+		priorities []int
+		change     func(holder *TaskHolder) error
+		ids        []int64
+		err        bool
+	}{
+		{"same priority", []int{0, 0, 0}, nil, []int64{1, 2, 3}, false},
+		{"higher priority first", []int{0, 5, 1, 5}, nil, []int64{2, 4, 3, 1}, false},
+		{"negative priority last", []int{-1, 0}, nil, []int64{2, 1}, false},
+		{"raise priority", []int{0, 0, 0}, func(holder *TaskHolder) error {
+			return holder.SetPriority(3, 1)
+		}, []int64{3, 1, 2}, false},
+		{"priority set again", []int{0, 0, 0}, func(holder *TaskHolder) error {
+			return holder.SetPriority(1, 0)
+		}, []int64{2, 3, 1}, false},
+		{"move up", []int{2, 0, 0}, func(holder *TaskHolder) error {
+			return holder.MoveQueued(2, true)
+		}, []int64{2, 1, 3}, false},
+		{"move down", []int{0, 0, 0}, func(holder *TaskHolder) error {
+			return holder.MoveQueued(1, false)
+		}, []int64{2, 1, 3}, false},
+		{"move the last down", []int{0, 0}, func(holder *TaskHolder) error {
+			return holder.MoveQueued(2, false)
+		}, []int64{1, 2}, false},
+		{"unknown task", []int{0}, func(holder *TaskHolder) error {
+			return holder.SetPriority(9, 1)
+		}, []int64{1}, true},
+		{"after itself", []int{0, 0}, func(holder *TaskHolder) error {
+			holder.SetAfter(2, 1)
+			return holder.SetAfter(1, 2)
+		}, []int64{1, 2}, true},
+	} {
+		holder := stoppedHolder(c.priorities...)
+		if c.change != nil {
+			if err := c.change(holder); (err != nil) != c.err {
+				t.Fatalf("%s: error %v", c.name, err)
+			}
+		}
+		ids := queuedIds(holder)
+		if len(ids) != len(c.ids) {
+			t.Fatalf("%s: queue %v, it should be %v", c.name, ids, c.ids)
+		}
+		for i := range ids {
+			if ids[i] != c.ids[i] {
+				t.Fatalf("%s: queue %v, it should be %v", c.name, ids, c.ids)
+			}
+		}
+	}
+}
+
+func TestTaskQueueDequeue(t *testing.T) {
+	holder := stoppedHolder(0, 0, 0)
+	second := holder.FindTask(2)
+	if !holder.dequeue(second) || holder.dequeue(second) {
+		t.Fatal("a task is dequeued twice")
+	}
+	if ids := queuedIds(holder); len(ids) != 2 || ids[0] != 1 || ids[1] != 3 {
+		t.Fatalf("queue %v after dequeuing task 2", ids)
+	}
+}
@@ -0,0 +1,83 @@
+package task
+
+import (
+	"time"
+
+	"go.uber.org/atomic"
+)
+
+// the range of the delay of the adaptive delay mode
+const (
+	minAdaptiveDelay = 50 * time.Microsecond
+	maxAdaptiveDelay = 200 * time.Millisecond
+)
+
+// rateController decides the delay between blocks of the adaptive delay
+// mode by how fast the server responds. It's slowed down quickly when the
+// responses are late or the server corrects the movement of the bot, and
+// sped up slowly while the server keeps up, so the blocks are sent just
+// under the rate the server starts to throttle at.
+type rateController struct {
+	// in microseconds, it's read by the task status display
+	delay *atomic.Int64
+	// the shortest round trip seen, the server is taken as busy when the
+	// round trips are much longer than it
+	baseline    time.Duration
+	corrections int64
+}
+
+// newRateController starts from the initial delay, corrections is the
+// number of corrections the server has sent before
+func newRateController(initial time.Duration, corrections int64) *rateController {
+	rc := &rateController{delay: atomic.NewInt64(0), corrections: corrections}
+	rc.set(initial)
+	return rc
+}
+
+func (rc *rateController) set(delay time.Duration) {
+	if delay < minAdaptiveDelay {
+		delay = minAdaptiveDelay
+	} else if delay > maxAdaptiveDelay {
+		delay = maxAdaptiveDelay
+	}
+	rc.delay.Store(delay.Microseconds())
+}
+
+func (rc *rateController) Delay() time.Duration {
+	return time.Duration(rc.delay.Load()) * time.Microsecond
+}
+
+func (rc *rateController) slowDown() {
+	rc.set(rc.Delay()*3/2 + minAdaptiveDelay)
+}
+
+func (rc *rateController) speedUp() {
+	rc.set(rc.Delay() * 19 / 20)
+}
+
+// roundTrip takes the time a command took to be answered or a block took
+// to be updated
+func (rc *rateController) roundTrip(d time.Duration) {
+	if rc.baseline == 0 || d < rc.baseline {
+		rc.baseline = d
+	}
+	if d > rc.baseline*3 && d-rc.baseline > 50*time.Millisecond {
+		rc.slowDown()
+	} else {
+		rc.speedUp()
+	}
+}
+
+// lost is called when a response doesn't arrive in time
+func (rc *rateController) lost() {
+	rc.slowDown()
+}
+
+// corrected takes the number of movement corrections the server has sent,
+// the server corrects the bot more often when it's lagging
+func (rc *rateController) corrected(count int64) {
+	if count > rc.corrections {
+		rc.corrections = count
+		rc.slowDown()
+	}
+}
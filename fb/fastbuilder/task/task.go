@@ -5,6 +5,7 @@ import (
 	"phoenixbuilder_3rd_gui/fb/fastbuilder/builder"
 	"phoenixbuilder_3rd_gui/fb/fastbuilder/command"
 	"phoenixbuilder_3rd_gui/fb/fastbuilder/configuration"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/environment"
 	I18n "phoenixbuilder_3rd_gui/fb/fastbuilder/i18n"
 	"phoenixbuilder_3rd_gui/fb/fastbuilder/parsing"
 	"phoenixbuilder_3rd_gui/fb/fastbuilder/types"
@@ -21,14 +22,25 @@ import (
 )
 
 const (
-	TaskStateUnknown     = 0
-	TaskStateRunning     = 1
-	TaskStatePaused      = 2
-	TaskStateDied        = 3
-	TaskStateCalculating = 4
-	TaskStateSpecialBrk  = 5
+	TaskStateUnknown      = 0
+	TaskStateRunning      = 1
+	TaskStatePaused       = 2
+	TaskStateDied         = 3
+	TaskStateCalculating  = 4
+	TaskStateSpecialBrk   = 5
+	TaskStateReconnecting = 6
+	TaskStateQueued       = 7
 )
 
+// at most this many sync points are waited for, older ones are taken
+// as acknowledged so that the blocks kept for resending don't pile up
+// when the server doesn't respond
+const maxPendingSyncPoints = 100
+
+// the blocks of a chunk are built without teleporting, a sync point is
+// sent when the last one is this old instead
+const syncPointInterval = 500 * time.Millisecond
+
 type Task struct {
 	TaskId        int64
 	CommandLine   string
@@ -38,6 +50,21 @@ type Task struct {
 	Type          byte
 	AsyncInfo
 	Config *configuration.FullConfig
+	// the id of the checkpoint the progress is saved to, 0 if it isn't saved
+	CheckpointId int64
+	holder       *TaskHolder
+	// broken by the user, the blocks are not verified then
+	broken bool
+	// the delay of the adaptive delay mode
+	rate *rateController
+	// tasks of higher priority are started first, a task is not started
+	// before the task After is done if it's not 0
+	Priority int
+	After    int64
+	// start starts generating and sending the blocks, it's called by the
+	// queue once, started is set then
+	start   func()
+	started bool
 }
 
 type AsyncInfo struct {
@@ -46,10 +73,120 @@ type AsyncInfo struct {
 	BeginTime time.Time
 }
 
-var TaskIdCounter *atomic.Int64 = atomic.NewInt64(0)
-var TaskMap sync.Map
-var BrokSender chan string = make(chan string)
-var ExtraDisplayStrings []string = []string{}
+// TaskHolder keeps the tasks of one session
+type TaskHolder struct {
+	TaskIdCounter       *atomic.Int64
+	TaskMap             sync.Map
+	ExtraDisplayStrings []string
+	ActivateTaskStatus  chan bool
+	ExportWaiter        chan map[string]interface{}
+	// where the progress of tasks is saved, nil to not save it
+	Checkpoints CheckpointStore
+	// where the blocks changed by tasks are saved to undo them, nil not
+	// to save them
+	Journals JournalStore
+	// the ids of the sync tasks, they aren't journaled
+	syncTasks sync.Map
+	// MaxConcurrentTasks of it limits the running tasks, nil not to limit them
+	Global   *types.GlobalConfig
+	queue    taskQueue
+	stopChan chan struct{}
+	stopOnce sync.Once
+	// closed and replaced every time the session is connected again
+	reconnected chan struct{}
+	connMu      sync.Mutex
+}
+
+// syncPoint is a command sent before the block at index, once the
+// server responds to it, all blocks before index are known to be built
+type syncPoint struct {
+	index  int
+	id     string
+	output chan *packet.CommandOutput
+	// when the command was sent and when it was found answered
+	sent     time.Time
+	answered time.Time
+}
+
+func (cp *syncPoint) done() bool {
+	if !cp.answered.IsZero() {
+		return true
+	}
+	select {
+	case <-cp.output:
+		cp.answered = time.Now()
+		return true
+	default:
+		return false
+	}
+}
+
+func NewTaskHolder() *TaskHolder {
+	return &TaskHolder{
+		TaskIdCounter:       atomic.NewInt64(0),
+		ExtraDisplayStrings: []string{},
+		ActivateTaskStatus:  make(chan bool),
+		stopChan:            make(chan struct{}),
+		reconnected:         make(chan struct{}),
+	}
+}
+
+func GetTaskHolder(env *environment.PBEnvironment) *TaskHolder {
+	return env.TaskHolder.(*TaskHolder)
+}
+
+// Stop breaks all the tasks and stops the task status display,
+// it should be called when the session is closed
+func (holder *TaskHolder) Stop() {
+	holder.stopOnce.Do(func() {
+		close(holder.stopChan)
+		holder.TaskMap.Range(func(_ interface{}, v interface{}) bool {
+			go v.(*Task).Break()
+			return true
+		})
+	})
+}
+
+func (holder *TaskHolder) stopped() bool {
+	select {
+	case <-holder.stopChan:
+		return true
+	default:
+		return false
+	}
+}
+
+// SetConnection hands the new connection to the tasks after the session
+// is connected again, tasks waiting for it resume building on it
+func (holder *TaskHolder) SetConnection(env *environment.PBEnvironment, conn *minecraft.Conn) {
+	holder.connMu.Lock()
+	defer holder.connMu.Unlock()
+	env.Connection = conn
+	close(holder.reconnected)
+	holder.reconnected = make(chan struct{})
+}
+
+// waitConnection returns the connection to continue on after conn is
+// lost, it blocks until the session is connected again and returns
+// false if the session is closed instead
+func (holder *TaskHolder) waitConnection(env *environment.PBEnvironment, conn *minecraft.Conn) (*minecraft.Conn, bool) {
+	holder.connMu.Lock()
+	if env.Connection != conn {
+		// connected again before the task noticed
+		defer holder.connMu.Unlock()
+		return env.Connection, true
+	}
+	reconnected := holder.reconnected
+	holder.connMu.Unlock()
+	select {
+	case <-reconnected:
+		holder.connMu.Lock()
+		defer holder.connMu.Unlock()
+		return env.Connection, true
+	case <-holder.stopChan:
+		return nil, false
+	}
+}
 
 func GetStateDesc(st byte) string {
 	if st == 0 {
@@ -64,17 +201,22 @@ func GetStateDesc(st byte) string {
 		return I18n.T(I18n.TaskTypeCalculating)
 	} else if st == 5 {
 		return I18n.T(I18n.TaskTypeSpecialTaskBreaking)
+	} else if st == 6 {
+		return I18n.T(I18n.TaskTypeReconnecting)
+	} else if st == 7 {
+		return I18n.T(I18n.TaskTypeQueued)
 	}
 	return "???????"
 }
 
 func (task *Task) Finalize() {
 	task.State = TaskStateDied
-	TaskMap.Delete(task.TaskId)
+	task.holder.TaskMap.Delete(task.TaskId)
+	task.holder.done(task)
 }
 
 func (task *Task) Pause() {
-	if task.State == TaskStatePaused {
+	if task.State == TaskStatePaused || task.State == TaskStateQueued {
 		return
 	}
 	task.ContinueLock.Lock()
@@ -98,6 +240,14 @@ func (task *Task) Resume() {
 }
 
 func (task *Task) Break() {
+	if task.holder != nil && task.holder.dequeue(task) {
+		// not started yet, the progress is kept only if the session is closed
+		if task.CheckpointId != 0 && !task.holder.stopped() {
+			task.holder.Checkpoints.Remove(task.CheckpointId)
+		}
+		task.Finalize()
+		return
+	}
 	if task.OutputChannel == nil {
 		task.State = TaskStateSpecialBrk
 		return
@@ -108,6 +258,7 @@ func (task *Task) Break() {
 	if task.State == TaskStateDied {
 		return
 	}
+	task.broken = true
 	chann := task.OutputChannel
 	for {
 		_, ok := <-chann
@@ -130,231 +281,551 @@ func (task *Task) Break() {
 	task.Resume()
 }
 
-func FindTask(taskId int64) *Task {
-	t, _ := TaskMap.Load(taskId)
+// CurrentDelay returns the delay of the task, it's the one decided by the
+// server's responses in the adaptive delay mode
+func (task *Task) CurrentDelay() int64 {
+	if task.Config.Delay().DelayMode == types.DelayModeAdaptive {
+		return task.rate.Delay().Microseconds()
+	}
+	return task.Config.Delay().Delay
+}
+
+func (holder *TaskHolder) FindTask(taskId int64) *Task {
+	t, _ := holder.TaskMap.Load(taskId)
 	ta, _ := t.(*Task)
 	return ta
 }
 
-func CreateTask(commandLine string, conn *minecraft.Conn) *Task {
-	cfg, err := parsing.Parse(commandLine, configuration.GlobalFullConfig().Main())
+func CreateTask(commandLine string, env *environment.PBEnvironment) *Task {
+	conn := env.Connection
+	configs, err := parsing.PipeParse(commandLine, env.Configuration.FullConfig.Main())
 	if err != nil {
 		command.Tellraw(conn, fmt.Sprintf(I18n.T(I18n.TaskFailedToParseCommand), err))
 		return nil
 	}
-	fcfg := configuration.ConcatFullConfig(cfg, configuration.GlobalFullConfig().Delay())
+	cfg := configs[0]
+	fcfg := configuration.ConcatFullConfig(cfg, env.Configuration.FullConfig.Delay())
+	if cfg.DryRun {
+		return createDryRunTask(commandLine, env, configs, fcfg)
+	}
+	return runTask(commandLine, env, configs, fcfg, env.Configuration.FullConfig.Global().TaskCreationType, nil, nil)
+}
+
+// ResumeTask creates the task of a checkpoint again, the blocks built
+// before the checkpoint was saved are skipped
+func ResumeTask(checkpointId int64, env *environment.PBEnvironment) (*Task, error) {
+	holder := GetTaskHolder(env)
+	if holder.Checkpoints == nil {
+		return nil, fmt.Errorf("checkpoints are not saved in this session")
+	}
+	checkpoint, err := holder.Checkpoints.Load(checkpointId)
+	if err != nil {
+		return nil, err
+	}
+	running := false
+	holder.TaskMap.Range(func(_ interface{}, v interface{}) bool {
+		if v.(*Task).CheckpointId == checkpointId {
+			running = true
+			return false
+		}
+		return true
+	})
+	if running {
+		return nil, fmt.Errorf("the task of checkpoint %d is already running", checkpointId)
+	}
+	if checkpoint.Main == nil || checkpoint.Delay == nil {
+		return nil, fmt.Errorf("checkpoint %d has no configuration", checkpointId)
+	}
+	fcfg := configuration.ConcatFullConfig(checkpoint.Main, checkpoint.Delay)
+	configs := append([]*types.MainConfig{fcfg.Main()}, checkpoint.Pipeline...)
+	return runTask(checkpoint.CommandLine, env, configs, fcfg, checkpoint.TaskType, checkpoint, nil), nil
+}
+
+// runTask generates the blocks with the pipeline of configs and sends
+// them, if checkpoint is not nil, the blocks before its BlockIndex are
+// skipped. If generate is not nil, it generates the blocks instead, the
+// task is not saved to checkpoints or journals then.
+func runTask(commandLine string, env *environment.PBEnvironment, configs []*types.MainConfig, fcfg *configuration.FullConfig, taskType byte, checkpoint *Checkpoint, generate func(blc chan *types.Module) error) *Task {
+	conn := env.Connection
+	cfg := configs[0]
+	holder := GetTaskHolder(env)
 	dcfg := fcfg.Delay()
 	und, _ := uuid.NewUUID()
 	command.SendWSCommand("gamemode c", und, conn)
 	blockschannel := make(chan *types.Module, 10240)
 	task := &Task{
-		TaskId:        TaskIdCounter.Add(1),
+		TaskId:        holder.TaskIdCounter.Add(1),
 		CommandLine:   commandLine,
 		OutputChannel: blockschannel,
 		State:         TaskStateCalculating,
-		Type:          configuration.GlobalFullConfig().Global().TaskCreationType,
+		Type:          taskType,
 		Config:        fcfg,
+		holder:        holder,
+		rate:          newRateController(time.Duration(dcfg.Delay)*time.Microsecond, env.MoveHolder.Corrections.Load()),
+		Priority:      cfg.Priority,
+		After:         cfg.After,
 	}
 	fmt.Println(task.Config.Delay())
 	taskid := task.TaskId
-	TaskMap.Store(taskid, task)
-	var asyncblockschannel chan *types.Module
-	if task.Type == types.TaskTypeAsync {
-		asyncblockschannel = blockschannel
-		blockschannel = make(chan *types.Module)
-		task.OutputChannel = blockschannel
-		go func() {
-			var blocks []*types.Module
-			for {
-				curblock, ok := <-asyncblockschannel
-				if !ok {
-					break
-				}
-				blocks = append(blocks, curblock)
-			}
-			task.State = TaskStateRunning
-			t1 := time.Now()
-			total := len(blocks)
-			task.AsyncInfo = AsyncInfo{
-				Built:     0,
-				Total:     total,
-				BeginTime: t1,
+	skip := 0
+	// only the blocks of async tasks are known before they are sent
+	optimizeFill := taskType == types.TaskTypeAsync && dcfg.FillOptimization
+	chunkOrdered := taskType == types.TaskTypeAsync
+	if checkpoint != nil {
+		// the blocks have to be the same as before to skip the built ones
+		optimizeFill = checkpoint.FillOptimization
+		chunkOrdered = checkpoint.ChunkOrdered
+	}
+	// the blocks of the builders are saved, the ones given by generate
+	// are restored from them
+	saved := generate == nil
+	if generate == nil {
+		generate = func(blc chan *types.Module) error {
+			return builder.PipeGenerate(env, configs, blc)
+		}
+	}
+	// only the blocks of async tasks are known before they are built
+	journaled := saved && holder.Journals != nil && taskType == types.TaskTypeAsync
+	if saved && holder.Journals != nil && !journaled {
+		holder.syncTasks.Store(taskid, true)
+	}
+	if saved && holder.Checkpoints != nil {
+		if checkpoint == nil {
+			checkpoint = &Checkpoint{
+				Id:               newCheckpointId(holder.Checkpoints),
+				CommandLine:      commandLine,
+				Builder:          cfg.Execute,
+				Main:             cfg,
+				Pipeline:         configs[1:],
+				TaskType:         taskType,
+				FillOptimization: optimizeFill,
+				ChunkOrdered:     chunkOrdered,
 			}
-			for _, blk := range blocks {
-				blockschannel <- blk
-				task.AsyncInfo.Built++
+		} else {
+			if !builder.ReadsWorld(cfg) {
+				skip = checkpoint.BlockIndex
 			}
-			close(blockschannel)
-		}()
-	} else {
-		task.State = TaskStateRunning
+			command.Tellraw(conn, fmt.Sprintf(I18n.T(I18n.Task_ResumedFromCheckpoint), taskid, checkpoint.Id, skip))
+		}
+		// the delay may be changed while the task is running
+		checkpoint.Delay = fcfg.Delay()
+		checkpoint.SavedAt = time.Now()
+		if err := holder.Checkpoints.Save(checkpoint); err != nil {
+			command.Tellraw(conn, fmt.Sprintf(I18n.T(I18n.Task_CheckpointSaveFailed), taskid, err))
+			checkpoint = nil
+		} else {
+			task.CheckpointId = checkpoint.Id
+		}
 	}
-	go func() {
-		t1 := time.Now()
-		blkscounter := 0
-		tothresholdcounter := 0
-		isFastMode := false
-		if dcfg.DelayMode == types.DelayModeDiscrete || dcfg.DelayMode == types.DelayModeNone {
-			isFastMode = true
+	// saveCheckpoint writes the progress of the task, the blocks before
+	// index are built
+	saveCheckpoint := func(index int) {
+		if checkpoint == nil {
+			return
+		}
+		checkpoint.BlockIndex = index
+		checkpoint.SavedAt = time.Now()
+		if err := holder.Checkpoints.Save(checkpoint); err != nil {
+			command.Tellraw(env.Connection, fmt.Sprintf(I18n.T(I18n.Task_CheckpointSaveFailed), taskid, err))
+			checkpoint = nil
+		}
+	}
+	// removeCheckpoint is called when the task is done or broken by the user
+	removeCheckpoint := func() {
+		if checkpoint != nil {
+			holder.Checkpoints.Remove(checkpoint.Id)
+		}
+	}
+	holder.TaskMap.Store(taskid, task)
+	// the task is started by the queue, it may wait for other tasks first
+	task.start = func() {
+		// the session may be connected again while the task is queued
+		conn := env.Connection
+		var asyncblockschannel chan *types.Module
+		if task.Type == types.TaskTypeAsync {
+			asyncblockschannel = blockschannel
+			blockschannel = make(chan *types.Module)
+			task.OutputChannel = blockschannel
+			go func() {
+				var blocks []*types.Module
+				for {
+					curblock, ok := <-asyncblockschannel
+					if !ok {
+						break
+					}
+					blocks = append(blocks, curblock)
+				}
+				if optimizeFill {
+					var stats builder.FillStats
+					blocks, stats = builder.OptimizeFill(blocks, cfg.Block)
+					if stats.Fills > 0 {
+						command.Tellraw(env.Connection, fmt.Sprintf(I18n.T(I18n.Task_FillOptimized), taskid, stats.Blocks, stats.Fills, stats.Saved()))
+					}
+				}
+				if chunkOrdered {
+					blocks = builder.ScheduleByChunk(blocks, cfg.Block)
+				}
+				// the blocks before skip were built by the task resumed from,
+				// the world there is not the one before it any more
+				if journaled && len(blocks) > skip {
+					if err := writeJournal(env, taskid, commandLine, cfg, blocks[skip:]); err != nil {
+						command.Tellraw(env.Connection, fmt.Sprintf(I18n.T(I18n.Task_JournalFailed), taskid, err))
+					}
+				}
+				task.State = TaskStateRunning
+				t1 := time.Now()
+				total := len(blocks)
+				task.AsyncInfo = AsyncInfo{
+					Built:     0,
+					Total:     total,
+					BeginTime: t1,
+				}
+				for _, blk := range blocks {
+					blockschannel <- blk
+					task.AsyncInfo.Built++
+				}
+				close(blockschannel)
+			}()
 		} else {
-			//isFastMode=false
-			command.SendWSCommand("gamemode c", und, conn)
-			command.SendWSCommand("gamerule sendcommandfeedback true", und, conn)
+			task.State = TaskStateRunning
 		}
-		//request := command.AllocateRequestString()
-		for {
-			task.ContinueLock.Lock()
-			task.ContinueLock.Unlock()
-			curblock, ok := <-blockschannel
-			if !ok {
-				if blkscounter == 0 {
-					command.Tellraw(conn, fmt.Sprintf(I18n.T(I18n.Task_D_NothingGenerated), taskid))
+		go func() {
+			// the connection is replaced when the session reconnects
+			conn := conn
+			t1 := time.Now()
+			blkscounter := 0
+			// blocks changed, a fill command changes more than one
+			placed := 0
+			tothresholdcounter := 0
+			isFastMode := false
+			if dcfg.DelayMode == types.DelayModeDiscrete || dcfg.DelayMode == types.DelayModeNone {
+				isFastMode = true
+			} else {
+				//isFastMode=false
+				command.SendWSCommand("gamemode c", und, conn)
+				command.SendWSCommand("gamerule sendcommandfeedback true", und, conn)
+			}
+			// blocks sent after the last sync point the server responded to,
+			// they are sent again if the connection is lost
+			var unacknowledged []*types.Module
+			var syncPoints []*syncPoint
+			var replay []*types.Module
+			acknowledged := skip
+			// the chunk the bot is teleported to, blocks ordered by chunk are
+			// built without teleporting again until the chunk changes
+			var chunkX, chunkZ int
+			teleported := false
+			var lastSync time.Time
+			// the blocks to verify when the task is done, the ones built again
+			// after verifying are not added
+			var built []*types.Module
+			verifying := false
+			repairs := 0
+			verify := func() ([]*types.Module, builder.Mismatches, error) {
+				// chunks sent for the teleports of the task arrive before the
+				// response, they would be read as the world otherwise
+				UUID := uuid.New()
+				w := make(chan *packet.CommandOutput, 1)
+				env.UUIDMap.Store(UUID.String(), w)
+				point := built[0].Point
+				command.SendWSCommand(fmt.Sprintf("testforblock %d %d %d air", point.X, point.Y, point.Z), UUID, conn)
+				select {
+				case <-w:
+				case <-time.After(5 * time.Second):
+					env.UUIDMap.Delete(UUID.String())
+				}
+				return builder.Verify(env, cfg, built)
+			}
+			//request := command.AllocateRequestString()
+			for {
+				task.ContinueLock.Lock()
+				task.ContinueLock.Unlock()
+				var curblock *types.Module
+				ok := true
+				if len(replay) > 0 {
+					curblock, replay = replay[0], replay[1:]
+				} else {
+					curblock, ok = <-blockschannel
+				}
+				if !ok && cfg.Verify > 0 && len(built) > 0 && !task.broken && !holder.stopped() {
+					verifying = true
+					wrong, mismatches, err := verify()
+					if err != nil {
+						command.Tellraw(conn, fmt.Sprintf("[%s %d] %s: %v", I18n.T(I18n.TaskTTeIuKoto), taskid, I18n.T(I18n.ERRORStr), err))
+					} else if len(wrong) == 0 {
+						command.Tellraw(conn, fmt.Sprintf(I18n.T(I18n.Task_VerifyPassed), taskid))
+					} else if repairs < cfg.Verify {
+						repairs++
+						command.Tellraw(conn, fmt.Sprintf(I18n.T(I18n.Task_VerifyRepairing), taskid, len(wrong), mismatches, repairs, cfg.Verify))
+						replay = wrong
+						// the bot was moved to read the world
+						teleported = false
+						continue
+					} else {
+						command.Tellraw(conn, fmt.Sprintf(I18n.T(I18n.Task_VerifyFailed), taskid, len(wrong), repairs, mismatches))
+					}
+				}
+				if !ok {
+					if holder.stopped() {
+						// the session is closed, keep it to be resumed later
+						saveCheckpoint(acknowledged)
+					} else {
+						removeCheckpoint()
+					}
+					if placed == 0 {
+						command.Tellraw(conn, fmt.Sprintf(I18n.T(I18n.Task_D_NothingGenerated), taskid))
+						runtime.GC()
+						task.Finalize()
+						return
+					}
+					timeUsed := time.Now().Sub(t1)
+					command.Tellraw(conn, fmt.Sprintf(I18n.T(I18n.Task_Summary_1), taskid, placed))
+					command.Tellraw(conn, fmt.Sprintf(I18n.T(I18n.Task_Summary_2), taskid, timeUsed.Seconds()))
+					command.Tellraw(conn, fmt.Sprintf(I18n.T(I18n.Task_Summary_3), taskid, float64(placed)/timeUsed.Seconds()))
 					runtime.GC()
 					task.Finalize()
 					return
 				}
-				timeUsed := time.Now().Sub(t1)
-				command.Tellraw(conn, fmt.Sprintf(I18n.T(I18n.Task_Summary_1), taskid, blkscounter))
-				command.Tellraw(conn, fmt.Sprintf(I18n.T(I18n.Task_Summary_2), taskid, timeUsed.Seconds()))
-				command.Tellraw(conn, fmt.Sprintf(I18n.T(I18n.Task_Summary_3), taskid, float64(blkscounter)/timeUsed.Seconds()))
-				runtime.GC()
-				task.Finalize()
-				return
-			}
-			if blkscounter%20 == 0 {
-				u_d, _ := uuid.NewUUID()
-				command.SendWSCommand(fmt.Sprintf("tp %d %d %d", curblock.Point.X, curblock.Point.Y, curblock.Point.Z), u_d, conn)
-				// SettingsCommand is unable to teleport the player.
-			}
-			blkscounter++
-			if !cfg.ExcludeCommands && curblock.CommandBlockData != nil {
-				if curblock.Block != nil {
-					request := command.SetBlockRequest(curblock, cfg)
+				// the responses of the sync points are late and the movement of
+				// the bot is corrected when the server is lagging
+				adaptive := dcfg.DelayMode == types.DelayModeAdaptive
+				if adaptive {
+					task.rate.corrected(env.MoveHolder.Corrections.Load())
+				}
+				for len(syncPoints) > 0 && (syncPoints[0].done() || len(syncPoints) > maxPendingSyncPoints) {
+					if adaptive && syncPoints[0].done() {
+						task.rate.roundTrip(syncPoints[0].answered.Sub(syncPoints[0].sent))
+					} else if adaptive {
+						// too many of them are waiting for the responses
+						task.rate.lost()
+					}
+					env.UUIDMap.Delete(syncPoints[0].id)
+					unacknowledged = unacknowledged[syncPoints[0].index-acknowledged:]
+					acknowledged = syncPoints[0].index
+					syncPoints = syncPoints[1:]
+				}
+				if checkpoint != nil && acknowledged > checkpoint.BlockIndex && time.Since(checkpoint.SavedAt) >= CheckpointInterval {
+					saveCheckpoint(acknowledged)
+				}
+				if cfg.Verify > 0 && !verifying {
+					built = append(built, curblock)
+				}
+				if blkscounter < skip {
+					// built before the task was resumed
+					blkscounter++
+					placed += moduleVolume(curblock)
+					continue
+				}
+				needTeleport := blkscounter%20 == 0 || !teleported
+				needSync := needTeleport
+				if chunkOrdered {
+					needTeleport = !teleported || curblock.Point.X>>4 != chunkX || curblock.Point.Z>>4 != chunkZ
+					needSync = needTeleport || time.Since(lastSync) >= syncPointInterval
+				}
+				if needSync {
+					lastSync = time.Now()
+					u_d, _ := uuid.NewUUID()
+					// the response of the command is taken as the sync point
+					cp := &syncPoint{
+						index:  blkscounter,
+						id:     u_d.String(),
+						output: make(chan *packet.CommandOutput, 1),
+						sent:   time.Now(),
+					}
+					env.UUIDMap.Store(cp.id, cp.output)
+					syncPoints = append(syncPoints, cp)
+					if needTeleport {
+						command.SendWSCommand(fmt.Sprintf("tp %d %d %d", curblock.Point.X, curblock.Point.Y, curblock.Point.Z), u_d, conn)
+						// SettingsCommand is unable to teleport the player.
+						chunkX, chunkZ = curblock.Point.X>>4, curblock.Point.Z>>4
+						teleported = true
+					} else {
+						// still in the same chunk, only the response is needed
+						command.SendWSCommand(fmt.Sprintf("testforblock %d %d %d air", curblock.Point.X, curblock.Point.Y, curblock.Point.Z), u_d, conn)
+					}
+				}
+				blkscounter++
+				placed += moduleVolume(curblock)
+				unacknowledged = append(unacknowledged, curblock)
+				var err error
+				if !cfg.ExcludeCommands && curblock.CommandBlockData != nil {
+					if curblock.Block != nil {
+						request := command.SetBlockRequest(curblock, cfg)
+						if !isFastMode {
+							//<-time.After(time.Second)
+							wc := make(chan bool)
+							env.BlockUpdateSubscribeMap.Store(protocol.BlockPos{int32(curblock.Point.X), int32(curblock.Point.Y), int32(curblock.Point.Z)}, wc)
+							sent := time.Now()
+							err = command.SendSizukanaCommand(request, conn)
+							select {
+							case <-wc:
+								if adaptive {
+									task.rate.roundTrip(time.Since(sent))
+								}
+							case <-time.After(time.Second * 2):
+								env.BlockUpdateSubscribeMap.Delete(protocol.BlockPos{int32(curblock.Point.X), int32(curblock.Point.Y), int32(curblock.Point.Z)})
+								if adaptive {
+									task.rate.lost()
+								}
+							}
+							close(wc)
+						} else {
+							err = command.SendSizukanaCommand(request, conn)
+						}
+					}
+					// a copy, the module may be sent again after reconnecting
+					// or verifying and shouldn't be invalidated twice
+					cbdata := *curblock.CommandBlockData
+					if cfg.InvalidateCommands {
+						cbdata.Command = "|" + cbdata.Command
+					}
 					if !isFastMode {
-						//<-time.After(time.Second)
-						wc := make(chan bool)
-						command.BlockUpdateSubscribeMap.Store(protocol.BlockPos{int32(curblock.Point.X), int32(curblock.Point.Y), int32(curblock.Point.Z)}, wc)
-						command.SendSizukanaCommand(request, conn)
+						UUID := uuid.New()
+						w := make(chan *packet.CommandOutput)
+						env.UUIDMap.Store(UUID.String(), w)
+						command.SendWSCommand(fmt.Sprintf("tp %d %d %d", curblock.Point.X, curblock.Point.Y+1, curblock.Point.Z), UUID, conn)
 						select {
-						case <-wc:
+						case <-time.After(time.Second):
+							env.UUIDMap.Delete(UUID.String())
 							break
-						case <-time.After(time.Second * 2):
-							command.BlockUpdateSubscribeMap.Delete(protocol.BlockPos{int32(curblock.Point.X), int32(curblock.Point.Y), int32(curblock.Point.Z)})
+						case <-w:
 						}
-						close(wc)
-					} else {
-						command.SendSizukanaCommand(request, conn)
+						close(w)
 					}
+					if err == nil {
+						err = conn.WritePacket(&packet.CommandBlockUpdate{
+							Block:              true,
+							Position:           protocol.BlockPos{int32(curblock.Point.X), int32(curblock.Point.Y), int32(curblock.Point.Z)},
+							Mode:               cbdata.Mode,
+							NeedsRedstone:      cbdata.NeedRedstone,
+							Conditional:        cbdata.Conditional,
+							Command:            cbdata.Command,
+							LastOutput:         cbdata.LastOutput,
+							Name:               cbdata.CustomName,
+							TickDelay:          cbdata.TickDelay,
+							ExecuteOnFirstTick: cbdata.ExecuteOnFirstTick,
+						})
+					}
+				} else if curblock.ChestSlot != nil {
+					request := command.ReplaceItemRequest(curblock, cfg)
+					err = command.SendSizukanaCommand(request, conn)
+				} else if curblock.FillTo != nil {
+					request := command.FillRequest(curblock, cfg)
+					err = command.SendSizukanaCommand(request, conn)
+				} else {
+					request := command.SetBlockRequest(curblock, cfg)
+					err = command.SendSizukanaCommand(request, conn)
+				} /*else if curblock.Entity != nil {
+					//request := command.SummonRequest(curblock, cfg)
+					//err := command.SendSizukanaCommand(request, conn)
+					//if err != nil {
+					//	panic(err)
+					//}
+				}*/
+				if err != nil {
+					// the connection is lost, wait for the session to reconnect and
+					// send the blocks after the last sync point again
+					task.State = TaskStateReconnecting
+					conn, ok = holder.waitConnection(env, conn)
+					if !ok {
+						saveCheckpoint(acknowledged)
+						// avoid gui crash when session is stopped but the task is still running
+						return
+					}
+					if task.State == TaskStateReconnecting {
+						task.State = TaskStateRunning
+					}
+					for _, cp := range syncPoints {
+						env.UUIDMap.Delete(cp.id)
+					}
+					syncPoints = nil
+					teleported = false
+					for _, module := range unacknowledged {
+						placed -= moduleVolume(module)
+					}
+					replay = append(unacknowledged, replay...)
+					unacknowledged = nil
+					blkscounter = acknowledged
+					command.Tellraw(conn, fmt.Sprintf(I18n.T(I18n.Task_ConnectionRestored), taskid, acknowledged))
+					continue
 				}
-				cbdata := curblock.CommandBlockData
-				if cfg.InvalidateCommands {
-					cbdata.Command = "|" + cbdata.Command
-				}
-				if !isFastMode {
-					UUID := uuid.New()
-					w := make(chan *packet.CommandOutput)
-					command.UUIDMap.Store(UUID.String(), w)
-					command.SendWSCommand(fmt.Sprintf("tp %d %d %d", curblock.Point.X, curblock.Point.Y+1, curblock.Point.Z), UUID, conn)
-					select {
-					case <-time.After(time.Second):
-						command.UUIDMap.Delete(UUID.String())
-						break
-					case <-w:
+				if dcfg.DelayMode == types.DelayModeContinuous {
+					time.Sleep(time.Duration(dcfg.Delay) * time.Microsecond)
+				} else if dcfg.DelayMode == types.DelayModeDiscrete {
+					tothresholdcounter++
+					if tothresholdcounter >= dcfg.DelayThreshold {
+						tothresholdcounter = 0
+						time.Sleep(time.Duration(dcfg.Delay) * time.Second)
 					}
-					close(w)
+				} else if dcfg.DelayMode == types.DelayModeAdaptive {
+					time.Sleep(task.rate.Delay())
 				}
-				conn.WritePacket(&packet.CommandBlockUpdate{
-					Block:              true,
-					Position:           protocol.BlockPos{int32(curblock.Point.X), int32(curblock.Point.Y), int32(curblock.Point.Z)},
-					Mode:               cbdata.Mode,
-					NeedsRedstone:      cbdata.NeedRedstone,
-					Conditional:        cbdata.Conditional,
-					Command:            cbdata.Command,
-					LastOutput:         cbdata.LastOutput,
-					Name:               cbdata.CustomName,
-					TickDelay:          cbdata.TickDelay,
-					ExecuteOnFirstTick: cbdata.ExecuteOnFirstTick,
-				})
-			} else if curblock.ChestSlot != nil {
-				request := command.ReplaceItemRequest(curblock, cfg)
-				command.SendSizukanaCommand(request, conn)
-			} else {
-				request := command.SetBlockRequest(curblock, cfg)
-				err := command.SendSizukanaCommand(request, conn)
+			}
+			//command.FreeRequestStringPtr(request)
+		}()
+		go func() {
+			if task.Type == types.TaskTypeAsync {
+				err := generate(asyncblockschannel)
+				close(asyncblockschannel)
 				if err != nil {
-					// panic(err)
-					// avoid gui crash when session is stopped but the task is still running"
-					return
-				}
-			} /*else if curblock.Entity != nil {
-				//request := command.SummonRequest(curblock, cfg)
-				//err := command.SendSizukanaCommand(request, conn)
-				//if err != nil {
-				//	panic(err)
-				//}
-			}*/
-			if dcfg.DelayMode == types.DelayModeContinuous {
-				time.Sleep(time.Duration(dcfg.Delay) * time.Microsecond)
-			} else if dcfg.DelayMode == types.DelayModeDiscrete {
-				tothresholdcounter++
-				if tothresholdcounter >= dcfg.DelayThreshold {
-					tothresholdcounter = 0
-					time.Sleep(time.Duration(dcfg.Delay) * time.Second)
+					command.Tellraw(env.Connection, fmt.Sprintf("[%s %d] %s: %v", I18n.T(I18n.TaskTTeIuKoto), taskid, I18n.T(I18n.ERRORStr), err))
 				}
+				return
 			}
-		}
-		//command.FreeRequestStringPtr(request)
-	}()
-	go func() {
-		if task.Type == types.TaskTypeAsync {
-			err := builder.Generate(cfg, asyncblockschannel)
-			close(asyncblockschannel)
+			err := generate(blockschannel)
+			close(blockschannel)
 			if err != nil {
-				command.Tellraw(conn, fmt.Sprintf("[%s %d] %s: %v", I18n.T(I18n.TaskTTeIuKoto), taskid, I18n.T(I18n.ERRORStr), err))
+				command.Tellraw(env.Connection, fmt.Sprintf("[%s %d] %s: %v", I18n.T(I18n.TaskTTeIuKoto), taskid, I18n.T(I18n.ERRORStr), err))
 			}
-			return
-		}
-		err := builder.Generate(cfg, blockschannel)
-		close(blockschannel)
-		if err != nil {
-			command.Tellraw(conn, fmt.Sprintf("[%s %d] %s: %v", I18n.T(I18n.TaskTTeIuKoto), taskid, I18n.T(I18n.ERRORStr), err))
-		}
-	}()
+		}()
+	}
+	holder.enqueue(task)
 	return task
 }
 
-var ActivateTaskStatus chan bool = make(chan bool)
-
-// var TaskIdCounter *atomic.Int64 = atomic.NewInt64(0)
-// var TaskMap sync.Map
-// var BrokSender chan string = make(chan string)
-// var ExtraDisplayStrings []string = []string{}
+// moduleVolume returns how many blocks a module changes
+func moduleVolume(module *types.Module) int {
+	if module.FillTo == nil {
+		return 1
+	}
+	size := func(a, b int) int {
+		if a > b {
+			return a - b + 1
+		}
+		return b - a + 1
+	}
+	return size(module.Point.X, module.FillTo.X) * size(module.Point.Y, module.FillTo.Y) * size(module.Point.Z, module.FillTo.Z)
+}
 
-func InitTaskStatusDisplay(conn *minecraft.Conn) {
-	sessionInitID := configuration.SessionInitID
-	TaskMap = sync.Map{}
+func InitTaskStatusDisplay(env *environment.PBEnvironment) {
+	holder := GetTaskHolder(env)
 	go func() {
 		defer func() {
 			recover()
 		}()
 		for {
-			str := <-BrokSender
-			if sessionInitID != configuration.SessionInitID {
-				// actually, the session is changed, so we should stop the task status display
+			select {
+			case str := <-env.BrokSender:
+				command.Tellraw(env.Connection, str)
+			case <-holder.stopChan:
 				return
 			}
-			command.Tellraw(conn, str)
 		}
 	}()
 	ticker := time.NewTicker(500 * time.Millisecond)
 	go func() {
+		defer ticker.Stop()
 		for {
-			<-ticker.C
-			if sessionInitID != configuration.SessionInitID {
-				// actually, the session is changed, so we should stop the task status display
+			select {
+			case <-ticker.C:
+			case <-holder.stopChan:
+				return
+			}
+			select {
+			case holder.ActivateTaskStatus <- true:
+			case <-holder.stopChan:
 				return
 			}
-			ActivateTaskStatus <- true
 		}
 	}()
 	go func() {
@@ -362,17 +833,16 @@ func InitTaskStatusDisplay(conn *minecraft.Conn) {
 			recover()
 		}()
 		for {
-			<-ActivateTaskStatus
-			if sessionInitID != configuration.SessionInitID {
-				// actually, the session is changed, so we should stop the task status display
+			select {
+			case <-holder.ActivateTaskStatus:
+			case <-holder.stopChan:
 				return
 			}
-			//<- ticker.C
-			if configuration.GlobalFullConfig().Global().TaskDisplayMode == types.TaskDisplayNo {
+			if env.Configuration.FullConfig.Global().TaskDisplayMode == types.TaskDisplayNo {
 				continue
 			}
 			var displayStrs []string
-			TaskMap.Range(func(_tid interface{}, _v interface{}) bool {
+			holder.TaskMap.Range(func(_tid interface{}, _v interface{}) bool {
 				tid, _ := _tid.(int64)
 				v, _ := _v.(*Task)
 				addstr := fmt.Sprintf("Task ID %d - %s - %s [%s]", tid, v.Config.Main().Execute, GetStateDesc(v.State), types.MakeTaskType(v.Type))
@@ -382,11 +852,11 @@ func InitTaskStatusDisplay(conn *minecraft.Conn) {
 				displayStrs = append(displayStrs, addstr)
 				return true
 			})
-			displayStrs = append(displayStrs, ExtraDisplayStrings...)
+			displayStrs = append(displayStrs, holder.ExtraDisplayStrings...)
 			if len(displayStrs) == 0 {
 				continue
 			}
-			command.Title(conn, strings.Join(displayStrs, "\n"))
+			command.Title(env.Connection, strings.Join(displayStrs, "\n"))
 		}
 	}()
 }
@@ -7,6 +7,8 @@ type Module struct {
 	//Entity *Entity
 	ChestSlot *ChestSlot
 	Point  Position
+	// fill from Point to FillTo with Block if it's not nil
+	FillTo *Position
 }
 
 type RuntimeModule struct {
@@ -4,6 +4,7 @@ const (
 	DelayModeContinuous = 0
 	DelayModeDiscrete   = 1
 	DelayModeNone       = 2
+	DelayModeAdaptive   = 3
 	DelayModeInvalid    = 100
 )
 
@@ -28,20 +29,56 @@ type MainConfig struct {
 	MapX, MapZ, MapY      int
 	Method, OldMethod     string
 	Facing, Path, Shape   string
+	Format                string
 	ExcludeCommands       bool
 	InvalidateCommands    bool
 	Strict                bool
+	DryRun                bool
+	DryRunPath            string
+	// the world of dry runs is generated by it, empty for an empty world
+	DryRunGenerator       string
+	Rotation, Scale       int
+	Mirror                string
+	Mask                  string
+	Seed                  int64
+	// the most times the wrong blocks are built again after the task is
+	// verified, 0 not to verify
+	Verify                int
+	// tasks of higher priority are started first, a task is started
+	// after the task After is done if it's not 0
+	Priority              int
+	After                 int64
+	// the control points of curves given with -point, and how the curve
+	// goes through them: bezier, catmullrom or polyline
+	Points                []Position
+	Curve                 string
+	// the layers of terrain: -top on the surface, -soil_depth blocks of
+	// -soil under it and the Block below, with water up to -water_level
+	// blocks above the bottom if it's more than 0
+	Top, Soil             string
+	SoilDepth, WaterLevel int
+	// the layers of noise of landscapes, the width of the largest hills
+	// and the chance of a tree in each cell, in percent
+	Octaves, NoiseSize    int
+	Trees                 int
+	// the arguments after the command, as they are written
+	Args                  []string
 }
 
 type DelayConfig struct {
 	Delay                 int64
 	DelayMode             byte
 	DelayThreshold        int
+	// merge the blocks of async tasks into fill commands
+	FillOptimization      bool
 }
 
 type GlobalConfig struct {
 	TaskCreationType      byte
 	TaskDisplayMode       byte
+	// how many tasks may run at once, the others wait in the queue,
+	// 0 not to limit them
+	MaxConcurrentTasks    int
 }
 
 func ParseDelayMode(mode string) byte {
@@ -51,6 +88,8 @@ func ParseDelayMode(mode string) byte {
 		return DelayModeDiscrete
 	}else if mode=="none" {
 		return DelayModeNone
+	}else if mode=="adaptive" {
+		return DelayModeAdaptive
 	}
 	return DelayModeInvalid
 }
@@ -62,6 +101,8 @@ func StrDelayMode(mode byte) string {
 		return "discrete"
 	}else if mode==DelayModeNone {
 		return "none"
+	}else if mode==DelayModeAdaptive {
+		return "adaptive"
 	}else{
 		return "invalid"
 	}
@@ -1,3 +0,0 @@
-package types
-
-var ForwardedBrokSender chan string
\ No newline at end of file
@@ -0,0 +1,115 @@
+package world_provider
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"phoenixbuilder_3rd_gui/fb/dragonfly/server/world/chunk"
+	"phoenixbuilder_3rd_gui/fb/minecraft/nbt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// The block states of 1.17.0 in the order of their runtime ids, it's the
+// block_states.nbt of dragonfly v0.1.0. The runtime ids of netease have
+// two more blocks, so the states are matched to the runtime ids by name,
+// the permutations of a block are in the same order in both.
+//
+//go:embed block_states.nbt
+var blockStatesData []byte
+
+// BlockState is a block as worlds and structures save it
+type BlockState struct {
+	Name       string                 `nbt:"name"`
+	Properties map[string]interface{} `nbt:"states"`
+	Version    int32                  `nbt:"version"`
+}
+
+var (
+	blockStatesOnce sync.Once
+	// indexed by runtime id, nil for the blocks netease added
+	blockStates []*BlockState
+	// name and states -> runtime id
+	blockStateRuntimeIds map[string]uint32
+)
+
+func stateKey(name string, properties map[string]interface{}) string {
+	keys := make([]string, 0, len(properties))
+	for key := range properties {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	b.WriteString(name)
+	for _, key := range keys {
+		fmt.Fprintf(&b, ";%s=%v", key, properties[key])
+	}
+	return b.String()
+}
+
+func loadBlockStates() {
+	byName := map[string][]*BlockState{}
+	dec := nbt.NewDecoder(bytes.NewBuffer(blockStatesData))
+	for {
+		state := &BlockState{}
+		if err := dec.Decode(state); err != nil {
+			break
+		}
+		byName[state.Name] = append(byName[state.Name], state)
+	}
+	blockStates = make([]*BlockState, len(RuntimeIdArray_117))
+	blockStateRuntimeIds = make(map[string]uint32, len(RuntimeIdArray_117))
+	next := map[string]int{}
+	for rid, block := range RuntimeIdArray_117 {
+		name := "minecraft:" + block.Name
+		states := byName[name]
+		i := next[name]
+		next[name]++
+		if i >= len(states) {
+			continue
+		}
+		blockStates[rid] = states[i]
+		blockStateRuntimeIds[stateKey(name, states[i].Properties)] = uint32(rid)
+	}
+}
+
+// BlockStateOf returns the name with the minecraft: prefix and the block
+// states of a runtime id
+func BlockStateOf(runtimeId uint32) (*BlockState, bool) {
+	blockStatesOnce.Do(loadBlockStates)
+	if int(runtimeId) >= len(blockStates) || blockStates[runtimeId] == nil {
+		return nil, false
+	}
+	return blockStates[runtimeId], true
+}
+
+// RuntimeIdOfState returns the runtime id of a block by its name and block
+// states, the name may be given without the minecraft: prefix
+func RuntimeIdOfState(name string, properties map[string]interface{}) (uint32, bool) {
+	blockStatesOnce.Do(loadBlockStates)
+	if !strings.HasPrefix(name, "minecraft:") {
+		name = "minecraft:" + name
+	}
+	rid, found := blockStateRuntimeIds[stateKey(name, properties)]
+	return rid, found
+}
+
+// useBlockStates makes chunks saved to and loaded from disk use the block
+// states, so that the worlds can be opened by the game. The blocks without
+// states keep their legacy data. The states are loaded when they are used.
+func useBlockStates() {
+	legacyState, legacyRuntimeId := chunk.RuntimeIDToState, chunk.StateToRuntimeID
+	chunk.RuntimeIDToState = func(runtimeID uint32) (string, map[string]interface{}, bool) {
+		if state, found := BlockStateOf(runtimeID); found {
+			return state.Name, state.Properties, true
+		}
+		return legacyState(runtimeID)
+	}
+	chunk.StateToRuntimeID = func(name string, properties map[string]interface{}) (uint32, bool) {
+		if rid, found := RuntimeIdOfState(name, properties); found {
+			return rid, true
+		}
+		return legacyRuntimeId(name, properties)
+	}
+}
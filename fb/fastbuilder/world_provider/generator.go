@@ -0,0 +1,59 @@
+package world_provider
+
+import (
+	"phoenixbuilder_3rd_gui/fb/dragonfly/server/block/cube"
+	"phoenixbuilder_3rd_gui/fb/dragonfly/server/world"
+	"phoenixbuilder_3rd_gui/fb/dragonfly/server/world/chunk"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/noise"
+	"sync"
+)
+
+// NoiseGenerator generates the chunks of a world with the hills, lakes
+// and trees of the terrain, the same ones the landscape builder builds
+// on the server. It's the generator of dry runs with -generator landscape.
+type NoiseGenerator struct {
+	Terrain *noise.Terrain
+	// the y of the bottom of the terrain
+	Base int
+
+	once       sync.Once
+	runtimeIds map[noise.Material]uint32
+}
+
+// the blocks of the materials of the terrain, their data is 0
+var noiseBlocks = map[noise.Material]string{
+	noise.MaterialStone:  "stone",
+	noise.MaterialSoil:   "dirt",
+	noise.MaterialTop:    "grass",
+	noise.MaterialSand:   "sand",
+	noise.MaterialWater:  "water",
+	noise.MaterialLog:    "log",
+	noise.MaterialLeaves: "leaves",
+}
+
+func (g *NoiseGenerator) GenerateChunk(pos world.ChunkPos, c *chunk.Chunk) {
+	g.once.Do(func() {
+		g.runtimeIds = make(map[noise.Material]uint32, len(noiseBlocks))
+		for rid, block := range RuntimeIdArray_117 {
+			if block.Data != 0 {
+				continue
+			}
+			for material, name := range noiseBlocks {
+				if block.Name == name {
+					g.runtimeIds[material] = uint32(rid)
+				}
+			}
+		}
+	})
+	for x := 0; x < 16; x++ {
+		for z := 0; z < 16; z++ {
+			for _, block := range g.Terrain.Column(int(pos[0])*16+x, int(pos[1])*16+z) {
+				y := g.Base + block.Y
+				if y < cube.MinY || y > cube.MaxY {
+					continue
+				}
+				c.SetRuntimeID(uint8(x), int16(y), uint8(z), 0, g.runtimeIds[block.Material])
+			}
+		}
+	}
+}
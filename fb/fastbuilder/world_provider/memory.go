@@ -0,0 +1,118 @@
+package world_provider
+
+import (
+	"phoenixbuilder_3rd_gui/fb/dragonfly/server/world"
+	"phoenixbuilder_3rd_gui/fb/dragonfly/server/world/chunk"
+	"sync"
+)
+
+// MemoryProvider keeps the chunks of a world in memory, it's used
+// when building into a local world instead of the server.
+type MemoryProvider struct {
+	mu       sync.Mutex
+	settings world.Settings
+	chunks   map[world.ChunkPos]*chunk.Chunk
+	blockNBT map[world.ChunkPos][]map[string]interface{}
+}
+
+func NewMemoryProvider() *MemoryProvider {
+	return &MemoryProvider{
+		settings: world.Settings{
+			Name: "World",
+		},
+		chunks:   map[world.ChunkPos]*chunk.Chunk{},
+		blockNBT: map[world.ChunkPos][]map[string]interface{}{},
+	}
+}
+
+func (p *MemoryProvider) Settings() world.Settings {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.settings
+}
+
+func (p *MemoryProvider) SaveSettings(s world.Settings) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.settings = s
+}
+
+func (p *MemoryProvider) LoadChunk(position world.ChunkPos) (*chunk.Chunk, bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	c, ok := p.chunks[position]
+	return c, ok, nil
+}
+
+func (p *MemoryProvider) SaveChunk(position world.ChunkPos, c *chunk.Chunk) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.chunks[position] = c
+	return nil
+}
+
+func (p *MemoryProvider) LoadEntities(world.ChunkPos) ([]world.SaveableEntity, error) {
+	return nil, nil
+}
+
+func (p *MemoryProvider) SaveEntities(world.ChunkPos, []world.SaveableEntity) error {
+	return nil
+}
+
+func (p *MemoryProvider) LoadBlockNBT(position world.ChunkPos) ([]map[string]interface{}, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.blockNBT[position], nil
+}
+
+func (p *MemoryProvider) SaveBlockNBT(position world.ChunkPos, data []map[string]interface{}) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(data) == 0 {
+		// the world doesn't know about block entities added by AddBlockNBT
+		return nil
+	}
+	p.blockNBT[position] = data
+	return nil
+}
+
+// AddBlockNBT adds a block entity, the position is taken from
+// the x, y and z fields of the NBT.
+func (p *MemoryProvider) AddBlockNBT(data map[string]interface{}) {
+	x, _ := data["x"].(int32)
+	z, _ := data["z"].(int32)
+	position := world.ChunkPos{x >> 4, z >> 4}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.blockNBT[position] = append(p.blockNBT[position], data)
+}
+
+// ChunkCount returns the count of chunks saved in the provider
+func (p *MemoryProvider) ChunkCount() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.chunks)
+}
+
+// SaveTo copies the world into another provider, the world using
+// the MemoryProvider should be closed before.
+func (p *MemoryProvider) SaveTo(provider world.Provider) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	provider.SaveSettings(p.settings)
+	for position, c := range p.chunks {
+		if err := provider.SaveChunk(position, c); err != nil {
+			return err
+		}
+	}
+	for position, data := range p.blockNBT {
+		if err := provider.SaveBlockNBT(position, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *MemoryProvider) Close() error {
+	return nil
+}
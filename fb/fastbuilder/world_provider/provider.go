@@ -14,18 +14,14 @@ import (
 	"github.com/google/uuid"
 )
 
-var ChunkInput chan *packet.LevelChunk = nil
-var ChunkCache map[world.ChunkPos]*packet.LevelChunk = nil
-var firstLoaded bool = false
-
 type OnlineWorldProvider struct {
-	connection *minecraft.Conn
+	holder *WorldHolder
 	//nbtmap map[world.ChunkPos][]map[string]interface{}
 }
 
-func NewOnlineWorldProvider(conn *minecraft.Conn) *OnlineWorldProvider {
+func NewOnlineWorldProvider(holder *WorldHolder) *OnlineWorldProvider {
 	return &OnlineWorldProvider{
-		connection: conn,
+		holder: holder,
 		//nbtmap: make(map[world.ChunkPos][]map[string]interface{}),
 	}
 }
@@ -40,24 +36,24 @@ func (p *OnlineWorldProvider) SaveSettings(_ world.Settings) {
 
 }
 
-func DoCache(pkt *packet.LevelChunk) {
-	if ChunkCache != nil {
-		quickCache(pkt)
+func (holder *WorldHolder) DoCache(pkt *packet.LevelChunk) {
+	if holder.ChunkCache != nil {
+		holder.quickCache(pkt)
 	}
 }
 
-func quickCache(pkt *packet.LevelChunk) {
-	ChunkCache[world.ChunkPos{pkt.ChunkX, pkt.ChunkZ}] = pkt
+func (holder *WorldHolder) quickCache(pkt *packet.LevelChunk) {
+	holder.ChunkCache[world.ChunkPos{pkt.ChunkX, pkt.ChunkZ}] = pkt
 }
 
-func wander(conn *minecraft.Conn, position world.ChunkPos) {
+func (holder *WorldHolder) wander(conn *minecraft.Conn, position world.ChunkPos) {
 	u_d, _ := uuid.NewUUID()
 	err := command.SendWSCommand(fmt.Sprintf("tp %d 127 %d", position[0]*16+100000, 1000000-position[1]*16+100000), u_d, conn)
 	if err != nil {
 		panic(fmt.Errorf("Connection closed: %+v", err))
 	}
 	select {
-	case <-ChunkInput:
+	case <-holder.ChunkInput:
 		//quickCache(inp)
 	case <-time.After(2 * time.Second):
 
@@ -70,12 +66,13 @@ func wander(conn *minecraft.Conn, position world.ChunkPos) {
 }
 
 func (p *OnlineWorldProvider) LoadChunk(position world.ChunkPos) (c *chunk.Chunk, exists bool, err error) {
-	if ChunkCache == nil {
+	holder := p.holder
+	if holder.ChunkCache == nil {
 		panic("LoadChunk() before creating a world")
 	}
-	cacheitem, hascacheitem := ChunkCache[position]
+	cacheitem, hascacheitem := holder.ChunkCache[position]
 	if hascacheitem {
-		delete(ChunkCache, position)
+		delete(holder.ChunkCache, position)
 		chunk, err := chunk.NetworkDecode(AirRuntimeId, cacheitem.RawPayload, int(cacheitem.SubChunkCount))
 		if err != nil {
 			bridge_fmt.Printf("Failed to decode chunk: %v\n", err)
@@ -83,21 +80,21 @@ func (p *OnlineWorldProvider) LoadChunk(position world.ChunkPos) (c *chunk.Chunk
 		}
 		return chunk, true, nil
 	}
-	if ChunkInput != nil {
+	if holder.ChunkInput != nil {
 		panic("Multithreading on OnlineWorldProvider's LoadChunk function isn't allowed")
 	}
 	u_d, _ := uuid.NewUUID()
-	ChunkInput = make(chan *packet.LevelChunk, 32)
-	err = command.SendWSCommand(fmt.Sprintf("tp %d 127 %d", position[0]*16, position[1]*16), u_d, p.connection)
+	holder.ChunkInput = make(chan *packet.LevelChunk, 32)
+	err = command.SendWSCommand(fmt.Sprintf("tp %d 127 %d", position[0]*16, position[1]*16), u_d, holder.connection)
 	if err != nil {
 		panic(fmt.Errorf("[2]Connection closed: %+v", err))
 	}
 	for {
-		inp, hasqi := ChunkCache[position]
+		inp, hasqi := holder.ChunkCache[position]
 		if !hasqi {
 			select {
-			case inp = <-ChunkInput:
-				quickCache(inp)
+			case inp = <-holder.ChunkInput:
+				holder.quickCache(inp)
 				// bridge_fmt.Printf("Waiting for chunk: current: %d, %d | expected: %v\n", inp.ChunkX, inp.ChunkZ, position)
 				if inp.ChunkX != position[0] || inp.ChunkZ != position[1] {
 					continue
@@ -105,15 +102,15 @@ func (p *OnlineWorldProvider) LoadChunk(position world.ChunkPos) (c *chunk.Chunk
 			case <-time.After(5 * time.Second):
 				runtime.GC()
 				bridge_fmt.Printf("Expected chunk %v didn't arrive, wandering around\n", position)
-				wander(p.connection, position)
+				holder.wander(holder.connection, position)
 				continue
 			}
 		} else {
-			delete(ChunkCache, position)
+			delete(holder.ChunkCache, position)
 		}
 		// Hit
-		close(ChunkInput)
-		ChunkInput = nil
+		close(holder.ChunkInput)
+		holder.ChunkInput = nil
 		chunk, err := chunk.NetworkDecode(AirRuntimeId, inp.RawPayload, int(inp.SubChunkCount))
 		if err != nil {
 			bridge_fmt.Printf("Failed to decode chunk: %v\n", err)
@@ -4,28 +4,91 @@ import (
 	"phoenixbuilder_3rd_gui/fb/dragonfly/server/world"
 	"phoenixbuilder_3rd_gui/fb/minecraft"
 	"phoenixbuilder_3rd_gui/fb/minecraft/protocol/packet"
+	"sync"
 )
 
-var CurrentWorld *world.World = nil
+// WorldHolder keeps the world interaction state of one session,
+// chunks received from the connection are either cached or passed
+// to the world that is currently loading them.
+type WorldHolder struct {
+	CurrentWorld *world.World
+	ChunkInput   chan *packet.LevelChunk
+	ChunkCache   map[world.ChunkPos]*packet.LevelChunk
+	firstLoaded  bool
+	connection   *minecraft.Conn
+	// visited instead of the world of the connection if it's not nil
+	local *world.World
+	// held by Visit, there is only one world for each session
+	visitMu sync.Mutex
+}
+
+func NewWorldHolder(conn *minecraft.Conn) *WorldHolder {
+	return &WorldHolder{
+		connection: conn,
+	}
+}
 
-func Create(conn *minecraft.Conn) *world.World {
+// NewLocalWorldHolder returns a holder that visits w instead of
+// requesting chunks from a connection, e.g. the world of a dry run
+func NewLocalWorldHolder(w *world.World) *WorldHolder {
+	return &WorldHolder{local: w}
+}
+
+// SetConnection replaces the connection chunks are requested from,
+// e.g. after the session is connected again
+func (holder *WorldHolder) SetConnection(conn *minecraft.Conn) {
+	holder.connection = conn
+}
+
+func (holder *WorldHolder) Create() *world.World {
 	intw := world.New(&StubLogger{}, 32)
-	intw.Provider(NewOnlineWorldProvider(conn))
+	intw.Provider(NewOnlineWorldProvider(holder))
 	return intw
 }
 
-func NewWorld(conn *minecraft.Conn) {
-	ChunkCache = make(map[world.ChunkPos]*packet.LevelChunk)
-	CurrentWorld = Create(conn)
-	firstLoaded = false
+func (holder *WorldHolder) NewWorld() {
+	holder.ChunkCache = make(map[world.ChunkPos]*packet.LevelChunk)
+	holder.CurrentWorld = holder.Create()
+	holder.firstLoaded = false
+}
+
+// DestroyWorld closes the current world, which stops its goroutines
+func (holder *WorldHolder) DestroyWorld() {
+	holder.firstLoaded = false
+	if holder.CurrentWorld != nil {
+		holder.CurrentWorld.Close()
+		holder.CurrentWorld.CloseChunkCacheJanitor()
+	}
+	holder.CurrentWorld = nil
+	holder.ChunkCache = nil
+}
+
+// Visit creates a world for visit to read blocks from and destroys it
+// after visit returns, the local world is visited as it is if there is one. The world is read by one caller at a time, Visit
+// waits until the callers before it are done.
+func (holder *WorldHolder) Visit(visit func(w *world.World)) {
+	holder.visitMu.Lock()
+	defer holder.visitMu.Unlock()
+	if holder.local != nil {
+		visit(holder.local)
+		return
+	}
+	holder.NewWorld()
+	defer holder.DestroyWorld()
+	visit(holder.CurrentWorld)
 }
 
-func DestroyWorld() {
-	firstLoaded = false
-	CurrentWorld = nil
-	ChunkCache = nil
+// HandleLevelChunk should be called for every LevelChunk packet
+// received by the session.
+func (holder *WorldHolder) HandleLevelChunk(pkt *packet.LevelChunk) {
+	if holder.ChunkInput != nil {
+		holder.ChunkInput <- pkt
+	} else {
+		holder.DoCache(pkt)
+	}
 }
 
 func init() {
 	InitRuntimeIdsWithoutMinecraftPrefix()
+	useBlockStates()
 }
@@ -8,6 +8,7 @@ import (
 	"crypto/rand"
 	"crypto/x509"
 	"encoding/base64"
+	"fmt"
 	"path/filepath"
 	fbauth "phoenixbuilder_3rd_gui/fb/fastbuilder/cv4/auth"
 	raknet "phoenixbuilder_3rd_gui/fb/go_raknet_1_9_1"
@@ -50,8 +51,8 @@ type Dialer struct {
 	Version string
 	// Phoenix Token
 	Token string
-	// Phoenix Auth Client
-	Client     *fbauth.Client
+	// Phoenix Auth Client, the server is dialed directly when it's nil
+	Client     fbauth.Authenticator
 	ServerCode string
 	Password   string
 
@@ -119,12 +120,10 @@ func (d Dialer) DialContext(ctx context.Context, network, address string) (conn
 	key, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
 
 	var chainData string
-	if d.ServerCode != "" {
+	if d.Client != nil {
 		data, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
 		pubKeyData := base64.StdEncoding.EncodeToString(data)
 		chainAddr, code, err := d.Client.Auth(d.ServerCode, d.Password, pubKeyData, d.Token, d.Version)
-		chainAndAddr := strings.Split(chainAddr, "|")
-		bridge_fmt.Printf("Auth pass\n")
 		if err != nil {
 			if code == -3 {
 				homedir, err := os.UserHomeDir()
@@ -139,6 +138,11 @@ func (d Dialer) DialContext(ctx context.Context, network, address string) (conn
 			}
 			return nil, err
 		}
+		chainAndAddr := strings.Split(chainAddr, "|")
+		if len(chainAndAddr) < 2 {
+			return nil, fmt.Errorf("invalid response of auth server: %s", chainAddr)
+		}
+		bridge_fmt.Printf("Auth pass\n")
 		chainData = chainAndAddr[0]
 		address = chainAndAddr[1]
 	}
@@ -179,23 +183,23 @@ func (d Dialer) DialContext(ctx context.Context, network, address string) (conn
 	defaultIdentityData(&conn.identityData)
 
 	var request []byte
-	/*if d.TokenSource == nil {
-		// We haven't logged into the user's XBL account. We create a login request with only one token
-		// holding the identity data set in the Dialer after making sure we clear data from the identity data
-		// that is only present when logged in.
+	if chainData == "" {
+		// No auth client was set in the Dialer, so we didn't get a chain from the auth server. We create a
+		// login request with only one token holding the identity data set in the Dialer after making sure we
+		// clear data from the identity data that is only present when logged in. This is used for local servers.
 		clearXBLIdentityData(&conn.identityData)
 		request = login.EncodeOffline(conn.identityData, conn.clientData, key)
-	} else {*/
-	// We login as an Android device and this will show up in the 'titleId' field in the JWT chain, which
-	// we can't edit. We just enforce Android data for logging in.
-	setAndroidData(&conn.clientData)
+	} else {
+		// We login as an Android device and this will show up in the 'titleId' field in the JWT chain, which
+		// we can't edit. We just enforce Android data for logging in.
+		setAndroidData(&conn.clientData)
 
-	request = login.Encode(chainData, conn.clientData, key)
-	identityData, _, _, _ := login.Parse(request)
-	// If we got the identity data from Minecraft auth, we need to make sure we set it in the Conn too, as
-	// we are not aware of the identity data ourselves yet.
-	conn.identityData = identityData
-	//}
+		request = login.Encode(chainData, conn.clientData, key)
+		identityData, _, _, _ := login.Parse(request)
+		// If we got the identity data from Minecraft auth, we need to make sure we set it in the Conn too, as
+		// we are not aware of the identity data ourselves yet.
+		conn.identityData = identityData
+	}
 	c := make(chan struct{})
 	go listenConn(conn, d.ErrorLog, c)
 
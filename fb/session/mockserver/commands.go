@@ -0,0 +1,255 @@
+package mockserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/command"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/structure"
+	"phoenixbuilder_3rd_gui/fb/minecraft"
+	"phoenixbuilder_3rd_gui/fb/minecraft/protocol"
+	"phoenixbuilder_3rd_gui/fb/minecraft/protocol/packet"
+	"strconv"
+	"strings"
+)
+
+// commandResult is the output of a command
+type commandResult struct {
+	success    bool
+	message    string
+	parameters []string
+}
+
+func succeed(message string, parameters ...string) commandResult {
+	return commandResult{success: true, message: message, parameters: parameters}
+}
+
+func fail(message string, parameters ...string) commandResult {
+	return commandResult{success: false, message: message, parameters: parameters}
+}
+
+// execute runs a command sent by the client, only the commands used by
+// fastbuilder are understood, the others fail as unknown commands.
+func (s *Server) execute(conn *minecraft.Conn, commandLine string) *packet.CommandOutput {
+	s.mu.Lock()
+	s.commands = append(s.commands, commandLine)
+	base := s.position
+	s.mu.Unlock()
+	result := s.run(conn, strings.TrimPrefix(commandLine, "/"), base)
+	output := &packet.CommandOutput{
+		OutputType: 3,
+		OutputMessages: []protocol.CommandOutputMessage{{
+			Success:    result.success,
+			Message:    result.message,
+			Parameters: result.parameters,
+		}},
+	}
+	if result.success {
+		output.SuccessCount = 1
+	}
+	return output
+}
+
+func (s *Server) run(conn *minecraft.Conn, commandLine string, base [3]int) commandResult {
+	args := strings.Fields(commandLine)
+	if len(args) == 0 {
+		return fail("commands.generic.unknown", "")
+	}
+	switch args[0] {
+	case "execute":
+		// execute <target> <x> <y> <z> <command>, the target is always the bot
+		if len(args) < 6 {
+			return fail("commands.generic.syntax")
+		}
+		pos, err := coordinates(args[2:5], base)
+		if err != nil {
+			return fail("commands.generic.syntax")
+		}
+		return s.run(conn, strings.Join(args[5:], " "), pos)
+	case "setblock":
+		if len(args) < 5 {
+			return fail("commands.generic.syntax")
+		}
+		pos, err := coordinates(args[1:4], base)
+		if err != nil {
+			return fail("commands.generic.syntax")
+		}
+		data := 0
+		if len(args) > 5 {
+			data, _ = strconv.Atoi(args[5])
+		}
+		s.mu.Lock()
+		lost := s.lostSetblocks > 0
+		if lost {
+			s.lostSetblocks--
+		}
+		s.mu.Unlock()
+		if lost {
+			return succeed("commands.setblock.success")
+		}
+		if err := s.World.SetBlock(pos[0], pos[1], pos[2], args[4], uint16(data)); err != nil {
+			return fail("commands.setblock.failed")
+		}
+		s.updateBlock(conn, pos)
+		return succeed("commands.setblock.success")
+	case "fill":
+		if len(args) < 8 {
+			return fail("commands.generic.syntax")
+		}
+		from, err := coordinates(args[1:4], base)
+		if err != nil {
+			return fail("commands.generic.syntax")
+		}
+		to, err := coordinates(args[4:7], base)
+		if err != nil {
+			return fail("commands.generic.syntax")
+		}
+		data := 0
+		if len(args) > 8 {
+			data, _ = strconv.Atoi(args[8])
+		}
+		count := 0
+		for i := 0; i < 3; i++ {
+			if from[i] > to[i] {
+				from[i], to[i] = to[i], from[i]
+			}
+		}
+		for x := from[0]; x <= to[0]; x++ {
+			for y := from[1]; y <= to[1]; y++ {
+				for z := from[2]; z <= to[2]; z++ {
+					if err := s.World.SetBlock(x, y, z, args[7], uint16(data)); err != nil {
+						return fail("commands.fill.failed")
+					}
+					count++
+				}
+			}
+		}
+		return succeed("commands.fill.success", strconv.Itoa(count))
+	case "tp", "teleport":
+		coords := args[1:]
+		if len(coords) == 4 {
+			// tp <target> <x> <y> <z>
+			coords = coords[1:]
+		}
+		if len(coords) != 3 {
+			return fail("commands.generic.syntax")
+		}
+		pos, err := coordinates(coords, base)
+		if err != nil {
+			return fail("commands.generic.syntax")
+		}
+		s.mu.Lock()
+		s.position = pos
+		s.mu.Unlock()
+		s.sendChunks(conn, pos[0], pos[2])
+		return succeed("commands.tp.success.coordinates", "FastBuilder", strconv.Itoa(pos[0]), strconv.Itoa(pos[1]), strconv.Itoa(pos[2]))
+	case "testforblock":
+		if len(args) < 5 {
+			return fail("commands.generic.syntax")
+		}
+		pos, err := coordinates(args[1:4], base)
+		if err != nil {
+			return fail("commands.generic.syntax")
+		}
+		x, y, z := strconv.Itoa(pos[0]), strconv.Itoa(pos[1]), strconv.Itoa(pos[2])
+		name, _ := s.World.Block(pos[0], pos[1], pos[2])
+		if name != strings.TrimPrefix(args[4], "minecraft:") {
+			return fail("commands.testforblock.failed.tile", x, y, z, name, args[4])
+		}
+		return succeed("commands.testforblock.success", x, y, z)
+	case "replaceitem":
+		// replaceitem block <x> <y> <z> slot.container <slot> <item> <count> <damage>
+		if len(args) < 9 || args[1] != "block" {
+			return fail("commands.generic.syntax")
+		}
+		pos, err := coordinates(args[2:5], base)
+		if err != nil {
+			return fail("commands.generic.syntax")
+		}
+		slot, _ := strconv.Atoi(args[6])
+		count, damage := 1, 0
+		if len(args) > 8 {
+			count, _ = strconv.Atoi(args[8])
+		}
+		if len(args) > 9 {
+			damage, _ = strconv.Atoi(args[9])
+		}
+		s.addItem(pos, map[string]interface{}{
+			"Name":   "minecraft:" + strings.TrimPrefix(args[7], "minecraft:"),
+			"Count":  uint8(count),
+			"Damage": int16(damage),
+			"Slot":   uint8(slot),
+		})
+		return succeed("commands.replaceitem.success")
+	case "tellraw":
+		if len(args) < 3 {
+			return fail("commands.generic.syntax")
+		}
+		var content command.TellrawStruct
+		if err := json.Unmarshal([]byte(strings.SplitN(commandLine, " ", 3)[2]), &content); err != nil {
+			return fail("commands.tellraw.jsonException", err.Error())
+		}
+		s.mu.Lock()
+		for _, item := range content.RawText {
+			s.messages = append(s.messages, item.Text)
+		}
+		s.messageCond.Broadcast()
+		s.mu.Unlock()
+		return succeed("")
+	case "gamemode", "gamerule", "titleraw", "say", "give", "clear", "summon", "kill":
+		return succeed("")
+	}
+	return fail("commands.generic.unknown", args[0])
+}
+
+// updateBlock tells the client that the block at the position is changed
+func (s *Server) updateBlock(conn *minecraft.Conn, pos [3]int) {
+	name, data := s.World.Block(pos[0], pos[1], pos[2])
+	runtimeId, _ := structure.RuntimeIdOf(name, data)
+	conn.WritePacket(&packet.UpdateBlock{
+		Position:          protocol.BlockPos{int32(pos[0]), int32(pos[1]), int32(pos[2])},
+		NewBlockRuntimeID: runtimeId,
+		Flags:             packet.BlockUpdateNetwork,
+	})
+}
+
+// addItem puts an item into the container at the position
+func (s *Server) addItem(pos [3]int, item map[string]interface{}) {
+	tag := s.World.BlockNBT(pos[0], pos[1], pos[2])
+	if tag == nil {
+		name, _ := s.World.Block(pos[0], pos[1], pos[2])
+		tag = map[string]interface{}{
+			"id":    strings.Replace(strings.Title(strings.Replace(name, "_", " ", -1)), " ", "", -1),
+			"Items": []interface{}{},
+		}
+	}
+	items, _ := tag["Items"].([]interface{})
+	for i, old := range items {
+		if old.(map[string]interface{})["Slot"] == item["Slot"] {
+			items = append(items[:i], items[i+1:]...)
+			break
+		}
+	}
+	tag["Items"] = append(items, item)
+	s.World.SetBlockNBT(pos[0], pos[1], pos[2], tag)
+}
+
+// coordinates parses the coordinates, which may be relative to the base
+func coordinates(args []string, base [3]int) (pos [3]int, err error) {
+	for i, arg := range args {
+		relative := strings.HasPrefix(arg, "~")
+		arg = strings.TrimPrefix(arg, "~")
+		value := 0
+		if arg != "" {
+			f, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				return pos, fmt.Errorf("invalid coordinate: %s", args[i])
+			}
+			value = int(f)
+		}
+		if relative {
+			value += base[i]
+		}
+		pos[i] = value
+	}
+	return pos, nil
+}
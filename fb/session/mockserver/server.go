@@ -0,0 +1,259 @@
+// Package mockserver is a local server for testing sessions without
+// the fb auth server and netease. It answers commands with a simple
+// in-memory world and sends its chunks to the session.
+package mockserver
+
+import (
+	"io/ioutil"
+	"log"
+	"phoenixbuilder_3rd_gui/fb/dragonfly/server/world"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/structure"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/types"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/world_provider"
+	"phoenixbuilder_3rd_gui/fb/minecraft"
+	"phoenixbuilder_3rd_gui/fb/minecraft/protocol"
+	"phoenixbuilder_3rd_gui/fb/minecraft/protocol/login"
+	"phoenixbuilder_3rd_gui/fb/minecraft/protocol/packet"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+const botRuntimeId = 1
+
+type Server struct {
+	World *World
+
+	listener *minecraft.Listener
+	mu       sync.Mutex
+	position [3]int
+	commands []string
+	messages []string
+	conns    []*minecraft.Conn
+	// notified when a message is received
+	messageCond *sync.Cond
+	// the next setblock commands that are lost, see LoseSetblocks
+	lostSetblocks int
+}
+
+// LoseSetblocks makes the next n setblock commands succeed without
+// changing the world, like packets dropped by a busy server
+func (s *Server) LoseSetblocks(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lostSetblocks = n
+}
+
+// Start starts a server listening on a random local port
+func Start() (*Server, error) {
+	listener, err := minecraft.ListenConfig{
+		ErrorLog:               log.New(ioutil.Discard, "", 0),
+		AuthenticationDisabled: true,
+		StatusProvider:         minecraft.NewStatusProvider("Mock Server"),
+	}.Listen("raknet", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		World:    NewWorld(),
+		listener: listener,
+		position: [3]int{0, 64, 0},
+	}
+	s.messageCond = sync.NewCond(&s.mu)
+	go s.accept()
+	return s, nil
+}
+
+// Addr returns the address the server is listening on
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Dial connects to the server, it can be used as the DialFn of a session.
+func (s *Server) Dial() (*minecraft.Conn, error) {
+	conn, err := minecraft.Dialer{
+		ErrorLog:     log.New(ioutil.Discard, "", 0),
+		IdentityData: login.IdentityData{DisplayName: "FastBuilder"},
+	}.Dial("raknet", s.Addr())
+	if err != nil {
+		return nil, err
+	}
+	// sessions don't wait for spawning since netease doesn't need it,
+	// but the listener only hands out the connection after that
+	conn.WritePacket(&packet.SetLocalPlayerAsInitialised{EntityRuntimeID: conn.GameData().EntityRuntimeID})
+	return conn, nil
+}
+
+// Close closes the server and all connections
+func (s *Server) Close() error {
+	s.mu.Lock()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	return s.listener.Close()
+}
+
+// Disconnect closes the connections to the server but keeps listening,
+// as if the connections were lost
+func (s *Server) Disconnect() {
+	s.mu.Lock()
+	conns := s.conns
+	s.conns = nil
+	s.mu.Unlock()
+	for _, conn := range conns {
+		conn.Close()
+	}
+}
+
+// Commands returns all commands received, in order
+func (s *Server) Commands() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.commands...)
+}
+
+// Messages returns the text of all tellraw commands received, in order
+func (s *Server) Messages() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.messages...)
+}
+
+// WaitMessage waits for a message containing the text, it returns
+// false if no such message is received before the timeout.
+func (s *Server) WaitMessage(text string, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	timer := time.AfterFunc(timeout, func() {
+		s.mu.Lock()
+		s.messageCond.Broadcast()
+		s.mu.Unlock()
+	})
+	defer timer.Stop()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	checked := 0
+	for {
+		for ; checked < len(s.messages); checked++ {
+			if strings.Contains(s.messages[checked], text) {
+				return true
+			}
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		s.messageCond.Wait()
+	}
+}
+
+// Position returns the position of the player
+func (s *Server) Position() (x, y, z int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.position[0], s.position[1], s.position[2]
+}
+
+func (s *Server) accept() {
+	for {
+		c, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		conn := c.(*minecraft.Conn)
+		s.mu.Lock()
+		s.conns = append(s.conns, conn)
+		s.mu.Unlock()
+		go s.handleConn(conn)
+	}
+}
+
+func (s *Server) handleConn(conn *minecraft.Conn) {
+	defer conn.Close()
+	x, y, z := s.Position()
+	err := conn.StartGame(minecraft.GameData{
+		WorldName:       "Mock Server",
+		EntityUniqueID:  botRuntimeId,
+		EntityRuntimeID: botRuntimeId,
+		PlayerGameMode:  1,
+		PlayerPosition:  mgl32.Vec3{float32(x), float32(y), float32(z)},
+		WorldSpawn:      protocol.BlockPos{int32(x), int32(y), int32(z)},
+		WorldGameMode:   1,
+	})
+	if err != nil {
+		return
+	}
+	for {
+		pk, err := conn.ReadPacket()
+		if err != nil {
+			return
+		}
+		switch p := pk.(type) {
+		case *packet.CommandRequest:
+			output := s.execute(conn, p.CommandLine)
+			output.CommandOrigin = p.CommandOrigin
+			conn.WritePacket(output)
+		case *packet.SettingsCommand:
+			s.execute(conn, p.CommandLine)
+		case *packet.CommandBlockUpdate:
+			if !p.Block {
+				break
+			}
+			s.World.SetBlockNBT(int(p.Position.X()), int(p.Position.Y()), int(p.Position.Z()), structure.CommandBlockNBT(&types.CommandBlockData{
+				Mode:               p.Mode,
+				Command:            p.Command,
+				CustomName:         p.Name,
+				LastOutput:         p.LastOutput,
+				TickDelay:          p.TickDelay,
+				ExecuteOnFirstTick: p.ExecuteOnFirstTick,
+				TrackOutput:        p.ShouldTrackOutput,
+				Conditional:        p.Conditional,
+				NeedRedstone:       p.NeedsRedstone,
+			}, p.Position.X(), p.Position.Y(), p.Position.Z()))
+		case *packet.StructureTemplateDataRequest:
+			conn.WritePacket(s.structureTemplate(p))
+		}
+	}
+}
+
+// sendChunks sends the chunks around the position
+func (s *Server) sendChunks(conn *minecraft.Conn, x, z int) {
+	for dx := int32(-1); dx <= 1; dx++ {
+		for dz := int32(-1); dz <= 1; dz++ {
+			conn.WritePacket(s.World.levelChunk(world.ChunkPos{int32(x>>4) + dx, int32(z>>4) + dz}))
+		}
+	}
+}
+
+func (s *Server) structureTemplate(p *packet.StructureTemplateDataRequest) *packet.StructureTemplateDataResponse {
+	origin := types.Position{
+		X: int(p.Position.X() + p.Settings.Offset.X()),
+		Y: int(p.Position.Y() + p.Settings.Offset.Y()),
+		Z: int(p.Position.Z() + p.Settings.Offset.Z()),
+	}
+	size := [3]int{int(p.Settings.Size.X()), int(p.Settings.Size.Y()), int(p.Settings.Size.Z())}
+	var blocks []*types.RuntimeModule
+	for x := 0; x < size[0]; x++ {
+		for y := 0; y < size[1]; y++ {
+			for z := 0; z < size[2]; z++ {
+				name, data := s.World.Block(origin.X+x, origin.Y+y, origin.Z+z)
+				runtimeId, _ := structure.RuntimeIdOf(name, data)
+				if runtimeId == world_provider.AirRuntimeId {
+					continue
+				}
+				blocks = append(blocks, &types.RuntimeModule{
+					BlockRuntimeId: runtimeId,
+					Point:          types.Position{X: x, Y: y, Z: z},
+				})
+			}
+		}
+	}
+	template, err := structure.MCStructureNBT(blocks, size, origin)
+	return &packet.StructureTemplateDataResponse{
+		StructureName:     p.StructureName,
+		Success:           err == nil,
+		ResponseType:      packet.StructureTemplateResponseExport,
+		StructureTemplate: template,
+	}
+}
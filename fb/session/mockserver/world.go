@@ -0,0 +1,124 @@
+package mockserver
+
+import (
+	"bytes"
+	"fmt"
+	"phoenixbuilder_3rd_gui/fb/dragonfly/server/block/cube"
+	"phoenixbuilder_3rd_gui/fb/dragonfly/server/world"
+	"phoenixbuilder_3rd_gui/fb/dragonfly/server/world/chunk"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/structure"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/world_provider"
+	"phoenixbuilder_3rd_gui/fb/minecraft/nbt"
+	"phoenixbuilder_3rd_gui/fb/minecraft/protocol/packet"
+	"strings"
+	"sync"
+)
+
+// World is a simple in-memory world, blocks are kept by their runtime
+// ids in the same way as the world provider of the sessions.
+type World struct {
+	mu       sync.Mutex
+	chunks   map[world.ChunkPos]*chunk.Chunk
+	blockNBT map[cube.Pos]map[string]interface{}
+}
+
+func NewWorld() *World {
+	return &World{
+		chunks:   map[world.ChunkPos]*chunk.Chunk{},
+		blockNBT: map[cube.Pos]map[string]interface{}{},
+	}
+}
+
+func (w *World) chunk(x, z int, create bool) *chunk.Chunk {
+	pos := world.ChunkPos{int32(x >> 4), int32(z >> 4)}
+	c, ok := w.chunks[pos]
+	if !ok && create {
+		c = chunk.New(world_provider.AirRuntimeId)
+		w.chunks[pos] = c
+	}
+	return c
+}
+
+// SetBlock places a block, the name may start with "minecraft:"
+func (w *World) SetBlock(x, y, z int, name string, data uint16) error {
+	if y < 0 || y > 255 {
+		return fmt.Errorf("position out of the world: %d %d %d", x, y, z)
+	}
+	runtimeId, found := structure.RuntimeIdOf(strings.TrimPrefix(name, "minecraft:"), data)
+	if !found {
+		return fmt.Errorf("unknown block: %s %d", name, data)
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.chunk(x, z, true).SetRuntimeID(uint8(x&15), int16(y), uint8(z&15), 0, runtimeId)
+	delete(w.blockNBT, cube.Pos{x, y, z})
+	return nil
+}
+
+// Block returns the name and data of the block at the position
+func (w *World) Block(x, y, z int) (name string, data uint16) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	runtimeId := uint32(world_provider.AirRuntimeId)
+	if c := w.chunk(x, z, false); c != nil && y >= 0 && y <= 255 {
+		runtimeId = c.RuntimeID(uint8(x&15), int16(y), uint8(z&15), 0)
+	}
+	block := world_provider.RuntimeIdArray_117[runtimeId]
+	return block.Name, block.Data
+}
+
+// SetBlockNBT sets the block entity at the position, it's removed
+// when the block is replaced.
+func (w *World) SetBlockNBT(x, y, z int, data map[string]interface{}) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	data["x"], data["y"], data["z"] = int32(x), int32(y), int32(z)
+	w.blockNBT[cube.Pos{x, y, z}] = data
+}
+
+// BlockNBT returns the block entity at the position, or nil
+func (w *World) BlockNBT(x, y, z int) map[string]interface{} {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.blockNBT[cube.Pos{x, y, z}]
+}
+
+// levelChunk encodes the chunk at the chunk position in the same way
+// as the dragonfly server does.
+func (w *World) levelChunk(pos world.ChunkPos) *packet.LevelChunk {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	c, ok := w.chunks[pos]
+	if !ok {
+		c = chunk.New(world_provider.AirRuntimeId)
+	}
+	data := chunk.Encode(c, chunk.NetworkEncoding)
+	count := byte(0)
+	for y := byte(0); y < 16; y++ {
+		if data.SubChunks[y] != nil {
+			count = y + 1
+		}
+	}
+	buf := bytes.NewBuffer(nil)
+	for y := byte(0); y < count; y++ {
+		if data.SubChunks[y] == nil {
+			buf.WriteByte(chunk.SubChunkVersion)
+			buf.WriteByte(0)
+			continue
+		}
+		buf.Write(data.SubChunks[y])
+	}
+	buf.Write(data.Data2D)
+	enc := nbt.NewEncoderWithEncoding(buf, nbt.NetworkLittleEndian)
+	for blockPos, blockNBT := range w.blockNBT {
+		if (world.ChunkPos{int32(blockPos[0] >> 4), int32(blockPos[2] >> 4)}) == pos {
+			enc.Encode(blockNBT)
+		}
+	}
+	return &packet.LevelChunk{
+		ChunkX:        pos[0],
+		ChunkZ:        pos[1],
+		SubChunkCount: uint32(count),
+		RawPayload:    buf.Bytes(),
+	}
+}
@@ -5,16 +5,15 @@ import (
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
-	"phoenixbuilder_3rd_gui/fb/fastbuilder/move"
 
 	"github.com/google/uuid"
 	"github.com/pterm/pterm"
 
 	"phoenixbuilder_3rd_gui/fb/fastbuilder/command"
 	"phoenixbuilder_3rd_gui/fb/fastbuilder/configuration"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/environment"
 	"phoenixbuilder_3rd_gui/fb/fastbuilder/function"
 	fbtask "phoenixbuilder_3rd_gui/fb/fastbuilder/task"
-	"phoenixbuilder_3rd_gui/fb/fastbuilder/world_provider"
 	"phoenixbuilder_3rd_gui/fb/minecraft/protocol"
 	"phoenixbuilder_3rd_gui/fb/minecraft/protocol/packet"
 
@@ -27,6 +26,7 @@ import (
 	"phoenixbuilder_3rd_gui/fb/minecraft"
 	bridge_fmt "phoenixbuilder_3rd_gui/fb/session/bridge/fmt"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -39,7 +39,15 @@ type SessionConfig struct {
 	ServerPasswd  string `yaml:"server_passwd" json:"server_passwd"`
 	RespondUser   string `yaml:"respond_user" json:"respond_user"`
 	MuteWorldChat bool   `yaml:"mute_world_chat" json:"mute_world_chat"`
-	iamDeveloper  bool
+	// don't dial the server again when the connection is lost
+	DisableReconnect bool `yaml:"disable_reconnect" json:"disable_reconnect"`
+	// AuthBackend is either AuthBackendFastBuilder or AuthBackendLocal
+	AuthBackend string `yaml:"auth_backend" json:"auth_backend"`
+	// the address of the auth server for AuthBackendFastBuilder
+	AuthServer string `yaml:"auth_server" json:"auth_server"`
+	// the address of the server to connect to for AuthBackendLocal
+	LocalServerAddress string `yaml:"local_server_address" json:"local_server_address"`
+	iamDeveloper       bool
 	// when "iamDeveloper" is true, the following fields are used,
 	// otherwise, the fields are ignored (restore to default)
 	NoPyRPC               bool   `yaml:"no_py_rpc" json:"no_py_rpc"`
@@ -49,6 +57,21 @@ type SessionConfig struct {
 	FBCodeName            string `yaml:"fb_codename" json:"fb_codename"`
 }
 
+const (
+	// the FastBuilder auth server, or a self-hosted one
+	AuthBackendFastBuilder = "fastbuilder"
+	// no auth server, connects to a server that doesn't verify logins
+	AuthBackendLocal = "local"
+)
+
+// how many times the session tries to dial the server again after
+// the connection is lost, the delay between attempts doubles each time
+const (
+	MaxReconnectAttempts = 5
+	FirstReconnectDelay  = time.Second
+	MaxReconnectDelay    = 30 * time.Second
+)
+
 func (config *SessionConfig) IsDeveloper() bool {
 	return config.iamDeveloper
 }
@@ -64,6 +87,10 @@ func NewConfig() *SessionConfig {
 		RespondUser:           "",
 		iamDeveloper:          false,
 		MuteWorldChat:         false,
+		DisableReconnect:      false,
+		AuthBackend:           AuthBackendFastBuilder,
+		AuthServer:            fbauth.DefaultAuthServer,
+		LocalServerAddress:    "",
 		NoPyRPC:               false,
 		NBTConstructorEnabled: true,
 		FBVersion:             DefaultFBVersion,
@@ -79,14 +106,37 @@ type Session struct {
 	// can use this to send command
 	cmdChan          chan string
 	closeFns         []func()
+	closeOnce        sync.Once
 	worldChatChannel chan []string
-	fbClinet         *fbauth.Client
-	mcConn           *minecraft.Conn
-	botRuntimeID     string
-	Config           *SessionConfig
+	fbClinet         fbauth.Authenticator
+	// the connection is replaced after reconnecting while the other
+	// goroutines of the session use it, get it with conn()
+	connMu        sync.RWMutex
+	mcConn        *minecraft.Conn
+	env           *environment.PBEnvironment
+	configuration *configuration.SessionConfiguration
+	botRuntimeID  string
+	Config        *SessionConfig
 	// set/ set end callback
 	CmdSetCbFn    func(X, Y, Z int)
 	CmdSetEndCbFn func(X, Y, Z int)
+	// output of this session (chat and title)
+	ChatCbFn  func(string)
+	TitleCbFn func(string)
+	// called with the number of the attempt when the connection is lost
+	// and the session is dialing the server again, and with 0 once the
+	// session is connected again
+	ReconnectCbFn func(attempt int)
+	// where the progress of tasks is saved, so unfinished tasks can be
+	// resumed after the app is restarted, nothing is saved if it's nil
+	CheckpointStore fbtask.CheckpointStore
+	// where the blocks changed by tasks are saved so the tasks can be
+	// undone, they are cleared when the session is closed, tasks can't be
+	// undone if it's nil
+	JournalStore fbtask.JournalStore
+	// when set, the session connects with it instead of the fb auth
+	// server and netease, e.g. to a local server in tests
+	DialFn func() (*minecraft.Conn, error)
 }
 
 type FBPlainToken struct {
@@ -95,20 +145,11 @@ type FBPlainToken struct {
 	Password     string `json:"password"`
 }
 
-var isStart bool
-
 func init() {
 	I18n.Init()
-	isStart = false
 }
 
 func NewSession(config *SessionConfig) *Session {
-	// it's weird that we need to do this, because actually we can only hold one session
-	// but maybe in the future we can support multiple sessions
-	if isStart {
-		return nil
-	}
-
 	config.iamDeveloper = false
 
 	if !config.iamDeveloper {
@@ -125,8 +166,12 @@ func NewSession(config *SessionConfig) *Session {
 		cmdChan:       make(chan string),
 		closeFns:      make([]func(), 0),
 		Config:        config,
+		configuration: configuration.NewSessionConfiguration(),
 		CmdSetCbFn:    func(X, Y, Z int) {},
 		CmdSetEndCbFn: func(X, Y, Z int) {},
+		ChatCbFn:      func(string) {},
+		TitleCbFn:     func(string) {},
+		ReconnectCbFn: func(int) {},
 	}
 	// configuration.MonkeyPathFileReader = make(map[string]fyne.URIReadCloser)
 	// configuration.MonkeyPathFileWriter = make(map[string]fyne.URIWriteCloser)
@@ -144,11 +189,6 @@ func NewSession(config *SessionConfig) *Session {
 // }
 
 func (s *Session) Start() (terminateChan chan string, startErr error) {
-	// we need to make sure no multiple session is running
-	if isStart {
-		return nil, fmt.Errorf("Session is already started")
-	}
-
 	// before we start, we need to make sure that the session is valid
 	// if not, we need to return an error
 
@@ -161,9 +201,8 @@ func (s *Session) Start() (terminateChan chan string, startErr error) {
 	// notify reciver of this chan that the session is terminated
 	// and the reason for termination
 
-	isStart = true
 	// when the session is terminated, we need to notify the caller
-	configuration.UserToken = s.Config.FBToken
+	s.configuration.UserToken = s.Config.FBToken
 	c := s.afterStart()
 	return c, nil
 }
@@ -175,7 +214,6 @@ func (s *Session) afterStart() chan string {
 }
 
 func (s *Session) beforeStart() (err error) {
-	configuration.SessionInitID += 1
 	// in this function, we need to make sure that the session is valid
 	// first, we need to connect to the fb auth server and get the token
 	// then, we try connecting to netease mc server
@@ -189,37 +227,55 @@ func (s *Session) beforeStart() (err error) {
 	}()
 
 	// print copyright
-	bridge_fmt.Print("The Following are copyright of Phoenix Builder Inside:")
-	bridge_fmt.Print(I18n.T(I18n.Copyright_Notice_Headline))
-	bridge_fmt.Print(I18n.T(I18n.Copyright_Notice_Line_1))
-	bridge_fmt.Print(I18n.T(I18n.Copyright_Notice_Line_2))
-	bridge_fmt.Print(I18n.T(I18n.Copyright_Notice_Line_3))
-	bridge_fmt.Print("https://github.com/Sandertv/gophertunnel")
-	bridge_fmt.Print("ファスト　ビルダー")
-	bridge_fmt.Print("F A S T  B U I L D E R")
-	bridge_fmt.Print("Contributors: Ruphane, CAIMEO")
-	bridge_fmt.Print("Copyright (c) FastBuilder DevGroup, Bouldev 2022")
-	bridge_fmt.Print("FastBuilder Phoenix " + s.Config.FBVersion)
+	s.print("The Following are copyright of Phoenix Builder Inside:")
+	s.print(I18n.T(I18n.Copyright_Notice_Headline))
+	s.print(I18n.T(I18n.Copyright_Notice_Line_1))
+	s.print(I18n.T(I18n.Copyright_Notice_Line_2))
+	s.print(I18n.T(I18n.Copyright_Notice_Line_3))
+	s.print("https://github.com/Sandertv/gophertunnel")
+	s.print("ファスト　ビルダー")
+	s.print("F A S T  B U I L D E R")
+	s.print("Contributors: Ruphane, CAIMEO")
+	s.print("Copyright (c) FastBuilder DevGroup, Bouldev 2022")
+	s.print("FastBuilder Phoenix " + s.Config.FBVersion)
 	if I18n.ShouldDisplaySpecial() {
-		bridge_fmt.Printf("%s", I18n.T(I18n.Special_Startup))
+		s.print(I18n.T(I18n.Special_Startup))
 	}
 
-	// check credentials
-	if (s.Config.FBUserName == "" || s.Config.FBPassword == "") && s.Config.FBToken == "" {
-		return fmt.Errorf("no credientials provided")
+	s.worldChatChannel = make(chan []string)
+	conn, err := s.dial()
+	if err != nil {
+		return err
 	}
+	return s.setupConn(conn)
+}
 
-	// check server configuration
-	if s.Config.ServerCode == "" {
-		return fmt.Errorf("no server code provided")
+// dial connects to the auth server and then the server,
+// or calls DialFn if it is set
+func (s *Session) dial() (*minecraft.Conn, error) {
+	if s.DialFn != nil {
+		conn, err := s.DialFn()
+		if err != nil {
+			return nil, fmt.Errorf("cannot dial to server: (%v)", err)
+		}
+		return conn, nil
 	}
 
-	// do what phoenix builder does
-	worldChatChannel := make(chan []string)
-	s.worldChatChannel = worldChatChannel
-	client := fbauth.CreateClient(worldChatChannel)
-	s.fbClinet = client
-	if s.Config.FBToken == "" {
+	// do what phoenix builder does, the client of the auth server is
+	// kept if only the connection to the server is lost
+	client := s.fbClinet
+	if client == nil || !client.CanSendMessage() {
+		var err error
+		client, err = s.createAuthenticator()
+		if err != nil {
+			return nil, err
+		}
+		if s.fbClinet != nil {
+			s.fbClinet.Close()
+		}
+		s.fbClinet = client
+	}
+	if s.Config.FBToken == "" && s.Config.AuthBackend != AuthBackendLocal {
 		// we need to get the token
 		tokenReq := &FBPlainToken{
 			EncryptToken: true,
@@ -228,15 +284,15 @@ func (s *Session) beforeStart() (err error) {
 		}
 		tokenReqStr, err := json.Marshal(tokenReq)
 		if err != nil {
-			return fmt.Errorf("cannot marshal token request to json: \n%v", err)
+			return nil, fmt.Errorf("cannot marshal token request to json: \n%v", err)
 		}
 		token := client.GetToken("", string(tokenReqStr))
 		if token == "" {
-			return fmt.Errorf("cannot get token: \n" + I18n.T(I18n.FBUC_LoginFailed))
+			return nil, fmt.Errorf("cannot get token: \n" + I18n.T(I18n.FBUC_LoginFailed))
 		}
 		s.Config.FBToken = token
 	}
-	bridge_fmt.Println(fmt.Sprintf("%s: %s", I18n.T(I18n.ServerCodeTrans), s.Config.ServerCode))
+	s.print(fmt.Sprintf("%s: %s", I18n.T(I18n.ServerCodeTrans), s.Config.ServerCode))
 	dialer := minecraft.Dialer{
 		ServerCode: s.Config.ServerCode,
 		Password:   s.Config.ServerPasswd,
@@ -246,19 +302,100 @@ func (s *Session) beforeStart() (err error) {
 	}
 	conn, err := dialer.Dial("raknet", "")
 	if err != nil {
-		return fmt.Errorf("cannot dial to netease mc server: (%v)", err)
+		return nil, fmt.Errorf("cannot dial to netease mc server: (%v)", err)
 	}
-	s.mcConn = conn
+	return conn, nil
+}
+
+// createAuthenticator checks the configuration of the auth backend
+// and connects to it
+func (s *Session) createAuthenticator() (fbauth.Authenticator, error) {
+	switch s.Config.AuthBackend {
+	case AuthBackendLocal:
+		if s.Config.LocalServerAddress == "" {
+			return nil, fmt.Errorf("no server address provided")
+		}
+		return &fbauth.StubAuthenticator{
+			ServerAddress: s.Config.LocalServerAddress,
+			RespondUser:   s.Config.RespondUser,
+		}, nil
+	case AuthBackendFastBuilder, "":
+		// check credentials
+		if (s.Config.FBUserName == "" || s.Config.FBPassword == "") && s.Config.FBToken == "" {
+			return nil, fmt.Errorf("no credientials provided")
+		}
+		// check server configuration
+		if s.Config.ServerCode == "" {
+			return nil, fmt.Errorf("no server code provided")
+		}
+		client, err := fbauth.CreateClient(s.Config.AuthServer, s.worldChatChannel)
+		if err != nil {
+			return nil, err
+		}
+		return client, nil
+	}
+	return nil, fmt.Errorf("unknown auth backend: %s", s.Config.AuthBackend)
+}
+
+// setupConn prepares the environment of the session for the connection
+func (s *Session) setupConn(conn *minecraft.Conn) error {
+	env := environment.NewEnvironment(conn, s.configuration)
+	s.env = env
+	s.handshake(conn)
 	s.closeFns = append(s.closeFns, func() {
+		conn := s.conn()
+		command.RemoveOutputHooks(conn)
 		conn.Close()
 	})
+
+	// init tasks and FB Functions
+	taskHolder := fbtask.NewTaskHolder()
+	taskHolder.Checkpoints = s.CheckpointStore
+	taskHolder.Journals = s.JournalStore
+	taskHolder.Global = env.Configuration.FullConfig.Global()
+	env.TaskHolder = taskHolder
+	s.closeFns = append(s.closeFns, taskHolder.Stop)
+	if s.JournalStore != nil {
+		s.closeFns = append(s.closeFns, func() { s.JournalStore.Clear() })
+	}
+	function.InitInternalFunctions(env)
+
+	// override the default nbt state
+	if !s.Config.iamDeveloper {
+		s.Config.NBTConstructorEnabled = !fbauth.ShouldDisableNBTConstructor
+	}
+	if s.Config.NBTConstructorEnabled {
+		nbtconstructor.InitNBTConstructor(env)
+	}
+
+	fbtask.InitTaskStatusDisplay(env)
+
+	// no necessary here
+	// signalhandler.Init(conn)
+
+	return nil
+}
+
+// handshake does what the client does after connecting to the server,
+// it is done again after reconnecting
+func (s *Session) handshake(conn *minecraft.Conn) {
+	s.connMu.Lock()
+	s.mcConn = conn
+	s.connMu.Unlock()
+	client := s.fbClinet
+	command.SetOutputHooks(conn, &command.OutputHooks{
+		Chat: func(str string) {
+			s.ChatCbFn(str)
+		},
+		Title: func(str string) {
+			s.TitleCbFn(str)
+		},
+	})
 	// override the default respond user
-	if s.Config.RespondUser == "" {
+	if s.Config.RespondUser == "" && client != nil {
 		s.Config.RespondUser = client.ShouldRespondUser()
 	}
-
-	// TODO: don't make this global
-	configuration.RespondUser = s.Config.RespondUser
+	s.configuration.RespondUser = s.Config.RespondUser
 
 	// set bot runtimeID
 	s.botRuntimeID = fmt.Sprintf("%d", conn.GameData().EntityUniqueID)
@@ -297,40 +434,57 @@ func (s *Session) beforeStart() (err error) {
 	conn.WritePacket(&packet.ClientCacheStatus{
 		Enabled: false,
 	})
+}
 
-	// init FB Functions
-	function.InitInternalFunctions()
-
-	// override the default nbt state
-	if !s.Config.iamDeveloper {
-		s.Config.NBTConstructorEnabled = !fbauth.ShouldDisableNBTConstructor
+// reconnect dials the server again after the connection is lost, the
+// tasks interrupted by it continue on the new connection
+func (s *Session) reconnect(cause error) error {
+	if s.Config.DisableReconnect {
+		return cause
 	}
-	if s.Config.NBTConstructorEnabled {
-		nbtconstructor.InitNBTConstructor()
+	s.print(fmt.Sprintf("Connection lost: %v", cause))
+	delay := FirstReconnectDelay
+	var err error
+	for attempt := 1; attempt <= MaxReconnectAttempts; attempt++ {
+		s.ReconnectCbFn(attempt)
+		s.print(fmt.Sprintf("Reconnecting in %v (%d/%d)", delay, attempt, MaxReconnectAttempts))
+		select {
+		case <-time.After(delay):
+		case <-s.stopChan:
+			return fmt.Errorf("session terminated by user")
+		}
+		var conn *minecraft.Conn
+		conn, err = s.redial()
+		if err == nil {
+			oldConn := s.conn()
+			s.handshake(conn)
+			command.RemoveOutputHooks(oldConn)
+			oldConn.Close()
+			s.env.MoveHolder.SetConnection(conn)
+			s.env.WorldHolder.SetConnection(conn)
+			fbtask.GetTaskHolder(s.env).SetConnection(s.env, conn)
+			s.ReconnectCbFn(0)
+			s.print("Reconnected")
+			return nil
+		}
+		s.print(fmt.Sprintf("Failed to reconnect: %v", err))
+		delay *= 2
+		if delay > MaxReconnectDelay {
+			delay = MaxReconnectDelay
+		}
 	}
+	return fmt.Errorf("failed to reconnect after %d attempts: %v", MaxReconnectAttempts, err)
+}
 
-	// TODO: don't make those global
-	fbtask.InitTaskStatusDisplay(conn)
-
-	// init the bot movement sync
-	move.ConnectTime = conn.GameData().ConnectTime
-	move.Position = conn.GameData().PlayerPosition
-	move.Pitch = conn.GameData().Pitch
-	move.Yaw = conn.GameData().Yaw
-	move.Connection = conn
-	move.RuntimeID = conn.GameData().EntityRuntimeID
-
-	// no necessary here
-	// signalhandler.Init(conn)
-
-	// TODO: it should have a better design
-	zeroId, _ := uuid.NewUUID()
-	oneId, _ := uuid.NewUUID()
-	configuration.ZeroId = zeroId
-	configuration.OneId = oneId
-	types.ForwardedBrokSender = fbtask.BrokSender
-
-	return nil
+// redial is dial that doesn't panic
+func (s *Session) redial() (conn *minecraft.Conn, err error) {
+	defer func() {
+		r := recover()
+		if r != nil {
+			err = fmt.Errorf("a panic occoured: %v", r)
+		}
+	}()
+	return s.dial()
 }
 
 func (s *Session) routine(c chan string) {
@@ -363,7 +517,7 @@ func (s *Session) routine(c chan string) {
 		for {
 			select {
 			case csmsg := <-s.worldChatChannel:
-				command.WorldChatTellraw(s.mcConn, csmsg[0], csmsg[1])
+				command.WorldChatTellraw(s.conn(), csmsg[0], csmsg[1])
 			case <-s.stopChan:
 				return
 			}
@@ -390,19 +544,19 @@ func (s *Session) routine(c chan string) {
 				if cmd[0] == '.' {
 					ud, _ := uuid.NewUUID()
 					chann := make(chan *packet.CommandOutput)
-					command.UUIDMap.Store(ud.String(), chann)
-					command.SendCommand(cmd[1:], ud, s.mcConn)
+					s.env.UUIDMap.Store(ud.String(), chann)
+					command.SendCommand(cmd[1:], ud, s.conn())
 					resp := <-chann
-					bridge_fmt.Printf("%+v\n", resp)
+					s.print(fmt.Sprintf("%+v\n", resp))
 				} else if cmd[0] == '!' {
 					ud, _ := uuid.NewUUID()
 					chann := make(chan *packet.CommandOutput)
-					command.UUIDMap.Store(ud.String(), chann)
-					command.SendWSCommand(cmd[1:], ud, s.mcConn)
+					s.env.UUIDMap.Store(ud.String(), chann)
+					command.SendWSCommand(cmd[1:], ud, s.conn())
 					resp := <-chann
-					bridge_fmt.Printf("%+v\n", resp)
+					s.print(fmt.Sprintf("%+v\n", resp))
 				}
-				function.Process(s.mcConn, cmd)
+				function.Process(s.env, cmd)
 			case <-s.stopChan:
 				return
 			}
@@ -410,16 +564,33 @@ func (s *Session) routine(c chan string) {
 	}()
 
 	// A loop that reads packets from the connection until it is closed.
-	conn := s.mcConn
+	conn := s.conn()
+	env := s.env
+	taskHolder := fbtask.GetTaskHolder(env)
+	nbtHolder := nbtconstructor.GetNBTConstructorHolder(env)
+	moveHolder := env.MoveHolder
 	user := s.Config.RespondUser
-	zeroId := configuration.ZeroId
+	conf := s.configuration
+	zeroId := conf.ZeroId
 	client := s.fbClinet
 	for {
 		// Read a packet from the connection: ReadPacket returns an error if the connection is closed or if
 		// a read timeout is set. You will generally want to return or break if this happens.
 		pk, err := conn.ReadPacket()
 		if err != nil {
-			panic(err)
+			select {
+			case <-s.stopChan:
+				terminateReason = "session terminated by user"
+				return
+			default:
+			}
+			if err := s.reconnect(err); err != nil {
+				terminateReason = fmt.Sprintf("Session terminated\n because the connection is lost: \n%v", err)
+				return
+			}
+			conn = s.conn()
+			client = s.fbClinet
+			continue
 		}
 
 		switch p := pk.(type) {
@@ -430,13 +601,13 @@ func (s *Session) routine(c chan string) {
 			//fmt.Printf("PyRpc!\n")
 			if strings.Contains(string(p.Content), "GetLoadingTime") {
 				//fmt.Printf("GetLoadingTime!!\n")
-				uid := s.mcConn.IdentityData().Uid
+				uid := conn.IdentityData().Uid
 				num := uid&255 ^ (uid&65280)>>8
 				curTime := time.Now().Unix()
 				num = curTime&3 ^ (num&7)<<2 ^ (curTime&252)<<3 ^ (num&248)<<8
 				numcont := make([]byte, 2)
 				binary.BigEndian.PutUint16(numcont, uint16(num))
-				s.mcConn.WritePacket(&packet.PyRpc{
+				conn.WritePacket(&packet.PyRpc{
 					Content: []byte{0x82, 0xc4, 0x8, 0x5f, 0x5f, 0x74, 0x79, 0x70, 0x65, 0x5f, 0x5f, 0xc4, 0x5, 0x74, 0x75, 0x70, 0x6c, 0x65, 0xc4, 0x5, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x93, 0xc4, 0x12, 0x53, 0x65, 0x74, 0x6c, 0x6f, 0x61, 0x64, 0x4c, 0x6f, 0x61, 0x64, 0x69, 0x6e, 0x67, 0x54, 0x69, 0x6d, 0x65, 0x82, 0xc4, 0x8, 0x5f, 0x5f, 0x74, 0x79, 0x70, 0x65, 0x5f, 0x5f, 0xc4, 0x5, 0x74, 0x75, 0x70, 0x6c, 0x65, 0xc4, 0x5, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x91, 0xcd, numcont[0], numcont[1], 0xc0},
 				})
 				// Good job, netease, you wasted 3 days of my idle time
@@ -477,6 +648,9 @@ func (s *Session) routine(c chan string) {
 			} else if strings.Contains(string(p.Content), "GetStartType") {
 				// 2021-12-22 10:51~11:55
 				// Thank netease for wasting my time again ;)
+				if client == nil {
+					break
+				}
 				encData := p.Content[68 : len(p.Content)-1]
 				response := client.TransferData(string(encData), fmt.Sprintf("%d", conn.IdentityData().Uid))
 				conn.WritePacket(&packet.PyRpc{
@@ -490,7 +664,9 @@ func (s *Session) routine(c chan string) {
 			break
 		case *packet.StructureTemplateDataResponse:
 			//fmt.Printf("RESPONSE %+v\n",p.StructureTemplate)
-			fbtask.ExportWaiter <- p.StructureTemplate
+			if taskHolder.ExportWaiter != nil {
+				taskHolder.ExportWaiter <- p.StructureTemplate
+			}
 			break
 		/*case *packet.InventoryContent:
 		for _, item := range p.Content {
@@ -504,7 +680,7 @@ func (s *Session) routine(c chan string) {
 				if user == p.SourceName {
 					if p.Message[0] == '>' && len(p.Message) > 1 {
 						umsg := p.Message[1:]
-						if !client.CanSendMessage() {
+						if client == nil || !client.CanSendMessage() {
 							command.WorldChatTellraw(conn, "FasｔBuildeｒ", "Lose connection to the authentication server.")
 							break
 						}
@@ -516,22 +692,22 @@ func (s *Session) routine(c chan string) {
 						//umsg:=p.Message[1:]
 						//
 					}
-					function.Process(conn, p.Message)
+					function.Process(env, p.Message)
 					break
 				}
 			}
 		case *packet.CommandOutput:
 			//if p.SuccessCount > 0 {
-			if p.CommandOrigin.UUID.String() == configuration.ZeroId.String() {
+			if p.CommandOrigin.UUID.String() == conf.ZeroId.String() {
 				pos, _ := utils.SliceAtoi(p.OutputMessages[0].Parameters)
 				if !(p.OutputMessages[0].Message == "commands.generic.unknown") {
-					configuration.IsOp = true
+					conf.IsOp = true
 				}
 				if len(pos) == 0 {
 					s.tellraw(I18n.T(I18n.InvalidPosition))
 					break
 				}
-				configuration.GlobalFullConfig().Main().Position = types.Position{
+				conf.FullConfig.Main().Position = types.Position{
 					X: pos[0],
 					Y: pos[1],
 					Z: pos[2],
@@ -539,13 +715,13 @@ func (s *Session) routine(c chan string) {
 				s.CmdSetCbFn(pos[0], pos[1], pos[2])
 				s.tellraw(fmt.Sprintf("%s: %v", I18n.T(I18n.PositionGot), pos))
 				break
-			} else if p.CommandOrigin.UUID.String() == configuration.OneId.String() {
+			} else if p.CommandOrigin.UUID.String() == conf.OneId.String() {
 				pos, _ := utils.SliceAtoi(p.OutputMessages[0].Parameters)
 				if len(pos) == 0 {
 					s.tellraw(I18n.T(I18n.InvalidPosition))
 					break
 				}
-				configuration.GlobalFullConfig().Main().End = types.Position{
+				conf.FullConfig.Main().End = types.Position{
 					X: pos[0],
 					Y: pos[1],
 					Z: pos[2],
@@ -555,7 +731,7 @@ func (s *Session) routine(c chan string) {
 				break
 			}
 			//}
-			pr, ok := command.UUIDMap.LoadAndDelete(p.CommandOrigin.UUID.String())
+			pr, ok := env.UUIDMap.LoadAndDelete(p.CommandOrigin.UUID.String())
 			if ok {
 				pu := pr.(chan *packet.CommandOutput)
 				pu <- p
@@ -568,21 +744,17 @@ func (s *Session) routine(c chan string) {
 				})
 			}
 		case *packet.LevelChunk:
-			if world_provider.ChunkInput != nil {
-				world_provider.ChunkInput <- p
-			} else {
-				world_provider.DoCache(p)
-			}
+			env.WorldHolder.HandleLevelChunk(p)
 		case *packet.UpdateBlock:
-			channel, h := command.BlockUpdateSubscribeMap.LoadAndDelete(p.Position)
+			channel, h := env.BlockUpdateSubscribeMap.LoadAndDelete(p.Position)
 			if h {
 				ch := channel.(chan bool)
 				ch <- true
 			}
 		case *packet.AddActor:
 			if p.EntityType == "minecraft:villager_v2" {
-				if nbtconstructor.AddVillagerChannel != nil {
-					nbtconstructor.AddVillagerChannel <- p
+				if nbtHolder != nil && nbtHolder.AddVillagerChannel != nil {
+					nbtHolder.AddVillagerChannel <- p
 				}
 			}
 		case *packet.InventoryContent:
@@ -590,40 +762,41 @@ func (s *Session) routine(c chan string) {
 				if len(p.Content) == 0 {
 					break
 				}
-				if nbtconstructor.InventoryContentChannel != nil {
-					nbtconstructor.InventoryContentChannel <- p
+				if nbtHolder != nil && nbtHolder.InventoryContentChannel != nil {
+					nbtHolder.InventoryContentChannel <- p
 				}
 			}
 		case *packet.ItemStackResponse:
-			if nbtconstructor.ItemStackResponseChannel != nil {
-				nbtconstructor.ItemStackResponseChannel <- p
+			if nbtHolder != nil && nbtHolder.ItemStackResponseChannel != nil {
+				nbtHolder.ItemStackResponseChannel <- p
 			}
 		case *packet.UpdateTrade:
-			if nbtconstructor.IsWorking {
-				nbtconstructor.TradeWindowID = p.WindowID
+			if nbtHolder != nil && nbtHolder.IsWorking {
+				nbtHolder.TradeWindowID = p.WindowID
 			}
 		case *packet.Respawn:
 			if p.EntityRuntimeID == conn.GameData().EntityRuntimeID {
-				move.Position = p.Position
+				moveHolder.Position = p.Position
 			}
 		case *packet.MovePlayer:
 			if p.EntityRuntimeID == conn.GameData().EntityRuntimeID {
-				move.Position = p.Position
-			} else if p.EntityRuntimeID == move.TargetRuntimeID {
-				move.Target = p.Position
+				moveHolder.Position = p.Position
+			} else if p.EntityRuntimeID == moveHolder.TargetRuntimeID {
+				moveHolder.Target = p.Position
 			}
 		case *packet.CorrectPlayerMovePrediction:
 			//fmt.Printf("correct %v\n",time.Now())
-			move.MoveP += 10
-			if move.MoveP > 100 {
-				move.MoveP = 0
+			moveHolder.Corrections.Inc()
+			moveHolder.MoveP += 10
+			if moveHolder.MoveP > 100 {
+				moveHolder.MoveP = 0
 			}
-			move.Position = p.Position
-			move.Jump()
+			moveHolder.Position = p.Position
+			moveHolder.Jump()
 		case *packet.AddPlayer:
-			if move.TargetRuntimeID == 0 && p.EntityRuntimeID != conn.GameData().EntityRuntimeID {
-				move.Target = p.Position
-				move.TargetRuntimeID = p.EntityRuntimeID
+			if moveHolder.TargetRuntimeID == 0 && p.EntityRuntimeID != conn.GameData().EntityRuntimeID {
+				moveHolder.Target = p.Position
+				moveHolder.TargetRuntimeID = p.EntityRuntimeID
 				//fmt.Printf("Got target: %s\n",p.Username)
 			}
 		}
@@ -637,30 +810,74 @@ func (s *Session) routine(c chan string) {
 }
 
 func (s *Session) GetPos() (x, y, z int) {
-	return configuration.GlobalFullConfig().Main().Position.X, configuration.GlobalFullConfig().Main().Position.Y, configuration.GlobalFullConfig().Main().Position.Z
+	mConf := s.configuration.FullConfig.Main()
+	return mConf.Position.X, mConf.Position.Y, mConf.Position.Z
 }
 
 func (s *Session) GetEndPos() (x, y, z int) {
-	return configuration.GlobalFullConfig().Main().End.X, configuration.GlobalFullConfig().Main().End.Y, configuration.GlobalFullConfig().Main().End.Z
+	mConf := s.configuration.FullConfig.Main()
+	return mConf.End.X, mConf.End.Y, mConf.End.Z
 }
 
 func (s *Session) sendCommand(commands string, UUID uuid.UUID) error {
-	return command.SendCommand(commands, UUID, s.mcConn)
+	return command.SendCommand(commands, UUID, s.conn())
 }
 
 func (s *Session) tellraw(lines ...string) error {
-	command.AdditionalChatCb(lines[0])
-	return command.Tellraw(s.mcConn, lines[0])
+	return command.Tellraw(s.conn(), lines[0])
+}
+
+// conn returns the current connection of the session
+func (s *Session) conn() *minecraft.Conn {
+	s.connMu.RLock()
+	defer s.connMu.RUnlock()
+	return s.mcConn
+}
+
+// print shows a message to the user of this session only,
+// it doesn't send anything to the server
+func (s *Session) print(str string) {
+	bridge_fmt.Print(str)
+	s.ChatCbFn(str)
 }
 
 func (s *Session) close() {
-	isStart = false
-	for _, fn := range s.closeFns {
-		fn()
+	s.closeOnce.Do(func() {
+		for _, fn := range s.closeFns {
+			fn()
+		}
+		if s.fbClinet != nil {
+			s.fbClinet.Close()
+		}
+		// let GC do the work
+		s.fbClinet = nil
+	})
+}
+
+// Configuration returns the build settings of this session,
+// changing them doesn't affect other sessions
+func (s *Session) Configuration() *configuration.SessionConfiguration {
+	return s.configuration
+}
+
+// SnapshotConfig returns a copy of the current build settings
+func (s *Session) SnapshotConfig() *configuration.FullConfig {
+	return s.configuration.Snapshot()
+}
+
+// RestoreConfig replaces the build settings with a snapshot
+// taken by SnapshotConfig
+func (s *Session) RestoreConfig(snapshot *configuration.FullConfig) {
+	s.configuration.Restore(snapshot)
+}
+
+// TaskHolder returns the tasks of this session,
+// it is nil before the session is started
+func (s *Session) TaskHolder() *fbtask.TaskHolder {
+	if s.env == nil {
+		return nil
 	}
-	// let GC do the work
-	s.fbClinet = nil
-	s.mcConn = nil
+	return fbtask.GetTaskHolder(s.env)
 }
 
 func (s *Session) Execute(cmd string) {
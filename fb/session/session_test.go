@@ -0,0 +1,893 @@
+package session
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"phoenixbuilder_3rd_gui/fb/dragonfly/server/world/chunk"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/noise"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/structure"
+	fbtask "phoenixbuilder_3rd_gui/fb/fastbuilder/task"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/types"
+	"phoenixbuilder_3rd_gui/fb/fastbuilder/world_provider"
+	"phoenixbuilder_3rd_gui/fb/session/mockserver"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"fyne.io/fyne/v2/test"
+)
+
+// startSession starts a session connected to a new mock server
+func startSession(t *testing.T) (*Session, *mockserver.Server) {
+	server, err := mockserver.Start()
+	if err != nil {
+		t.Fatalf("error starting mock server: %v", err)
+	}
+	config := NewConfig()
+	config.Lang = "en_US"
+	s := NewSession(config)
+	s.DialFn = server.Dial
+	terminateChan, err := s.Start()
+	if err != nil {
+		server.Close()
+		t.Fatalf("error starting session: %v", err)
+	}
+	t.Cleanup(func() {
+		s.Stop()
+		select {
+		case <-terminateChan:
+		case <-time.After(5 * time.Second):
+		}
+		server.Close()
+	})
+	return s, server
+}
+
+func TestSessionFunctions(t *testing.T) {
+	s, server := startSession(t)
+	s.Execute("set 10 70 -3")
+	if !server.WaitMessage("10, 70, -3", 5*time.Second) {
+		t.Fatalf("set didn't respond, messages: %v", server.Messages())
+	}
+	if x, y, z := s.GetPos(); x != 10 || y != 70 || z != -3 {
+		t.Fatalf("position was not set: %d %d %d", x, y, z)
+	}
+	s.Execute("get")
+	if !server.WaitMessage("Position got", 5*time.Second) {
+		t.Fatalf("get didn't respond, messages: %v", server.Messages())
+	}
+	if x, y, z := s.GetPos(); x != 0 || y != 64 || z != 0 {
+		t.Fatalf("position was not got from the server: %d %d %d", x, y, z)
+	}
+}
+
+func TestSessionLocalAuthBackend(t *testing.T) {
+	server, err := mockserver.Start()
+	if err != nil {
+		t.Fatalf("error starting mock server: %v", err)
+	}
+	defer server.Close()
+	config := NewConfig()
+	config.Lang = "en_US"
+	config.AuthBackend = AuthBackendLocal
+	config.LocalServerAddress = server.Addr()
+	s := NewSession(config)
+	if _, err := s.Start(); err != nil {
+		t.Fatalf("error starting session with the local backend: %v", err)
+	}
+	s.Stop()
+	if config.FBToken != "" {
+		t.Fatalf("token was set by the local backend: %s", config.FBToken)
+	}
+
+	config = NewConfig()
+	config.AuthBackend = AuthBackendLocal
+	if _, err := NewSession(config).Start(); err == nil {
+		t.Fatalf("session started without a server address")
+	}
+}
+
+func TestSessionBuildAndExport(t *testing.T) {
+	s, server := startSession(t)
+	s.Execute("set 0 64 0")
+	s.Execute("setend 4 64 4")
+	s.Execute("round -r 2 -f y -h 1 -b stone -d 1")
+	if !server.WaitMessage("block(s) have been changed", 10*time.Second) {
+		t.Fatalf("task didn't finish, messages: %v", server.Messages())
+	}
+	if name, data := server.World.Block(0, 64, 0); name != "stone" || data != 1 {
+		t.Fatalf("block was not placed: %s %d", name, data)
+	}
+
+	// chunks sent for the teleports of the task must be handled by the
+	// session before exporting, otherwise they are taken as the cache
+	s.Execute("get")
+	if !server.WaitMessage("Position got", 5*time.Second) {
+		t.Fatalf("get didn't respond, messages: %v", server.Messages())
+	}
+
+	// files are written through the storage of fyne
+	test.NewApp()
+	path := filepath.Join(t.TempDir(), "round.mcstructure")
+	s.Execute("set -2 64 -2")
+	s.Execute("setend 2 64 2")
+	s.Execute("export -p file://" + path)
+	if !server.WaitMessage("Successfully exported", 20*time.Second) {
+		t.Fatalf("export didn't finish, messages: %v", server.Messages())
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("error opening exported file: %v", err)
+	}
+	defer file.Close()
+	exported, err := structure.ReadMCStructure(file)
+	if err != nil {
+		t.Fatalf("error reading exported file: %v", err)
+	}
+	if exported.Size != [3]int{5, 1, 5} {
+		t.Fatalf("unexpected size of exported structure: %v", exported.Size)
+	}
+	placed := 0
+	for x := -2; x <= 2; x++ {
+		for z := -2; z <= 2; z++ {
+			if name, _ := server.World.Block(x, 64, z); name != "air" {
+				placed++
+			}
+		}
+	}
+	if len(exported.Blocks) != placed {
+		t.Fatalf("exported %d blocks, %d were placed", len(exported.Blocks), placed)
+	}
+}
+
+func TestSessionPipeline(t *testing.T) {
+	s, server := startSession(t)
+	s.Execute("set 0 64 0")
+	s.Execute("round -r 2 -f y -h 1 -b stone | offset 3 1 -1 | replace -ob stone -b glass")
+	if !server.WaitMessage("block(s) have been changed", 10*time.Second) {
+		t.Fatalf("task didn't finish, messages: %v", server.Messages())
+	}
+	if name, _ := server.World.Block(3, 65, -1); name != "glass" {
+		t.Fatalf("block of the pipeline was not placed: %s", name)
+	}
+	if name, _ := server.World.Block(0, 64, 0); name != "air" {
+		t.Fatalf("block was placed without the offset: %s", name)
+	}
+
+	// clockwise seen from above, east turns to south
+	s.Execute("set 10 64 10")
+	s.Execute("setend 12 64 10")
+	s.Execute("fill -b stone | rotate -deg 90")
+	if !server.WaitMessage("[Task 2] 3 block(s) have been changed", 10*time.Second) {
+		t.Fatalf("rotated fill didn't finish, messages: %v", server.Messages())
+	}
+	if name, _ := server.World.Block(10, 64, 12); name != "stone" {
+		t.Fatalf("block was not rotated: %s", name)
+	}
+
+	s.Execute("round -r 2 | sphere -r 2")
+	if !server.WaitMessage("cannot be used after | in a pipeline", 5*time.Second) {
+		t.Fatalf("builder was accepted as a transform, messages: %v", server.Messages())
+	}
+}
+
+func TestSessionOrientStructure(t *testing.T) {
+	s, server := startSession(t)
+	test.NewApp()
+	var blocks []*types.RuntimeModule
+	for i, block := range []types.ConstBlock{{Name: "oak_stairs", Data: 0}, {Name: "stone", Data: 0}} {
+		runtimeId, found := structure.RuntimeIdOf(block.Name, block.Data)
+		if !found {
+			t.Fatalf("no runtime id of %s", block.Name)
+		}
+		blocks = append(blocks, &types.RuntimeModule{BlockRuntimeId: runtimeId, Point: types.Position{X: i}})
+	}
+	path := filepath.Join(t.TempDir(), "stairs.mcstructure")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("error creating structure file: %v", err)
+	}
+	if err := structure.WriteMCStructure(file, blocks, structure.Size(blocks), types.Position{}); err != nil {
+		t.Fatalf("error writing structure file: %v", err)
+	}
+	file.Close()
+
+	s.Execute("set 0 64 0")
+	s.Execute("mcstructure -p file://" + path + " -rotate 90")
+	if !server.WaitMessage("block(s) have been changed", 10*time.Second) {
+		t.Fatalf("task didn't finish, messages: %v", server.Messages())
+	}
+	// facing east is turned to south
+	if name, data := server.World.Block(0, 64, 0); name != "oak_stairs" || data != 2 {
+		t.Fatalf("stairs were not rotated: %s %d", name, data)
+	}
+	if name, _ := server.World.Block(0, 64, 1); name != "stone" {
+		t.Fatalf("stone was not rotated: %s", name)
+	}
+
+	s.Execute("set 20 64 0")
+	s.Execute("mcstructure -p file://" + path + " -mirror x -scale 2")
+	if !server.WaitMessage("[Task 2] 16 block(s) have been changed", 10*time.Second) {
+		t.Fatalf("task didn't finish, messages: %v", server.Messages())
+	}
+	if name, data := server.World.Block(21, 65, 1); name != "oak_stairs" || data != 1 {
+		t.Fatalf("stairs were not mirrored: %s %d", name, data)
+	}
+	if name, _ := server.World.Block(18, 64, 0); name != "stone" {
+		t.Fatalf("stone was not mirrored: %s", name)
+	}
+}
+
+func TestSessionRegionOperations(t *testing.T) {
+	s, server := startSession(t)
+	s.Execute("set 0 64 0")
+	s.Execute("setend 2 66 2")
+	s.Execute("hollow -b stone")
+	if !server.WaitMessage("[Task 1] 27 block(s) have been changed", 10*time.Second) {
+		t.Fatalf("hollow didn't finish, messages: %v", server.Messages())
+	}
+	if name, _ := server.World.Block(1, 65, 1); name != "air" {
+		t.Fatalf("inside of the box is not air: %s", name)
+	}
+	server.World.SetBlock(1, 64, 1, "stone", 1)
+	server.World.SetBlock(2, 64, 2, "glass", 0)
+	// chunks sent for the teleports of the task would be read as the world
+	s.Execute("get")
+	if !server.WaitMessage("Position got", 5*time.Second) {
+		t.Fatalf("get didn't respond, messages: %v", server.Messages())
+	}
+	s.Execute("set 0 64 0")
+
+	s.Execute("replace -ob stone -b planks")
+	if !server.WaitMessage("[Task 2] 24 block(s) have been changed", 20*time.Second) {
+		t.Fatalf("replace didn't finish, messages: %v", server.Messages())
+	}
+	if name, _ := server.World.Block(0, 64, 0); name != "planks" {
+		t.Fatalf("stone was not replaced: %s", name)
+	}
+	if name, data := server.World.Block(1, 64, 1); name != "stone" || data != 1 {
+		t.Fatalf("stone of other data was replaced: %s %d", name, data)
+	}
+
+	s.Execute("keep -b glass")
+	if !server.WaitMessage("[Task 3] 1 block(s) have been changed", 20*time.Second) {
+		t.Fatalf("keep didn't finish, messages: %v", server.Messages())
+	}
+	if name, _ := server.World.Block(1, 65, 1); name != "glass" {
+		t.Fatalf("air was not filled: %s", name)
+	}
+}
+
+func TestSessionPatternAndMask(t *testing.T) {
+	s, server := startSession(t)
+	s.Execute("set 0 64 0")
+	s.Execute("setend 3 64 3")
+	s.Execute("fill -b \"stone@1:1,glass:1\"")
+	if !server.WaitMessage("[Task 1] 16 block(s) have been changed", 10*time.Second) {
+		t.Fatalf("fill didn't finish, messages: %v", server.Messages())
+	}
+	glass := 0
+	for x := 0; x <= 3; x++ {
+		for z := 0; z <= 3; z++ {
+			name, data := server.World.Block(x, 64, z)
+			if name == "glass" {
+				glass++
+			} else if name != "stone" || data != 1 {
+				t.Fatalf("block not in the pattern was placed: %s %d", name, data)
+			}
+		}
+	}
+	if glass == 0 || glass == 16 {
+		t.Fatalf("only one block of the pattern was placed")
+	}
+	// chunks sent for the teleports of the task would be read as the world
+	s.Execute("get")
+	if !server.WaitMessage("Position got", 5*time.Second) {
+		t.Fatalf("get didn't respond, messages: %v", server.Messages())
+	}
+	s.Execute("set 0 64 0")
+
+	s.Execute("fill -b planks -mask glass")
+	if !server.WaitMessage(fmt.Sprintf("[Task 2] %d block(s) have been changed", glass), 20*time.Second) {
+		t.Fatalf("masked fill didn't finish, messages: %v", server.Messages())
+	}
+	for x := 0; x <= 3; x++ {
+		for z := 0; z <= 3; z++ {
+			if name, _ := server.World.Block(x, 64, z); name == "glass" {
+				t.Fatalf("glass was not replaced at %d %d", x, z)
+			}
+		}
+	}
+}
+
+func TestSessionFillOptimization(t *testing.T) {
+	s, server := startSession(t)
+	s.Execute("filloptimization true")
+	if !server.WaitMessage("Fill optimization set to: true", 5*time.Second) {
+		t.Fatalf("fill optimization was not set, messages: %v", server.Messages())
+	}
+	s.Execute("set 0 64 0")
+	s.Execute("setend 3 67 3")
+	s.Execute("hollow -b stone")
+	if !server.WaitMessage("[Task 1] 64 block(s) have been changed", 10*time.Second) {
+		t.Fatalf("hollow didn't finish, messages: %v", server.Messages())
+	}
+	if !server.WaitMessage("fill command(s)", time.Second) {
+		t.Fatalf("no statistics of fill commands, messages: %v", server.Messages())
+	}
+	fills, setblocks := 0, 0
+	for _, commandLine := range server.Commands() {
+		if strings.HasPrefix(commandLine, "fill ") {
+			fills++
+		} else if strings.HasPrefix(commandLine, "setblock ") {
+			setblocks++
+		}
+	}
+	if fills == 0 || fills+setblocks >= 64 {
+		t.Fatalf("blocks were not merged, %d fill and %d setblock command(s)", fills, setblocks)
+	}
+	for x := 0; x <= 3; x++ {
+		for y := 64; y <= 67; y++ {
+			for z := 0; z <= 3; z++ {
+				want := "stone"
+				if x > 0 && x < 3 && y > 64 && y < 67 && z > 0 && z < 3 {
+					want = "air"
+				}
+				if name, _ := server.World.Block(x, y, z); name != want {
+					t.Fatalf("%s at %d %d %d, want %s", name, x, y, z, want)
+				}
+			}
+		}
+	}
+}
+
+func TestSessionChunkOrder(t *testing.T) {
+	s, server := startSession(t)
+	s.Execute("set 0 64 0")
+	s.Execute("setend 31 65 3")
+	s.Execute("fill -b \"sand:1,stone:1\"")
+	if !server.WaitMessage("[Task 1] 256 block(s) have been changed", 10*time.Second) {
+		t.Fatalf("fill didn't finish, messages: %v", server.Messages())
+	}
+	teleports, lastStone, firstSand := 0, -1, -1
+	for i, commandLine := range server.Commands() {
+		if strings.HasPrefix(commandLine, "tp ") {
+			teleports++
+		} else if strings.HasPrefix(commandLine, "setblock ") {
+			if strings.Contains(commandLine, " stone ") {
+				lastStone = i
+			} else if strings.Contains(commandLine, " sand ") && firstSand < 0 {
+				firstSand = i
+			}
+		}
+	}
+	if lastStone < 0 || firstSand < 0 || firstSand < lastStone {
+		t.Fatalf("sand was built before stone")
+	}
+	// a pass for the solid blocks and one for the sand over 2 chunks
+	if teleports > 4 {
+		t.Fatalf("teleported %d times", teleports)
+	}
+}
+
+func TestSessionVerify(t *testing.T) {
+	s, server := startSession(t)
+	s.Execute("set 0 64 0")
+	s.Execute("setend 3 64 3")
+	server.LoseSetblocks(3)
+	s.Execute("fill -b stone -verify 2")
+	if !server.WaitMessage("[Task 1] 3 block(s) are wrong (stone x3), building them again (1/2).", 20*time.Second) {
+		t.Fatalf("lost blocks were not found, messages: %v", server.Messages())
+	}
+	if !server.WaitMessage("[Task 1] Verified, all blocks are right.", 20*time.Second) {
+		t.Fatalf("lost blocks were not built again, messages: %v", server.Messages())
+	}
+	if !server.WaitMessage("[Task 1] 19 block(s) have been changed", 10*time.Second) {
+		t.Fatalf("task didn't finish, messages: %v", server.Messages())
+	}
+	for x := 0; x <= 3; x++ {
+		for z := 0; z <= 3; z++ {
+			if name, _ := server.World.Block(x, 64, z); name != "stone" {
+				t.Fatalf("%s at %d 64 %d", name, x, z)
+			}
+		}
+	}
+}
+
+func TestSessionAdaptiveDelay(t *testing.T) {
+	s, server := startSession(t)
+	s.Execute("delay mode set adaptive")
+	if !server.WaitMessage("Delay mode set: adaptive", 5*time.Second) {
+		t.Fatalf("delay mode was not set, messages: %v", server.Messages())
+	}
+	s.Execute("set 0 64 0")
+	s.Execute("setend 7 64 7")
+	s.Execute("fill -b stone")
+	if !server.WaitMessage("[Task 1] 64 block(s) have been changed", 20*time.Second) {
+		t.Fatalf("fill didn't finish, messages: %v", server.Messages())
+	}
+	for x := 0; x <= 7; x++ {
+		for z := 0; z <= 7; z++ {
+			if name, _ := server.World.Block(x, 64, z); name != "stone" {
+				t.Fatalf("%s at %d 64 %d", name, x, z)
+			}
+		}
+	}
+}
+
+func TestSessionTaskQueue(t *testing.T) {
+	s, server := startSession(t)
+	s.Execute("task concurrency 1")
+	if !server.WaitMessage("At most 1 task(s) run at once now", 5*time.Second) {
+		t.Fatalf("concurrency was not set, messages: %v", server.Messages())
+	}
+	s.Execute("set 0 64 0")
+	s.Execute("setend 31 64 31")
+	s.Execute("fill -b stone")
+	s.Execute("set 0 66 0")
+	s.Execute("setend 1 66 1")
+	s.Execute("fill -b dirt")
+	s.Execute("fill -b glass -priority 5")
+	// started after task 2 even though its priority is the highest
+	s.Execute("fill -b wool -priority 9 -after 2")
+	if !server.WaitMessage("[Task 4] 4 block(s) have been changed", 30*time.Second) {
+		t.Fatalf("queued tasks didn't finish, messages: %v", server.Messages())
+	}
+	finished := make(map[int]int)
+	for i, message := range server.Messages() {
+		var taskId, changed int
+		if j := strings.Index(message, "[Task"); j >= 0 {
+			if _, err := fmt.Sscanf(message[j:], "[Task %d] %d block(s) have been changed.", &taskId, &changed); err == nil {
+				finished[taskId] = i
+			}
+		}
+	}
+	if len(finished) != 4 || !(finished[1] < finished[3] && finished[3] < finished[2] && finished[2] < finished[4]) {
+		t.Fatalf("tasks finished in the wrong order: %v", finished)
+	}
+	if name, _ := server.World.Block(0, 66, 0); name != "wool" {
+		t.Fatalf("%s at 0 66 0, want wool", name)
+	}
+}
+
+func TestSessionShapes(t *testing.T) {
+	s, server := startSession(t)
+	s.Execute("set 0 64 0")
+	s.Execute("setend 5 66 0")
+	for i, c := range []struct {
+		command string
+		blocks  int
+	}{
+		// 9 blocks a layer
+		{"cylinder -r 2 -h 3 -b stone", 27},
+		{"pyramid -r 2 -h 3 -b stone", 25 + 9 + 1},
+		// a turn as wide as the radius covers the circle once
+		{"helix -r 3 -h 8 -l 8 -w 3 -b stone", 25},
+		{"line -b stone", 6},
+		// a tube open at both ends, 9 of the 25 blocks of a layer are inside
+		{"cylinder -r 3 -h 2 -s hollow -b stone", 2 * (25 - 9)},
+	} {
+		s.Execute(c.command)
+		message := fmt.Sprintf("[Task %d] %d block(s) have been changed", i+1, c.blocks)
+		if !server.WaitMessage(message, 10*time.Second) {
+			t.Fatalf("%s: %q not found, messages: %v", c.command, message, server.Messages())
+		}
+	}
+	if name, _ := server.World.Block(3, 65, 0); name != "stone" {
+		t.Fatalf("%s at 3 65 0 on the line", name)
+	}
+}
+
+func TestSessionCurve(t *testing.T) {
+	s, server := startSession(t)
+	s.Execute("curve -curve polyline -point 0,64,0 -point 10,64,0 -w 3 -b stone")
+	if !server.WaitMessage("[Task 1] 33 block(s) have been changed", 10*time.Second) {
+		t.Fatalf("polyline didn't finish, messages: %v", server.Messages())
+	}
+	if name, _ := server.World.Block(5, 64, -1); name != "stone" {
+		t.Fatalf("%s at 5 64 -1, the road should be 3 blocks wide", name)
+	}
+
+	// the points of the file are relative to the position
+	test.NewApp()
+	path := filepath.Join(t.TempDir(), "points.txt")
+	if err := os.WriteFile(path, []byte("# a bend\n0 0 0\n8,2,8\n\n16 0 0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s.Execute("set 0 70 20")
+	s.Execute("curve -p file://" + path + " -b glass")
+	if !server.WaitMessage("[Task 2]", 10*time.Second) || !server.WaitMessage("[Task 2] Time used", 10*time.Second) {
+		t.Fatalf("curve didn't finish, messages: %v", server.Messages())
+	}
+	// catmull-rom curves go through all the points
+	for _, point := range [][3]int{{0, 70, 20}, {8, 72, 28}, {16, 70, 20}} {
+		if name, _ := server.World.Block(point[0], point[1], point[2]); name != "glass" {
+			t.Fatalf("%s at %v", name, point)
+		}
+	}
+}
+
+// cubeFaces are the triangles of the unit cube, by the corners of it
+var cubeFaces = [][3]int{
+	{0, 1, 3}, {0, 3, 2}, {4, 6, 7}, {4, 7, 5}, {0, 4, 5}, {0, 5, 1},
+	{2, 3, 7}, {2, 7, 6}, {0, 2, 6}, {0, 6, 4}, {1, 5, 7}, {1, 7, 3},
+}
+
+func TestSessionModel(t *testing.T) {
+	s, server := startSession(t)
+	test.NewApp()
+	dir := t.TempDir()
+	corner := func(i int) string {
+		return fmt.Sprintf("%d %d %d", i>>2&1, i>>1&1, i&1)
+	}
+	stl := "solid cube\n"
+	for _, face := range cubeFaces {
+		stl += "facet normal 0 0 0\nouter loop\n"
+		for _, i := range face {
+			stl += "vertex " + corner(i) + "\n"
+		}
+		stl += "endloop\nendfacet\n"
+	}
+	stl += "endsolid cube\n"
+	if err := os.WriteFile(filepath.Join(dir, "cube.stl"), []byte(stl), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s.Execute("set 0 64 0")
+	s.Execute("model -p file://" + filepath.Join(dir, "cube.stl") + " -h 5 -b stone")
+	if !server.WaitMessage("[Task 1] 125 block(s) have been changed", 10*time.Second) {
+		t.Fatalf("solid model didn't finish, messages: %v", server.Messages())
+	}
+	if name, _ := server.World.Block(2, 66, 2); name != "stone" {
+		t.Fatalf("%s at the center of the cube", name)
+	}
+
+	// the colors of the materials choose the blocks
+	obj := "mtllib cube.mtl\nusemtl red\n"
+	for i := 0; i < 8; i++ {
+		obj += "v " + corner(i) + "\n"
+	}
+	for _, face := range cubeFaces {
+		obj += fmt.Sprintf("f %d %d %d\n", face[0]+1, face[1]+1, face[2]+1)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "cube.obj"), []byte(obj), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "cube.mtl"), []byte("newmtl red\nKd 0.6 0.2 0.2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s.Execute("set 0 64 20")
+	s.Execute("model -p file://" + filepath.Join(dir, "cube.obj") + " -h 5 -s hollow -b stone")
+	if !server.WaitMessage("[Task 2] 98 block(s) have been changed", 10*time.Second) {
+		t.Fatalf("hollow model didn't finish, messages: %v", server.Messages())
+	}
+	if name, _ := server.World.Block(0, 64, 20); name == "stone" || name == "air" {
+		t.Fatalf("%s at the corner of the red cube", name)
+	}
+}
+
+func TestSessionTerrain(t *testing.T) {
+	s, server := startSession(t)
+	test.NewApp()
+	heightmap := image.NewGray(image.Rect(0, 0, 4, 1))
+	for x, gray := range []uint8{0, 85, 170, 255} {
+		heightmap.SetGray(x, 0, color.Gray{Y: gray})
+	}
+	path := filepath.Join(t.TempDir(), "heightmap.png")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(file, heightmap); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	s.Execute("set 0 64 0")
+	s.Execute("setend 3 64 0")
+	s.Execute("terrain -p file://" + path + " -h 4 -soil_depth 1 -water_level 2 -b stone")
+	if !server.WaitMessage("[Task 1] 13 block(s) have been changed", 10*time.Second) {
+		t.Fatalf("terrain didn't finish, messages: %v", server.Messages())
+	}
+	for point, want := range map[[3]int]string{
+		{0, 64, 0}: "dirt", {0, 66, 0}: "water", {1, 64, 0}: "dirt", {1, 65, 0}: "dirt",
+		{2, 64, 0}: "stone", {2, 65, 0}: "dirt", {2, 66, 0}: "grass",
+		{3, 65, 0}: "stone", {3, 66, 0}: "dirt", {3, 67, 0}: "grass",
+	} {
+		if name, _ := server.World.Block(point[0], point[1], point[2]); name != want {
+			t.Fatalf("%s at %v, it should be %s", name, point, want)
+		}
+	}
+}
+
+func TestSessionLandscape(t *testing.T) {
+	s, server := startSession(t)
+	s.Execute("set 0 0 0")
+	s.Execute("setend 15 0 15")
+	s.Execute("landscape -seed 7 -h 24 -noise_size 32 -water_level 8 -trees 60 -b stone")
+	if !server.WaitMessage("[Task 1] Time used", 20*time.Second) {
+		t.Fatalf("landscape didn't finish, messages: %v", server.Messages())
+	}
+	// the generator of offline worlds makes the same chunk
+	terrain := noise.NewTerrain(7)
+	terrain.Size = 32
+	terrain.Height = 24
+	terrain.WaterLevel = 8
+	terrain.Trees = 0.6
+	c := chunk.New(world_provider.AirRuntimeId)
+	(&world_provider.NoiseGenerator{Terrain: terrain}).GenerateChunk([2]int32{0, 0}, c)
+	found := map[string]bool{}
+	for x := 0; x < 16; x++ {
+		for z := 0; z < 16; z++ {
+			for y := 0; y < 40; y++ {
+				want := world_provider.RuntimeIdArray_117[c.RuntimeID(uint8(x), int16(y), uint8(z), 0)].Name
+				if name, _ := server.World.Block(x, y, z); name != want {
+					t.Fatalf("%s at %d %d %d, the generator made %s", name, x, y, z, want)
+				}
+				found[want] = true
+			}
+		}
+	}
+	for _, name := range []string{"stone", "water", "log", "leaves"} {
+		if !found[name] {
+			t.Fatalf("no %s in the landscape", name)
+		}
+	}
+}
+
+// changedBlocks returns the number of blocks in the summary of the task
+func changedBlocks(server *mockserver.Server) int {
+	var taskId, changed int
+	for _, message := range server.Messages() {
+		// messages are prefixed with the time
+		if i := strings.Index(message, "[Task"); i < 0 {
+			continue
+		} else if _, err := fmt.Sscanf(message[i:], "[Task %d] %d block(s) have been changed.", &taskId, &changed); err == nil {
+			break
+		}
+	}
+	return changed
+}
+
+func TestSessionReconnect(t *testing.T) {
+	s, server := startSession(t)
+	reconnected := make(chan struct{}, 1)
+	s.ReconnectCbFn = func(attempt int) {
+		if attempt == 0 {
+			reconnected <- struct{}{}
+		}
+	}
+	s.Execute("delay set 5000")
+	s.Execute("set 0 64 0")
+	s.Execute("round -r 10 -f y -h 1 -b stone")
+	time.Sleep(500 * time.Millisecond)
+	server.Disconnect()
+	select {
+	case <-reconnected:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("session didn't reconnect")
+	}
+	if !server.WaitMessage("block(s) have been changed", 30*time.Second) {
+		t.Fatalf("task didn't finish after reconnecting, messages: %v", server.Messages())
+	}
+	if !server.WaitMessage("resuming from block", time.Second) {
+		t.Fatalf("task wasn't resumed, messages: %v", server.Messages())
+	}
+	changed := changedBlocks(server)
+	placed := 0
+	for x := -10; x <= 10; x++ {
+		for z := -10; z <= 10; z++ {
+			if name, _ := server.World.Block(x, 64, z); name == "stone" {
+				placed++
+			}
+		}
+	}
+	if changed == 0 || placed != changed {
+		t.Fatalf("%d blocks were placed, the task changed %d", placed, changed)
+	}
+}
+
+// memoryCheckpointStore keeps checkpoints in memory instead of the
+// storage of the app
+type memoryCheckpointStore struct {
+	mu          sync.Mutex
+	checkpoints map[int64]fbtask.Checkpoint
+}
+
+func (store *memoryCheckpointStore) Save(checkpoint *fbtask.Checkpoint) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	store.checkpoints[checkpoint.Id] = *checkpoint
+	return nil
+}
+
+func (store *memoryCheckpointStore) Load(id int64) (*fbtask.Checkpoint, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	checkpoint, ok := store.checkpoints[id]
+	if !ok {
+		return nil, fmt.Errorf("checkpoint %d not found", id)
+	}
+	return &checkpoint, nil
+}
+
+func (store *memoryCheckpointStore) Remove(id int64) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	delete(store.checkpoints, id)
+	return nil
+}
+
+func (store *memoryCheckpointStore) List() ([]*fbtask.Checkpoint, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	var checkpoints []*fbtask.Checkpoint
+	for _, checkpoint := range store.checkpoints {
+		checkpoint := checkpoint
+		checkpoints = append(checkpoints, &checkpoint)
+	}
+	return checkpoints, nil
+}
+
+func TestSessionResumeCheckpoint(t *testing.T) {
+	server, err := mockserver.Start()
+	if err != nil {
+		t.Fatalf("error starting mock server: %v", err)
+	}
+	defer server.Close()
+	store := &memoryCheckpointStore{checkpoints: map[int64]fbtask.Checkpoint{}}
+	config := NewConfig()
+	config.Lang = "en_US"
+	s := NewSession(config)
+	s.DialFn = server.Dial
+	s.CheckpointStore = store
+	terminateChan, err := s.Start()
+	if err != nil {
+		t.Fatalf("error starting session: %v", err)
+	}
+	s.Execute("delay set 5000")
+	s.Execute("set 0 64 0")
+	s.Execute("round -r 10 -f y -h 1 -b stone")
+	time.Sleep(time.Second)
+	s.Stop()
+	select {
+	case <-terminateChan:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("session didn't stop")
+	}
+
+	// the progress is saved when the task is stopped with the session
+	var checkpoint *fbtask.Checkpoint
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		checkpoints, _ := store.List()
+		if len(checkpoints) == 1 && checkpoints[0].BlockIndex > 0 {
+			checkpoint = checkpoints[0]
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if checkpoint == nil {
+		t.Fatalf("checkpoint was not saved: %v", store.checkpoints)
+	}
+
+	s, server = startSession(t)
+	s.CheckpointStore = store
+	s.TaskHolder().Checkpoints = store
+	journals := &memoryJournalStore{journals: map[int64]fbtask.Journal{}}
+	s.TaskHolder().Journals = journals
+	sent := len(server.Commands())
+	s.Execute(fmt.Sprintf("task resume %d", checkpoint.Id))
+	if !server.WaitMessage("block(s) have been changed", 30*time.Second) {
+		t.Fatalf("task didn't finish after resuming, messages: %v", server.Messages())
+	}
+	if !server.WaitMessage("Resumed from checkpoint", time.Second) {
+		t.Fatalf("task wasn't resumed from the checkpoint, messages: %v", server.Messages())
+	}
+	setblocks := 0
+	for _, command := range server.Commands()[sent:] {
+		if strings.Contains(command, "setblock") {
+			setblocks++
+		}
+	}
+	if changed := changedBlocks(server); setblocks+checkpoint.BlockIndex != changed {
+		t.Fatalf("%d blocks were sent after resuming from block %d, the task changed %d", setblocks, checkpoint.BlockIndex, changed)
+	}
+	// the blocks built before resuming are not undone by the resumed task
+	if journal, err := journals.Load(1); err != nil || len(journal.Before) != setblocks {
+		t.Fatalf("the journal of the resumed task is %v, %v, it should have %d blocks", journal, err, setblocks)
+	}
+	if checkpoints, _ := store.List(); len(checkpoints) != 0 {
+		t.Fatalf("checkpoint was not removed after the task is done")
+	}
+}
+
+type memoryJournalStore struct {
+	mu       sync.Mutex
+	journals map[int64]fbtask.Journal
+}
+
+func (store *memoryJournalStore) Save(journal *fbtask.Journal) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	store.journals[journal.TaskId] = *journal
+	return nil
+}
+
+func (store *memoryJournalStore) Load(taskId int64) (*fbtask.Journal, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	journal, ok := store.journals[taskId]
+	if !ok {
+		return nil, fmt.Errorf("task %d has no journal", taskId)
+	}
+	return &journal, nil
+}
+
+func (store *memoryJournalStore) List() ([]int64, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	var ids []int64
+	for id := range store.journals {
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func (store *memoryJournalStore) Clear() error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	store.journals = map[int64]fbtask.Journal{}
+	return nil
+}
+
+func TestSessionUndoRedo(t *testing.T) {
+	s, server := startSession(t)
+	s.TaskHolder().Journals = &memoryJournalStore{journals: map[int64]fbtask.Journal{}}
+	server.World.SetBlock(1, 64, 1, "glass", 0)
+	s.Execute("set 0 64 0")
+	s.Execute("setend 2 64 2")
+	s.Execute("fill -b stone")
+	if !server.WaitMessage("[Task 1] 9 block(s) have been changed", 20*time.Second) {
+		t.Fatalf("fill didn't finish, messages: %v", server.Messages())
+	}
+	if name, _ := server.World.Block(1, 64, 1); name != "stone" {
+		t.Fatalf("glass was not replaced: %s", name)
+	}
+
+	s.Execute("task undo 1")
+	if !server.WaitMessage("[Task 2] 9 block(s) have been changed", 20*time.Second) {
+		t.Fatalf("undo didn't finish, messages: %v", server.Messages())
+	}
+	if name, _ := server.World.Block(1, 64, 1); name != "glass" {
+		t.Fatalf("glass was not restored: %s", name)
+	}
+	if name, _ := server.World.Block(0, 64, 0); name != "air" {
+		t.Fatalf("air was not restored: %s", name)
+	}
+	s.Execute("task undo 1")
+	if !server.WaitMessage("task 1 is undone already", 5*time.Second) {
+		t.Fatalf("task was undone twice, messages: %v", server.Messages())
+	}
+
+	s.Execute("task redo 1")
+	if !server.WaitMessage("[Task 3] 9 block(s) have been changed", 20*time.Second) {
+		t.Fatalf("redo didn't finish, messages: %v", server.Messages())
+	}
+	if name, _ := server.World.Block(1, 64, 1); name != "stone" {
+		t.Fatalf("stone was not built again: %s", name)
+	}
+
+	s.Execute("tasktype sync")
+	s.Execute("fill -b glass")
+	if !server.WaitMessage("[Task 4] 9 block(s) have been changed", 20*time.Second) {
+		t.Fatalf("sync fill didn't finish, messages: %v", server.Messages())
+	}
+	s.Execute("task undo 4")
+	if !server.WaitMessage("task 4 is a sync task, only async tasks can be undone", 5*time.Second) {
+		t.Fatalf("sync task was undone, messages: %v", server.Messages())
+	}
+}
@@ -10,6 +10,7 @@ require (
 	github.com/cespare/xxhash v1.1.0
 	github.com/disintegration/imaging v1.6.2
 	github.com/go-gl/mathgl v1.0.0
+	github.com/golang/snappy v0.0.1
 	github.com/google/uuid v1.1.2
 	github.com/gorilla/websocket v1.4.2
 	github.com/klauspost/compress v1.11.1
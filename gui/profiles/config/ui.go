@@ -2,6 +2,7 @@ package config
 
 import (
 	//"golang.design/x/clipboard"
+	fbauth "phoenixbuilder_3rd_gui/fb/fastbuilder/cv4/auth"
 	"phoenixbuilder_3rd_gui/fb/session"
 	"strings"
 
@@ -108,6 +109,31 @@ func (g *GUI) makeForm(config *SessionConfigWithName) fyne.CanvasObject {
 	worldChatEnable := widget.NewCheck("启用", func(b bool) { config.Config.MuteWorldChat = !b })
 	worldChatEnable.Checked = !config.Config.MuteWorldChat
 
+	reconnectEnable := widget.NewCheck("启用", func(b bool) { config.Config.DisableReconnect = !b })
+	reconnectEnable.Checked = !config.Config.DisableReconnect
+
+	authServerEntry := widget.NewEntryWithData(binding.BindString(&config.Config.AuthServer))
+	authServerEntry.PlaceHolder = fbauth.DefaultAuthServer
+	localServerEntry := widget.NewEntryWithData(binding.BindString(&config.Config.LocalServerAddress))
+	localServerEntry.PlaceHolder = "127.0.0.1:19132"
+	authBackendSelector := widget.NewRadioGroup([]string{"FastBuilder 验证服务器", "本地(不验证)"}, func(backend string) {
+		if backend == "本地(不验证)" {
+			config.Config.AuthBackend = session.AuthBackendLocal
+			authServerEntry.Disable()
+			localServerEntry.Enable()
+		} else {
+			config.Config.AuthBackend = session.AuthBackendFastBuilder
+			authServerEntry.Enable()
+			localServerEntry.Disable()
+		}
+	})
+	if config.Config.AuthBackend == session.AuthBackendLocal {
+		authBackendSelector.SetSelected("本地(不验证)")
+	} else {
+		authBackendSelector.SetSelected("FastBuilder 验证服务器")
+	}
+	authBackendSelector.Required = true
+
 	var developerOptions fyne.CanvasObject
 	if !config.Config.IsDeveloper() {
 		developerOptions = widget.NewLabel("你不是开发者，无法设置这些选项")
@@ -154,12 +180,22 @@ func (g *GUI) makeForm(config *SessionConfigWithName) fyne.CanvasObject {
 			),
 			Open: true,
 		},
+		&widget.AccordionItem{
+			Title: "验证服务器",
+			Detail: container.NewVBox(
+				authBackendSelector,
+				container.NewGridWithColumns(2, widget.NewLabel("验证服务器地址:"), authServerEntry),
+				container.NewGridWithColumns(2, widget.NewLabel("服务器地址(本地):"), localServerEntry),
+			),
+			Open: false,
+		},
 		&widget.AccordionItem{
 			Title: "其他选项",
 			Detail: container.NewVBox(
 				container.NewGridWithColumns(2, widget.NewLabel("语言:"), languageSelector),
 				container.NewGridWithColumns(2, widget.NewLabel("操作员:"), operatorEntry),
 				container.NewGridWithColumns(2, widget.NewLabel("世界聊天:"), worldChatEnable),
+				container.NewGridWithColumns(2, widget.NewLabel("断线重连:"), reconnectEnable),
 			),
 			Open: false,
 		},
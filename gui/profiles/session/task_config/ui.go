@@ -5,6 +5,7 @@ import (
 	"phoenixbuilder_3rd_gui/fb/fastbuilder/configuration"
 	"phoenixbuilder_3rd_gui/fb/fastbuilder/task"
 	"phoenixbuilder_3rd_gui/fb/fastbuilder/types"
+	bot_session "phoenixbuilder_3rd_gui/fb/session"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
@@ -18,13 +19,16 @@ type GUI struct {
 	getContent   func() fyne.CanvasObject
 	origContent  fyne.CanvasObject
 	masterWindow fyne.Window
+	botSession   *bot_session.Session
 
 	content      fyne.CanvasObject
 	majorContent fyne.CanvasObject
 }
 
-func New() *GUI {
-	gui := &GUI{}
+func New(botSession *bot_session.Session) *GUI {
+	gui := &GUI{
+		botSession: botSession,
+	}
 	return gui
 }
 
@@ -38,6 +42,7 @@ type DelaySetter interface {
 type GlobalDelaySetter struct {
 	mirrorDelayConfig  *types.DelayConfig
 	mirrorCreationType byte
+	sessionConfig      *configuration.SessionConfiguration
 }
 
 func (gds *GlobalDelaySetter) DelayConfigGetter() *types.DelayConfig {
@@ -54,18 +59,21 @@ func (gds *GlobalDelaySetter) CreationTypeSetter(b byte) {
 }
 
 func (gds *GlobalDelaySetter) Submit() bool {
-	configuration.GlobalFullConfig().Delay().Delay = gds.mirrorDelayConfig.Delay
-	configuration.GlobalFullConfig().Delay().DelayMode = gds.mirrorDelayConfig.DelayMode
-	configuration.GlobalFullConfig().Delay().DelayThreshold = gds.mirrorDelayConfig.DelayThreshold
-	configuration.GlobalFullConfig().Global().TaskCreationType = gds.mirrorCreationType
+	fullConfig := gds.sessionConfig.FullConfig
+	fullConfig.Delay().Delay = gds.mirrorDelayConfig.Delay
+	fullConfig.Delay().DelayMode = gds.mirrorDelayConfig.DelayMode
+	fullConfig.Delay().DelayThreshold = gds.mirrorDelayConfig.DelayThreshold
+	fullConfig.Delay().FillOptimization = gds.mirrorDelayConfig.FillOptimization
+	fullConfig.Global().TaskCreationType = gds.mirrorCreationType
 	return true
 }
 
-func makeGlobalDelaySetter() *GlobalDelaySetter {
-	_mirrorDelayConfig := *configuration.GlobalFullConfig().Delay()
+func makeGlobalDelaySetter(sessionConfig *configuration.SessionConfiguration) *GlobalDelaySetter {
+	_mirrorDelayConfig := *sessionConfig.FullConfig.Delay()
 	return &GlobalDelaySetter{
 		mirrorDelayConfig:  &_mirrorDelayConfig,
-		mirrorCreationType: configuration.GlobalFullConfig().Global().TaskCreationType,
+		mirrorCreationType: sessionConfig.FullConfig.Global().TaskCreationType,
+		sessionConfig:      sessionConfig,
 	}
 }
 
@@ -74,6 +82,7 @@ type TaskDelaySetter struct {
 	mirrorDelayConfig  *types.DelayConfig
 	mirrorCreationType byte
 	task               *task.Task
+	holder             *task.TaskHolder
 }
 
 func (tds *TaskDelaySetter) DelayConfigGetter() *types.DelayConfig {
@@ -90,7 +99,7 @@ func (tds *TaskDelaySetter) CreationTypeSetter(b byte) {
 }
 
 func (tds *TaskDelaySetter) Submit() bool {
-	v, ok := task.TaskMap.Load(tds.id)
+	v, ok := tds.holder.TaskMap.Load(tds.id)
 	if !ok {
 		return false
 	}
@@ -113,10 +122,14 @@ func (tds *TaskDelaySetter) Break() {
 	tds.task.Break()
 }
 
-func makeAllTasksSetter() []fyne.CanvasObject {
+func makeAllTasksSetter(holder *task.TaskHolder) []fyne.CanvasObject {
 	taskDelaySetters := make([]fyne.CanvasObject, 0)
-	task.TaskMap.Range(func(k, v interface{}) bool {
+	holder.TaskMap.Range(func(k, v interface{}) bool {
 		t := v.(*task.Task)
+		if t.State == task.TaskStateQueued {
+			// listed in the queue
+			return true
+		}
 		_mirrorDelayConfig := *(t.Config.Delay())
 		var content fyne.CanvasObject
 
@@ -125,6 +138,7 @@ func makeAllTasksSetter() []fyne.CanvasObject {
 			mirrorDelayConfig:  &_mirrorDelayConfig,
 			mirrorCreationType: t.Type,
 			task:               t,
+			holder:             holder,
 		}
 		delaySettingerGUI := MakeDelaySetterGUI(taskDelaySetter, false)
 		taskHandler := container.NewHBox(
@@ -160,8 +174,10 @@ type DelaySetterGUI struct {
 	typeContinuousContent fyne.CanvasObject
 	typeDiscreteContent   fyne.CanvasObject
 	typeNoneContent       fyne.CanvasObject
+	typeAdaptiveContent   fyne.CanvasObject
 	bindDelay             binding.ExternalInt
 	bindDelayThres        binding.ExternalInt
+	fillOptimizationCheck *widget.Check
 	submit                *widget.Button
 	content               fyne.CanvasObject
 	isGlobal              bool
@@ -172,6 +188,8 @@ const DescriptionAsync = "先算后建(推荐)"
 const DescriptionContinuous = "连续(每放置一个方块等待一会儿/推荐)"
 const DescriptionDiscrete = "离散(每放置几个方块等待一会儿)"
 const DescriptionNone = "极限速度"
+const DescriptionAdaptive = "自适应(根据服务器的响应自动调整速度)"
+const DescriptionFillOptimization = "合并相同的方块为 fill 指令(仅先算后建)"
 
 func (dsg *DelaySetterGUI) UpdateUI(firstOpen bool) {
 	taskType := dsg.ds.CreationTypeGetter()
@@ -193,6 +211,8 @@ func (dsg *DelaySetterGUI) UpdateUI(firstOpen bool) {
 		dsg.delayTypeRG.SetSelected(DescriptionDiscrete)
 	} else if DelayMode == types.DelayModeNone {
 		dsg.delayTypeRG.SetSelected(DescriptionNone)
+	} else if DelayMode == types.DelayModeAdaptive {
+		dsg.delayTypeRG.SetSelected(DescriptionAdaptive)
 	}
 	dsg.updateDelayContent(DelayMode, firstOpen)
 }
@@ -206,6 +226,7 @@ func (dsg *DelaySetterGUI) updateDelayContent(delayMode byte, firstOpen bool) {
 		dsg.typeContinuousContent.Show()
 		dsg.typeDiscreteContent.Hide()
 		dsg.typeNoneContent.Hide()
+		dsg.typeAdaptiveContent.Hide()
 	case types.DelayModeDiscrete:
 		if !firstOpen {
 			dsg.bindDelay.Set(15)
@@ -214,6 +235,7 @@ func (dsg *DelaySetterGUI) updateDelayContent(delayMode byte, firstOpen bool) {
 		dsg.typeContinuousContent.Hide()
 		dsg.typeDiscreteContent.Show()
 		dsg.typeNoneContent.Hide()
+		dsg.typeAdaptiveContent.Hide()
 	case types.DelayModeNone:
 		if firstOpen {
 			dsg.bindDelay.Set(0)
@@ -221,6 +243,16 @@ func (dsg *DelaySetterGUI) updateDelayContent(delayMode byte, firstOpen bool) {
 		dsg.typeContinuousContent.Hide()
 		dsg.typeDiscreteContent.Hide()
 		dsg.typeNoneContent.Show()
+		dsg.typeAdaptiveContent.Hide()
+	case types.DelayModeAdaptive:
+		if !firstOpen {
+			// where the delay starts from
+			dsg.bindDelay.Set(1000)
+		}
+		dsg.typeContinuousContent.Hide()
+		dsg.typeDiscreteContent.Hide()
+		dsg.typeNoneContent.Hide()
+		dsg.typeAdaptiveContent.Show()
 	}
 }
 
@@ -236,12 +268,16 @@ func MakeDelaySetterGUI(ds DelaySetter, isGlobal bool) *DelaySetterGUI {
 	}
 	dsg.delayTypeRG = &widget.RadioGroup{
 		Horizontal: false,
-		Options:    []string{DescriptionContinuous, DescriptionDiscrete, DescriptionNone},
+		Options:    []string{DescriptionContinuous, DescriptionDiscrete, DescriptionNone, DescriptionAdaptive},
 		Required:   true,
 	}
 	dsg.typeNoneContent = container.NewHBox(
 		widget.NewLabelWithStyle("极限速度/不稳定/极其快", fyne.TextAlignCenter, fyne.TextStyle{Bold: true}),
 	)
+	dsg.typeAdaptiveContent = container.NewVBox(
+		widget.NewLabelWithStyle("自动调整/稳定/较快", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		widget.NewLabel("服务器响应变慢或纠正机器人位置时放慢, 否则逐渐加快"),
+	)
 	Delay := int(dsg.ds.DelayConfigGetter().Delay)
 	bDelay := binding.BindInt(&Delay)
 	dsg.bindDelay = bDelay
@@ -259,6 +295,12 @@ func MakeDelaySetterGUI(ds DelaySetter, isGlobal bool) *DelaySetterGUI {
 		container.NewBorder(nil, nil, widget.NewLabel("每放置"), widget.NewLabel("个方块"), delayDelayThresEntry),
 		container.NewBorder(nil, nil, widget.NewLabel("就等待"), widget.NewLabel("秒"), widget.NewEntryWithData(binding.IntToString(bDelay))),
 	)
+	dsg.fillOptimizationCheck = widget.NewCheck(DescriptionFillOptimization, func(b bool) {
+		currentDelay := dsg.ds.DelayConfigGetter()
+		currentDelay.FillOptimization = b
+		dsg.ds.DelayConfigSetter(currentDelay)
+	})
+	dsg.fillOptimizationCheck.SetChecked(dsg.ds.DelayConfigGetter().FillOptimization)
 	dsg.submit = &widget.Button{
 		Text: "设置",
 		OnTapped: func() {
@@ -297,6 +339,8 @@ func MakeDelaySetterGUI(ds DelaySetter, isGlobal bool) *DelaySetterGUI {
 	} else {
 		dsg.taskTypeRG.Hide()
 		dsg.taskTypeRG.Disable()
+		// the blocks of the task are merged when it's created
+		dsg.fillOptimizationCheck.Disable()
 	}
 	dsg.delayTypeRG.OnChanged = func(s string) {
 		delayMode := byte(types.DelayModeInvalid)
@@ -306,6 +350,8 @@ func MakeDelaySetterGUI(ds DelaySetter, isGlobal bool) *DelaySetterGUI {
 			delayMode = types.DelayModeDiscrete
 		} else if s == DescriptionNone {
 			delayMode = types.DelayModeNone
+		} else if s == DescriptionAdaptive {
+			delayMode = types.DelayModeAdaptive
 		}
 		dsg.updateDelayContent(byte(delayMode), false)
 		currentDelay := dsg.ds.DelayConfigGetter()
@@ -321,16 +367,149 @@ func MakeDelaySetterGUI(ds DelaySetter, isGlobal bool) *DelaySetterGUI {
 		dsg.typeNoneContent,
 		dsg.typeContinuousContent,
 		dsg.typeDiscreteContent,
+		dsg.typeAdaptiveContent,
+		widget.NewSeparator(),
+		dsg.fillOptimizationCheck,
 		widget.NewSeparator(),
 		dsg.submit,
 	)
 	return dsg
 }
 
+// makeUnfinishedTasks lists the checkpoints of unfinished tasks,
+// they can be resumed or discarded
+func (g *GUI) makeUnfinishedTasks(holder *task.TaskHolder) fyne.CanvasObject {
+	if holder.Checkpoints == nil {
+		return widget.NewLabel("此会话不保存任务进度")
+	}
+	checkpoints, err := holder.Checkpoints.List()
+	if err != nil {
+		return widget.NewLabel(fmt.Sprintf("无法读取任务进度: %v", err))
+	}
+	if len(checkpoints) == 0 {
+		return widget.NewLabel("没有未完成的任务")
+	}
+	items := make([]fyne.CanvasObject, 0)
+	for _, checkpoint := range checkpoints {
+		checkpoint := checkpoint
+		var content fyne.CanvasObject
+		info := widget.NewLabel(fmt.Sprintf("%s\n已建造 %d 个方块, 保存于 %s",
+			checkpoint.CommandLine, checkpoint.BlockIndex, checkpoint.SavedAt.Format("2006-01-02 15:04:05")))
+		info.Wrapping = fyne.TextWrapWord
+		content = container.NewVBox(
+			info,
+			container.NewGridWithColumns(2,
+				widget.NewButtonWithIcon("继续", theme.MediaPlayIcon(), func() {
+					g.botSession.Execute(fmt.Sprintf("task resume %d", checkpoint.Id))
+					content.Hide()
+				}),
+				widget.NewButtonWithIcon("删除", theme.DeleteIcon(), func() {
+					holder.Checkpoints.Remove(checkpoint.Id)
+					content.Hide()
+				}),
+			),
+		)
+		items = append(items, content)
+	}
+	return container.NewVBox(items...)
+}
+
+// makeJournaledTasks lists the finished tasks that can be undone, the
+// blocks before them are built again by undo
+func (g *GUI) makeJournaledTasks(holder *task.TaskHolder) fyne.CanvasObject {
+	if holder.Journals == nil {
+		return widget.NewLabel("此会话不保存任务之前的方块")
+	}
+	ids, err := holder.Journals.List()
+	if err != nil {
+		return widget.NewLabel(fmt.Sprintf("无法读取任务记录: %v", err))
+	}
+	items := make([]fyne.CanvasObject, 0)
+	for _, id := range ids {
+		id := id
+		if holder.FindTask(id) != nil {
+			continue
+		}
+		items = append(items, container.NewHBox(
+			widget.NewLabel(fmt.Sprintf("Task-%v", id)),
+			container.NewGridWithColumns(2,
+				widget.NewButtonWithIcon("撤销", theme.ContentUndoIcon(), func() {
+					g.botSession.Execute(fmt.Sprintf("task undo %d", id))
+				}),
+				widget.NewButtonWithIcon("重做", theme.ContentRedoIcon(), func() {
+					g.botSession.Execute(fmt.Sprintf("task redo %d", id))
+				}),
+			),
+		))
+	}
+	if len(items) == 0 {
+		return widget.NewLabel("没有可以撤销的任务")
+	}
+	return container.NewVBox(items...)
+}
+
+// makeTaskQueue lists the tasks waiting to be started, they can be
+// moved in the queue or cancelled, and sets how many tasks run at once
+func (g *GUI) makeTaskQueue(holder *task.TaskHolder) fyne.CanvasObject {
+	global := g.botSession.Configuration().FullConfig.Global()
+	maxConcurrent := global.MaxConcurrentTasks
+	bMaxConcurrent := binding.BindInt(&maxConcurrent)
+	limit := container.NewBorder(nil, nil, widget.NewLabel("最多同时运行的任务数(0 为不限制):"), &widget.Button{
+		Text: "设置",
+		OnTapped: func() {
+			if v, err := bMaxConcurrent.Get(); err == nil && v >= 0 {
+				global.MaxConcurrentTasks = v
+				holder.Schedule()
+			}
+		},
+		Icon:          theme.ConfirmIcon(),
+		IconPlacement: widget.ButtonIconTrailingText,
+	}, widget.NewEntryWithData(binding.IntToString(bMaxConcurrent)))
+	list := container.NewVBox()
+	var refresh func()
+	refresh = func() {
+		queued := holder.Queued()
+		items := make([]fyne.CanvasObject, 0, len(queued))
+		for _, t := range queued {
+			t := t
+			desc := fmt.Sprintf("Task-%d: %s\n优先级 %d", t.TaskId, t.CommandLine, t.Priority)
+			if t.After != 0 {
+				desc += fmt.Sprintf(", 在 Task-%d 之后", t.After)
+			}
+			info := widget.NewLabel(desc)
+			info.Wrapping = fyne.TextWrapWord
+			items = append(items, container.NewVBox(
+				info,
+				container.NewGridWithColumns(3,
+					widget.NewButtonWithIcon("上移", theme.MoveUpIcon(), func() {
+						holder.MoveQueued(t.TaskId, true)
+						refresh()
+					}),
+					widget.NewButtonWithIcon("下移", theme.MoveDownIcon(), func() {
+						holder.MoveQueued(t.TaskId, false)
+						refresh()
+					}),
+					widget.NewButtonWithIcon("取消", theme.MediaStopIcon(), func() {
+						t.Break()
+						refresh()
+					}),
+				),
+			))
+		}
+		if len(items) == 0 {
+			items = append(items, widget.NewLabel("没有排队中的任务"))
+		}
+		list.Objects = items
+		list.Refresh()
+	}
+	refresh()
+	return container.NewVBox(limit, widget.NewSeparator(), list)
+}
+
 func (g *GUI) makeMajorContent() fyne.CanvasObject {
-	globalSetter := makeGlobalDelaySetter()
+	globalSetter := makeGlobalDelaySetter(g.botSession.Configuration())
 	globalSetterWidget := MakeDelaySetterGUI(globalSetter, true)
-	taskSetters := makeAllTasksSetter()
+	taskSetters := makeAllTasksSetter(g.botSession.TaskHolder())
 	var taskContent fyne.CanvasObject
 	if len(taskSetters) == 0 {
 		taskContent = widget.NewLabel("还没有运行中的任务")
@@ -345,6 +524,9 @@ func (g *GUI) makeMajorContent() fyne.CanvasObject {
 		// widget.NewSeparator(),
 		widget.NewLabelWithStyle("现有任务", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
 		widget.NewCard("现有任务", "调整正在运行的任务", taskContent),
+		widget.NewCard("任务队列", "按顺序开始排队中的任务", g.makeTaskQueue(g.botSession.TaskHolder())),
+		widget.NewCard("未完成的任务", "继续上次没有建造完的任务", g.makeUnfinishedTasks(g.botSession.TaskHolder())),
+		widget.NewCard("已完成的任务", "撤销任务, 恢复任务之前的方块", g.makeJournaledTasks(g.botSession.TaskHolder())),
 	)
 }
 
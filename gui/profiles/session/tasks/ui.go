@@ -30,6 +30,9 @@ type GUI struct {
 	content fyne.CanvasObject
 	// every ui element except return btn and two pos buttons
 	majorContent fyne.CanvasObject
+	// dry run options, applied to every building command
+	dryRunGet     func() (bool, error)
+	dryRunPathGet func() string
 	// addMonkeyPathReader func(path string, fp fyne.URIReadCloser)
 	// addMonkeyPathWriter func(path string, fp fyne.URIWriteCloser)
 }
@@ -117,6 +120,22 @@ func (g *GUI) sendCmdAndClose(cmd string) {
 	g.setContent(g.origContent)
 }
 
+// sendBuildCmdAndClose is sendCmdAndClose for commands that build,
+// which may be built into a local world instead of the server
+func (g *GUI) sendBuildCmdAndClose(cmd string) {
+	dryRun, err := g.dryRunGet()
+	if err != nil {
+		return
+	}
+	if dryRun {
+		cmd += " -dryrun"
+		if path := g.dryRunPathGet(); path != "" {
+			cmd += fmt.Sprintf(" -dryrun_path \"%v\"", path)
+		}
+	}
+	g.sendCmdAndClose(cmd)
+}
+
 func (g *GUI) makeIntEntry(v int, name string, hint string) (*widget.FormItem, func() (int, error)) {
 	cv := v
 	bv := binding.BindInt(&cv)
@@ -481,9 +500,47 @@ func (g *GUI) makeGeoCmdContent() fyne.CanvasObject {
 	heightFormItem, heightGet := g.makeIntEntry(0, "高度", "")
 	lengthFormItem, lengthGet := g.makeIntEntry(0, "长度", "")
 	widthFormItem, widthGet := g.makeIntEntry(0, "宽度", "")
-	blockFormItem, blockGet := g.makeStringEntry("air", "方块", "方块名称")
+	blockFormItem, blockGet := g.makeStringEntry("air", "方块", "方块名称，也可以是图案，如 stone:60,cobblestone:40")
 	blockdataFormItem, blockdataGet := g.makeIntEntry(0, "值", "方块特殊值")
 	shpere_shapeFormItem, shpere_shapeGet := g.makeTranslateRGSelectEntry([]string{"空心", "实心"}, []string{"hollow", "solid"}, "球填充", "空心则只有一个壳")
+	regionFormItem, regionGet := g.makeTranslateSelectEntry([]string{"填充", "替换", "四周墙壁", "外壳", "空心外壳", "只填充空气"}, []string{"fill", "replace", "walls", "outline", "hollow", "keep"}, "操作", "空心外壳会把内部替换为空气")
+	regionBlockFormItem, regionBlockGet := g.makeStringEntry("stone", "方块", "方块名称，也可以是图案，如 stone:60,cobblestone:40")
+	regionBlockdataFormItem, regionBlockdataGet := g.makeIntEntry(0, "值", "方块特殊值")
+	oldBlockFormItem, oldBlockGet := g.makeStringEntry("air", "被替换方块", "仅用于替换")
+	oldBlockdataFormItem, oldBlockdataGet := g.makeIntEntry(0, "被替换方块值", "")
+	anyOldDataOption, anyOldDataGet := g.makeBoolOption(false, "替换时忽略被替换方块的特殊值")
+	solidFormItem, solidGetter := g.makeTranslateRGSelectEntry([]string{"圆柱", "圆锥", "金字塔", "穹顶"}, []string{"cylinder", "cone", "pyramid", "dome"}, "目标", "穹顶是半个球, 高度对它无效")
+	solidRadiusFormItem, solidRadiusGet := g.makeIntEntry(5, "半径", "底面的半径, 金字塔底面边长为 2*半径+1")
+	solidHeightFormItem, solidHeightGet := g.makeIntEntry(10, "高度", "负数则向反方向建造")
+	solidFacingFormItem, solidFacingGet := g.makeRGSelectEntry([]string{"y", "x", "z"}, "朝向", "例: 选择y,则底面在x-z平面上,向上建造")
+	solidShapeFormItem, solidShapeGet := g.makeTranslateRGSelectEntry([]string{"实心", "空心"}, []string{"solid", "hollow"}, "填充", "空心则底面是开口的, 圆柱两端都开口")
+	solidBlockFormItem, solidBlockGet := g.makeStringEntry("stone", "方块", "方块名称，也可以是图案，如 stone:60,cobblestone:40")
+	solidBlockdataFormItem, solidBlockdataGet := g.makeIntEntry(0, "值", "方块特殊值")
+	torusRadiusFormItem, torusRadiusGet := g.makeIntEntry(8, "半径", "圆环中心线的半径")
+	torusTubeFormItem, torusTubeGet := g.makeIntEntry(3, "管半径", "圆环管子的半径, 不大于半径")
+	torusFacingFormItem, torusFacingGet := g.makeRGSelectEntry([]string{"y", "x", "z"}, "朝向", "例: 选择y,则圆环平放在x-z平面上")
+	torusShapeFormItem, torusShapeGet := g.makeTranslateRGSelectEntry([]string{"实心", "空心"}, []string{"solid", "hollow"}, "填充", "空心则只有一个壳")
+	torusBlockFormItem, torusBlockGet := g.makeStringEntry("stone", "方块", "方块名称，也可以是图案，如 stone:60,cobblestone:40")
+	torusBlockdataFormItem, torusBlockdataGet := g.makeIntEntry(0, "值", "方块特殊值")
+	helixRadiusFormItem, helixRadiusGet := g.makeIntEntry(5, "半径", "")
+	helixHeightFormItem, helixHeightGet := g.makeIntEntry(20, "高度", "负数则向反方向建造")
+	helixPitchFormItem, helixPitchGet := g.makeIntEntry(16, "每圈高度", "转一圈升高的方块数")
+	helixWidthFormItem, helixWidthGet := g.makeIntEntry(5, "宽度", "从外向内的宽度, 等于半径时为旋转楼梯")
+	helixFacingFormItem, helixFacingGet := g.makeRGSelectEntry([]string{"y", "x", "z"}, "朝向", "例: 选择y,则沿y轴向上旋转")
+	helixBlockFormItem, helixBlockGet := g.makeStringEntry("stone", "方块", "方块名称，也可以是图案，如 stone:60,cobblestone:40")
+	helixBlockdataFormItem, helixBlockdataGet := g.makeIntEntry(0, "值", "方块特殊值")
+	lineThicknessFormItem, lineThicknessGet := g.makeIntEntry(1, "粗细", "线的直径")
+	curveFormItem, curveGet := g.makeTranslateRGSelectEntry([]string{"平滑(经过每个点)", "贝塞尔", "折线"}, []string{"catmullrom", "bezier", "polyline"}, "曲线", "贝塞尔曲线只经过首尾两个点")
+	curveSourceFormItem, curveSourceGet := g.makeTranslateRGSelectEntry([]string{"手动输入", "文件"}, []string{"input", "file"}, "控制点", "文件中每行一个点 x,y,z, 相对于起点")
+	curvePointsEntry := widget.NewMultiLineEntry()
+	curvePointsEntry.SetPlaceHolder("每行一个点 x,y,z, 为世界坐标")
+	curvePathOption, curvePathGet := g.makeReadPathOption("选择控制点文件", "txt", []string{".txt"})
+	curveWidthFormItem, curveWidthGet := g.makeIntEntry(3, "宽度", "横截面的宽度")
+	curveHeightFormItem, curveHeightGet := g.makeIntEntry(1, "高度", "横截面从曲线向上的高度")
+	curveBlockFormItem, curveBlockGet := g.makeStringEntry("stone", "方块", "方块名称，也可以是图案，如 stone:60,cobblestone:40")
+	curveBlockdataFormItem, curveBlockdataGet := g.makeIntEntry(0, "值", "方块特殊值")
+	lineBlockFormItem, lineBlockGet := g.makeStringEntry("stone", "方块", "方块名称，也可以是图案，如 stone:60,cobblestone:40")
+	lineBlockdataFormItem, lineBlockdataGet := g.makeIntEntry(0, "值", "方块特殊值")
 
 	c := container.NewDocTabs(
 		&container.TabItem{
@@ -524,7 +581,7 @@ func (g *GUI) makeGeoCmdContent() fyne.CanvasObject {
 					if err != nil {
 						return
 					}
-					g.sendCmdAndClose(fmt.Sprintf("%v -r %v -f %v -h 1 -b %v -d %v", target, radius, facing, block, blockData))
+					g.sendBuildCmdAndClose(fmt.Sprintf("%v -r %v -f %v -h 1 -b %v -d %v", target, radius, facing, block, blockData))
 				}),
 			),
 		},
@@ -548,7 +605,7 @@ func (g *GUI) makeGeoCmdContent() fyne.CanvasObject {
 					if err != nil {
 						return
 					}
-					g.sendCmdAndClose(fmt.Sprintf("sphere -r %v -s %v", radius, shape))
+					g.sendBuildCmdAndClose(fmt.Sprintf("sphere -r %v -s %v", radius, shape))
 				}),
 			),
 		},
@@ -577,7 +634,7 @@ func (g *GUI) makeGeoCmdContent() fyne.CanvasObject {
 					if err != nil {
 						return
 					}
-					g.sendCmdAndClose(fmt.Sprintf("ellipse -l %v -w %v -f %v", length, width, facing))
+					g.sendBuildCmdAndClose(fmt.Sprintf("ellipse -l %v -w %v -f %v", length, width, facing))
 				}),
 			),
 		},
@@ -606,7 +663,323 @@ func (g *GUI) makeGeoCmdContent() fyne.CanvasObject {
 					if err != nil {
 						return
 					}
-					g.sendCmdAndClose(fmt.Sprintf("ellipsoid -l %v -w %v -h %v", length, width, height))
+					g.sendBuildCmdAndClose(fmt.Sprintf("ellipsoid -l %v -w %v -h %v", length, width, height))
+				}),
+			),
+		},
+		&container.TabItem{
+			Text: "柱/锥",
+			Content: container.NewVBox(
+				widget.NewForm(
+					solidFormItem,
+					solidRadiusFormItem,
+					solidHeightFormItem,
+					solidFacingFormItem,
+					solidShapeFormItem,
+					solidBlockFormItem,
+					solidBlockdataFormItem,
+				),
+				container.NewGridWithColumns(2, widget.NewLabel("底面中心位置"), g.startPos.UpdateBtn),
+				g.startPos.PosContent(),
+				g.makeConfirmButton("绘制", func() {
+					target, err := solidGetter()
+					if err != nil {
+						return
+					}
+					radius, err := solidRadiusGet()
+					if err != nil {
+						return
+					}
+					height, err := solidHeightGet()
+					if err != nil {
+						return
+					}
+					facing, err := solidFacingGet()
+					if err != nil {
+						return
+					}
+					shape, err := solidShapeGet()
+					if err != nil {
+						return
+					}
+					block, err := solidBlockGet()
+					if err != nil {
+						return
+					}
+					blockData, err := solidBlockdataGet()
+					if err != nil {
+						return
+					}
+					err = g.setStartPos()
+					if err != nil {
+						return
+					}
+					g.sendBuildCmdAndClose(fmt.Sprintf("%v -r %v -h %v -f %v -s %v -b %v -d %v", target, radius, height, facing, shape, block, blockData))
+				}),
+			),
+		},
+		&container.TabItem{
+			Text: "圆环",
+			Content: container.NewVBox(
+				widget.NewForm(
+					torusRadiusFormItem,
+					torusTubeFormItem,
+					torusFacingFormItem,
+					torusShapeFormItem,
+					torusBlockFormItem,
+					torusBlockdataFormItem,
+				),
+				container.NewGridWithColumns(2, widget.NewLabel("圆心位置"), g.startPos.UpdateBtn),
+				g.startPos.PosContent(),
+				g.makeConfirmButton("绘制", func() {
+					radius, err := torusRadiusGet()
+					if err != nil {
+						return
+					}
+					tube, err := torusTubeGet()
+					if err != nil {
+						return
+					}
+					facing, err := torusFacingGet()
+					if err != nil {
+						return
+					}
+					shape, err := torusShapeGet()
+					if err != nil {
+						return
+					}
+					block, err := torusBlockGet()
+					if err != nil {
+						return
+					}
+					blockData, err := torusBlockdataGet()
+					if err != nil {
+						return
+					}
+					err = g.setStartPos()
+					if err != nil {
+						return
+					}
+					g.sendBuildCmdAndClose(fmt.Sprintf("torus -r %v -w %v -f %v -s %v -b %v -d %v", radius, tube, facing, shape, block, blockData))
+				}),
+			),
+		},
+		&container.TabItem{
+			Text: "螺旋",
+			Content: container.NewVBox(
+				widget.NewForm(
+					helixRadiusFormItem,
+					helixHeightFormItem,
+					helixPitchFormItem,
+					helixWidthFormItem,
+					helixFacingFormItem,
+					helixBlockFormItem,
+					helixBlockdataFormItem,
+				),
+				container.NewGridWithColumns(2, widget.NewLabel("底面中心位置"), g.startPos.UpdateBtn),
+				g.startPos.PosContent(),
+				g.makeConfirmButton("绘制", func() {
+					radius, err := helixRadiusGet()
+					if err != nil {
+						return
+					}
+					height, err := helixHeightGet()
+					if err != nil {
+						return
+					}
+					pitch, err := helixPitchGet()
+					if err != nil {
+						return
+					}
+					width, err := helixWidthGet()
+					if err != nil {
+						return
+					}
+					facing, err := helixFacingGet()
+					if err != nil {
+						return
+					}
+					block, err := helixBlockGet()
+					if err != nil {
+						return
+					}
+					blockData, err := helixBlockdataGet()
+					if err != nil {
+						return
+					}
+					err = g.setStartPos()
+					if err != nil {
+						return
+					}
+					g.sendBuildCmdAndClose(fmt.Sprintf("helix -r %v -h %v -l %v -w %v -f %v -b %v -d %v", radius, height, pitch, width, facing, block, blockData))
+				}),
+			),
+		},
+		&container.TabItem{
+			Text: "直线",
+			Content: container.NewVBox(
+				widget.NewForm(
+					lineThicknessFormItem,
+					lineBlockFormItem,
+					lineBlockdataFormItem,
+				),
+				container.NewGridWithColumns(2, widget.NewLabel("起点"), g.startPos.UpdateBtn),
+				g.startPos.PosContent(),
+				container.NewGridWithColumns(2, widget.NewLabel("终点"), g.endPos.UpdateBtn),
+				g.endPos.PosContent(),
+				g.makeConfirmButton("绘制", func() {
+					thickness, err := lineThicknessGet()
+					if err != nil {
+						return
+					}
+					block, err := lineBlockGet()
+					if err != nil {
+						return
+					}
+					blockData, err := lineBlockdataGet()
+					if err != nil {
+						return
+					}
+					err = g.setStartPos()
+					if err != nil {
+						return
+					}
+					err = g.setEndPos()
+					if err != nil {
+						return
+					}
+					g.sendBuildCmdAndClose(fmt.Sprintf("line -w %v -b %v -d %v", thickness, block, blockData))
+				}),
+			),
+		},
+		&container.TabItem{
+			Text: "曲线",
+			Content: container.NewVBox(
+				widget.NewForm(
+					curveFormItem,
+					curveSourceFormItem,
+				),
+				curvePointsEntry,
+				curvePathOption,
+				widget.NewForm(
+					curveWidthFormItem,
+					curveHeightFormItem,
+					curveBlockFormItem,
+					curveBlockdataFormItem,
+				),
+				container.NewGridWithColumns(2, widget.NewLabel("起点(用于文件中的点)"), g.startPos.UpdateBtn),
+				g.startPos.PosContent(),
+				g.makeConfirmButton("绘制", func() {
+					curve, err := curveGet()
+					if err != nil {
+						return
+					}
+					source, err := curveSourceGet()
+					if err != nil {
+						return
+					}
+					width, err := curveWidthGet()
+					if err != nil {
+						return
+					}
+					height, err := curveHeightGet()
+					if err != nil {
+						return
+					}
+					block, err := curveBlockGet()
+					if err != nil {
+						return
+					}
+					blockData, err := curveBlockdataGet()
+					if err != nil {
+						return
+					}
+					cmd := fmt.Sprintf("curve -curve %v -w %v -h %v -b %v -d %v", curve, width, height, block, blockData)
+					if source == "file" {
+						path, _, err := curvePathGet()
+						if err != nil {
+							return
+						}
+						cmd += fmt.Sprintf(" -p %v", path)
+					} else {
+						points := 0
+						for _, line := range strings.Split(curvePointsEntry.Text, "\n") {
+							point := strings.Join(strings.Fields(strings.ReplaceAll(line, "，", ",")), "")
+							if point == "" {
+								continue
+							}
+							cmd += fmt.Sprintf(" -point %v", point)
+							points++
+						}
+						if points < 2 {
+							dialog.NewError(fmt.Errorf("曲线至少需要两个控制点"), g.masterWindow).Show()
+							return
+						}
+					}
+					err = g.setStartPos()
+					if err != nil {
+						return
+					}
+					g.sendBuildCmdAndClose(cmd)
+				}),
+			),
+		},
+		&container.TabItem{
+			Text: "区域",
+			Content: container.NewVBox(
+				widget.NewForm(
+					regionFormItem,
+					regionBlockFormItem,
+					regionBlockdataFormItem,
+					oldBlockFormItem,
+					oldBlockdataFormItem,
+				),
+				anyOldDataOption,
+				container.NewGridWithColumns(2, widget.NewLabel("区域起点"), g.startPos.UpdateBtn),
+				g.startPos.PosContent(),
+				container.NewGridWithColumns(2, widget.NewLabel("区域终点"), g.endPos.UpdateBtn),
+				g.endPos.PosContent(),
+				g.makeConfirmButton("执行", func() {
+					target, err := regionGet()
+					if err != nil {
+						return
+					}
+					block, err := regionBlockGet()
+					if err != nil {
+						return
+					}
+					blockData, err := regionBlockdataGet()
+					if err != nil {
+						return
+					}
+					cmd := fmt.Sprintf("%v -b %v -d %v", target, block, blockData)
+					if target == "replace" {
+						oldBlock, err := oldBlockGet()
+						if err != nil {
+							return
+						}
+						oldBlockData, err := oldBlockdataGet()
+						if err != nil {
+							return
+						}
+						anyOldData, err := anyOldDataGet()
+						if err != nil {
+							return
+						}
+						cmd += fmt.Sprintf(" -ob %v -od %v", oldBlock, oldBlockData)
+						if anyOldData {
+							cmd += " -old_method any"
+						}
+					}
+					err = g.setStartPos()
+					if err != nil {
+						return
+					}
+					err = g.setEndPos()
+					if err != nil {
+						return
+					}
+					g.sendBuildCmdAndClose(cmd)
 				}),
 			),
 		},
@@ -618,13 +991,19 @@ func (g *GUI) makeBuildingContent() fyne.CanvasObject {
 	excludecommandsOption, excludecommandsGet := g.makeBoolOption(false, "不导入命令方块中的命令")
 	invalidatecommandsOption, invalidateCommandsGet := g.makeBoolOption(false, "导入，但无效化命令方块中的命令")
 	strictOption, strictGet := g.makeBoolOption(true, "验证文件签名")
-	pathOption, pathGet := g.makeReadPathOption("选择建筑文件", ".schematic/.bdx/.mcacblock", []string{".schematic", ".bdx", ".mcacblock"})
+	pathOption, pathGet := g.makeReadPathOption("选择建筑文件", ".schematic/.bdx/.mcacblock/.mcstructure/.schem/.litematic", []string{".schematic", ".bdx", ".mcacblock", ".mcstructure", ".schem", ".litematic"})
+	rotationFormItem, rotationGet := g.makeTranslateRGSelectEntry([]string{"0°", "90°", "180°", "270°"}, []string{"0", "90", "180", "270"}, "旋转", "以建筑起点为中心，从上方看顺时针旋转")
+	mirrorFormItem, mirrorGet := g.makeTranslateRGSelectEntry([]string{"不镜像", "x轴", "z轴"}, []string{"", "x", "z"}, "镜像", "沿x轴镜像时东西翻转")
+	scaleFormItem, scaleGet := g.makeIntEntry(1, "放大倍数", "每个方块放大为 n*n*n 个方块")
+	verifyFormItem, verifyGet := g.makeIntEntry(0, "校验重建次数", "建造完成后检查方块，最多重新建造错误的方块 n 次，0 为不校验")
 	return container.NewVBox(
-		widget.NewLabel("支持 schematic/bdx/mcacblock 文件"),
+		widget.NewLabel("支持 schematic/bdx/mcacblock/mcstructure/schem/litematic 文件"),
+		widget.NewLabel("schem/litematic 为Java版格式，无法转换的方块会在导入时列出"),
 		pathOption,
 		excludecommandsOption,
 		invalidatecommandsOption,
 		strictOption,
+		widget.NewForm(rotationFormItem, mirrorFormItem, scaleFormItem, verifyFormItem),
 		container.NewGridWithColumns(2, widget.NewLabel("建筑起点位置"), g.startPos.UpdateBtn),
 		g.startPos.PosContent(),
 		g.makeConfirmButton("导入", func() {
@@ -654,6 +1033,38 @@ func (g *GUI) makeBuildingContent() fyne.CanvasObject {
 			if strict {
 				flags = append(flags, "--strict")
 			}
+			rotation, err := rotationGet()
+			if err != nil {
+				return
+			}
+			if rotation != "0" {
+				flags = append(flags, "-rotate "+rotation)
+			}
+			mirror, err := mirrorGet()
+			if err != nil {
+				return
+			}
+			if mirror != "" {
+				flags = append(flags, "-mirror "+mirror)
+			}
+			scale, err := scaleGet()
+			if err != nil {
+				return
+			}
+			if scale < 1 {
+				dialog.NewError(fmt.Errorf("放大倍数至少为1"), g.masterWindow).Show()
+				return
+			}
+			if scale > 1 {
+				flags = append(flags, fmt.Sprintf("-scale %d", scale))
+			}
+			verify, err := verifyGet()
+			if err != nil {
+				return
+			}
+			if verify > 0 {
+				flags = append(flags, fmt.Sprintf("-verify %d", verify))
+			}
 			flagStr := strings.Join(flags, " ")
 			err = g.setStartPos()
 			if err != nil {
@@ -667,9 +1078,13 @@ func (g *GUI) makeBuildingContent() fyne.CanvasObject {
 				cmd = "acme -p " + cmd
 			} else if ext == ".bdx" {
 				cmd = "bdump -p " + cmd
+			} else if ext == ".mcstructure" {
+				cmd = "mcstructure -p " + cmd
+			} else if ext == ".schem" || ext == ".litematic" {
+				cmd = "javaschem -p " + cmd
 			}
 			// g.addMonkeyPathReader(path, fp)
-			g.sendCmdAndClose(cmd)
+			g.sendBuildCmdAndClose(cmd)
 		}),
 	)
 }
@@ -680,6 +1095,25 @@ func (g *GUI) makePlotContent() fyne.CanvasObject {
 	mapXFormItem, mapXGet := g.makeIntEntry(1, "横向", "横向由几张地图构成")
 	mapZFormItem, mapZGet := g.makeIntEntry(1, "纵向", "纵向由几张地图构成")
 	mapYFormItem, mapYGet := g.makeIntEntry(0, "允许使用高度", ">40时通过阴影产生更多颜色")
+	modelPathOption, modelPathGet := g.makeReadPathOption("选择模型", "obj/stl", []string{".obj", ".OBJ", ".stl", ".STL"})
+	modelHeightFormItem, modelHeightGet := g.makeIntEntry(32, "高度", "模型缩放到的高度, 不大于1则按模型原本的大小")
+	modelFacingFormItem, modelFacingGet := g.makeRGSelectEntry([]string{"y", "x", "z"}, "向上的轴", "模型中朝上的轴, 多数建模软件导出的stl为z")
+	modelShapeFormItem, modelShapeGet := g.makeTranslateRGSelectEntry([]string{"实心", "空心"}, []string{"solid", "hollow"}, "填充", "空心则只有表面")
+	modelBlockFormItem, modelBlockGet := g.makeStringEntry("stone", "方块", "模型没有颜色的部分使用的方块")
+	terrainPathOption, terrainPathGet := g.makeReadPathOption("选择高度图", "png/jpg", []string{".png", ".PNG", ".jpg", ".jpeg", ".JPG"})
+	terrainHeightFormItem, terrainHeightGet := g.makeIntEntry(32, "高度", "白色的高度, 黑色为区域最低处")
+	terrainTopFormItem, terrainTopGet := g.makeStringEntry("grass", "表层方块", "地表的方块")
+	terrainSoilFormItem, terrainSoilGet := g.makeStringEntry("dirt", "土壤方块", "表层下的方块, 也用于水下的地表")
+	terrainSoilDepthFormItem, terrainSoilDepthGet := g.makeIntEntry(3, "土壤厚度", "")
+	terrainBlockFormItem, terrainBlockGet := g.makeStringEntry("stone", "底层方块", "土壤下的方块，也可以是图案，如 stone:60,andesite:40")
+	terrainWaterFormItem, terrainWaterGet := g.makeIntEntry(0, "水位", "从区域最低处向上的高度, 0为不加水")
+	landscapeSeedFormItem, landscapeSeedGet := g.makeIntEntry(0, "种子", "相同的种子生成相同的地形")
+	landscapeHeightFormItem, landscapeHeightGet := g.makeIntEntry(32, "高度", "地表最高处距区域最低处的高度")
+	landscapeSizeFormItem, landscapeSizeGet := g.makeIntEntry(64, "山丘大小", "最大的山丘的宽度")
+	landscapeOctavesFormItem, landscapeOctavesGet := g.makeIntEntry(4, "细节层数", "每层比上一层小一半")
+	landscapeWaterFormItem, landscapeWaterGet := g.makeIntEntry(10, "水位", "从区域最低处向上的高度, 0为没有水和湖泊")
+	landscapeTreesFormItem, landscapeTreesGet := g.makeIntEntry(30, "树木密度", "每7x7格有一棵树的概率(%)")
+	landscapeBlockFormItem, landscapeBlockGet := g.makeStringEntry("stone", "底层方块", "土壤下的方块，也可以是图案，如 stone:60,andesite:40")
 	c := container.NewDocTabs(
 		&container.TabItem{
 			Text: "图片",
@@ -703,7 +1137,7 @@ func (g *GUI) makePlotContent() fyne.CanvasObject {
 						return
 					}
 					// g.addMonkeyPathReader(path, fp)
-					g.sendCmdAndClose(fmt.Sprintf("plot -p %v -f %v", path, facing))
+					g.sendBuildCmdAndClose(fmt.Sprintf("plot -p %v -f %v", path, facing))
 				}),
 			),
 		},
@@ -743,7 +1177,165 @@ func (g *GUI) makePlotContent() fyne.CanvasObject {
 						return
 					}
 					// g.addMonkeyPathReader(path, fp)
-					g.sendCmdAndClose(fmt.Sprintf("mapart -p %v -mapX %v -mapZ %v -mapY %v", path, mapX, mapZ, mapY))
+					g.sendBuildCmdAndClose(fmt.Sprintf("mapart -p %v -mapX %v -mapZ %v -mapY %v", path, mapX, mapZ, mapY))
+				}),
+			),
+		},
+		&container.TabItem{
+			Text: "模型",
+			Content: container.NewVBox(
+				widget.NewLabel("支持 obj(包括mtl材质颜色和贴图)/stl 文件, 按颜色选择方块"),
+				modelPathOption,
+				widget.NewForm(
+					modelHeightFormItem,
+					modelFacingFormItem,
+					modelShapeFormItem,
+					modelBlockFormItem,
+				),
+				container.NewGridWithColumns(2, widget.NewLabel("模型最低角位置"), g.startPos.UpdateBtn),
+				g.startPos.PosContent(),
+				g.makeConfirmButton("建造", func() {
+					path, _, err := modelPathGet()
+					if err != nil {
+						return
+					}
+					height, err := modelHeightGet()
+					if err != nil {
+						return
+					}
+					facing, err := modelFacingGet()
+					if err != nil {
+						return
+					}
+					shape, err := modelShapeGet()
+					if err != nil {
+						return
+					}
+					block, err := modelBlockGet()
+					if err != nil {
+						return
+					}
+					err = g.setStartPos()
+					if err != nil {
+						return
+					}
+					g.sendBuildCmdAndClose(fmt.Sprintf("model -p %v -h %v -f %v -s %v -b %v", path, height, facing, shape, block))
+				}),
+			),
+		},
+		&container.TabItem{
+			Text: "地形",
+			Content: container.NewVBox(
+				widget.NewLabel("把灰度图作为高度图, 拉伸到区域的x-z范围"),
+				terrainPathOption,
+				widget.NewForm(
+					terrainHeightFormItem,
+					terrainTopFormItem,
+					terrainSoilFormItem,
+					terrainSoilDepthFormItem,
+					terrainBlockFormItem,
+					terrainWaterFormItem,
+				),
+				container.NewGridWithColumns(2, widget.NewLabel("区域起点"), g.startPos.UpdateBtn),
+				g.startPos.PosContent(),
+				container.NewGridWithColumns(2, widget.NewLabel("区域终点"), g.endPos.UpdateBtn),
+				g.endPos.PosContent(),
+				g.makeConfirmButton("建造", func() {
+					path, _, err := terrainPathGet()
+					if err != nil {
+						return
+					}
+					height, err := terrainHeightGet()
+					if err != nil {
+						return
+					}
+					top, err := terrainTopGet()
+					if err != nil {
+						return
+					}
+					soil, err := terrainSoilGet()
+					if err != nil {
+						return
+					}
+					soilDepth, err := terrainSoilDepthGet()
+					if err != nil {
+						return
+					}
+					block, err := terrainBlockGet()
+					if err != nil {
+						return
+					}
+					waterLevel, err := terrainWaterGet()
+					if err != nil {
+						return
+					}
+					err = g.setStartPos()
+					if err != nil {
+						return
+					}
+					err = g.setEndPos()
+					if err != nil {
+						return
+					}
+					g.sendBuildCmdAndClose(fmt.Sprintf("terrain -p %v -h %v -top %v -soil %v -soil_depth %v -b %v -water_level %v", path, height, top, soil, soilDepth, block, waterLevel))
+				}),
+			),
+		},
+		&container.TabItem{
+			Text: "随机地形",
+			Content: container.NewVBox(
+				widget.NewLabel("用噪声在区域的x-z范围内生成山丘、湖泊和树木"),
+				widget.NewForm(
+					landscapeSeedFormItem,
+					landscapeHeightFormItem,
+					landscapeSizeFormItem,
+					landscapeOctavesFormItem,
+					landscapeWaterFormItem,
+					landscapeTreesFormItem,
+					landscapeBlockFormItem,
+				),
+				container.NewGridWithColumns(2, widget.NewLabel("区域起点"), g.startPos.UpdateBtn),
+				g.startPos.PosContent(),
+				container.NewGridWithColumns(2, widget.NewLabel("区域终点"), g.endPos.UpdateBtn),
+				g.endPos.PosContent(),
+				g.makeConfirmButton("生成", func() {
+					seed, err := landscapeSeedGet()
+					if err != nil {
+						return
+					}
+					height, err := landscapeHeightGet()
+					if err != nil {
+						return
+					}
+					size, err := landscapeSizeGet()
+					if err != nil {
+						return
+					}
+					octaves, err := landscapeOctavesGet()
+					if err != nil {
+						return
+					}
+					waterLevel, err := landscapeWaterGet()
+					if err != nil {
+						return
+					}
+					trees, err := landscapeTreesGet()
+					if err != nil {
+						return
+					}
+					block, err := landscapeBlockGet()
+					if err != nil {
+						return
+					}
+					err = g.setStartPos()
+					if err != nil {
+						return
+					}
+					err = g.setEndPos()
+					if err != nil {
+						return
+					}
+					g.sendBuildCmdAndClose(fmt.Sprintf("landscape -seed %v -h %v -noise_size %v -octaves %v -water_level %v -trees %v -b %v", seed, height, size, octaves, waterLevel, trees, block))
 				}),
 			),
 		},
@@ -793,9 +1385,11 @@ func (g *GUI) makeNbtContent() fyne.CanvasObject {
 }
 
 func (g *GUI) makeExportContent() fyne.CanvasObject {
-	pathOption, pathGet := g.makeWritePathOption("导出到建筑文件", ".bdx", []string{".bdx"})
+	pathOption, pathGet := g.makeWritePathOption("导出到建筑文件", ".bdx/.mcstructure/.schem", []string{".bdx", ".mcstructure", ".schem"})
+	formatFormItem, formatGet := g.makeTranslateRGSelectEntry([]string{"BDX", "mcstructure", "Sponge schem"}, []string{"bdx", "mcstructure", "schem"}, "导出格式", "schem为Java版格式，部分方块可能无法准确转换")
 	return container.NewVBox(
 		pathOption,
+		widget.NewForm(formatFormItem),
 		container.NewGridWithColumns(2, widget.NewLabel("导出建筑起点位置"), g.startPos.UpdateBtn),
 		g.startPos.PosContent(),
 		container.NewGridWithColumns(2, widget.NewLabel("导出建筑终点位置"), g.endPos.UpdateBtn),
@@ -813,13 +1407,74 @@ func (g *GUI) makeExportContent() fyne.CanvasObject {
 			if err != nil {
 				return
 			}
-			cmd := fmt.Sprintf("export -p %v", path)
+			format, err := formatGet()
+			if err != nil {
+				return
+			}
+			cmd := fmt.Sprintf("export -p %v -format %v", path, format)
 			// g.addMonkeyPathWriter(path, fp)
 			g.sendCmdAndClose(cmd)
 		}),
 	)
 }
 
+func (g *GUI) makeDryRunContent() fyne.CanvasObject {
+	dryRunOption, dryRunGet := g.makeBoolOption(false, "模拟建造: 只在本地世界中建造，不发送到服务器")
+	savePath := ""
+	bv := binding.BindString(&savePath)
+	savePathEntry := widget.NewEntryWithData(bv)
+	savePathEntry.Disable()
+	savePathEntry.SetPlaceHolder("不保存")
+	g.dryRunGet = dryRunGet
+	g.dryRunPathGet = func() string {
+		gv, _ := bv.Get()
+		return gv
+	}
+	return container.NewVBox(
+		dryRunOption,
+		widget.NewLabel("模拟建造可以在没有服务器的情况下检查建筑、统计方块数量和用时"),
+		container.NewBorder(nil, nil, nil, container.NewHBox(
+			&widget.Button{
+				Text: "保存到存档文件夹",
+				OnTapped: func() {
+					dialog.NewFolderOpen(func(uri fyne.ListableURI, err error) {
+						if err != nil {
+							dialog.ShowError(err, g.masterWindow)
+							return
+						}
+						if uri == nil {
+							return
+						}
+						bv.Set(uri.String())
+					}, g.masterWindow).Show()
+				},
+			},
+			&widget.Button{
+				Text: "保存为结构文件",
+				OnTapped: func() {
+					fd := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+						if err != nil {
+							dialog.ShowError(err, g.masterWindow)
+							return
+						}
+						if writer == nil {
+							return
+						}
+						bv.Set(writer.URI().String())
+						writer.Close()
+					}, g.masterWindow)
+					fd.SetFilter(storage.NewExtensionFileFilter([]string{".mcstructure", ".schem"}))
+					fd.Show()
+				},
+			},
+			&widget.Button{
+				Text:     "不保存",
+				OnTapped: func() { bv.Set("") },
+			},
+		), savePathEntry),
+	)
+}
+
 func (g *GUI) makeMajorContent() fyne.CanvasObject {
 	// fileStorageLabel := widget.NewLabel("文件存储")
 	// fileStorageLabel.Wrapping = fyne.TextWrapWord
@@ -848,6 +1503,10 @@ func (g *GUI) makeMajorContent() fyne.CanvasObject {
 			// 	),
 			// 	Open: true,
 			// },
+			&widget.AccordionItem{
+				Title:  "模拟建造",
+				Detail: g.makeDryRunContent(),
+			},
 			&widget.AccordionItem{
 				Title:  "几何指令",
 				Detail: g.makeGeoCmdContent(),
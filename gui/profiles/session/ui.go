@@ -9,9 +9,8 @@ import (
 	"strings"
 	"time"
 
-	bot_bridge_command "phoenixbuilder_3rd_gui/fb/fastbuilder/command"
+	fbtask "phoenixbuilder_3rd_gui/fb/fastbuilder/task"
 	bot_session "phoenixbuilder_3rd_gui/fb/session"
-	bot_bridge_fmt "phoenixbuilder_3rd_gui/fb/session/bridge/fmt"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
@@ -23,7 +22,7 @@ import (
 type GUI struct {
 	setContent   func(v fyne.CanvasObject)
 	getContent   func() fyne.CanvasObject
-	origContent  fyne.CanvasObject
+	closeFn      func()
 	masterWindow fyne.Window
 	app          fyne.App
 
@@ -53,10 +52,11 @@ type GUI struct {
 	BotSession                      *bot_session.Session
 }
 
-func New(config *config.SessionConfigWithName, writeBackConfigFn func()) *GUI {
+func New(config *config.SessionConfigWithName, writeBackConfigFn func(), closeFn func()) *GUI {
 	gui := &GUI{
 		sessionConfig:     config,
 		writeBackConfigFn: writeBackConfigFn,
+		closeFn:           closeFn,
 	}
 	return gui
 }
@@ -79,8 +79,11 @@ func (g *GUI) doneLoading() {
 }
 
 func (g *GUI) closeGUI() {
+	if g.alreadyClosed {
+		return
+	}
 	g.alreadyClosed = true
-	g.setContent(g.origContent)
+	g.closeFn()
 	g.BotSession.Stop()
 }
 
@@ -125,6 +128,16 @@ func (g *GUI) onLoginError(err error) {
 	g.closeGUI()
 }
 
+// onReconnect shows the reconnect state of the session, the interrupted
+// tasks continue after reconnecting
+func (g *GUI) onReconnect(attempt int) {
+	if attempt == 0 {
+		g.doneLoading()
+		return
+	}
+	g.setLoading(fmt.Sprintf("和租赁服的连接断开了，正在重连 (%d/%d)...", attempt, bot_session.MaxReconnectAttempts))
+}
+
 func (g *GUI) onRuntimeError(err error) {
 	dialog.NewError(err, g.masterWindow).Show()
 	g.closeGUI()
@@ -221,7 +234,6 @@ func (g *GUI) makeToolContent() fyne.CanvasObject {
 }
 
 func (g *GUI) GetContent(setContent func(v fyne.CanvasObject), getContent func() fyne.CanvasObject, masterWindow fyne.Window, app fyne.App) fyne.CanvasObject {
-	g.origContent = getContent()
 	g.setContent = setContent
 	g.getContent = getContent
 	g.masterWindow = masterWindow
@@ -240,17 +252,15 @@ func (g *GUI) GetContent(setContent func(v fyne.CanvasObject), getContent func()
 }
 
 func (g *GUI) AfterMount() {
-	bot_bridge_fmt.HookFunc = g.redirectCliOutput
-	bot_bridge_command.AdditionalChatCb = g.redirectCliOutput
-	bot_bridge_command.AdditionalTitleCb = g.redirectTitleDisplay
+	g.BotSession = bot_session.NewSession(g.sessionConfig.Config)
+	g.BotSession.ChatCbFn = g.redirectCliOutput
+	g.BotSession.TitleCbFn = g.redirectTitleDisplay
+	g.BotSession.ReconnectCbFn = g.onReconnect
+	g.BotSession.CheckpointStore = fbtask.NewStorageCheckpointStore(g.app.Storage())
+	g.BotSession.JournalStore = fbtask.NewStorageJournalStore(g.app.Storage())
 
 	g.setLoading("正在登录，最长可能需要30s...")
 	go func() {
-		g.BotSession = bot_session.NewSession(g.sessionConfig.Config)
-		if g.BotSession == nil {
-			g.onLoginError(fmt.Errorf("一个现有会话未正常退出，或许你需要重启程序"))
-			return
-		}
 		terminateChan, err := g.BotSession.Start()
 		if err != nil {
 			g.onLoginError(fmt.Errorf("无法顺利登陆到租赁服中\n%v", err))
@@ -261,7 +271,7 @@ func (g *GUI) AfterMount() {
 		g.createFromTemplateBtn.OnTapped = func() {
 			g.setContent(g.taskMenu.GetContent(g.setContent, g.getContent, g.masterWindow))
 		}
-		g.taskConfigMenu = task_config.New()
+		g.taskConfigMenu = task_config.New(g.BotSession)
 		g.taskSettingsButton.OnTapped = func() {
 			g.setContent(g.taskConfigMenu.GetContent(g.setContent, g.getContent, g.masterWindow))
 		}
@@ -26,6 +26,8 @@ type GUI struct {
 	app          fyne.App
 	onPanic      func(error)
 	content      fyne.CanvasObject
+	tabs         *container.AppTabs
+	sessionTabs  map[int]*container.TabItem
 	entryIndex   []int
 	configs      map[int]*config.SessionConfigWithName
 	counter      int
@@ -35,10 +37,11 @@ type GUI struct {
 func New(storage fyne.Storage) *GUI {
 	//configPath := path.Join(dataFolder, "config.yaml")
 	gui := &GUI{
-		entryIndex: make([]int, 0),
-		configs:    make(map[int]*config.SessionConfigWithName, 0),
-		counter:    0,
-		storage:    storage,
+		entryIndex:  make([]int, 0),
+		configs:     make(map[int]*config.SessionConfigWithName, 0),
+		sessionTabs: make(map[int]*container.TabItem),
+		counter:     0,
+		storage:     storage,
 	}
 	return gui
 }
@@ -127,6 +130,10 @@ func (g *GUI) onEdit(i int) {
 }
 
 func (g *GUI) onDelete(i int) {
+	if _, ok := g.sessionTabs[i]; ok {
+		dialog.ShowInformation("无法删除", "该配置正在使用中，请先结束会话", g.masterWindow)
+		return
+	}
 	delete(g.configs, i)
 	g.updateEntryIndex()
 	g.content.Refresh()
@@ -134,8 +141,29 @@ func (g *GUI) onDelete(i int) {
 }
 
 func (g *GUI) onLogin(i int) {
-	s := ui_session.New(g.configs[i], g.WriteBackConfigFile)
-	g.setContent(s.GetContent(g.setContent, g.getContent, g.masterWindow, g.app))
+	if tab, ok := g.sessionTabs[i]; ok {
+		// this profile is already logged in
+		g.tabs.Select(tab)
+		return
+	}
+	tab := container.NewTabItemWithIcon(g.configs[i].Name, theme.ComputerIcon(), widget.NewLabel(""))
+	setTabContent := func(v fyne.CanvasObject) {
+		tab.Content = v
+		g.tabs.Refresh()
+	}
+	getTabContent := func() fyne.CanvasObject {
+		return tab.Content
+	}
+	closeTab := func() {
+		delete(g.sessionTabs, i)
+		g.tabs.Remove(tab)
+		g.tabs.SelectIndex(0)
+	}
+	s := ui_session.New(g.configs[i], g.WriteBackConfigFile, closeTab)
+	tab.Content = s.GetContent(setTabContent, getTabContent, g.masterWindow, g.app)
+	g.sessionTabs[i] = tab
+	g.tabs.Append(tab)
+	g.tabs.Select(tab)
 	s.AfterMount()
 	fmt.Println("login", i)
 }
@@ -270,5 +298,8 @@ func (g *GUI) GetContent(setContent func(v fyne.CanvasObject), getContent func()
 		nil,
 		g.makeProfilesList(),
 	)
-	return g.content
+	g.tabs = container.NewAppTabs(
+		container.NewTabItemWithIcon("登录配置", theme.AccountIcon(), g.content),
+	)
+	return g.tabs
 }